@@ -0,0 +1,88 @@
+// Command webpush-sink is a local stand-in for a browser push service. It prints a
+// PushSubscription to register via POST /api/v1/push/subscriptions, then decrypts and logs
+// every message the server pushes to it. Use -status 410 to test expired-subscription cleanup.
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"yourapp/internal/util"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8089", "listen address")
+	status := flag.Int("status", http.StatusCreated, "status code to answer pushes with (404/410 simulate an expired subscription)")
+	genVAPID := flag.Bool("gen-vapid", false, "print a new VAPID key pair for VAPID_PUBLIC_KEY/VAPID_PRIVATE_KEY and exit")
+	flag.Parse()
+
+	if *genVAPID {
+		publicKey, privateKey, err := util.GenerateVAPIDKeys()
+		if err != nil {
+			log.Fatal("Failed to generate VAPID keys:", err)
+		}
+		fmt.Printf("VAPID_PUBLIC_KEY=%s\nVAPID_PRIVATE_KEY=%s\n", publicKey, privateKey)
+		return
+	}
+
+	// Keys a browser would create for its subscription
+	uaPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatal("Failed to generate subscription key:", err)
+	}
+	authSecret := make([]byte, 16)
+	if _, err := rand.Read(authSecret); err != nil {
+		log.Fatal("Failed to generate auth secret:", err)
+	}
+
+	subscription, _ := json.MarshalIndent(map[string]interface{}{
+		"endpoint": fmt.Sprintf("http://%s/push/sink", *addr),
+		"keys": map[string]string{
+			"p256dh": base64.RawURLEncoding.EncodeToString(uaPrivate.PublicKey().Bytes()),
+			"auth":   base64.RawURLEncoding.EncodeToString(authSecret),
+		},
+	}, "", "  ")
+	fmt.Printf("Register this subscription with POST /api/v1/push/subscriptions:\n%s\n", subscription)
+
+	http.HandleFunc("/push/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "vapid t=") {
+			log.Printf("Rejected push without VAPID authorization")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("Content-Encoding") != "aes128gcm" {
+			log.Printf("Rejected push with content encoding %q", r.Header.Get("Content-Encoding"))
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		payload, err := util.DecryptWebPushPayload(uaPrivate, authSecret, body)
+		if err != nil {
+			log.Printf("Failed to decrypt push (%d bytes): %v", len(body), err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		log.Printf("Push received (TTL %s, answering %d): %s", r.Header.Get("TTL"), *status, payload)
+		w.WriteHeader(*status)
+	})
+
+	log.Printf("Stand-in push service listening on http://%s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -1,38 +1,38 @@
 package app
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"os"
 	"strconv"
+	"time"
 
 	"yourapp/internal/service"
 	"yourapp/internal/util"
+	"yourapp/internal/websocket"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ChatHandler struct {
 	chatService service.ChatService
-	wsHub       interface {
-		BroadcastToUser(string, map[string]interface{})
-	}
 }
 
-func NewChatHandler(chatService service.ChatService, wsHub interface {
-	BroadcastToUser(string, map[string]interface{})
-}) *ChatHandler {
+func NewChatHandler(chatService service.ChatService) *ChatHandler {
 	return &ChatHandler{
 		chatService: chatService,
-		wsHub:       wsHub,
 	}
 }
 
-// SendMessageRequest is the request body for sending a chat message
+// SendMessageRequest is the request body for sending a chat message. Content may be empty
+// when the message has attachments.
 type SendMessageRequest struct {
 	ReceiverID string `json:"receiver_id" binding:"required"`
-	Content    string `json:"content" binding:"required"`
+	service.MessageContent
 }
 
-// SendMessage sends a chat message and pushes to recipient via WebSocket
+// SendMessage sends a direct message; the service delivers it to the recipient in real time
 // POST /api/v1/chat/messages
 func (h *ChatHandler) SendMessage(c *gin.Context) {
 	userID, exists := c.Get("userID")
@@ -47,27 +47,12 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 		return
 	}
 
-	msg, err := h.chatService.SendMessage(userID.(string), req.ReceiverID, req.Content)
+	msg, err := h.chatService.SendMessage(userID.(string), req.ReceiverID, &req.MessageContent)
 	if err != nil {
 		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
 		return
 	}
 
-	// Push to recipient via WebSocket for real-time delivery
-	if h.wsHub != nil {
-		h.wsHub.BroadcastToUser(req.ReceiverID, map[string]interface{}{
-			"type": "chat_message",
-			"payload": map[string]interface{}{
-				"id":         msg.ID,
-				"sender_id":  msg.SenderID,
-				"receiver_id": msg.ReceiverID,
-				"content":    msg.Content,
-				"created_at": msg.CreatedAt,
-				"sender":     msg.Sender,
-			},
-		})
-	}
-
 	util.SuccessResponse(c, http.StatusCreated, "Message sent", gin.H{"message": msg})
 }
 
@@ -163,3 +148,837 @@ func (h *ChatHandler) GetUnreadCountBySenders(c *gin.Context) {
 
 	util.SuccessResponse(c, http.StatusOK, "Unread counts by sender retrieved", gin.H{"counts": counts})
 }
+
+// GetConversations returns the inbox: pinned conversations (first page only) followed by the
+// others, most recently active first, each with the current user's unread count and state
+// GET /api/v1/chat/conversations?limit=20&cursor=...&archived=true
+func (h *ChatHandler) GetConversations(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+	opts := service.ConversationListOptions{
+		Limit:    limit,
+		Cursor:   c.Query("cursor"),
+		Archived: c.Query("archived") == "true",
+	}
+
+	page, err := h.chatService.GetConversations(userID.(string), opts)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Conversations retrieved", gin.H{
+		"pinned":        page.Pinned,
+		"conversations": page.Conversations,
+		"next_cursor":   page.NextCursor,
+		"request_count": page.RequestCount,
+	})
+}
+
+// MarkConversationRead marks a conversation read up to its last message
+// PUT /api/v1/chat/conversations/:id/read
+func (h *ChatHandler) MarkConversationRead(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.chatService.MarkConversationRead(userID.(string), c.Param("id")); err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Conversation marked as read", nil)
+}
+
+// ArchiveConversation archives or unarchives a conversation for the current user
+// PUT /api/v1/chat/conversations/:id/archive {"archived": true}
+func (h *ChatHandler) ArchiveConversation(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req struct {
+		Archived *bool `json:"archived" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	conversation, err := h.chatService.ArchiveConversation(userID.(string), c.Param("id"), *req.Archived)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Conversation updated", gin.H{"conversation": conversation})
+}
+
+// MuteConversation mutes or unmutes a conversation for the current user. Without until the
+// conversation stays muted until it is unmuted.
+// PUT /api/v1/chat/conversations/:id/mute {"muted": true, "until": "2026-01-01T08:00:00Z"}
+func (h *ChatHandler) MuteConversation(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req struct {
+		Muted *bool      `json:"muted" binding:"required"`
+		Until *time.Time `json:"until"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	conversation, err := h.chatService.MuteConversation(userID.(string), c.Param("id"), *req.Muted, req.Until)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Conversation updated", gin.H{"conversation": conversation})
+}
+
+// PinConversation pins or unpins a conversation at the top of the current user's inbox
+// PUT /api/v1/chat/conversations/:id/pin {"pinned": true}
+func (h *ChatHandler) PinConversation(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req struct {
+		Pinned *bool `json:"pinned" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	conversation, err := h.chatService.PinConversation(userID.(string), c.Param("id"), *req.Pinned)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Conversation updated", gin.H{"conversation": conversation})
+}
+
+// SetDisappearingTimer sets after how many seconds new messages of a conversation disappear
+// PUT /api/v1/chat/conversations/:id/disappearing {"disappear_after": 86400}
+func (h *ChatHandler) SetDisappearingTimer(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req struct {
+		DisappearAfter *int `json:"disappear_after" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	conversation, err := h.chatService.SetDisappearingTimer(userID.(string), c.Param("id"), *req.DisappearAfter)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Conversation updated", gin.H{"conversation": conversation})
+}
+
+// CreateGroup creates a group chat with the current user as admin
+// POST /api/v1/chat/conversations {"title": "...", "avatar_url": "...", "member_ids": ["..."]}
+func (h *ChatHandler) CreateGroup(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req service.CreateGroupChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	conversation, err := h.chatService.CreateGroup(userID.(string), &req)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusCreated, "Group created", gin.H{"conversation": conversation})
+}
+
+// GetConversationByID returns a conversation with its participants and their read state
+// GET /api/v1/chat/conversations/:id
+func (h *ChatHandler) GetConversationByID(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	conversation, err := h.chatService.GetConversationByID(userID.(string), c.Param("id"))
+	if err != nil {
+		util.ErrorResponse(c, http.StatusNotFound, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Conversation retrieved", gin.H{"conversation": conversation})
+}
+
+// GetConversationMessages returns messages of a conversation and marks it read
+// GET /api/v1/chat/conversations/:id/messages?limit=50&offset=0
+func (h *ChatHandler) GetConversationMessages(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	messages, err := h.chatService.GetConversationMessages(userID.(string), c.Param("id"), limit, offset)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	_ = h.chatService.MarkConversationRead(userID.(string), c.Param("id"))
+
+	util.SuccessResponse(c, http.StatusOK, "Messages retrieved", gin.H{"messages": messages})
+}
+
+// SendConversationMessage sends a message to a direct or group conversation
+// POST /api/v1/chat/conversations/:id/messages {"content": "...", "attachment_ids": [...], "reply_to_message_id": "..."}
+func (h *ChatHandler) SendConversationMessage(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req service.MessageContent
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	msg, err := h.chatService.SendConversationMessage(userID.(string), c.Param("id"), &req)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusCreated, "Message sent", gin.H{"message": msg})
+}
+
+// EditMessage changes the content of the user's message (within 15 minutes of sending it)
+// PUT /api/v1/chat/messages/:id {"content": "..."}
+func (h *ChatHandler) EditMessage(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	msg, err := h.chatService.EditMessage(userID.(string), c.Param("id"), req.Content)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Message edited", gin.H{"message": msg})
+}
+
+// UnsendMessage removes the user's message for everyone in the conversation
+// POST /api/v1/chat/messages/:id/unsend
+func (h *ChatHandler) UnsendMessage(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	msg, err := h.chatService.UnsendMessage(userID.(string), c.Param("id"))
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Message unsent", gin.H{"message": msg})
+}
+
+// SearchMessages searches the messages of the current user's conversations. from and to take
+// a date (2006-01-02, both days included) or an RFC 3339 time.
+// GET /api/v1/chat/search?q=keyword&conversation_id=xxx&from=2026-01-01&to=2026-01-31&context=2&limit=20&offset=0
+func (h *ChatHandler) SearchMessages(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	query := c.Query("q")
+	if query == "" {
+		util.BadRequest(c, "Search keyword is required")
+		return
+	}
+	from, err := parseSearchTime(c.Query("from"), false)
+	if err != nil {
+		util.BadRequest(c, "Invalid from date")
+		return
+	}
+	to, err := parseSearchTime(c.Query("to"), true)
+	if err != nil {
+		util.BadRequest(c, "Invalid to date")
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	contextSize, _ := strconv.Atoi(c.DefaultQuery("context", "0"))
+
+	results, err := h.chatService.SearchMessages(userID.(string), service.ChatSearchOptions{
+		Query:          query,
+		ConversationID: c.Query("conversation_id"),
+		From:           from,
+		To:             to,
+		Context:        contextSize,
+		Limit:          limit,
+		Offset:         offset,
+	})
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Messages retrieved", gin.H{
+		"results": results,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// parseSearchTime parses a date or RFC 3339 time of a search filter. A date used as the end of
+// a range includes the whole day.
+func parseSearchTime(value string, end bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// GetMessageContext returns the messages around a message, to jump to it (e.g. from a search result)
+// GET /api/v1/chat/messages/:id/context?size=20
+func (h *ChatHandler) GetMessageContext(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+
+	messageContext, err := h.chatService.GetMessageContext(userID.(string), c.Param("id"), size)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusNotFound, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Messages retrieved", messageContext)
+}
+
+// ForwardMessage forwards a message to a conversation or to a friend
+// POST /api/v1/chat/messages/:id/forward {"conversation_id": "..."} or {"receiver_id": "..."}
+func (h *ChatHandler) ForwardMessage(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req service.ForwardMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	msg, err := h.chatService.ForwardMessage(userID.(string), c.Param("id"), &req)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusCreated, "Message forwarded", gin.H{"message": msg})
+}
+
+// DeleteMessage deletes a message for the current user only
+// DELETE /api/v1/chat/messages/:id
+func (h *ChatHandler) DeleteMessage(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.chatService.DeleteMessageForMe(userID.(string), c.Param("id")); err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Message deleted", nil)
+}
+
+// ReactToMessage sets the user's reaction to a message
+// POST /api/v1/chat/messages/:id/reaction {"reaction": "love"}
+func (h *ChatHandler) ReactToMessage(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req struct {
+		Reaction string `json:"reaction,omitempty"` // like, love, haha, wow, sad, angry
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Reaction == "" {
+		// Reaction is optional, default to "like"
+		req.Reaction = "like"
+	}
+
+	reactions, err := h.chatService.ReactToMessage(userID.(string), c.Param("id"), req.Reaction)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Reaction saved", gin.H{"reactions": reactions})
+}
+
+// RemoveReaction removes the user's reaction to a message
+// DELETE /api/v1/chat/messages/:id/reaction
+func (h *ChatHandler) RemoveReaction(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	reactions, err := h.chatService.RemoveReaction(userID.(string), c.Param("id"))
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Reaction removed", gin.H{"reactions": reactions})
+}
+
+// UploadAttachment uploads an image, file or voice note to send with a message
+// POST /api/v1/chat/attachments (multipart, field "file")
+func (h *ChatHandler) UploadAttachment(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		util.BadRequest(c, "File is required")
+		return
+	}
+	if file.Size > service.MaxChatFileSize {
+		util.BadRequest(c, fmt.Sprintf("File size exceeds %dMB limit", service.MaxChatFileSize/(1024*1024)))
+		return
+	}
+
+	// Save temporarily
+	tmpPath := fmt.Sprintf("/tmp/chat_attachment_%s_%d%s", userID.(string), time.Now().UnixNano(), util.GetFileExt(file.Filename))
+	if err := c.SaveUploadedFile(file, tmpPath); err != nil {
+		util.InternalServerError(c, "Failed to save uploaded file")
+		return
+	}
+	defer os.Remove(tmpPath)
+
+	attachment, err := h.chatService.UploadAttachment(userID.(string), file.Filename, tmpPath, file.Size)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusCreated, "Attachment uploaded", gin.H{"attachment": attachment})
+}
+
+// GetAttachment returns an attachment with fresh signed URLs (participants only)
+// GET /api/v1/chat/attachments/:id
+func (h *ChatHandler) GetAttachment(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	attachment, err := h.chatService.GetAttachment(userID.(string), c.Param("id"))
+	if err != nil {
+		util.ErrorResponse(c, http.StatusNotFound, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Attachment retrieved", gin.H{"attachment": attachment})
+}
+
+// UpdateGroup changes a group chat's title or avatar (admins only)
+// PUT /api/v1/chat/conversations/:id {"title": "...", "avatar_url": "..."}
+func (h *ChatHandler) UpdateGroup(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req service.UpdateGroupChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	conversation, err := h.chatService.UpdateGroup(userID.(string), c.Param("id"), &req)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Group updated", gin.H{"conversation": conversation})
+}
+
+// AddGroupMembers adds friends of the current user to a group chat (admins only)
+// POST /api/v1/chat/conversations/:id/members {"user_ids": ["..."]}
+func (h *ChatHandler) AddGroupMembers(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req struct {
+		UserIDs []string `json:"user_ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	conversation, err := h.chatService.AddGroupMembers(userID.(string), c.Param("id"), req.UserIDs)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Members added", gin.H{"conversation": conversation})
+}
+
+// RemoveGroupMember removes a member from a group chat (admins only)
+// DELETE /api/v1/chat/conversations/:id/members/:userID
+func (h *ChatHandler) RemoveGroupMember(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	conversation, err := h.chatService.RemoveGroupMember(userID.(string), c.Param("id"), c.Param("userID"))
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Member removed", gin.H{"conversation": conversation})
+}
+
+// SetGroupMemberRole makes a member an admin or a member again (admins only)
+// PUT /api/v1/chat/conversations/:id/members/:userID/role {"role": "admin"}
+func (h *ChatHandler) SetGroupMemberRole(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	conversation, err := h.chatService.SetGroupMemberRole(userID.(string), c.Param("id"), c.Param("userID"), req.Role)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Role updated", gin.H{"conversation": conversation})
+}
+
+// LeaveGroup removes the current user from a group chat
+// POST /api/v1/chat/conversations/:id/leave
+func (h *ChatHandler) LeaveGroup(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.chatService.LeaveGroup(userID.(string), c.Param("id")); err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Left the group", nil)
+}
+
+// GetChatSettings returns the current user's chat privacy settings
+// GET /api/v1/chat/settings
+func (h *ChatHandler) GetChatSettings(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	settings, err := h.chatService.GetSettings(userID.(string))
+	if err != nil {
+		util.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Chat settings retrieved", gin.H{"settings": settings})
+}
+
+// UpdateChatSettings changes the current user's chat privacy settings
+// PUT /api/v1/chat/settings {"hide_read_receipts": true, "message_requests": "friends_of_friends"}
+func (h *ChatHandler) UpdateChatSettings(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req service.ChatSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	settings, err := h.chatService.UpdateSettings(userID.(string), &req)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Chat settings updated", gin.H{"settings": settings})
+}
+
+// GetMessageRequests returns the pending message requests from non-friends, most recently
+// active first
+// GET /api/v1/chat/requests?limit=20&cursor=...
+func (h *ChatHandler) GetMessageRequests(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+	opts := service.ConversationListOptions{
+		Limit:    limit,
+		Cursor:   c.Query("cursor"),
+		Requests: true,
+	}
+
+	page, err := h.chatService.GetConversations(userID.(string), opts)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Message requests retrieved", gin.H{
+		"conversations": page.Conversations,
+		"next_cursor":   page.NextCursor,
+	})
+}
+
+// AcceptMessageRequest moves a message request into the inbox
+// POST /api/v1/chat/requests/:id/accept
+func (h *ChatHandler) AcceptMessageRequest(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	conversation, err := h.chatService.AcceptMessageRequest(userID.(string), c.Param("id"))
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Message request accepted", gin.H{"conversation": conversation})
+}
+
+// DeclineMessageRequest removes a message request without telling its sender
+// POST /api/v1/chat/requests/:id/decline
+func (h *ChatHandler) DeclineMessageRequest(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.chatService.DeclineMessageRequest(userID.(string), c.Param("id")); err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Message request declined", nil)
+}
+
+// BlockMessageRequest declines a message request and blocks its sender
+// POST /api/v1/chat/requests/:id/block
+func (h *ChatHandler) BlockMessageRequest(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.chatService.BlockMessageRequest(userID.(string), c.Param("id")); err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Sender blocked", nil)
+}
+
+// RegisterSocketHandlers lets clients chat over their WebSocket connection:
+//
+//	chat.send   {"client_id", "conversation_id" | "receiver_id", "content", "attachment_ids", "reply_to_message_id"} -> chat.ack {"client_id", "message"}
+//	chat.typing {"conversation_id", "typing"}
+//	chat.read   {"conversation_id"}
+//
+// Rejected frames are answered with an "error" frame.
+func (h *ChatHandler) RegisterSocketHandlers(hub *websocket.Hub) {
+	hub.Handle("chat.send", h.socketSend)
+	hub.Handle("chat.typing", h.socketTyping)
+	hub.Handle("chat.read", h.socketRead)
+}
+
+func (h *ChatHandler) socketSend(userID string, frame *websocket.InboundFrame) (*websocket.Message, error) {
+	var req service.ClientMessageRequest
+	if err := decodeSocketPayload(frame, &req); err != nil {
+		return nil, err
+	}
+
+	msg, err := h.chatService.SendClientMessage(userID, &req)
+	if err != nil {
+		return nil, websocket.NewFrameError(websocket.ErrorCodeRejected, err.Error())
+	}
+
+	return &websocket.Message{
+		Type: "chat.ack",
+		Payload: map[string]interface{}{
+			"client_id": req.ClientID,
+			"message":   msg,
+		},
+	}, nil
+}
+
+func (h *ChatHandler) socketTyping(userID string, frame *websocket.InboundFrame) (*websocket.Message, error) {
+	var req struct {
+		ConversationID string `json:"conversation_id"`
+		Typing         *bool  `json:"typing"`
+	}
+	if err := decodeSocketPayload(frame, &req); err != nil {
+		return nil, err
+	}
+	if req.ConversationID == "" || req.Typing == nil {
+		return nil, websocket.NewFrameError(websocket.ErrorCodeInvalidPayload, "conversation_id and typing are required")
+	}
+
+	if err := h.chatService.SetTyping(userID, req.ConversationID, *req.Typing); err != nil {
+		return nil, websocket.NewFrameError(websocket.ErrorCodeRejected, err.Error())
+	}
+	return nil, nil
+}
+
+func (h *ChatHandler) socketRead(userID string, frame *websocket.InboundFrame) (*websocket.Message, error) {
+	var req struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	if err := decodeSocketPayload(frame, &req); err != nil {
+		return nil, err
+	}
+	if req.ConversationID == "" {
+		return nil, websocket.NewFrameError(websocket.ErrorCodeInvalidPayload, "conversation_id is required")
+	}
+
+	if err := h.chatService.MarkConversationRead(userID, req.ConversationID); err != nil {
+		return nil, websocket.NewFrameError(websocket.ErrorCodeRejected, err.Error())
+	}
+	return nil, nil
+}
+
+// decodeSocketPayload decodes the frame's payload into v
+func decodeSocketPayload(frame *websocket.InboundFrame, v interface{}) error {
+	if len(frame.Payload) == 0 {
+		return websocket.NewFrameError(websocket.ErrorCodeInvalidPayload, "payload is required")
+	}
+	if err := json.Unmarshal(frame.Payload, v); err != nil {
+		return websocket.NewFrameError(websocket.ErrorCodeInvalidPayload, "invalid payload: "+err.Error())
+	}
+	return nil
+}
@@ -55,7 +55,13 @@ func (h *CommentHandler) GetComment(c *gin.Context) {
 		return
 	}
 
-	comment, err := h.commentService.GetCommentByID(commentID)
+	// Get viewer ID (if authenticated)
+	viewerID := ""
+	if userID, exists := c.Get("userID"); exists {
+		viewerID = userID.(string)
+	}
+
+	comment, err := h.commentService.GetCommentByID(commentID, viewerID)
 	if err != nil {
 		util.NotFound(c, err.Error())
 		return
@@ -65,7 +71,7 @@ func (h *CommentHandler) GetComment(c *gin.Context) {
 }
 
 // GetCommentsByPost handles getting comments by post ID
-// GET /api/v1/posts/:id/comments
+// GET /api/v1/posts/:id/comments?sort=top&limit=20&cursor=...&depth=2&replies=3
 func (h *CommentHandler) GetCommentsByPost(c *gin.Context) {
 	postID := c.Param("id")
 	if postID == "" {
@@ -73,38 +79,25 @@ func (h *CommentHandler) GetCommentsByPost(c *gin.Context) {
 		return
 	}
 
-	limitStr := c.DefaultQuery("limit", "20")
-	offsetStr := c.DefaultQuery("offset", "0")
-
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit < 1 {
-		limit = 20
-	}
-	if limit > 100 {
-		limit = 100
-	}
-
-	offset, err := strconv.Atoi(offsetStr)
-	if err != nil || offset < 0 {
-		offset = 0
-	}
-
-	comments, total, err := h.commentService.GetCommentsByPostID(postID, limit, offset)
+	opts := parseCommentListOptions(c)
+	page, err := h.commentService.GetCommentsByPostID(postID, opts)
 	if err != nil {
 		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
 		return
 	}
 
 	util.SuccessResponse(c, http.StatusOK, "Comments retrieved successfully", gin.H{
-		"comments": comments,
-		"total":    total,
-		"limit":    limit,
-		"offset":   offset,
+		"comments":    page.Comments,
+		"total":       page.Total,
+		"limit":       opts.Limit,
+		"offset":      opts.Offset,
+		"sort":        page.Sort,
+		"next_cursor": page.NextCursor,
 	})
 }
 
-// GetReplies handles getting replies to a comment
-// GET /api/v1/comments/:id/replies
+// GetReplies handles getting replies to a comment ("load more replies")
+// GET /api/v1/comments/:id/replies?limit=20&cursor=...&depth=2&replies=3
 func (h *CommentHandler) GetReplies(c *gin.Context) {
 	commentID := c.Param("id")
 	if commentID == "" {
@@ -112,10 +105,25 @@ func (h *CommentHandler) GetReplies(c *gin.Context) {
 		return
 	}
 
-	limitStr := c.DefaultQuery("limit", "20")
-	offsetStr := c.DefaultQuery("offset", "0")
+	opts := parseCommentListOptions(c)
+	page, err := h.commentService.GetRepliesByCommentID(commentID, opts)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Replies retrieved successfully", gin.H{
+		"replies":     page.Comments,
+		"total":       page.Total,
+		"limit":       opts.Limit,
+		"offset":      opts.Offset,
+		"next_cursor": page.NextCursor,
+	})
+}
 
-	limit, err := strconv.Atoi(limitStr)
+// parseCommentListOptions reads paging (limit/offset or cursor) and thread bounds (depth/replies)
+func parseCommentListOptions(c *gin.Context) service.CommentListOptions {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	if err != nil || limit < 1 {
 		limit = 20
 	}
@@ -123,23 +131,33 @@ func (h *CommentHandler) GetReplies(c *gin.Context) {
 		limit = 100
 	}
 
-	offset, err := strconv.Atoi(offsetStr)
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
 	if err != nil || offset < 0 {
 		offset = 0
 	}
 
-	replies, total, err := h.commentService.GetRepliesByCommentID(commentID, limit, offset)
+	depth, err := strconv.Atoi(c.DefaultQuery("depth", strconv.Itoa(service.DefaultCommentReplyDepth)))
 	if err != nil {
-		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
-		return
+		depth = service.DefaultCommentReplyDepth
 	}
 
-	util.SuccessResponse(c, http.StatusOK, "Replies retrieved successfully", gin.H{
-		"replies": replies,
-		"total":   total,
-		"limit":   limit,
-		"offset":  offset,
-	})
+	replies, err := strconv.Atoi(c.DefaultQuery("replies", strconv.Itoa(service.DefaultRepliesPerComment)))
+	if err != nil {
+		replies = service.DefaultRepliesPerComment
+	}
+
+	opts := service.CommentListOptions{
+		Sort:    c.Query("sort"),
+		Limit:   limit,
+		Offset:  offset,
+		Cursor:  c.Query("cursor"),
+		Depth:   depth,
+		Replies: replies,
+	}
+	if userID, exists := c.Get("userID"); exists {
+		opts.ViewerID = userID.(string)
+	}
+	return opts
 }
 
 // UpdateComment handles comment update
@@ -196,6 +214,53 @@ func (h *CommentHandler) DeleteComment(c *gin.Context) {
 	util.SuccessResponse(c, http.StatusOK, "Comment deleted successfully", nil)
 }
 
+// PinComment handles pinning a comment to the top of its post (post author only)
+// POST /api/v1/comments/:id/pin
+func (h *CommentHandler) PinComment(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	commentID := c.Param("id")
+	if commentID == "" {
+		util.BadRequest(c, "Comment ID is required")
+		return
+	}
+
+	comment, err := h.commentService.PinComment(userID.(string), commentID)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Comment pinned successfully", gin.H{"comment": comment})
+}
+
+// UnpinComment handles removing the pin from a comment (post author only)
+// DELETE /api/v1/comments/:id/pin
+func (h *CommentHandler) UnpinComment(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	commentID := c.Param("id")
+	if commentID == "" {
+		util.BadRequest(c, "Comment ID is required")
+		return
+	}
+
+	if err := h.commentService.UnpinComment(userID.(string), commentID); err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Comment unpinned successfully", nil)
+}
+
 // GetCommentCount handles getting comment count for a post
 // GET /api/v1/posts/:id/comments/count
 func (h *CommentHandler) GetCommentCount(c *gin.Context) {
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"net/http"
+
+	"yourapp/internal/service"
+	"yourapp/internal/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CounterHandler exposes the engagement counter reconciliation to admins
+type CounterHandler struct {
+	reconciler *service.CounterReconciler
+}
+
+func NewCounterHandler(reconciler *service.CounterReconciler) *CounterHandler {
+	return &CounterHandler{
+		reconciler: reconciler,
+	}
+}
+
+// ReconcileCounters runs a reconciliation immediately and reports how many counters were fixed
+// POST /api/v1/admin/counters/reconcile
+func (h *CounterHandler) ReconcileCounters(c *gin.Context) {
+	report, err := h.reconciler.Reconcile()
+	if err != nil {
+		util.ErrorResponse(c, http.StatusInternalServerError, "Failed to reconcile counters", gin.H{
+			"error":  err.Error(),
+			"report": report,
+		})
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Counters reconciled successfully", gin.H{"report": report})
+}
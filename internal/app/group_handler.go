@@ -3,6 +3,7 @@ package app
 import (
 	"fmt"
 	"net/http"
+	"os"
 	"strconv"
 
 	"yourapp/internal/service"
@@ -13,14 +14,14 @@ import (
 
 type GroupHandler struct {
 	groupService service.GroupService
-	cloudinary   *util.CloudinaryClient
+	mediaStore   util.MediaStore
 	jwtSecret    string
 }
 
-func NewGroupHandler(groupService service.GroupService, cloudinary *util.CloudinaryClient, jwtSecret string) *GroupHandler {
+func NewGroupHandler(groupService service.GroupService, mediaStore util.MediaStore, jwtSecret string) *GroupHandler {
 	return &GroupHandler{
 		groupService: groupService,
-		cloudinary:   cloudinary,
+		mediaStore:   mediaStore,
 		jwtSecret:    jwtSecret,
 	}
 }
@@ -95,13 +96,14 @@ func (h *GroupHandler) CreateGroupWithCover(c *gin.Context) {
 
 	// Handle cover photo upload
 	file, err := c.FormFile("cover_photo")
-	if err == nil && file != nil && h.cloudinary != nil {
+	if err == nil && file != nil && h.mediaStore != nil {
 		// Save file temporarily
 		tmpPath := fmt.Sprintf("/tmp/group_cover_%s_%s", userID.(string), file.Filename)
 		if err := c.SaveUploadedFile(file, tmpPath); err == nil {
-			url, err := h.cloudinary.UploadImage(tmpPath)
+			defer os.Remove(tmpPath)
+			stored, err := h.uploadCover(tmpPath)
 			if err == nil {
-				req.CoverPhoto = &url
+				req.CoverPhoto = &stored.URL
 			}
 		}
 	}
@@ -483,7 +485,7 @@ func (h *GroupHandler) UpdateGroupCover(c *gin.Context) {
 		return
 	}
 
-	if h.cloudinary == nil {
+	if h.mediaStore == nil {
 		util.InternalServerError(c, "Image upload not configured")
 		return
 	}
@@ -506,16 +508,17 @@ func (h *GroupHandler) UpdateGroupCover(c *gin.Context) {
 		util.InternalServerError(c, "Failed to save uploaded file")
 		return
 	}
+	defer os.Remove(tmpPath)
 
-	// Upload to Cloudinary
-	url, err := h.cloudinary.UploadImage(tmpPath)
+	// Upload to media store
+	stored, err := h.uploadCover(tmpPath)
 	if err != nil {
 		util.InternalServerError(c, "Failed to upload image")
 		return
 	}
 
 	// Update group
-	coverURL := url
+	coverURL := stored.URL
 	req := service.UpdateGroupRequest{
 		CoverPhoto: &coverURL,
 	}
@@ -528,3 +531,18 @@ func (h *GroupHandler) UpdateGroupCover(c *gin.Context) {
 
 	util.SuccessResponse(c, http.StatusOK, "Cover photo updated", gin.H{"group": group})
 }
+
+// uploadCover stores a group cover image in the media store
+func (h *GroupHandler) uploadCover(filePath string) (*util.StoredMedia, error) {
+	// Re-encode first so EXIF metadata (e.g. GPS position) never reaches the store
+	compressed, err := util.CompressImage(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(compressed)
+
+	return h.mediaStore.Put(util.NewMediaKey("groups/covers", compressed), compressed, util.PutOptions{
+		ContentType: util.DetectMimeType(compressed),
+		Hints:       util.ImageDeliveryHints,
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"yourapp/internal/model"
 	"yourapp/internal/repository"
 	"yourapp/internal/service"
 	"yourapp/internal/util"
@@ -95,7 +96,7 @@ func (h *LikeHandler) LikePost(c *gin.Context) {
 					}
 				}
 
-				// Send notification (will check internally if already sent)
+				// Send notification (grouped with other unread likes on the post)
 				_ = h.notificationService.SendPostLikedNotification(
 					post.UserID,
 					userID.(string),
@@ -190,10 +191,11 @@ func (h *LikeHandler) UnlikeComment(c *gin.Context) {
 }
 
 // GetLikes handles getting likes for a target
-// GET /api/v1/likes?target_type=post&target_id=xxx&limit=20&offset=0
+// GET /api/v1/likes?target_type=post&target_id=xxx&reaction=love&limit=20&offset=0
 func (h *LikeHandler) GetLikes(c *gin.Context) {
 	targetType := c.Query("target_type")
 	targetID := c.Query("target_id")
+	reaction := c.Query("reaction")
 
 	if targetType == "" || targetID == "" {
 		util.BadRequest(c, "target_type and target_id are required")
@@ -216,17 +218,25 @@ func (h *LikeHandler) GetLikes(c *gin.Context) {
 		offset = 0
 	}
 
-	likes, total, err := h.likeService.GetLikesByTarget(targetType, targetID, limit, offset)
+	likes, total, err := h.likeService.GetLikesByTarget(targetType, targetID, reaction, limit, offset)
 	if err != nil {
 		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
 		return
 	}
 
+	// Breakdown for the reaction tabs of the "who reacted" list
+	reactionCounts := map[string]int64{}
+	if counts, err := h.likeService.GetReactionCountsBatch(targetType, []string{targetID}); err == nil {
+		reactionCounts = counts[targetID]
+	}
+
 	util.SuccessResponse(c, http.StatusOK, "Likes retrieved successfully", gin.H{
-		"likes":  likes,
-		"total":  total,
-		"limit":  limit,
-		"offset": offset,
+		"likes":           likes,
+		"total":           total,
+		"reaction":        reaction,
+		"reaction_counts": reactionCounts,
+		"limit":           limit,
+		"offset":          offset,
 	})
 }
 
@@ -247,5 +257,14 @@ func (h *LikeHandler) GetLikeCount(c *gin.Context) {
 		return
 	}
 
-	util.SuccessResponse(c, http.StatusOK, "Like count retrieved successfully", gin.H{"count": count})
+	reactionCounts := map[string]int64{}
+	if counts, err := h.likeService.GetReactionCountsBatch(targetType, []string{targetID}); err == nil {
+		reactionCounts = counts[targetID]
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Like count retrieved successfully", gin.H{
+		"count":           count,
+		"reaction_counts": reactionCounts,
+		"top_reactions":   model.TopReactions(reactionCounts, 3),
+	})
 }
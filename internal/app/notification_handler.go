@@ -1,7 +1,9 @@
 package app
 
 import (
+	"html"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"yourapp/internal/service"
@@ -12,12 +14,18 @@ import (
 
 type NotificationHandler struct {
 	notificationService service.NotificationService
+	preferenceService   service.NotificationPreferenceService
 	jwtSecret           string
 }
 
-func NewNotificationHandler(notificationService service.NotificationService, jwtSecret string) *NotificationHandler {
+func NewNotificationHandler(
+	notificationService service.NotificationService,
+	preferenceService service.NotificationPreferenceService,
+	jwtSecret string,
+) *NotificationHandler {
 	return &NotificationHandler{
 		notificationService: notificationService,
+		preferenceService:   preferenceService,
 		jwtSecret:           jwtSecret,
 	}
 }
@@ -162,3 +170,113 @@ func (h *NotificationHandler) DeleteNotification(c *gin.Context) {
 
 	util.SuccessResponse(c, http.StatusOK, "Notification deleted successfully", nil)
 }
+
+// GetPreferences handles getting the user's notification channels per type and quiet hours
+// GET /api/v1/notifications/preferences
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	prefs, err := h.preferenceService.GetPreferences(userID.(string))
+	if err != nil {
+		util.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Notification preferences retrieved successfully", prefs)
+}
+
+// UpdatePreferences handles changing notification channels per type, quiet hours and/or digest frequency
+// PUT /api/v1/notifications/preferences
+func (h *NotificationHandler) UpdatePreferences(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req service.UpdateNotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	prefs, err := h.preferenceService.UpdatePreferences(userID.(string), &req)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Notification preferences updated successfully", prefs)
+}
+
+// ConfirmDigestUnsubscribe handles the unsubscribe link of digest emails opened in a browser.
+// It only shows a confirmation form, because mail scanners and link previews fetch links too.
+// GET /api/v1/notifications/digest/unsubscribe?token=...
+func (h *NotificationHandler) ConfirmDigestUnsubscribe(c *gin.Context) {
+	token := c.Query("token")
+	if err := h.preferenceService.CheckUnsubscribeToken(token); err != nil {
+		c.Data(http.StatusBadRequest, "text/html; charset=utf-8", []byte(digestUnsubscribeInvalidPage()))
+		return
+	}
+
+	form := `<form method="POST" action="?token=` + html.EscapeString(url.QueryEscape(token)) + `" style="margin: 24px 0 0;">
+            <input type="hidden" name="token" value="` + html.EscapeString(token) + `">
+            <button type="submit" style="padding: 10px 20px; background-color: #1e3a8a; color: #ffffff; border: 0; border-radius: 4px; font-size: 15px; cursor: pointer;">Berhenti berlangganan</button>
+        </form>`
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(digestUnsubscribePage(
+		"Berhenti berlangganan email ringkasan?",
+		"Anda tidak akan lagi menerima email ringkasan aktivitas. Aktifkan kembali kapan saja melalui halaman pengaturan notifikasi.",
+		form,
+	)))
+}
+
+// UnsubscribeDigest handles the confirmation form of the unsubscribe page and the one-click
+// List-Unsubscribe-Post request of mail clients
+// POST /api/v1/notifications/digest/unsubscribe?token=...
+func (h *NotificationHandler) UnsubscribeDigest(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		token = c.PostForm("token")
+	}
+
+	if err := h.preferenceService.UnsubscribeDigest(token); err != nil {
+		c.Data(http.StatusBadRequest, "text/html; charset=utf-8", []byte(digestUnsubscribeInvalidPage()))
+		return
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(digestUnsubscribePage(
+		"Berhasil berhenti berlangganan",
+		"Anda tidak akan lagi menerima email ringkasan aktivitas. Aktifkan kembali kapan saja melalui halaman pengaturan notifikasi.",
+		"",
+	)))
+}
+
+func digestUnsubscribeInvalidPage() string {
+	return digestUnsubscribePage(
+		"Tautan tidak valid",
+		"Tautan berhenti berlangganan ini tidak valid. Atur email ringkasan melalui halaman pengaturan notifikasi.",
+		"",
+	)
+}
+
+func digestUnsubscribePage(title, message, form string) string {
+	return `<!DOCTYPE html>
+<html lang="id">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>` + title + `</title>
+</head>
+<body style="margin: 0; padding: 40px 20px; font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background-color: #f4f6f8;">
+    <div style="max-width: 480px; margin: 0 auto; background-color: #ffffff; border: 1px solid #e5e7eb; border-radius: 4px; padding: 32px;">
+        <h1 style="margin: 0 0 12px; color: #1e3a8a; font-size: 20px;">` + title + `</h1>
+        <p style="margin: 0; color: #374151; font-size: 15px; line-height: 1.6;">` + message + `</p>
+        ` + form + `
+    </div>
+</body>
+</html>`
+}
@@ -17,14 +17,15 @@ type PostHandler struct {
 	postService         service.PostService
 	postViewService     service.PostViewService
 	notificationService service.NotificationService
-	cloudinaryClient    *util.CloudinaryClient
 	wsHub               interface {
 		BroadcastToUser(string, map[string]interface{})
 		BroadcastToAll(map[string]interface{})
 	}
-	likeService    service.LikeService
-	commentService service.CommentService
-	jwtSecret      string
+	likeService     service.LikeService
+	commentService  service.CommentService
+	uploadService   service.UploadService
+	mediaJobService service.MediaJobService
+	jwtSecret       string
 }
 
 func NewPostHandler(postService service.PostService, jwtSecret string) *PostHandler {
@@ -34,27 +35,29 @@ func NewPostHandler(postService service.PostService, jwtSecret string) *PostHand
 	}
 }
 
-func NewPostHandlerWithCloudinary(
+func NewPostHandlerWithMedia(
 	postService service.PostService,
 	postViewService service.PostViewService,
 	notificationService service.NotificationService,
-	cloudinaryClient *util.CloudinaryClient,
 	wsHub interface {
 		BroadcastToUser(string, map[string]interface{})
 		BroadcastToAll(map[string]interface{})
 	},
 	likeService service.LikeService,
 	commentService service.CommentService,
+	uploadService service.UploadService,
+	mediaJobService service.MediaJobService,
 	jwtSecret string,
 ) *PostHandler {
 	return &PostHandler{
 		postService:         postService,
 		postViewService:     postViewService,
 		notificationService: notificationService,
-		cloudinaryClient:    cloudinaryClient,
 		wsHub:               wsHub,
 		likeService:         likeService,
 		commentService:     commentService,
+		uploadService:       uploadService,
+		mediaJobService:     mediaJobService,
 		jwtSecret:           jwtSecret,
 	}
 }
@@ -118,10 +121,16 @@ func (h *PostHandler) GetPost(c *gin.Context) {
 		commentCount, _ := h.commentService.GetCommentCount(post.ID)
 		post.LikesCount = likeCount
 		post.CommentsCount = commentCount
+		userReaction := ""
 		if viewerID != "" {
-			liked, _, _ := h.likeService.CheckUserLiked(viewerID, model.TargetTypePost, post.ID)
+			liked, like, _ := h.likeService.CheckUserLiked(viewerID, model.TargetTypePost, post.ID)
 			post.UserLiked = liked
+			if liked {
+				userReaction = like.Reaction
+			}
 		}
+		reactionCounts, _ := h.likeService.GetReactionCountsBatch(model.TargetTypePost, []string{post.ID})
+		post.SetReactions(reactionCounts[post.ID], userReaction)
 	}
 
 	util.SuccessResponse(c, http.StatusOK, "Post retrieved successfully", gin.H{"post": post})
@@ -173,14 +182,16 @@ func (h *PostHandler) GetPostsByUserID(c *gin.Context) {
 		}
 		likeCounts, _ := h.likeService.GetLikeCountsBatch(model.TargetTypePost, postIDs)
 		commentCounts, _ := h.commentService.GetCommentCountsBatch(postIDs)
-		userLiked := make(map[string]bool)
+		reactionCounts, _ := h.likeService.GetReactionCountsBatch(model.TargetTypePost, postIDs)
+		userReactions := make(map[string]string)
 		if viewerID != "" {
-			userLiked, _ = h.likeService.GetUserLikedTargets(viewerID, model.TargetTypePost, postIDs)
+			userReactions, _ = h.likeService.GetUserReactions(viewerID, model.TargetTypePost, postIDs)
 		}
 		for _, p := range posts {
 			p.LikesCount = likeCounts[p.ID]
 			p.CommentsCount = commentCounts[p.ID]
-			p.UserLiked = userLiked[p.ID]
+			p.UserLiked = userReactions[p.ID] != ""
+			p.SetReactions(reactionCounts[p.ID], userReactions[p.ID])
 		}
 	}
 
@@ -237,14 +248,16 @@ func (h *PostHandler) GetPostsByGroupID(c *gin.Context) {
 		}
 		likeCounts, _ := h.likeService.GetLikeCountsBatch(model.TargetTypePost, postIDs)
 		commentCounts, _ := h.commentService.GetCommentCountsBatch(postIDs)
-		userLiked := make(map[string]bool)
+		reactionCounts, _ := h.likeService.GetReactionCountsBatch(model.TargetTypePost, postIDs)
+		userReactions := make(map[string]string)
 		if viewerID != "" {
-			userLiked, _ = h.likeService.GetUserLikedTargets(viewerID, model.TargetTypePost, postIDs)
+			userReactions, _ = h.likeService.GetUserReactions(viewerID, model.TargetTypePost, postIDs)
 		}
 		for _, p := range posts {
 			p.LikesCount = likeCounts[p.ID]
 			p.CommentsCount = commentCounts[p.ID]
-			p.UserLiked = userLiked[p.ID]
+			p.UserLiked = userReactions[p.ID] != ""
+			p.SetReactions(reactionCounts[p.ID], userReactions[p.ID])
 		}
 	}
 
@@ -294,7 +307,7 @@ func (h *PostHandler) GetFeed(c *gin.Context) {
 		return
 	}
 
-	// Enrich posts with likes_count, comments_count, user_liked (single batch query each)
+	// Enrich posts with likes_count, comments_count, reactions, user_liked (single batch query each)
 	if h.likeService != nil && h.commentService != nil && len(posts) > 0 {
 		postIDs := make([]string, len(posts))
 		for i, p := range posts {
@@ -302,11 +315,13 @@ func (h *PostHandler) GetFeed(c *gin.Context) {
 		}
 		likeCounts, _ := h.likeService.GetLikeCountsBatch(model.TargetTypePost, postIDs)
 		commentCounts, _ := h.commentService.GetCommentCountsBatch(postIDs)
-		userLiked, _ := h.likeService.GetUserLikedTargets(userID.(string), model.TargetTypePost, postIDs)
+		reactionCounts, _ := h.likeService.GetReactionCountsBatch(model.TargetTypePost, postIDs)
+		userReactions, _ := h.likeService.GetUserReactions(userID.(string), model.TargetTypePost, postIDs)
 		for _, p := range posts {
 			p.LikesCount = likeCounts[p.ID]
 			p.CommentsCount = commentCounts[p.ID]
-			p.UserLiked = userLiked[p.ID]
+			p.UserLiked = userReactions[p.ID] != ""
+			p.SetReactions(reactionCounts[p.ID], userReactions[p.ID])
 		}
 	}
 
@@ -408,6 +423,137 @@ func (h *PostHandler) CountPostsByGroupID(c *gin.Context) {
 	util.SuccessResponse(c, http.StatusOK, "Post count retrieved successfully", gin.H{"count": count})
 }
 
+// GetProfilePins handles getting the posts pinned on a user's profile
+// GET /api/v1/posts/user/:userID/pins
+func (h *PostHandler) GetProfilePins(c *gin.Context) {
+	userID := c.Param("userID")
+	if userID == "" {
+		util.BadRequest(c, "User ID is required")
+		return
+	}
+
+	posts, err := h.postService.GetProfilePins(userID)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Pinned posts retrieved successfully", gin.H{
+		"posts": posts,
+		"max":   model.MaxProfilePinnedPosts,
+	})
+}
+
+// PinToProfile handles pinning a post to the author's profile
+// POST /api/v1/posts/:id/pin
+func (h *PostHandler) PinToProfile(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	postID := c.Param("id")
+	if postID == "" {
+		util.BadRequest(c, "Post ID is required")
+		return
+	}
+
+	posts, err := h.postService.PinPostToProfile(userID.(string), postID)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Post pinned successfully", gin.H{"posts": posts})
+}
+
+// UnpinFromProfile handles removing a post from the author's profile pins
+// DELETE /api/v1/posts/:id/pin
+func (h *PostHandler) UnpinFromProfile(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	postID := c.Param("id")
+	if postID == "" {
+		util.BadRequest(c, "Post ID is required")
+		return
+	}
+
+	posts, err := h.postService.UnpinPostFromProfile(userID.(string), postID)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Post unpinned successfully", gin.H{"posts": posts})
+}
+
+// ReorderProfilePins handles reordering the current user's profile pins
+// PUT /api/v1/posts/pins
+func (h *PostHandler) ReorderProfilePins(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req service.ReorderProfilePinsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	posts, err := h.postService.ReorderProfilePins(userID.(string), req.PostIDs)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Pinned posts reordered successfully", gin.H{"posts": posts})
+}
+
+// PinInGroup handles pinning a post within its group (group admins/moderators only)
+// POST /api/v1/posts/:id/group-pin
+func (h *PostHandler) PinInGroup(c *gin.Context) {
+	h.setGroupPin(c, true)
+}
+
+// UnpinInGroup handles unpinning a post within its group (group admins/moderators only)
+// DELETE /api/v1/posts/:id/group-pin
+func (h *PostHandler) UnpinInGroup(c *gin.Context) {
+	h.setGroupPin(c, false)
+}
+
+func (h *PostHandler) setGroupPin(c *gin.Context, pinned bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	postID := c.Param("id")
+	if postID == "" {
+		util.BadRequest(c, "Post ID is required")
+		return
+	}
+
+	post, err := h.postService.SetGroupPin(userID.(string), postID, pinned)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	message := "Post pinned in group successfully"
+	if !pinned {
+		message = "Post unpinned in group successfully"
+	}
+	util.SuccessResponse(c, http.StatusOK, message, gin.H{"post": post})
+}
+
 // CreatePostWithImages handles post creation with image uploads (async)
 // POST /api/v1/posts/upload
 func (h *PostHandler) CreatePostWithImages(c *gin.Context) {
@@ -526,40 +672,13 @@ func (h *PostHandler) CreatePostWithImages(c *gin.Context) {
 		return
 	}
 
-	// Process images in background (async) — data is already in memory
-	go func() {
-		log.Printf("[IMAGE UPLOAD] Starting async image processing for post %s, %d file(s)", post.ID, len(fileDataList))
-
-		imageURLs, err := h.cloudinaryClient.ProcessMultipleFiles(fileDataList)
-		if err != nil {
-			log.Printf("[IMAGE UPLOAD] Cloudinary upload failed for post %s: %v", post.ID, err)
-			return
-		}
-
-		log.Printf("[IMAGE UPLOAD] Cloudinary upload success for post %s: %v", post.ID, imageURLs)
-
-		updateReq := service.UpdatePostRequest{
-			ImageURLs: imageURLs,
-		}
-
-		updatedPost, err := h.postService.UpdatePost(userID.(string), post.ID, updateReq)
-		if err != nil {
-			log.Printf("[IMAGE UPLOAD] Failed to update post %s: %v", post.ID, err)
-			return
-		}
-
-		log.Printf("[IMAGE UPLOAD] Post %s updated successfully with %d image(s)", updatedPost.ID, len(imageURLs))
-
-		if h.notificationService != nil {
-			_ = h.notificationService.SendPostUploadCompletedNotification(userID.(string), updatedPost.ID, len(imageURLs))
-		}
-		if h.wsHub != nil {
-			h.wsHub.BroadcastToAll(map[string]interface{}{
-				"type":    "new_post",
-				"post_id": updatedPost.ID,
-			})
-		}
-	}()
+	// Queue images for background processing (durable, retried by the media worker)
+	if err := h.mediaJobService.EnqueueFiles(post, fileDataList); err != nil {
+		log.Printf("[IMAGE UPLOAD] Failed to queue images for post %s: %v", post.ID, err)
+		h.discardPlaceholderPost(userID.(string), post.ID)
+		util.InternalServerError(c, "Failed to queue images for processing")
+		return
+	}
 
 	// Send initial WebSocket notification that upload is pending
 	if h.wsHub != nil {
@@ -715,53 +834,118 @@ func (h *PostHandler) CreatePostWithVideos(c *gin.Context) {
 		return
 	}
 
-	// Process videos in background (async) — data is already in memory
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("[VIDEO UPLOAD] PANIC in goroutine for post %s: %v", post.ID, r)
-			}
-		}()
+	// Queue videos for background processing (durable, retried by the media worker)
+	if err := h.mediaJobService.EnqueueFiles(post, fileDataList); err != nil {
+		log.Printf("[VIDEO UPLOAD] Failed to queue videos for post %s: %v", post.ID, err)
+		h.discardPlaceholderPost(userID.(string), post.ID)
+		util.InternalServerError(c, "Failed to queue videos for processing")
+		return
+	}
 
-		log.Printf("[VIDEO UPLOAD] Starting async video processing for post %s, %d file(s)", post.ID, len(fileDataList))
+	// Send initial WebSocket notification that upload is pending
+	if h.wsHub != nil {
+		h.wsHub.BroadcastToUser(userID.(string), map[string]interface{}{
+			"type":    "post_upload_pending",
+			"post_id": post.ID,
+			"message": "Post sedang diproses, video sedang diupload...",
+			"status":  "pending",
+			"data": map[string]interface{}{
+				"post_id": post.ID,
+			},
+		})
+	}
 
-		videoURLs, err := h.cloudinaryClient.ProcessMultipleVideos(fileDataList)
-		if err != nil {
-			log.Printf("[VIDEO UPLOAD] Cloudinary upload failed for post %s: %v", post.ID, err)
-			return
-		}
+	// Return response immediately (ASYNC)
+	util.SuccessResponse(c, http.StatusAccepted, "Post created and videos are being processed", gin.H{
+		"post":   post,
+		"status": "processing",
+	})
+}
 
-		log.Printf("[VIDEO UPLOAD] Cloudinary upload success for post %s: %v", post.ID, videoURLs)
+// discardPlaceholderPost deletes a post created for media that could not be queued, so the
+// user is not left with an empty post
+func (h *PostHandler) discardPlaceholderPost(userID, postID string) {
+	if err := h.postService.DeletePost(userID, postID); err != nil {
+		log.Printf("[MEDIA] Failed to delete placeholder post %s: %v", postID, err)
+	}
+}
 
-		updateReq := service.UpdatePostRequest{
-			VideoURLs: videoURLs,
-		}
+// CreatePostFromUploadsRequest attaches completed resumable uploads to a new post
+type CreatePostFromUploadsRequest struct {
+	Content   *string  `json:"content"`
+	GroupID   *string  `json:"group_id"`
+	UploadIDs []string `json:"upload_ids"`
+}
 
-		updatedPost, err := h.postService.UpdatePost(userID.(string), post.ID, updateReq)
-		if err != nil {
-			log.Printf("[VIDEO UPLOAD] Failed to update post %s with video URLs: %v", post.ID, err)
-			return
-		}
+// CreatePostFromUploads handles post creation from completed resumable uploads (async)
+// POST /api/v1/posts/upload-resumable
+func (h *PostHandler) CreatePostFromUploads(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
 
-		log.Printf("[VIDEO UPLOAD] Post %s updated successfully with %d video(s)", updatedPost.ID, len(videoURLs))
+	if h.uploadService == nil || h.mediaJobService == nil {
+		util.ErrorResponse(c, http.StatusServiceUnavailable, "Media uploads are not available", nil)
+		return
+	}
 
-		if h.notificationService != nil {
-			_ = h.notificationService.SendPostUploadCompletedNotification(userID.(string), updatedPost.ID, len(videoURLs), "video")
-		}
-		if h.wsHub != nil {
-			h.wsHub.BroadcastToAll(map[string]interface{}{
-				"type":    "new_post",
-				"post_id": updatedPost.ID,
-			})
+	var req CreatePostFromUploadsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	if len(req.UploadIDs) == 0 {
+		util.BadRequest(c, "upload_ids is required")
+		return
+	}
+
+	uploads, err := h.uploadService.ConsumeUploads(userID.(string), req.UploadIDs)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	// Same placeholder trick as the multipart handlers: media URLs are filled in later
+	createContent := req.Content
+	if createContent == nil || *createContent == "" {
+		placeholder := " "
+		createContent = &placeholder
+	}
+	post, err := h.postService.CreatePost(userID.(string), service.CreatePostRequest{
+		Content:   createContent,
+		ImageURLs: []string{},
+		VideoURLs: []string{},
+		GroupID:   req.GroupID,
+	})
+	if err != nil {
+		for _, u := range uploads {
+			h.uploadService.ReleaseUpload(u)
 		}
-	}()
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	// Hand the uploaded files over to the media job queue; the upload records are no longer needed
+	err = h.mediaJobService.EnqueueUploads(post, uploads)
+	for _, u := range uploads {
+		h.uploadService.ReleaseUpload(u)
+	}
+	if err != nil {
+		log.Printf("[RESUMABLE UPLOAD] Failed to queue media for post %s: %v", post.ID, err)
+		h.discardPlaceholderPost(userID.(string), post.ID)
+		util.InternalServerError(c, "Failed to queue media for processing")
+		return
+	}
 
 	// Send initial WebSocket notification that upload is pending
 	if h.wsHub != nil {
 		h.wsHub.BroadcastToUser(userID.(string), map[string]interface{}{
 			"type":    "post_upload_pending",
 			"post_id": post.ID,
-			"message": "Post sedang diproses, video sedang diupload...",
+			"message": "Post sedang diproses, media sedang diupload...",
 			"status":  "pending",
 			"data": map[string]interface{}{
 				"post_id": post.ID,
@@ -769,13 +953,41 @@ func (h *PostHandler) CreatePostWithVideos(c *gin.Context) {
 		})
 	}
 
-	// Return response immediately (ASYNC)
-	util.SuccessResponse(c, http.StatusAccepted, "Post created and videos are being processed", gin.H{
+	util.SuccessResponse(c, http.StatusAccepted, "Post created and media is being processed", gin.H{
 		"post":   post,
 		"status": "processing",
 	})
 }
 
+// GetMediaStatus handles getting the media processing status of a post
+// GET /api/v1/posts/:id/media-status
+func (h *PostHandler) GetMediaStatus(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	postID := c.Param("id")
+	if postID == "" {
+		util.BadRequest(c, "Post ID is required")
+		return
+	}
+
+	if h.mediaJobService == nil {
+		util.ErrorResponse(c, http.StatusServiceUnavailable, "Media processing is not available", nil)
+		return
+	}
+
+	status, err := h.mediaJobService.GetMediaStatus(postID, userID.(string))
+	if err != nil {
+		util.NotFound(c, err.Error())
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Media status retrieved successfully", status)
+}
+
 // TrackView handles tracking a post view
 // POST /api/v1/posts/:id/view
 func (h *PostHandler) TrackView(c *gin.Context) {
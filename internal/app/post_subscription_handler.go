@@ -0,0 +1,81 @@
+package app
+
+import (
+	"net/http"
+
+	"yourapp/internal/service"
+	"yourapp/internal/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PostSubscriptionHandler lets users follow, unfollow, mute and unmute comment threads of posts
+type PostSubscriptionHandler struct {
+	subscriptionService service.PostSubscriptionService
+}
+
+func NewPostSubscriptionHandler(subscriptionService service.PostSubscriptionService) *PostSubscriptionHandler {
+	return &PostSubscriptionHandler{
+		subscriptionService: subscriptionService,
+	}
+}
+
+// GetSubscription handles getting the user's notification state for a post
+// GET /api/v1/posts/:id/subscription
+func (h *PostSubscriptionHandler) GetSubscription(c *gin.Context) {
+	h.handle(c, h.subscriptionService.GetSubscription, "Subscription retrieved successfully")
+}
+
+// FollowPost handles following a post's comment thread
+// POST /api/v1/posts/:id/follow
+func (h *PostSubscriptionHandler) FollowPost(c *gin.Context) {
+	h.handle(c, h.subscriptionService.FollowPost, "Post followed successfully")
+}
+
+// UnfollowPost handles unfollowing a post's comment thread
+// DELETE /api/v1/posts/:id/follow
+func (h *PostSubscriptionHandler) UnfollowPost(c *gin.Context) {
+	h.handle(c, h.subscriptionService.UnfollowPost, "Post unfollowed successfully")
+}
+
+// MutePost handles muting all comment notifications of a post
+// POST /api/v1/posts/:id/mute
+func (h *PostSubscriptionHandler) MutePost(c *gin.Context) {
+	h.handle(c, h.subscriptionService.MutePost, "Post muted successfully")
+}
+
+// UnmutePost handles unmuting comment notifications of a post
+// DELETE /api/v1/posts/:id/mute
+func (h *PostSubscriptionHandler) UnmutePost(c *gin.Context) {
+	h.handle(c, h.subscriptionService.UnmutePost, "Post unmuted successfully")
+}
+
+func (h *PostSubscriptionHandler) handle(
+	c *gin.Context,
+	action func(userID, postID string) (*service.PostSubscriptionStatus, error),
+	successMessage string,
+) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	postID := c.Param("id")
+	if postID == "" {
+		util.BadRequest(c, "Post ID is required")
+		return
+	}
+
+	status, err := action(userID.(string), postID)
+	if err != nil {
+		if err.Error() == "post not found" {
+			util.NotFound(c, err.Error())
+			return
+		}
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, successMessage, gin.H{"subscription": status})
+}
@@ -45,8 +45,19 @@ func NewRouter(cfg *config.Config) *gin.Engine {
 		panic("Failed to connect to database: " + err.Error())
 	}
 
+	// Stored comment reaction counters start at zero when the columns are first added
+	needsReactionBackfill := !db.Migrator().HasColumn(&model.Comment{}, "reaction_count")
+	// Same for the post/comment engagement counters (filled by the first reconciliation run)
+	needsCounterBackfill := !db.Migrator().HasColumn(&model.Post{}, "likes_count")
+	// Existing authors and commenters are subscribed when the subscriptions table is created
+	needsSubscriptionBackfill := !db.Migrator().HasTable(&model.PostSubscription{})
+	// Existing direct messages are grouped into conversations when the table is created
+	needsConversationBackfill := !db.Migrator().HasTable(&model.Conversation{})
+	// Messages from before delivery receipts count as delivered (and read, when read) when sent
+	needsReceiptBackfill := db.Migrator().HasTable(&model.ChatMessage{}) && !db.Migrator().HasColumn(&model.ChatMessage{}, "delivered_at")
+
 	// Auto migrate
-	if err := db.AutoMigrate(&model.User{}, &model.Profile{}, &model.Friendship{}, &model.Notification{}, &model.Post{}, &model.PostTag{}, &model.PostLocation{}, &model.Group{}, &model.GroupMember{}, &model.Comment{}, &model.Like{}, &model.PostView{}, &model.ChatMessage{}, &model.Payment{}, &model.RolePrice{}); err != nil {
+	if err := db.AutoMigrate(&model.User{}, &model.Profile{}, &model.Friendship{}, &model.Notification{}, &model.Post{}, &model.PostTag{}, &model.PostLocation{}, &model.Group{}, &model.GroupMember{}, &model.Comment{}, &model.Like{}, &model.PostView{}, &model.ChatMessage{}, &model.ChatAttachment{}, &model.ChatReaction{}, &model.ChatMessageDeletion{}, &model.ChatSettings{}, &model.Conversation{}, &model.ConversationParticipant{}, &model.Payment{}, &model.RolePrice{}, &model.MediaUpload{}, &model.MediaJob{}, &model.PostSubscription{}, &model.NotificationGroupActor{}, &model.NotificationDelivery{}, &model.NotificationPreference{}, &model.NotificationSettings{}, &model.WebPushSubscription{}); err != nil {
 		panic("Failed to migrate database: " + err.Error())
 	}
 
@@ -55,6 +66,20 @@ func NewRouter(cfg *config.Config) *gin.Engine {
 	// We need to drop any foreign key constraints on target_id since it can reference multiple tables
 	fixLikesTableConstraints(db)
 
+	if needsReactionBackfill {
+		backfillCommentReactionCounts(db)
+	}
+	if needsSubscriptionBackfill {
+		backfillPostSubscriptions(db)
+	}
+	if needsConversationBackfill {
+		backfillConversations(db)
+	}
+	if needsReceiptBackfill {
+		backfillChatReceipts(db)
+	}
+	createChatSearchIndex(db)
+
 	// Initialize Redis with retry logic
 	redisClient := initRedisWithRetry(cfg)
 
@@ -67,9 +92,16 @@ func NewRouter(cfg *config.Config) *gin.Engine {
 	commentRepo := repository.NewCommentRepository(db, redisClient)
 	likeRepo := repository.NewLikeRepository(db, redisClient)
 	chatRepo := repository.NewChatRepository(db)
+	conversationRepo := repository.NewConversationRepository(db)
 	groupRepo := repository.NewGroupRepository(db, redisClient)
 	paymentRepo := repository.NewPaymentRepository(db)
 	rolePriceRepo := repository.NewRolePriceRepository(db)
+	mediaUploadRepo := repository.NewMediaUploadRepository(db)
+	mediaJobRepo := repository.NewMediaJobRepository(db)
+	counterRepo := repository.NewCounterRepository(db)
+	postSubscriptionRepo := repository.NewPostSubscriptionRepository(db)
+	notificationPreferenceRepo := repository.NewNotificationPreferenceRepository(db)
+	webPushSubscriptionRepo := repository.NewWebPushSubscriptionRepository(db)
 
 	// Initialize RabbitMQ with retry logic
 	rabbitMQ := initRabbitMQWithRetry(cfg)
@@ -121,78 +153,150 @@ func NewRouter(cfg *config.Config) *gin.Engine {
 		log.Println("Tmp directory initialized successfully")
 	}
 
-	// Initialize Cloudinary client
-	var cloudinaryClient *util.CloudinaryClient
-	if cfg.CloudinaryCloudName != "" && cfg.CloudinaryAPIKey != "" && cfg.CloudinaryAPISecret != "" {
-		var err error
-		cloudinaryClient, err = util.NewCloudinaryClient(cfg)
+	// Initialize media store (Cloudinary, local disk or S3-compatible, see MEDIA_STORE_DRIVER)
+	mediaStore, err := util.NewMediaStore(cfg)
+	if err != nil {
+		log.Printf("Warning: Failed to initialize media store: %v. Falling back to local disk.", err)
+		localStore, localErr := util.NewLocalMediaStore(cfg.MediaLocalDir, cfg.MediaPublicURL, cfg.MediaSigningSecret)
+		if localErr != nil {
+			log.Printf("Warning: Failed to initialize local media store: %v. Media uploads will be disabled.", localErr)
+		} else {
+			mediaStore = localStore
+		}
+	}
+	if mediaStore != nil {
+		log.Printf("Media store initialized (driver: %s)", mediaStore.Driver())
+	}
+
+	// Initialize Web Push (VAPID); without keys subscriptions are stored but nothing is sent
+	var webPushClient *util.WebPushClient
+	if cfg.VAPIDPrivateKey != "" {
+		webPushClient, err = util.NewWebPushClient(cfg.VAPIDPublicKey, cfg.VAPIDPrivateKey, cfg.VAPIDSubject, cfg.WebPushAllowLoopback)
 		if err != nil {
-			log.Printf("Warning: Failed to initialize Cloudinary: %v. Image uploads will be disabled.", err)
+			log.Printf("Warning: Failed to initialize web push: %v. Web push is disabled.", err)
+			webPushClient = nil
 		} else {
-			log.Println("Cloudinary initialized successfully")
+			log.Println("Web push initialized")
 		}
-	} else {
-		log.Println("Cloudinary credentials not configured. Image uploads will be disabled.")
 	}
+	webPushService := service.NewWebPushService(webPushSubscriptionRepo, webPushClient, wsHub, cfg.WebPushAllowLoopback)
 
 	// Initialize services
 	authService := service.NewAuthServiceWithConfig(userRepo, cfg.JWTSecret, rabbitMQ, cfg)
 	profileService := service.NewProfileService(profileRepo, userRepo)
-	notificationService := service.NewNotificationService(notificationRepo, rabbitMQ)
+	notificationService := service.NewNotificationService(notificationRepo, notificationPreferenceRepo, userRepo, rabbitMQ, emailService, webPushService)
 	notificationService.SetWSHub(wsHub)
 	friendshipService := service.NewFriendshipService(friendshipRepo, userRepo, notificationService)
-	postService := service.NewPostService(postRepo, userRepo, friendshipRepo)
+	groupService := service.NewGroupService(groupRepo, userRepo)
+	postService := service.NewPostService(postRepo, userRepo, friendshipRepo, postSubscriptionRepo, groupService)
 	postViewRepo := repository.NewPostViewRepository(db, redisClient)
 	postViewService := service.NewPostViewService(postViewRepo, postRepo, userRepo)
-	commentService := service.NewCommentService(commentRepo, userRepo, postRepo, notificationService)
 	likeService := service.NewLikeService(likeRepo, userRepo, postRepo, commentRepo)
-	chatService := service.NewChatService(chatRepo, userRepo, friendshipRepo)
-	groupService := service.NewGroupService(groupRepo, userRepo)
+	commentService := service.NewCommentService(commentRepo, userRepo, postRepo, postSubscriptionRepo, likeService, notificationService)
+	postSubscriptionService := service.NewPostSubscriptionService(postSubscriptionRepo, postRepo)
+	chatService := service.NewChatService(chatRepo, conversationRepo, userRepo, friendshipRepo, webPushService, mediaStore, wsHub)
+	// Direct messages sent while a user was offline are delivered once they connect
+	wsHub.SetPresenceCallback(func(userID string, online bool) {
+		if !online {
+			return
+		}
+		go func() {
+			if err := chatService.MarkDelivered(userID); err != nil {
+				log.Printf("[CHAT] Failed to mark messages to user %s delivered: %v", userID, err)
+			}
+		}()
+	})
 	paymentService := service.NewPaymentService(paymentRepo, rolePriceRepo, userRepo, notificationService, cfg, wsHub)
 	rolePriceService := service.NewRolePriceService(rolePriceRepo)
+	uploadService := service.NewUploadService(mediaUploadRepo)
+	mediaJobService := service.NewMediaJobService(mediaJobRepo, postRepo, mediaStore)
 
-	// Initialize notification worker if RabbitMQ is available
-	// TODO: Re-enable RabbitMQ worker later for async processing
-	/*
-		if rabbitMQ != nil {
-			notificationWorker := service.NewNotificationWorker(notificationService, rabbitMQ, wsHub)
-			if err := notificationWorker.Start(); err != nil {
-				log.Printf("Warning: Failed to start notification worker: %v", err)
-			} else {
-				log.Println("Notification worker started successfully")
+	// Start media worker (processes queued post images/videos with retries)
+	if mediaStore != nil {
+		mediaWorker := service.NewMediaWorker(mediaJobRepo, postRepo, postService, notificationService, mediaStore, wsHub)
+		mediaWorker.Start()
+	} else {
+		log.Println("Media worker not started - no media store available. Media jobs will stay queued.")
+	}
+
+	// Keep denormalized like/comment/view/share counters honest
+	counterReconciler := service.NewCounterReconciler(counterRepo)
+	if needsCounterBackfill {
+		if _, err := counterReconciler.Reconcile(); err != nil {
+			log.Printf("Warning: Failed to backfill engagement counters: %v", err)
+		}
+	}
+	counterReconciler.Start()
+
+	// Email digest of unread activity for users who have not been around
+	digestService := service.NewDigestService(notificationPreferenceRepo, notificationRepo, conversationRepo, friendshipRepo, postRepo, userRepo, rabbitMQ, emailService, wsHub, cfg)
+	digestService.Start()
+
+	// Periodically remove abandoned resumable uploads from the tmp directory and chat
+	// attachments that were never sent
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if n, err := uploadService.CleanupExpired(); err != nil {
+				log.Printf("Warning: Failed to clean up expired uploads: %v", err)
+			} else if n > 0 {
+				log.Printf("Cleaned up %d expired upload(s)", n)
+			}
+			if n, err := chatService.CleanupAttachments(); err != nil {
+				log.Printf("Warning: Failed to clean up unsent chat attachments: %v", err)
+			} else if n > 0 {
+				log.Printf("Cleaned up %d unsent chat attachment(s)", n)
 			}
 		}
-	*/
-	// For now, notifications are sent directly via WebSocket (no RabbitMQ)
+	}()
+
+	// Delete disappearing chat messages once they expire, and messages older than the
+	// retention period
+	go func() {
+		retention := time.Duration(cfg.ChatRetentionDays) * 24 * time.Hour
+		ticker := time.NewTicker(1 * time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			if n, err := chatService.ExpireMessages(retention); err != nil {
+				log.Printf("Warning: Failed to delete expired chat messages: %v", err)
+			} else if n > 0 {
+				log.Printf("Deleted %d expired chat message(s)", n)
+			}
+		}
+	}()
+
+	// Queue notifications on RabbitMQ for the notification worker. Without a broker, or when
+	// publishing is not confirmed, they are delivered synchronously by the caller.
+	if rabbitMQ != nil {
+		notificationWorker := service.NewNotificationWorker(notificationService, rabbitMQ, cfg.NotificationWorkerConcurrency)
+		if err := notificationWorker.Start(); err != nil {
+			log.Printf("Warning: Failed to start notification worker: %v. Notifications will be delivered synchronously.", err)
+		} else {
+			notificationService.SetQueueEnabled(true)
+		}
+	}
 
 	// Initialize handlers (auth with WS so admin gets real-time new_user)
 	authHandler := NewAuthHandlerWithWS(authService, cfg.JWTSecret, wsHub)
 	userHandler := NewUserHandler(userRepo, cfg.JWTSecret, wsHub, notificationService)
 	profileHandler := NewProfileHandler(profileService, cfg.JWTSecret)
 	friendshipHandler := NewFriendshipHandler(friendshipService, cfg.JWTSecret)
-	notificationHandler := NewNotificationHandler(notificationService, cfg.JWTSecret)
-
-	// Initialize post handler with Cloudinary if available
-	var postHandler *PostHandler
-	if cloudinaryClient != nil {
-		postHandler = NewPostHandlerWithCloudinary(postService, postViewService, notificationService, cloudinaryClient, wsHub, likeService, commentService, cfg.JWTSecret)
-	} else {
-		// Create a simple post handler without Cloudinary but with view service and engagement enrichment
-		postHandler = &PostHandler{
-			postService:     postService,
-			postViewService: postViewService,
-			likeService:     likeService,
-			commentService: commentService,
-			jwtSecret:       cfg.JWTSecret,
-		}
-	}
+	notificationPreferenceService := service.NewNotificationPreferenceService(notificationPreferenceRepo, cfg.DigestUnsubscribeSecret)
+	notificationHandler := NewNotificationHandler(notificationService, notificationPreferenceService, cfg.JWTSecret)
 
+	postHandler := NewPostHandlerWithMedia(postService, postViewService, notificationService, wsHub, likeService, commentService, uploadService, mediaJobService, cfg.JWTSecret)
 	commentHandler := NewCommentHandler(commentService, cfg.JWTSecret)
+	postSubscriptionHandler := NewPostSubscriptionHandler(postSubscriptionService)
 	likeHandler := NewLikeHandlerWithNotification(likeService, notificationService, postService, userRepo, cfg.JWTSecret)
-	chatHandler := NewChatHandler(chatService, wsHub)
-	groupHandler := NewGroupHandler(groupService, cloudinaryClient, cfg.JWTSecret)
+	chatHandler := NewChatHandler(chatService)
+	chatHandler.RegisterSocketHandlers(wsHub)
+	webPushHandler := NewWebPushHandler(webPushService)
+	groupHandler := NewGroupHandler(groupService, mediaStore, cfg.JWTSecret)
 	paymentHandler := NewPaymentHandler(paymentService)
 	rolePriceHandler := NewRolePriceHandler(rolePriceService)
+	uploadHandler := NewUploadHandler(uploadService)
+	counterHandler := NewCounterHandler(counterReconciler)
 
 	// API routes
 	api := r.Group("/api/v1")
@@ -246,6 +350,7 @@ func NewRouter(cfg *config.Config) *gin.Engine {
 				admin.POST("/users/:id/ban", userHandler.BanUser)
 				admin.POST("/users/:id/unban", userHandler.UnbanUser)
 				admin.PUT("/users/:id/role", userHandler.UpdateUserRole)
+				admin.POST("/counters/reconcile", counterHandler.ReconcileCounters)
 				// Role prices CRUD (admin only)
 				admin.POST("/role-prices", rolePriceHandler.CreateRolePrice)
 				admin.PUT("/role-prices/:id", rolePriceHandler.UpdateRolePrice)
@@ -293,6 +398,10 @@ func NewRouter(cfg *config.Config) *gin.Engine {
 		// Notification routes
 		notifications := api.Group("/notifications")
 		{
+			// Public routes (signed one-click unsubscribe link of digest emails)
+			notifications.GET("/digest/unsubscribe", notificationHandler.ConfirmDigestUnsubscribe)
+			notifications.POST("/digest/unsubscribe", notificationHandler.UnsubscribeDigest)
+
 			// Protected routes
 			notifications.Use(authHandler.AuthMiddleware())
 			{
@@ -301,6 +410,8 @@ func NewRouter(cfg *config.Config) *gin.Engine {
 				notifications.GET("/unread/count", notificationHandler.GetUnreadCount)
 				notifications.PUT("/:id/read", notificationHandler.MarkAsRead)
 				notifications.PUT("/read-all", notificationHandler.MarkAllAsRead)
+				notifications.GET("/preferences", notificationHandler.GetPreferences)
+				notifications.PUT("/preferences", notificationHandler.UpdatePreferences)
 				notifications.DELETE("/:id", notificationHandler.DeleteNotification)
 			}
 		}
@@ -312,6 +423,7 @@ func NewRouter(cfg *config.Config) *gin.Engine {
 			// IMPORTANT: More specific routes must be registered before wildcard routes
 			posts.GET("/user/:userID", postHandler.GetPostsByUserID)
 			posts.GET("/user/:userID/count", postHandler.CountPostsByUserID)
+			posts.GET("/user/:userID/pins", postHandler.GetProfilePins)
 			posts.GET("/group/:groupID", postHandler.GetPostsByGroupID)
 			posts.GET("/group/:groupID/count", postHandler.CountPostsByGroupID)
 
@@ -332,17 +444,44 @@ func NewRouter(cfg *config.Config) *gin.Engine {
 				posts.POST("", postHandler.CreatePost)
 				posts.POST("/upload", postHandler.CreatePostWithImages)       // Async image upload
 			posts.POST("/upload-video", postHandler.CreatePostWithVideos) // Async video upload
+				posts.POST("/upload-resumable", postHandler.CreatePostFromUploads) // Attach completed resumable uploads
 				posts.GET("/feed", postHandler.GetFeed)
 				posts.PUT("/:id", postHandler.UpdatePost)
 				posts.DELETE("/:id", postHandler.DeletePost)
 				posts.POST("/:id/view", postHandler.TrackView) // Track post view
+				posts.GET("/:id/media-status", postHandler.GetMediaStatus)
+
+				// Pinning (profile pins by author, group pins by group admins/moderators)
+				posts.PUT("/pins", postHandler.ReorderProfilePins)
+				posts.POST("/:id/pin", postHandler.PinToProfile)
+				posts.DELETE("/:id/pin", postHandler.UnpinFromProfile)
+				posts.POST("/:id/group-pin", postHandler.PinInGroup)
+				posts.DELETE("/:id/group-pin", postHandler.UnpinInGroup)
 
 				// Post likes
 				posts.POST("/:id/like", likeHandler.LikePost)
 				posts.DELETE("/:id/like", likeHandler.UnlikePost)
+
+				// Comment thread notifications (follow/unfollow, per-post mute)
+				posts.GET("/:id/subscription", postSubscriptionHandler.GetSubscription)
+				posts.POST("/:id/follow", postSubscriptionHandler.FollowPost)
+				posts.DELETE("/:id/follow", postSubscriptionHandler.UnfollowPost)
+				posts.POST("/:id/mute", postSubscriptionHandler.MutePost)
+				posts.DELETE("/:id/mute", postSubscriptionHandler.UnmutePost)
 			}
 		}
 
+		// Resumable upload routes (chunked, tus-style)
+		uploads := api.Group("/uploads")
+		uploads.Use(authHandler.AuthMiddleware())
+		{
+			uploads.POST("", uploadHandler.CreateUpload)
+			uploads.HEAD("/:id", uploadHandler.HeadUpload)
+			uploads.GET("/:id", uploadHandler.GetUpload)
+			uploads.PATCH("/:id", uploadHandler.PatchUpload)
+			uploads.DELETE("/:id", uploadHandler.DeleteUpload)
+		}
+
 		// Comment routes
 		comments := api.Group("/comments")
 		{
@@ -356,6 +495,8 @@ func NewRouter(cfg *config.Config) *gin.Engine {
 				comments.POST("", commentHandler.CreateComment)
 				comments.PUT("/:id", commentHandler.UpdateComment)
 				comments.DELETE("/:id", commentHandler.DeleteComment)
+				comments.POST("/:id/pin", commentHandler.PinComment)
+				comments.DELETE("/:id/pin", commentHandler.UnpinComment)
 
 				// Comment likes
 				comments.POST("/:id/like", likeHandler.LikeComment)
@@ -377,9 +518,55 @@ func NewRouter(cfg *config.Config) *gin.Engine {
 		{
 			chat.POST("/messages", chatHandler.SendMessage)
 			chat.GET("/messages", chatHandler.GetConversation)
+			chat.PUT("/messages/:id", chatHandler.EditMessage)
+			chat.DELETE("/messages/:id", chatHandler.DeleteMessage)
+			chat.POST("/messages/:id/unsend", chatHandler.UnsendMessage)
+			chat.POST("/messages/:id/forward", chatHandler.ForwardMessage)
+			chat.GET("/messages/:id/context", chatHandler.GetMessageContext)
+			chat.POST("/messages/:id/reaction", chatHandler.ReactToMessage)
+			chat.DELETE("/messages/:id/reaction", chatHandler.RemoveReaction)
 			chat.PUT("/read/:senderID", chatHandler.MarkAsRead)
 			chat.GET("/unread/by-senders", chatHandler.GetUnreadCountBySenders)
 			chat.GET("/unread/count", chatHandler.GetUnreadCount)
+			chat.GET("/search", chatHandler.SearchMessages)
+			chat.GET("/settings", chatHandler.GetChatSettings)
+			chat.PUT("/settings", chatHandler.UpdateChatSettings)
+			chat.GET("/requests", chatHandler.GetMessageRequests)
+			chat.POST("/requests/:id/accept", chatHandler.AcceptMessageRequest)
+			chat.POST("/requests/:id/decline", chatHandler.DeclineMessageRequest)
+			chat.POST("/requests/:id/block", chatHandler.BlockMessageRequest)
+			chat.POST("/attachments", chatHandler.UploadAttachment)
+			chat.GET("/attachments/:id", chatHandler.GetAttachment)
+			chat.GET("/conversations", chatHandler.GetConversations)
+			chat.POST("/conversations", chatHandler.CreateGroup)
+			chat.GET("/conversations/:id", chatHandler.GetConversationByID)
+			chat.PUT("/conversations/:id", chatHandler.UpdateGroup)
+			chat.GET("/conversations/:id/messages", chatHandler.GetConversationMessages)
+			chat.POST("/conversations/:id/messages", chatHandler.SendConversationMessage)
+			chat.POST("/conversations/:id/members", chatHandler.AddGroupMembers)
+			chat.DELETE("/conversations/:id/members/:userID", chatHandler.RemoveGroupMember)
+			chat.PUT("/conversations/:id/members/:userID/role", chatHandler.SetGroupMemberRole)
+			chat.POST("/conversations/:id/leave", chatHandler.LeaveGroup)
+			chat.PUT("/conversations/:id/read", chatHandler.MarkConversationRead)
+			chat.PUT("/conversations/:id/archive", chatHandler.ArchiveConversation)
+			chat.PUT("/conversations/:id/mute", chatHandler.MuteConversation)
+			chat.PUT("/conversations/:id/pin", chatHandler.PinConversation)
+			chat.PUT("/conversations/:id/disappearing", chatHandler.SetDisappearingTimer)
+		}
+
+		// Web Push routes
+		push := api.Group("/push")
+		{
+			// Public routes
+			push.GET("/vapid-public-key", webPushHandler.GetPublicKey)
+
+			// Protected routes
+			push.Use(authHandler.AuthMiddleware())
+			{
+				push.GET("/subscriptions", webPushHandler.GetSubscriptions)
+				push.POST("/subscriptions", webPushHandler.Subscribe)
+				push.DELETE("/subscriptions", webPushHandler.Unsubscribe)
+			}
 		}
 
 		// Payment routes
@@ -434,6 +621,12 @@ func NewRouter(cfg *config.Config) *gin.Engine {
 		websocket.ServeWS(wsHub, cfg.JWTSecret).ServeHTTP(c.Writer, c.Request)
 	})
 
+	// Local media store files (signed URLs are verified for private keys)
+	if localStore, ok := mediaStore.(*util.LocalMediaStore); ok {
+		r.GET("/media/*key", localStore.ServeMedia)
+		r.HEAD("/media/*key", localStore.ServeMedia)
+	}
+
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
@@ -536,6 +729,120 @@ func initTmpDir() error {
 	return os.MkdirAll(tmpDir, 0755)
 }
 
+// backfillCommentReactionCounts fills the stored comment reaction counters from the likes table
+func backfillCommentReactionCounts(db *gorm.DB) {
+	query := `
+		UPDATE comments c
+		SET reaction_count = l.total, negative_reaction_count = l.negative
+		FROM (
+			SELECT target_id, COUNT(*) AS total,
+				COUNT(*) FILTER (WHERE reaction IN ?) AS negative
+			FROM likes
+			WHERE target_type = ?
+			GROUP BY target_id
+		) l
+		WHERE c.id = l.target_id
+	`
+	result := db.Exec(query, []string{model.ReactionSad, model.ReactionAngry}, model.TargetTypeComment)
+	if result.Error != nil {
+		log.Printf("Warning: Failed to backfill comment reaction counts: %v", result.Error)
+		return
+	}
+	log.Printf("Backfilled reaction counts for %d comment(s)", result.RowsAffected)
+}
+
+// backfillChatReceipts marks existing messages delivered, and read ones read, at their send time
+func backfillChatReceipts(db *gorm.DB) {
+	query := `
+		UPDATE chat_messages
+		SET delivered_at = created_at,
+			read_at = CASE WHEN is_read THEN created_at END
+		WHERE delivered_at IS NULL
+	`
+	result := db.Exec(query)
+	if result.Error != nil {
+		log.Printf("Warning: Failed to backfill chat receipts: %v", result.Error)
+		return
+	}
+	log.Printf("Backfilled receipts for %d chat message(s)", result.RowsAffected)
+}
+
+// createChatSearchIndex creates the full-text index used by chat search. GORM index tags cannot
+// express it, so it is created here; it must match the expression in chatRepository.Search.
+func createChatSearchIndex(db *gorm.DB) {
+	query := `
+		CREATE INDEX IF NOT EXISTS idx_chat_messages_content_search
+		ON chat_messages USING GIN (to_tsvector('simple', content))
+	`
+	if err := db.Exec(query).Error; err != nil {
+		log.Printf("Warning: Failed to create chat search index: %v", err)
+	}
+}
+
+// backfillPostSubscriptions subscribes the authors and commenters of existing posts
+func backfillPostSubscriptions(db *gorm.DB) {
+	query := `
+		INSERT INTO post_subscriptions (id, post_id, user_id, reason, muted, created_at, updated_at)
+		SELECT gen_random_uuid(), s.post_id, s.user_id, MIN(s.reason), false, NOW(), NOW()
+		FROM (
+			SELECT id AS post_id, user_id, ? AS reason FROM posts WHERE deleted_at IS NULL
+			UNION ALL
+			SELECT post_id, user_id, ? AS reason FROM comments WHERE deleted_at IS NULL
+		) s
+		GROUP BY s.post_id, s.user_id
+		ON CONFLICT (post_id, user_id) DO NOTHING
+	`
+	result := db.Exec(query, model.PostSubscriptionReasonAuthor, model.PostSubscriptionReasonCommented)
+	if result.Error != nil {
+		log.Printf("Warning: Failed to backfill post subscriptions: %v", result.Error)
+		return
+	}
+	log.Printf("Backfilled %d post subscription(s)", result.RowsAffected)
+}
+
+// backfillConversations creates a direct conversation for every pair of users who exchanged
+// messages, links the messages to it and fills the last message and unread counts
+func backfillConversations(db *gorm.DB) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		steps := []string{
+			`INSERT INTO conversations (id, type, direct_key, last_activity_at, created_at, updated_at)
+			SELECT gen_random_uuid(), 'direct', LEAST(sender_id::text, receiver_id::text) || ':' || GREATEST(sender_id::text, receiver_id::text),
+				MAX(created_at), MIN(created_at), NOW()
+			FROM chat_messages
+			GROUP BY 3
+			ON CONFLICT (direct_key) DO NOTHING`,
+			`UPDATE chat_messages m SET conversation_id = c.id
+			FROM conversations c
+			WHERE m.conversation_id IS NULL
+				AND c.direct_key = LEAST(m.sender_id::text, m.receiver_id::text) || ':' || GREATEST(m.sender_id::text, m.receiver_id::text)`,
+			`UPDATE conversations c SET last_message_id = (
+				SELECT id FROM chat_messages m
+				WHERE m.conversation_id = c.id AND m.deleted_at IS NULL
+				ORDER BY m.created_at DESC LIMIT 1
+			)`,
+			`INSERT INTO conversation_participants (conversation_id, user_id, unread_count, muted, created_at, updated_at)
+			SELECT c.id, p.user_id::uuid,
+				(SELECT COUNT(*) FROM chat_messages m
+				WHERE m.conversation_id = c.id AND m.receiver_id::text = p.user_id AND NOT m.is_read AND m.deleted_at IS NULL),
+				false, c.created_at, NOW()
+			FROM conversations c
+			CROSS JOIN LATERAL unnest(string_to_array(c.direct_key, ':')) AS p(user_id)
+			ON CONFLICT DO NOTHING`,
+		}
+		for _, step := range steps {
+			if err := tx.Exec(step).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to backfill conversations: %v", err)
+		return
+	}
+	log.Println("Backfilled chat conversations")
+}
+
 // fixLikesTableConstraints removes incorrect foreign key constraints from the likes table
 // Since likes.target_id is polymorphic (can reference posts or comments), we cannot have
 // a foreign key constraint on it. GORM may create incorrect constraints during AutoMigrate.
@@ -619,8 +926,9 @@ func corsMiddleware(clientURL string) gin.HandlerFunc {
 		}
 
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Upload-Offset, Upload-Length")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH, HEAD")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Location, Upload-Offset, Upload-Length")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
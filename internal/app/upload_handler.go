@@ -0,0 +1,155 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"yourapp/internal/model"
+	"yourapp/internal/service"
+	"yourapp/internal/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UploadHandler implements resumable (tus-style) chunked uploads.
+// Flow: POST /uploads -> PATCH /uploads/:id with Upload-Offset until complete -> attach via POST /posts/upload-resumable.
+// A client that lost its connection can HEAD /uploads/:id to learn the committed offset and resume from there.
+type UploadHandler struct {
+	uploadService service.UploadService
+}
+
+func NewUploadHandler(uploadService service.UploadService) *UploadHandler {
+	return &UploadHandler{
+		uploadService: uploadService,
+	}
+}
+
+// CreateUpload handles creating a new resumable upload
+// POST /api/v1/uploads
+func (h *UploadHandler) CreateUpload(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req service.CreateUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	upload, err := h.uploadService.CreateUpload(userID.(string), req)
+	if err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	c.Header("Location", "/api/v1/uploads/"+upload.ID)
+	setUploadHeaders(c, upload)
+	util.SuccessResponse(c, http.StatusCreated, "Upload created successfully", gin.H{
+		"upload":     upload,
+		"chunk_size": service.MaxUploadChunkSize,
+	})
+}
+
+// GetUpload handles getting upload progress
+// GET /api/v1/uploads/:id
+func (h *UploadHandler) GetUpload(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	upload, err := h.uploadService.GetUpload(userID.(string), c.Param("id"))
+	if err != nil {
+		util.NotFound(c, err.Error())
+		return
+	}
+
+	setUploadHeaders(c, upload)
+	util.SuccessResponse(c, http.StatusOK, "Upload retrieved successfully", gin.H{"upload": upload})
+}
+
+// HeadUpload returns the committed offset in headers only
+// HEAD /api/v1/uploads/:id
+func (h *UploadHandler) HeadUpload(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+
+	upload, err := h.uploadService.GetUpload(userID.(string), c.Param("id"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	setUploadHeaders(c, upload)
+	c.Status(http.StatusOK)
+}
+
+// PatchUpload handles appending a chunk to an upload
+// PATCH /api/v1/uploads/:id
+// Headers: Upload-Offset (required), body: raw chunk bytes (application/offset+octet-stream)
+func (h *UploadHandler) PatchUpload(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		util.BadRequest(c, "Invalid or missing Upload-Offset header")
+		return
+	}
+
+	if c.Request.ContentLength > service.MaxUploadChunkSize {
+		util.ErrorResponse(c, http.StatusRequestEntityTooLarge, "Chunk exceeds maximum size", nil)
+		return
+	}
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, service.MaxUploadChunkSize)
+
+	upload, err := h.uploadService.AppendChunk(userID.(string), c.Param("id"), offset, body)
+	if err != nil {
+		if errors.Is(err, service.ErrUploadOffsetMismatch) {
+			setUploadHeaders(c, upload)
+			util.ErrorResponse(c, http.StatusConflict, "Upload-Offset does not match current offset", gin.H{
+				"offset": upload.Offset,
+			})
+			return
+		}
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	setUploadHeaders(c, upload)
+	util.SuccessResponse(c, http.StatusOK, "Chunk uploaded successfully", gin.H{"upload": upload})
+}
+
+// DeleteUpload handles aborting an upload
+// DELETE /api/v1/uploads/:id
+func (h *UploadHandler) DeleteUpload(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.uploadService.AbortUpload(userID.(string), c.Param("id")); err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Upload deleted successfully", nil)
+}
+
+func setUploadHeaders(c *gin.Context, upload *model.MediaUpload) {
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Size, 10))
+}
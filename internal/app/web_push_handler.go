@@ -0,0 +1,100 @@
+package app
+
+import (
+	"net/http"
+
+	"yourapp/internal/service"
+	"yourapp/internal/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebPushHandler manages the Web Push subscriptions of the current user's devices
+type WebPushHandler struct {
+	webPushService service.WebPushService
+}
+
+func NewWebPushHandler(webPushService service.WebPushService) *WebPushHandler {
+	return &WebPushHandler{
+		webPushService: webPushService,
+	}
+}
+
+// GetPublicKey returns the VAPID public key the browser needs to subscribe
+// GET /api/v1/push/vapid-public-key
+func (h *WebPushHandler) GetPublicKey(c *gin.Context) {
+	if !h.webPushService.Enabled() {
+		util.ErrorResponse(c, http.StatusServiceUnavailable, "Web push is not configured", nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "VAPID public key retrieved successfully", gin.H{
+		"public_key": h.webPushService.PublicKey(),
+	})
+}
+
+// GetSubscriptions lists the devices registered for push
+// GET /api/v1/push/subscriptions
+func (h *WebPushHandler) GetSubscriptions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	subs, err := h.webPushService.GetSubscriptions(userID.(string))
+	if err != nil {
+		util.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Push subscriptions retrieved successfully", gin.H{"subscriptions": subs})
+}
+
+// Subscribe registers the browser's PushSubscription for the current user
+// POST /api/v1/push/subscriptions
+func (h *WebPushHandler) Subscribe(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req service.WebPushSubscribeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	if err := h.webPushService.Subscribe(userID.(string), &req, c.Request.UserAgent()); err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusCreated, "Push subscription saved", nil)
+}
+
+// Unsubscribe removes a device
+// DELETE /api/v1/push/subscriptions
+func (h *WebPushHandler) Unsubscribe(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		util.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req struct {
+		Endpoint string `json:"endpoint" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	if err := h.webPushService.Unsubscribe(userID.(string), req.Endpoint); err != nil {
+		util.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	util.SuccessResponse(c, http.StatusOK, "Push subscription removed", nil)
+}
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 
@@ -12,6 +15,7 @@ type Config struct {
 	ServerPort string
 	ServerHost string
 	ClientURL  string
+	APIURL     string // Public base URL of this API, used for links in emails (e.g. unsubscribe)
 
 	// Database
 	PostgresHost     string
@@ -40,6 +44,12 @@ type Config struct {
 	RabbitMQUser     string
 	RabbitMQPassword string
 
+	// Number of notification messages delivered concurrently (also the consumer prefetch)
+	NotificationWorkerConcurrency int
+
+	// Chat messages older than this many days are deleted for everyone (0 = keep forever)
+	ChatRetentionDays int
+
 	// Email
 	EmailFrom    string
 	EmailName    string // Custom sender name (e.g., "Zacode")
@@ -59,6 +69,31 @@ type Config struct {
 	CloudinaryAPISecret string
 	CloudinaryFolder    string
 
+	// Media storage
+	MediaStoreDriver   string // cloudinary, local, s3 (empty = cloudinary if configured, otherwise local)
+	MediaLocalDir      string // Root directory for the local driver
+	MediaPublicURL     string // Public base URL of the local driver's static route
+	MediaSigningSecret string // HMAC secret for local signed URLs (defaults to a key derived from the JWT secret)
+
+	// S3-compatible storage (AWS S3, MinIO, R2, ...)
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3PublicURL       string // Optional CDN/base URL for public objects
+	S3ForcePathStyle  bool
+
+	// Web Push (VAPID keys are base64url: raw 32-byte private scalar, 65-byte uncompressed public point)
+	VAPIDPublicKey  string
+	VAPIDPrivateKey string
+	VAPIDSubject    string // mailto: or https: contact for push services
+	// HMAC secret of the unsubscribe links in digest emails (defaults to a key derived from the JWT secret)
+	DigestUnsubscribeSecret string
+
+	// Development only: accept push endpoints on loopback hosts (a local stand-in push service)
+	WebPushAllowLoopback bool
+
 	// Midtrans
 	MidtransServerKey string
 	MidtransClientKey string
@@ -75,6 +110,7 @@ func Load() (*Config, error) {
 		ServerPort: getEnv("PORT", "5000"),
 		ServerHost: getEnv("SERVER_HOST", "0.0.0.0"),
 		ClientURL:  getEnv("CLIENT_URL", "http://localhost:3000"),
+		APIURL:     getEnv("API_URL", ""),
 
 		// Database
 		PostgresHost:     getEnv("POSTGRES_HOST", "localhost"),
@@ -103,6 +139,11 @@ func Load() (*Config, error) {
 		RabbitMQUser:     getEnv("RABBITMQ_USER", "guest"),
 		RabbitMQPassword: getEnv("RABBITMQ_PASSWORD", "guest"),
 
+		NotificationWorkerConcurrency: getEnvInt("NOTIFICATION_WORKER_CONCURRENCY", 4),
+
+		// Chat
+		ChatRetentionDays: getEnvInt("CHAT_RETENTION_DAYS", 0),
+
 		// Email
 		EmailFrom:    getEnv("EMAIL_FROM", ""),
 		EmailName:    getEnv("EMAIL_NAME", "Zacode"),
@@ -122,6 +163,30 @@ func Load() (*Config, error) {
 		CloudinaryAPISecret: getEnv("CLOUDINARY_API_SECRET", ""),
 		CloudinaryFolder:    getEnv("CLOUDINARY_FOLDER", "social-media"),
 
+		// Media storage
+		MediaStoreDriver:   getEnv("MEDIA_STORE_DRIVER", ""),
+		MediaLocalDir:      getEnv("MEDIA_LOCAL_DIR", "media"),
+		MediaPublicURL:     getEnv("MEDIA_PUBLIC_URL", ""),
+		MediaSigningSecret: getEnv("MEDIA_SIGNING_SECRET", ""),
+
+		// S3-compatible storage
+		S3Endpoint:        getEnv("S3_ENDPOINT", ""),
+		S3Region:          getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:          getEnv("S3_BUCKET", ""),
+		S3AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+		S3PublicURL:       getEnv("S3_PUBLIC_URL", ""),
+		S3ForcePathStyle:  getEnvBool("S3_FORCE_PATH_STYLE", false),
+
+		// Web Push
+		VAPIDPublicKey:  getEnv("VAPID_PUBLIC_KEY", ""),
+		VAPIDPrivateKey: getEnv("VAPID_PRIVATE_KEY", ""),
+		VAPIDSubject:    getEnv("VAPID_SUBJECT", ""),
+
+		WebPushAllowLoopback: getEnvBool("WEB_PUSH_ALLOW_LOOPBACK", false),
+
+		DigestUnsubscribeSecret: getEnv("DIGEST_UNSUBSCRIBE_SECRET", ""),
+
 		// Midtrans
 		MidtransServerKey: getEnv("MIDTRANS_SERVER_KEY", ""),
 		MidtransClientKey: getEnv("MIDTRANS_CLIENT_KEY", ""),
@@ -134,6 +199,22 @@ func Load() (*Config, error) {
 		cfg.FrontendURL = cfg.ClientURL
 	}
 
+	// Fallback API URL for links in emails
+	if cfg.APIURL == "" {
+		cfg.APIURL = fmt.Sprintf("http://localhost:%s", cfg.ServerPort)
+	}
+
+	// Fallback media settings for the local driver
+	if cfg.MediaPublicURL == "" {
+		cfg.MediaPublicURL = fmt.Sprintf("http://localhost:%s/media", cfg.ServerPort)
+	}
+	if cfg.MediaSigningSecret == "" {
+		cfg.MediaSigningSecret = deriveSecret(cfg.JWTSecret, "media")
+	}
+	if cfg.DigestUnsubscribeSecret == "" {
+		cfg.DigestUnsubscribeSecret = deriveSecret(cfg.JWTSecret, "digest-unsubscribe")
+	}
+
 	// Build database URL if not provided
 	if cfg.DatabaseURL == "" {
 		cfg.DatabaseURL = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
@@ -154,6 +235,14 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// deriveSecret derives a separate key for purpose from secret, so a leaked derived key or
+// signature cannot be used to forge values signed with secret itself
+func deriveSecret(secret, purpose string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
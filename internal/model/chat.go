@@ -7,19 +7,109 @@ import (
 	"gorm.io/gorm"
 )
 
-// ChatMessage represents a direct message between two users
+// Chat message types
+const (
+	ChatMessageTypeText   = "text"
+	ChatMessageTypeImage  = "image" // Only image attachments, with an optional caption
+	ChatMessageTypeFile   = "file"
+	ChatMessageTypeAudio  = "audio"  // A voice note
+	ChatMessageTypeSystem = "system" // Group change written by the server; Metadata["event"] tells which
+)
+
+// Chat attachment kinds
+const (
+	ChatAttachmentImage = "image"
+	ChatAttachmentFile  = "file"
+	ChatAttachmentAudio = "audio"
+)
+
+// System message events (ChatMessage.Metadata["event"])
+const (
+	ChatEventGroupCreated  = "group_created"
+	ChatEventGroupUpdated  = "group_updated"
+	ChatEventMembersAdded  = "members_added"
+	ChatEventMemberRemoved = "member_removed"
+	ChatEventMemberLeft    = "member_left"
+	ChatEventRoleChanged   = "role_changed"
+	ChatEventTimerChanged  = "disappearing_timer_changed" // Metadata["disappear_after"] is the new timer
+)
+
+// ChatMessage represents a message in a conversation. Direct messages also name their receiver;
+// group messages have no receiver.
 type ChatMessage struct {
-	ID        string         `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	SenderID  string         `gorm:"type:uuid;not null;index" json:"sender_id"`
-	ReceiverID string        `gorm:"type:uuid;not null;index" json:"receiver_id"`
-	Content   string         `gorm:"type:text;not null" json:"content"`
-	IsRead    bool           `gorm:"default:false" json:"is_read"`
-	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID               string                 `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	SenderID         string                 `gorm:"type:uuid;not null;index;uniqueIndex:idx_chat_messages_sender_client_id,priority:1" json:"sender_id"`
+	ReceiverID       *string                `gorm:"type:uuid;index" json:"receiver_id,omitempty"`
+	ConversationID   *string                `gorm:"type:uuid;index" json:"conversation_id,omitempty"`
+	ClientMessageID  *string                `gorm:"type:varchar(64);uniqueIndex:idx_chat_messages_sender_client_id,priority:2" json:"client_id,omitempty"` // Sender's idempotency key
+	MessageType      string                 `gorm:"type:varchar(20);not null;default:'text'" json:"message_type"`
+	Content          string                 `gorm:"type:text;not null" json:"content"`
+	Metadata         map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"metadata,omitempty"` // System messages only
+	IsRead           bool                   `gorm:"default:false" json:"is_read"`                         // Direct messages only, see ConversationParticipant
+	DeliveredAt      *time.Time             `json:"delivered_at,omitempty"`                               // Direct messages only
+	ReadAt           *time.Time             `json:"read_at,omitempty"`                                    // Direct messages only, unless the receiver hides read receipts
+	EditedAt         *time.Time             `json:"edited_at,omitempty"`
+	UnsentAt         *time.Time             `json:"unsent_at,omitempty"`                                  // Unsent for everyone; the message is kept as a tombstone without content
+	ReplyToMessageID *string                `gorm:"type:uuid;index" json:"reply_to_message_id,omitempty"` // Quoted message of the same conversation
+	IsForwarded      bool                   `gorm:"not null;default:false" json:"forwarded"`
+	ExpiresAt        *time.Time             `gorm:"index" json:"expires_at,omitempty"` // Disappearing messages only; deleted for everyone after this time
+	CreatedAt        time.Time              `gorm:"autoCreateTime;index" json:"created_at"`
+	DeletedAt        gorm.DeletedAt         `gorm:"index" json:"-"`
 
 	// Relationships
-	Sender   User `gorm:"foreignKey:SenderID;references:ID" json:"sender,omitempty"`
-	Receiver User `gorm:"foreignKey:ReceiverID;references:ID" json:"receiver,omitempty"`
+	Sender      User                  `gorm:"foreignKey:SenderID;references:ID" json:"sender,omitempty"`
+	Receiver    *User                 `gorm:"foreignKey:ReceiverID;references:ID" json:"receiver,omitempty"`
+	Attachments []ChatAttachment      `gorm:"foreignKey:MessageID;references:ID;constraint:OnDelete:CASCADE" json:"attachments,omitempty"`
+	Reactions   []ChatReaction        `gorm:"foreignKey:MessageID;references:ID;constraint:OnDelete:CASCADE" json:"reactions,omitempty"`
+	Deletions   []ChatMessageDeletion `gorm:"foreignKey:MessageID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	ReplyTo     *ChatMessage          `gorm:"foreignKey:ReplyToMessageID;references:ID;constraint:OnDelete:SET NULL" json:"-"`
+
+	// Computed from ReplyTo (not in DB), see SetQuote
+	Quote *ChatQuote `gorm:"-" json:"reply_to,omitempty"`
+}
+
+// chatQuoteLength limits the quoted snippet of a reply, in characters
+const chatQuoteLength = 100
+
+// ChatQuote is the snippet of the message a reply quotes
+type ChatQuote struct {
+	ID          string    `json:"id"`
+	SenderID    string    `json:"sender_id"`
+	Sender      *User     `json:"sender,omitempty"`
+	MessageType string    `json:"message_type"`
+	Snippet     string    `json:"snippet"`          // Start of the content; empty for attachments without a caption
+	Unsent      bool      `json:"unsent,omitempty"` // The quoted message was unsent and has no snippet
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SetQuote fills Quote from the preloaded ReplyTo message
+func (c *ChatMessage) SetQuote() {
+	if c.ReplyTo == nil {
+		return
+	}
+	quoted := c.ReplyTo
+	quote := &ChatQuote{
+		ID:          quoted.ID,
+		SenderID:    quoted.SenderID,
+		MessageType: quoted.MessageType,
+		Unsent:      quoted.IsUnsent(),
+		CreatedAt:   quoted.CreatedAt,
+	}
+	if quoted.Sender.ID != "" {
+		quote.Sender = &quoted.Sender
+	}
+	if !quote.Unsent {
+		quote.Snippet = quoted.Content
+		if runes := []rune(quote.Snippet); len(runes) > chatQuoteLength {
+			quote.Snippet = string(runes[:chatQuoteLength]) + "..."
+		}
+	}
+	c.Quote = quote
+}
+
+// IsUnsent reports whether the sender unsent the message for everyone
+func (c *ChatMessage) IsUnsent() bool {
+	return c.UnsentAt != nil
 }
 
 // BeforeCreate hook
@@ -34,3 +124,93 @@ func (c *ChatMessage) BeforeCreate(tx *gorm.DB) error {
 func (ChatMessage) TableName() string {
 	return "chat_messages"
 }
+
+// ChatAttachment is a file attached to a chat message. Files are stored under private keys and
+// only handed out as signed URLs to participants of the message's conversation.
+type ChatAttachment struct {
+	ID           string    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	MessageID    *string   `gorm:"type:uuid;index" json:"message_id,omitempty"` // Set once sent; unsent uploads expire
+	UploaderID   string    `gorm:"type:uuid;not null;index" json:"uploader_id"`
+	Kind         string    `gorm:"type:varchar(10);not null" json:"kind"` // image, file, audio
+	Filename     string    `gorm:"type:varchar(255);not null" json:"filename"`
+	MimeType     string    `gorm:"type:varchar(100);not null" json:"mime_type"`
+	Size         int64     `gorm:"not null" json:"size"`
+	Width        int       `json:"width,omitempty"` // Images only
+	Height       int       `json:"height,omitempty"`
+	Blurhash     string    `gorm:"type:varchar(64)" json:"blurhash,omitempty"`
+	StorageKey   string    `gorm:"type:text;not null" json:"-"`
+	ThumbnailKey *string   `gorm:"type:text" json:"-"`
+	CreatedAt    time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+
+	// Signed URLs for the requesting participant (not in DB)
+	URL          string `gorm:"-" json:"url,omitempty"`
+	ThumbnailURL string `gorm:"-" json:"thumbnail_url,omitempty"`
+}
+
+// BeforeCreate hook
+func (a *ChatAttachment) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName specifies the table name
+func (ChatAttachment) TableName() string {
+	return "chat_attachments"
+}
+
+// ChatReaction is a user's reaction to a chat message, one of the Like reactions.
+// A user has at most one reaction per message.
+type ChatReaction struct {
+	MessageID string    `gorm:"type:uuid;primaryKey" json:"message_id"`
+	UserID    string    `gorm:"type:uuid;primaryKey" json:"user_id"`
+	Reaction  string    `gorm:"type:varchar(20);not null" json:"reaction"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName specifies the table name
+func (ChatReaction) TableName() string {
+	return "chat_reactions"
+}
+
+// ChatMessageDeletion hides a message from one user only ("delete for me")
+type ChatMessageDeletion struct {
+	MessageID string    `gorm:"type:uuid;primaryKey"`
+	UserID    string    `gorm:"type:uuid;primaryKey;index"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name
+func (ChatMessageDeletion) TableName() string {
+	return "chat_message_deletions"
+}
+
+// Who can send the user message requests (ChatSettings.MessageRequests)
+const (
+	MessageRequestsEveryone         = "everyone"
+	MessageRequestsFriendsOfFriends = "friends_of_friends"
+	MessageRequestsNobody           = "nobody"
+)
+
+// ChatSettings holds a user's chat privacy settings
+type ChatSettings struct {
+	UserID string `gorm:"type:uuid;primaryKey" json:"-"`
+	// HideReadReceipts stops telling senders when the user read their messages. The user no
+	// longer sees when others read theirs either.
+	HideReadReceipts bool `gorm:"not null;default:false" json:"hide_read_receipts"`
+	// MessageRequests is who, besides friends, can start a conversation with the user
+	MessageRequests string    `gorm:"type:varchar(20);not null;default:'friends_of_friends'" json:"message_requests"`
+	CreatedAt       time.Time `gorm:"autoCreateTime" json:"-"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"-"`
+}
+
+// TableName specifies the table name
+func (ChatSettings) TableName() string {
+	return "chat_settings"
+}
+
+// DefaultChatSettings returns the settings of a user who never changed them
+func DefaultChatSettings(userID string) *ChatSettings {
+	return &ChatSettings{UserID: userID, MessageRequests: MessageRequestsFriendsOfFriends}
+}
@@ -18,6 +18,12 @@ type Comment struct {
 	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
+	// Pinned by the post author (top-level comments only, one per post)
+	IsPinned bool `gorm:"default:false;index" json:"is_pinned"`
+	// Stored reaction counters (maintained on like/unlike/change), used for sorting
+	ReactionCount         int64 `gorm:"not null;default:0" json:"reaction_count"`
+	NegativeReactionCount int64 `gorm:"not null;default:0" json:"negative_reaction_count"` // sad + angry
+
 	// Relationships
 	Post    Post      `gorm:"foreignKey:PostID;references:ID" json:"post,omitempty"`
 	User    User      `gorm:"foreignKey:UserID;references:ID" json:"user,omitempty"`
@@ -26,6 +32,31 @@ type Comment struct {
 	// Likes relationship is polymorphic (target_type + target_id), so we don't use foreign key constraint
 	// Likes are accessed via service layer using TargetID and TargetType
 	LikeCount int64 `gorm:"-" json:"like_count"` // Virtual field, calculated
+	// Reaction breakdown and the viewer's own reaction, filled by CommentService
+	ReactionCounts map[string]int64 `gorm:"-" json:"reaction_counts,omitempty"`
+	TopReactions   []string         `gorm:"-" json:"top_reactions,omitempty"`
+	UserReaction   string           `gorm:"-" json:"user_reaction,omitempty"`
+
+	// Thread loading (see CommentService): replies are loaded to a bounded depth/width,
+	// the rest is fetched through GET /comments/:id/replies?cursor=RepliesCursor
+	ReplyCount     int64  `gorm:"not null;default:0" json:"reply_count"` // Direct replies, stored counter
+	HasMoreReplies bool   `gorm:"-" json:"has_more_replies"`
+	RepliesCursor  string `gorm:"-" json:"replies_cursor,omitempty"` // Empty when no replies were loaded yet
+}
+
+// Comment sort modes for GET /posts/:id/comments
+const (
+	CommentSortNewest        = "newest"
+	CommentSortOldest        = "oldest"
+	CommentSortTop           = "top"           // Most reactions first
+	CommentSortControversial = "controversial" // Most evenly split between positive and negative reactions
+)
+
+// SetReactions fills the reaction breakdown fields for the API response
+func (c *Comment) SetReactions(counts map[string]int64, userReaction string) {
+	c.ReactionCounts = counts
+	c.TopReactions = TopReactions(counts, 3)
+	c.UserReaction = userReaction
 }
 
 // BeforeCreate hook to generate UUID
@@ -0,0 +1,201 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Conversation types
+const (
+	ConversationTypeDirect = "direct"
+	ConversationTypeGroup  = "group"
+)
+
+// Conversation participant roles. Group admins manage the title, avatar and members.
+const (
+	ConversationRoleAdmin  = "admin"
+	ConversationRoleMember = "member"
+)
+
+// Message request states, set on the participant who received a direct message from a
+// non-friend
+const (
+	MessageRequestPending  = "pending"
+	MessageRequestAccepted = "accepted"
+	MessageRequestDeclined = "declined"
+)
+
+// Conversation.Request values: the viewer received or sent an unanswered message request
+const (
+	ConversationRequestReceived = "received"
+	ConversationRequestSent     = "sent"
+)
+
+// Disappearing message timers a conversation can have (Conversation.DisappearAfter), in seconds
+var DisappearingTimers = []int{0, 24 * 60 * 60, 7 * 24 * 60 * 60, 90 * 24 * 60 * 60}
+
+// MaxGroupMembers limits the participants of a group conversation
+const MaxGroupMembers = 100
+
+// Conversation groups the chat messages between its participants. Each pair of users has
+// one direct conversation, found through its DirectKey; group conversations have a title,
+// an optional avatar and admins.
+type Conversation struct {
+	ID             string    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Type           string    `gorm:"type:varchar(20);not null;default:'direct'" json:"type"`
+	DirectKey      *string   `gorm:"type:varchar(80);uniqueIndex" json:"-"`    // See DirectConversationKey
+	Title          *string   `gorm:"type:varchar(100)" json:"title,omitempty"` // Groups only
+	AvatarURL      *string   `gorm:"type:text" json:"avatar_url,omitempty"`    // Groups only
+	CreatedByID    *string   `gorm:"type:uuid" json:"created_by_id,omitempty"`
+	LastMessageID  *string   `gorm:"type:uuid" json:"last_message_id,omitempty"`
+	LastActivityAt time.Time `gorm:"not null;index" json:"last_activity_at"`    // Last message, or creation
+	DisappearAfter int       `gorm:"not null;default:0" json:"disappear_after"` // Seconds until new messages disappear, 0 = off
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Relationships
+	LastMessage  *ChatMessage              `gorm:"foreignKey:LastMessageID;references:ID;constraint:OnDelete:SET NULL" json:"last_message,omitempty"`
+	Participants []ConversationParticipant `gorm:"foreignKey:ConversationID;references:ID" json:"participants,omitempty"`
+
+	// Computed fields for the requesting participant (not in DB), see SetViewer
+	UnreadCount       int64      `gorm:"-" json:"unread_count"`
+	LastReadMessageID *string    `gorm:"-" json:"last_read_message_id,omitempty"`
+	Archived          bool       `gorm:"-" json:"archived"`
+	Muted             bool       `gorm:"-" json:"muted"`
+	MutedUntil        *time.Time `gorm:"-" json:"muted_until,omitempty"`
+	Pinned            bool       `gorm:"-" json:"pinned"`
+	Role              string     `gorm:"-" json:"role"`
+	Request           string     `gorm:"-" json:"request,omitempty"` // See ConversationRequestReceived
+}
+
+// BeforeCreate hook
+func (c *Conversation) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName specifies the table name
+func (Conversation) TableName() string {
+	return "conversations"
+}
+
+// SetViewer fills the computed fields from the participant state of userID
+func (c *Conversation) SetViewer(userID string) {
+	p := c.Participant(userID)
+	if p == nil {
+		return
+	}
+	c.UnreadCount = p.UnreadCount
+	c.LastReadMessageID = p.LastReadMessageID
+	c.Archived = p.ArchivedAt != nil
+	c.Muted = p.IsMuted(time.Now())
+	if c.Muted {
+		c.MutedUntil = p.MutedUntil
+	}
+	c.Pinned = p.PinnedAt != nil
+	c.Role = p.Role
+	c.Request = ""
+	if p.IsRequest() {
+		c.Request = ConversationRequestReceived
+	} else if c.RequestRecipient() != nil {
+		// A declined request still looks unanswered to its sender
+		c.Request = ConversationRequestSent
+	}
+}
+
+// DirectConversationKey identifies the direct conversation of two users regardless of order
+func DirectConversationKey(userA, userB string) string {
+	if userA > userB {
+		userA, userB = userB, userA
+	}
+	return userA + ":" + userB
+}
+
+// ConversationParticipant is a user's membership and inbox state in a conversation
+type ConversationParticipant struct {
+	ConversationID    string     `gorm:"type:uuid;primaryKey" json:"conversation_id"`
+	UserID            string     `gorm:"type:uuid;primaryKey;index" json:"user_id"`
+	Role              string     `gorm:"type:varchar(20);not null;default:'member'" json:"role"`
+	LastReadMessageID *string    `gorm:"type:uuid" json:"last_read_message_id,omitempty"`
+	LastReadAt        *time.Time `json:"last_read_at,omitempty"`
+
+	// Inbox state of the participant alone; the viewer's own is exposed on Conversation, see SetViewer
+	UnreadCount int64      `gorm:"not null;default:0" json:"-"`
+	ArchivedAt  *time.Time `json:"-"`
+	Muted       bool       `gorm:"not null;default:false" json:"-"`
+	MutedUntil  *time.Time `json:"-"` // nil while muted means until unmuted
+	PinnedAt    *time.Time `json:"-"`
+
+	RequestStatus *string   `gorm:"type:varchar(20)" json:"-"` // See MessageRequestPending, nil when not a request
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Relationships
+	Conversation Conversation `gorm:"foreignKey:ConversationID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	User         User         `gorm:"foreignKey:UserID;references:ID" json:"user,omitempty"`
+}
+
+// TableName specifies the table name
+func (ConversationParticipant) TableName() string {
+	return "conversation_participants"
+}
+
+// IsMuted reports whether the participant muted the conversation at t
+func (p *ConversationParticipant) IsMuted(t time.Time) bool {
+	return p.Muted && (p.MutedUntil == nil || p.MutedUntil.After(t))
+}
+
+// IsRequest reports whether the participant received a message request they have not accepted
+func (p *ConversationParticipant) IsRequest() bool {
+	return p.RequestStatus != nil && *p.RequestStatus != MessageRequestAccepted
+}
+
+// IsGroup reports whether the conversation is a group chat
+func (c *Conversation) IsGroup() bool {
+	return c.Type == ConversationTypeGroup
+}
+
+// Participant returns the participant state of userID, or nil when they are not a participant
+func (c *Conversation) Participant(userID string) *ConversationParticipant {
+	for i := range c.Participants {
+		if c.Participants[i].UserID == userID {
+			return &c.Participants[i]
+		}
+	}
+	return nil
+}
+
+// RequestRecipient returns the participant who received the conversation's unaccepted message
+// request, or nil when there is none
+func (c *Conversation) RequestRecipient() *ConversationParticipant {
+	for i := range c.Participants {
+		if c.Participants[i].IsRequest() {
+			return &c.Participants[i]
+		}
+	}
+	return nil
+}
+
+// RequestAccepted reports whether a message request of the conversation was accepted, which
+// lets its participants chat without being friends
+func (c *Conversation) RequestAccepted() bool {
+	for _, participant := range c.Participants {
+		if participant.RequestStatus != nil && *participant.RequestStatus == MessageRequestAccepted {
+			return true
+		}
+	}
+	return false
+}
+
+// ParticipantIDs returns the user IDs of the participants
+func (c *Conversation) ParticipantIDs() []string {
+	ids := make([]string, len(c.Participants))
+	for i, participant := range c.Participants {
+		ids[i] = participant.UserID
+	}
+	return ids
+}
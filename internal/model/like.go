@@ -1,6 +1,7 @@
 package model
 
 import (
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -47,3 +48,29 @@ const (
 	ReactionSad   = "sad"
 	ReactionAngry = "angry"
 )
+
+// IsNegativeReaction reports whether a reaction counts against a comment in the controversial sort
+func IsNegativeReaction(reaction string) bool {
+	return reaction == ReactionSad || reaction == ReactionAngry
+}
+
+// Reactions lists every supported reaction in display order
+var Reactions = []string{ReactionLike, ReactionLove, ReactionHaha, ReactionWow, ReactionSad, ReactionAngry}
+
+// TopReactions returns up to n reactions with a non-zero count, most used first.
+// Ties keep the display order of Reactions.
+func TopReactions(counts map[string]int64, n int) []string {
+	top := make([]string, 0, n)
+	for _, r := range Reactions {
+		if counts[r] > 0 {
+			top = append(top, r)
+		}
+	}
+	sort.SliceStable(top, func(i, j int) bool {
+		return counts[top[i]] > counts[top[j]]
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
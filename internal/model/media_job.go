@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MediaJob is a persisted unit of media processing (one file) for a post.
+// Jobs survive restarts and are retried with backoff by the media worker.
+type MediaJob struct {
+	ID          string     `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	PostID      string     `gorm:"type:uuid;not null;index" json:"post_id"`
+	UserID      string     `gorm:"type:uuid;not null;index" json:"user_id"`
+	MediaType   string     `gorm:"type:varchar(20);not null" json:"media_type"` // image, video
+	Filename    string     `gorm:"type:varchar(255);not null" json:"filename"`
+	InputKey    string     `gorm:"type:text;not null" json:"-"`                            // Media store key of the source file
+	Position    int        `gorm:"not null;default:0" json:"position"`                     // Order of the file within the post
+	Status      string     `gorm:"type:varchar(20);default:'pending';index" json:"status"` // pending, processing, done, failed
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts int        `gorm:"not null;default:5" json:"max_attempts"`
+	NextRunAt   time.Time  `gorm:"index" json:"next_run_at"`
+	LeaseUntil  *time.Time `gorm:"index" json:"-"` // A processing job whose lease ran out is assumed lost (crash/restart)
+	LastError   *string    `gorm:"type:text" json:"last_error,omitempty"`
+	ResultURL   *string    `gorm:"type:text" json:"result_url,omitempty"`
+	ResultKey   *string    `gorm:"type:text" json:"-"`  // Media store key of the processed file (full variant for images)
+	Result      *string    `gorm:"type:jsonb" json:"-"` // Processed PostMedia (dimensions, blurhash, variants) stored as JSON
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (j *MediaJob) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == "" {
+		j.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName specifies the table name
+func (MediaJob) TableName() string {
+	return "media_jobs"
+}
+
+// Media job status constants
+const (
+	MediaJobStatusPending    = "pending"
+	MediaJobStatusProcessing = "processing"
+	MediaJobStatusDone       = "done"
+	MediaJobStatusFailed     = "failed"
+)
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MediaUpload tracks a resumable (chunked) upload written to the tmp directory
+type MediaUpload struct {
+	ID        string    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID    string    `gorm:"type:uuid;not null;index" json:"user_id"`
+	Filename  string    `gorm:"type:varchar(255);not null" json:"filename"`
+	MediaType string    `gorm:"type:varchar(20);not null" json:"media_type"` // image, video
+	MimeType  string    `gorm:"type:varchar(100)" json:"mime_type"`
+	Size      int64     `gorm:"not null" json:"size"`                                     // Total size declared on creation
+	Offset    int64     `gorm:"column:upload_offset;not null;default:0" json:"offset"`    // Bytes received so far
+	Status    string    `gorm:"type:varchar(20);default:'uploading';index" json:"status"` // uploading, completed, consumed
+	TmpPath   string    `gorm:"type:text;not null" json:"-"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (m *MediaUpload) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName specifies the table name
+func (MediaUpload) TableName() string {
+	return "media_uploads"
+}
+
+// Media upload status constants
+const (
+	MediaUploadStatusUploading = "uploading"
+	MediaUploadStatusCompleted = "completed"
+	MediaUploadStatusConsumed  = "consumed" // Attached to a post; file is owned by the processing step
+)
+
+// Media type constants
+const (
+	MediaTypeImage = "image"
+	MediaTypeVideo = "video"
+)
@@ -21,6 +21,12 @@ type Notification struct {
 	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 
+	// Grouping: while a grouped notification is unread, further activity of the same type on the
+	// same target is folded into it. GroupKey is cleared when the notification is read.
+	GroupKey     *string             `gorm:"type:varchar(255);uniqueIndex" json:"-"`
+	ActorCount   int                 `gorm:"not null;default:1" json:"actor_count"`
+	LatestActors []NotificationActor `gorm:"type:jsonb;serializer:json" json:"latest_actors,omitempty"` // Most recent actors first
+
 	// Relationships
 	User   User  `gorm:"foreignKey:UserID;references:ID" json:"user,omitempty"`
 	Sender *User `gorm:"foreignKey:SenderID;references:ID" json:"sender,omitempty"`
@@ -39,6 +45,48 @@ func (Notification) TableName() string {
 	return "notifications"
 }
 
+// NotificationActor is one of the users behind a grouped notification
+type NotificationActor struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// NotificationLatestActorsLimit is how many of the most recent actors a grouped notification keeps
+const NotificationLatestActorsLimit = 3
+
+// NotificationGroupKey identifies the group a notification of notifType about targetID belongs to
+func NotificationGroupKey(userID, notifType, targetID string) string {
+	return userID + ":" + notifType + ":" + targetID
+}
+
+// NotificationGroupActor records that a user took part in a grouped notification,
+// so the actor count stays distinct when the same user acts again
+type NotificationGroupActor struct {
+	NotificationID string    `gorm:"type:uuid;primaryKey" json:"notification_id"`
+	UserID         string    `gorm:"type:uuid;primaryKey" json:"user_id"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+
+	// Relationships
+	Notification Notification `gorm:"foreignKey:NotificationID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// TableName specifies the table name
+func (NotificationGroupActor) TableName() string {
+	return "notification_group_actors"
+}
+
+// NotificationDelivery records a queued notification message the worker has handled, so a
+// redelivered or republished message with the same dedupe key is not delivered twice
+type NotificationDelivery struct {
+	DedupeKey string    `gorm:"type:varchar(64);primaryKey" json:"dedupe_key"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// TableName specifies the table name
+func (NotificationDelivery) TableName() string {
+	return "notification_deliveries"
+}
+
 // Notification type constants
 const (
 	NotificationTypeFriendRequest       = "friend_request"
@@ -47,8 +95,10 @@ const (
 	NotificationTypeFriendRemoved       = "friend_removed"
 	NotificationTypeCommentReply        = "comment_reply"
 	NotificationTypePostComment         = "post_comment"
+	NotificationTypeThreadActivity      = "thread_activity" // Comment on a post the user follows (grouped)
 	NotificationTypePostUploadCompleted = "post_upload_completed"
-	NotificationTypePostLiked           = "post_liked"
+	NotificationTypePostUploadFailed    = "post_upload_failed"
+	NotificationTypePostLiked           = "post_liked" // Grouped per post
 	NotificationTypeRoleUpdated         = "role_updated"
 	NotificationTypeRolePurchased       = "role_purchased"
 )
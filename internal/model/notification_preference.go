@@ -0,0 +1,161 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// NotificationPreference stores on which channels a user receives one notification type.
+// Types without a row use DefaultNotificationChannels; all channels disabled means "off".
+type NotificationPreference struct {
+	ID        string    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"-"`
+	UserID    string    `gorm:"type:uuid;not null;index:idx_notification_preference,unique" json:"-"`
+	Type      string    `gorm:"type:varchar(50);not null;index:idx_notification_preference,unique" json:"type"`
+	InApp     bool      `gorm:"not null" json:"in_app"` // Stored notification + WebSocket push
+	Email     bool      `gorm:"not null;default:false" json:"email"`
+	Push      bool      `gorm:"not null;default:false" json:"push"` // Web push to the user's devices
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"-"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (p *NotificationPreference) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName specifies the table name
+func (NotificationPreference) TableName() string {
+	return "notification_preferences"
+}
+
+// Off reports whether the notification type is disabled on every channel
+func (p *NotificationPreference) Off() bool {
+	return !p.InApp && !p.Email && !p.Push
+}
+
+// NotificationTypes lists the notification types users can configure, in display order
+var NotificationTypes = []string{
+	NotificationTypeFriendRequest,
+	NotificationTypeFriendAccepted,
+	NotificationTypeFriendRejected,
+	NotificationTypeFriendRemoved,
+	NotificationTypeCommentReply,
+	NotificationTypePostComment,
+	NotificationTypeThreadActivity,
+	NotificationTypePostLiked,
+	NotificationTypePostUploadCompleted,
+	NotificationTypePostUploadFailed,
+	NotificationTypeRoleUpdated,
+	NotificationTypeRolePurchased,
+}
+
+// IsNotificationType reports whether notifType is one of NotificationTypes
+func IsNotificationType(notifType string) bool {
+	for _, t := range NotificationTypes {
+		if t == notifType {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAccountNotification reports whether a notification type is about the user's account
+// (role changes and purchases), which is delivered even during quiet hours
+func IsAccountNotification(notifType string) bool {
+	return notifType == NotificationTypeRoleUpdated || notifType == NotificationTypeRolePurchased
+}
+
+// DefaultNotificationChannels returns the channels used for a type the user never configured:
+// everything is shown in-app and pushed, only account-related types are also emailed
+func DefaultNotificationChannels(userID, notifType string) *NotificationPreference {
+	pref := &NotificationPreference{UserID: userID, Type: notifType, InApp: true, Push: true}
+	switch notifType {
+	case NotificationTypeFriendRequest, NotificationTypeRoleUpdated, NotificationTypeRolePurchased:
+		pref.Email = true
+	case NotificationTypePostUploadCompleted:
+		pref.Push = false
+	}
+	return pref
+}
+
+// NotificationSettings holds per-user delivery settings that apply to all notification types
+type NotificationSettings struct {
+	UserID            string     `gorm:"type:uuid;primaryKey" json:"-"`
+	QuietHoursEnabled bool       `gorm:"not null;default:false" json:"enabled"`
+	QuietHoursStart   string     `gorm:"type:varchar(5);not null;default:'22:00'" json:"start"` // HH:MM in Timezone
+	QuietHoursEnd     string     `gorm:"type:varchar(5);not null;default:'07:00'" json:"end"`   // HH:MM in Timezone
+	Timezone          string     `gorm:"type:varchar(64);not null;default:'Asia/Jakarta'" json:"timezone"`
+	DigestFrequency   string     `gorm:"type:varchar(10);not null;default:'weekly'" json:"-"` // off, daily, weekly
+	LastDigestAt      *time.Time `gorm:"type:timestamp" json:"-"`
+	CreatedAt         time.Time  `gorm:"autoCreateTime" json:"-"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime" json:"-"`
+}
+
+// Email digest frequencies
+const (
+	DigestFrequencyOff    = "off"
+	DigestFrequencyDaily  = "daily"
+	DigestFrequencyWeekly = "weekly"
+)
+
+// DigestInterval returns how often a digest with the given frequency is sent (0 when off)
+func DigestInterval(frequency string) time.Duration {
+	switch frequency {
+	case DigestFrequencyDaily:
+		return 24 * time.Hour
+	case DigestFrequencyWeekly:
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+// TableName specifies the table name
+func (NotificationSettings) TableName() string {
+	return "notification_settings"
+}
+
+// DefaultNotificationSettings returns the settings of a user who never changed them
+func DefaultNotificationSettings(userID string) *NotificationSettings {
+	return &NotificationSettings{
+		UserID:          userID,
+		QuietHoursStart: "22:00",
+		QuietHoursEnd:   "07:00",
+		Timezone:        "Asia/Jakarta",
+		DigestFrequency: DigestFrequencyWeekly,
+	}
+}
+
+// InQuietHours reports whether t falls inside the user's quiet hours.
+// A window whose end is before its start spans midnight (e.g. 22:00-07:00).
+func (s *NotificationSettings) InQuietHours(t time.Time) bool {
+	if !s.QuietHoursEnabled {
+		return false
+	}
+	start, err1 := ParseClock(s.QuietHoursStart)
+	end, err2 := ParseClock(s.QuietHoursEnd)
+	if err1 != nil || err2 != nil || start == end {
+		return false
+	}
+	if loc, err := time.LoadLocation(s.Timezone); err == nil {
+		t = t.In(loc)
+	}
+	now := t.Hour()*60 + t.Minute()
+	if start < end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+// ParseClock parses an HH:MM time of day into minutes after midnight
+func ParseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
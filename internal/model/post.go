@@ -15,16 +15,27 @@ type Post struct {
 	Content      *string        `gorm:"type:text" json:"content,omitempty"`
 	ImageURLs    string         `gorm:"type:jsonb" json:"image_urls,omitempty"` // Array of image URLs stored as JSON
 	VideoURLs    string         `gorm:"type:jsonb;default:'[]'" json:"video_urls,omitempty"` // Array of video URLs stored as JSON
+	Media        string         `gorm:"type:jsonb;default:'[]'" json:"media,omitempty"`      // Array of PostMedia stored as JSON (image_urls/video_urls keep the full-size URLs for older clients)
 	SharedPostID *string        `gorm:"type:uuid;index;references:posts(id)" json:"shared_post_id,omitempty"`
-	IsPinned     bool           `gorm:"default:false" json:"is_pinned"`
+	IsPinned     bool           `gorm:"default:false" json:"is_pinned"`     // Pinned within its group (set by group admins/moderators only)
+	ProfilePin   *int           `gorm:"index" json:"profile_pin,omitempty"` // Position among the author's profile pins (0 = first), nil if not pinned
+	MediaStatus  string         `gorm:"type:varchar(20);default:'ready'" json:"media_status"` // processing, ready, failed
 	CreatedAt    time.Time      `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt    time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
 
+	// Engagement counters, updated in the same transaction as the rows they count
+	// and repaired periodically by the counter reconciler
+	LikesCount    int64 `gorm:"not null;default:0" json:"likes_count"`
+	CommentsCount int64 `gorm:"not null;default:0" json:"comments_count"` // Includes replies
+	ViewsCount    int64 `gorm:"not null;default:0" json:"views_count"`
+	SharesCount   int64 `gorm:"not null;default:0" json:"shares_count"`
+
 	// Computed fields for API response (not in DB)
-	LikesCount    int64 `json:"likes_count,omitempty" gorm:"-"`
-	CommentsCount int64 `json:"comments_count,omitempty" gorm:"-"`
-	UserLiked     bool  `json:"user_liked,omitempty" gorm:"-"`
+	UserLiked      bool             `json:"user_liked,omitempty" gorm:"-"`
+	ReactionCounts map[string]int64 `json:"reaction_counts,omitempty" gorm:"-"` // Likes per reaction type
+	TopReactions   []string         `json:"top_reactions,omitempty" gorm:"-"`   // Up to 3 most used reactions
+	UserReaction   string           `json:"user_reaction,omitempty" gorm:"-"`   // The viewer's reaction, if any
 
 	// Relationships
 	User       User          `gorm:"foreignKey:UserID;references:ID" json:"user,omitempty"`
@@ -34,6 +45,33 @@ type Post struct {
 	Location   *PostLocation `gorm:"foreignKey:PostID;references:ID" json:"location,omitempty"`
 }
 
+// Post media status constants
+const (
+	PostMediaStatusProcessing = "processing" // Media jobs are still pending or running
+	PostMediaStatusReady      = "ready"      // No media, or all media processed
+	PostMediaStatusFailed     = "failed"     // Every media job failed permanently
+)
+
+// PostMedia is a processed image or video attached to a post
+type PostMedia struct {
+	Type     string                  `json:"type"` // image, video
+	URL      string                  `json:"url"`  // Full-size URL
+	Width    int                     `json:"width,omitempty"`
+	Height   int                     `json:"height,omitempty"`
+	Blurhash string                  `json:"blurhash,omitempty"` // Placeholder shown while the image loads
+	Variants map[string]MediaVariant `json:"variants,omitempty"` // thumbnail, medium, full
+}
+
+// MediaVariant is one resized rendition of an image
+type MediaVariant struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// MaxProfilePinnedPosts is the maximum number of posts a user can pin on their profile
+const MaxProfilePinnedPosts = 3
+
 // BeforeCreate hook to generate UUID and ensure JSONB defaults
 func (p *Post) BeforeCreate(tx *gorm.DB) error {
 	if p.ID == "" {
@@ -45,6 +83,12 @@ func (p *Post) BeforeCreate(tx *gorm.DB) error {
 	if p.VideoURLs == "" {
 		p.VideoURLs = "[]"
 	}
+	if p.Media == "" {
+		p.Media = "[]"
+	}
+	if p.MediaStatus == "" {
+		p.MediaStatus = PostMediaStatusReady
+	}
 	return nil
 }
 
@@ -56,6 +100,9 @@ func (p *Post) BeforeSave(tx *gorm.DB) error {
 	if p.VideoURLs == "" {
 		p.VideoURLs = "[]"
 	}
+	if p.Media == "" {
+		p.Media = "[]"
+	}
 	return nil
 }
 
@@ -117,16 +164,51 @@ func (p *Post) SetVideoURLs(urls []string) error {
 	return nil
 }
 
-// MarshalJSON custom JSON marshaling to convert ImageURLs/VideoURLs string to array
+// GetMedia returns Media as a slice of PostMedia
+func (p *Post) GetMedia() []PostMedia {
+	if p.Media == "" || p.Media == "[]" {
+		return []PostMedia{}
+	}
+	var media []PostMedia
+	if err := json.Unmarshal([]byte(p.Media), &media); err != nil {
+		return []PostMedia{}
+	}
+	return media
+}
+
+// SetMedia sets Media from a slice of PostMedia
+func (p *Post) SetMedia(media []PostMedia) error {
+	if len(media) == 0 {
+		p.Media = "[]"
+		return nil
+	}
+	bytes, err := json.Marshal(media)
+	if err != nil {
+		return err
+	}
+	p.Media = string(bytes)
+	return nil
+}
+
+// SetReactions fills the reaction breakdown fields for the API response
+func (p *Post) SetReactions(counts map[string]int64, userReaction string) {
+	p.ReactionCounts = counts
+	p.TopReactions = TopReactions(counts, 3)
+	p.UserReaction = userReaction
+}
+
+// MarshalJSON custom JSON marshaling to convert ImageURLs/VideoURLs/Media string to array
 func (p *Post) MarshalJSON() ([]byte, error) {
 	type Alias Post
 	aux := &struct {
-		ImageURLs []string `json:"image_urls,omitempty"`
-		VideoURLs []string `json:"video_urls,omitempty"`
+		ImageURLs []string    `json:"image_urls,omitempty"`
+		VideoURLs []string    `json:"video_urls,omitempty"`
+		Media     []PostMedia `json:"media,omitempty"`
 		*Alias
 	}{
 		ImageURLs: p.GetImageURLs(),
 		VideoURLs: p.GetVideoURLs(),
+		Media:     p.GetMedia(),
 		Alias:     (*Alias)(p),
 	}
 	return json.Marshal(aux)
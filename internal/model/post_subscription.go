@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PostSubscription makes a user receive thread_activity notifications for a post.
+// Authors and commenters are subscribed automatically; anyone can follow a post explicitly.
+// A muted subscription is kept (instead of deleted) so commenting again does not resubscribe.
+type PostSubscription struct {
+	ID        string    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	PostID    string    `gorm:"type:uuid;not null;index:idx_post_subscriber,unique" json:"post_id"`
+	UserID    string    `gorm:"type:uuid;not null;index:idx_post_subscriber,unique;index" json:"user_id"`
+	Reason    string    `gorm:"type:varchar(20);not null;default:'followed'" json:"reason"` // author, commented, followed
+	Muted     bool      `gorm:"not null;default:false" json:"muted"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (s *PostSubscription) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName specifies the table name
+func (PostSubscription) TableName() string {
+	return "post_subscriptions"
+}
+
+// Post subscription reasons
+const (
+	PostSubscriptionReasonAuthor    = "author"
+	PostSubscriptionReasonCommented = "commented"
+	PostSubscriptionReasonFollowed  = "followed"
+)
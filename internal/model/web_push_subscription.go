@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// WebPushSubscription is one browser/device registered for Web Push.
+// Push services hand out a unique endpoint per subscription, so the endpoint identifies the device.
+type WebPushSubscription struct {
+	ID         string     `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID     string     `gorm:"type:uuid;not null;index" json:"user_id"`
+	Endpoint   string     `gorm:"type:text;not null;uniqueIndex" json:"endpoint"`
+	P256dh     string     `gorm:"type:varchar(255);not null" json:"-"` // Browser's ECDH public key (base64url)
+	Auth       string     `gorm:"type:varchar(255);not null" json:"-"` // Browser's auth secret (base64url)
+	UserAgent  string     `gorm:"type:varchar(512)" json:"user_agent,omitempty"`
+	LastUsedAt *time.Time `gorm:"type:timestamp" json:"last_used_at,omitempty"` // Last successful delivery
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (s *WebPushSubscription) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName specifies the table name
+func (WebPushSubscription) TableName() string {
+	return "web_push_subscriptions"
+}
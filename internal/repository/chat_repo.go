@@ -1,20 +1,102 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"yourapp/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ChatRepository interface {
 	Create(msg *model.ChatMessage) error
 	FindByID(id string) (*model.ChatMessage, error)
+	// FindByClientID finds the sender's message by its client-generated ID
+	FindByClientID(senderID, clientID string) (*model.ChatMessage, error)
+	// GetConversation finds a page of the direct messages between the users, without those the
+	// first user deleted for themselves
 	GetConversation(senderID, receiverID string, limit, offset int) ([]*model.ChatMessage, error)
+	// FindByConversationID finds a page of a conversation's messages visible to the viewer, oldest first
+	FindByConversationID(conversationID, viewerID string, limit, offset int) ([]*model.ChatMessage, error)
 	MarkAsRead(receiverID, senderID string) error
 	GetUnreadCount(userID string) (int64, error)
 	GetUnreadCountBySenders(userID string) (map[string]int64, error)
+	MarkDelivered(messageID string, at time.Time) error
+	// MarkDeliveredTo marks every undelivered direct message to the receiver delivered and
+	// returns them (ID, sender and conversation only)
+	MarkDeliveredTo(receiverID string, at time.Time) ([]*model.ChatMessage, error)
+	// FindSettings returns the user's chat settings, or the default ones
+	FindSettings(userID string) (*model.ChatSettings, error)
+	// FindHidingReadReceipts returns which of the users hide their read receipts
+	FindHidingReadReceipts(userIDs []string) (map[string]bool, error)
+	UpsertSettings(settings *model.ChatSettings) error
+	CreateAttachment(attachment *model.ChatAttachment) error
+	FindAttachmentByID(id string) (*model.ChatAttachment, error)
+	FindAttachmentsByIDs(ids []string) ([]*model.ChatAttachment, error)
+	// FindUnsentAttachments finds attachments uploaded before the given time that were never sent
+	FindUnsentAttachments(before time.Time, limit int) ([]*model.ChatAttachment, error)
+	DeleteAttachment(id string) error
+	// CountAttachmentsWithKey counts the attachments stored under the key; forwarded
+	// attachments share the files of the original
+	CountAttachmentsWithKey(key string) (int64, error)
+	// UpdateContent edits a message that was not unsent
+	UpdateContent(messageID, content string, editedAt time.Time) error
+	// Unsend clears the message's content, attachments and reactions, keeping it as a tombstone.
+	// It returns the removed attachments so their files can be deleted.
+	Unsend(messageID string, at time.Time) ([]*model.ChatAttachment, error)
+	// FindExpired finds disappearing messages that expired at now and, when olderThan is set,
+	// messages sent before it (ID and conversation only)
+	FindExpired(now time.Time, olderThan *time.Time, limit int) ([]*model.ChatMessage, error)
+	// DeleteMessages permanently deletes the messages with their attachments, reactions and
+	// deletions, moving the last message of their conversations back. It returns the deleted
+	// attachments so their files can be deleted.
+	DeleteMessages(messages []*model.ChatMessage) ([]*model.ChatAttachment, error)
+	// HideForUser deletes the message for the user only
+	HideForUser(messageID, userID string) error
+	// SetReaction creates or replaces the user's reaction to the message
+	SetReaction(reaction *model.ChatReaction) error
+	DeleteReaction(messageID, userID string) error
+	FindReactions(messageID string) ([]model.ChatReaction, error)
+	// Search finds the messages of the user's conversations matching a full-text query, newest first
+	Search(query ChatSearchQuery) ([]ChatSearchHit, error)
+	// FindAround finds up to before/after visible messages of msg's conversation right before and
+	// after it, both oldest first
+	FindAround(msg *model.ChatMessage, viewerID string, before, after int) ([]*model.ChatMessage, []*model.ChatMessage, error)
+}
+
+// Full-text search uses the language-neutral "simple" configuration, as chats mix languages.
+// idx_chat_messages_content_search indexes the same expression.
+const chatSearchConfig = "simple"
+
+// Highlighted terms in ChatSearchHit.Headline are wrapped in these control characters, which
+// cannot be confused with message text
+const (
+	ChatSearchMatchStart = "\x02"
+	ChatSearchMatchStop  = "\x03"
+)
+
+// ChatSearchQuery selects the messages a chat search looks in
+type ChatSearchQuery struct {
+	UserID         string // Only the user's conversations, without messages they deleted for themselves
+	Query          string // Web search syntax: words, "quoted phrases" and -excluded words
+	ConversationID string // Optional
+	From           *time.Time
+	To             *time.Time // Exclusive
+	Limit          int
+	Offset         int
+}
+
+// ChatSearchHit is a message matching a search with the fragment of its content around the match
+type ChatSearchHit struct {
+	Message  *model.ChatMessage
+	Headline string
 }
 
+// notHiddenFrom excludes the messages the viewer deleted for themselves
+const notHiddenFrom = "NOT EXISTS (SELECT 1 FROM chat_message_deletions d WHERE d.message_id = chat_messages.id AND d.user_id = ?)"
+
 type chatRepository struct {
 	db *gorm.DB
 }
@@ -23,13 +105,31 @@ func NewChatRepository(db *gorm.DB) ChatRepository {
 	return &chatRepository{db: db}
 }
 
+// messageQuery preloads what a message is shown with. Senders and receivers are limited to
+// their public fields.
+func (r *chatRepository) messageQuery() *gorm.DB {
+	return r.db.Preload("Sender", selectPublicUser).Preload("Receiver", selectPublicUser).
+		Preload("Attachments").Preload("Reactions").Preload("ReplyTo.Sender", selectPublicUser)
+}
+
 func (r *chatRepository) Create(msg *model.ChatMessage) error {
 	return r.db.Create(msg).Error
 }
 
 func (r *chatRepository) FindByID(id string) (*model.ChatMessage, error) {
 	var msg model.ChatMessage
-	err := r.db.Preload("Sender").Preload("Receiver").Where("id = ?", id).First(&msg).Error
+	err := r.messageQuery().Where("id = ?", id).First(&msg).Error
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (r *chatRepository) FindByClientID(senderID, clientID string) (*model.ChatMessage, error) {
+	var msg model.ChatMessage
+	err := r.messageQuery().
+		Where("sender_id = ? AND client_message_id = ?", senderID, clientID).
+		First(&msg).Error
 	if err != nil {
 		return nil, err
 	}
@@ -38,9 +138,28 @@ func (r *chatRepository) FindByID(id string) (*model.ChatMessage, error) {
 
 func (r *chatRepository) GetConversation(senderID, receiverID string, limit, offset int) ([]*model.ChatMessage, error) {
 	var messages []*model.ChatMessage
-	err := r.db.Preload("Sender").Preload("Receiver").
+	err := r.messageQuery().
 		Where("(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)",
 			senderID, receiverID, receiverID, senderID).
+		Where(notHiddenFrom, senderID).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+func (r *chatRepository) FindByConversationID(conversationID, viewerID string, limit, offset int) ([]*model.ChatMessage, error) {
+	var messages []*model.ChatMessage
+	err := r.messageQuery().
+		Where("conversation_id = ?", conversationID).
+		Where(notHiddenFrom, viewerID).
 		Order("created_at DESC").
 		Limit(limit).
 		Offset(offset).
@@ -60,11 +179,14 @@ func (r *chatRepository) MarkAsRead(receiverID, senderID string) error {
 		Update("is_read", true).Error
 }
 
+// GetUnreadCount sums the user's unread messages over all their conversations, except message
+// requests they did not accept
 func (r *chatRepository) GetUnreadCount(userID string) (int64, error) {
 	var count int64
-	err := r.db.Model(&model.ChatMessage{}).
-		Where("receiver_id = ? AND is_read = ?", userID, false).
-		Count(&count).Error
+	err := r.db.Model(&model.ConversationParticipant{}).
+		Where("user_id = ? AND (request_status IS NULL OR request_status = ?)", userID, model.MessageRequestAccepted).
+		Select("COALESCE(SUM(unread_count), 0)").
+		Scan(&count).Error
 	return count, err
 }
 
@@ -87,3 +209,328 @@ func (r *chatRepository) GetUnreadCountBySenders(userID string) (map[string]int6
 	}
 	return result, nil
 }
+
+func (r *chatRepository) MarkDelivered(messageID string, at time.Time) error {
+	return r.db.Model(&model.ChatMessage{}).
+		Where("id = ? AND delivered_at IS NULL", messageID).
+		Update("delivered_at", at).Error
+}
+
+func (r *chatRepository) MarkDeliveredTo(receiverID string, at time.Time) ([]*model.ChatMessage, error) {
+	var messages []*model.ChatMessage
+	err := r.db.Model(&messages).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}, {Name: "sender_id"}, {Name: "conversation_id"}}}).
+		Where("receiver_id = ? AND delivered_at IS NULL", receiverID).
+		Update("delivered_at", at).Error
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// FindSettings finds the user's chat settings
+func (r *chatRepository) FindSettings(userID string) (*model.ChatSettings, error) {
+	var settings model.ChatSettings
+	err := r.db.Where("user_id = ?", userID).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.DefaultChatSettings(userID), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (r *chatRepository) FindHidingReadReceipts(userIDs []string) (map[string]bool, error) {
+	hiding := make(map[string]bool)
+	if len(userIDs) == 0 {
+		return hiding, nil
+	}
+	var ids []string
+	err := r.db.Model(&model.ChatSettings{}).
+		Where("user_id IN ? AND hide_read_receipts", userIDs).
+		Pluck("user_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		hiding[id] = true
+	}
+	return hiding, nil
+}
+
+// UpsertSettings creates or replaces the user's chat settings
+func (r *chatRepository) UpsertSettings(settings *model.ChatSettings) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"hide_read_receipts", "message_requests", "updated_at"}),
+	}).Create(settings).Error
+}
+
+func (r *chatRepository) CreateAttachment(attachment *model.ChatAttachment) error {
+	return r.db.Create(attachment).Error
+}
+
+func (r *chatRepository) FindAttachmentByID(id string) (*model.ChatAttachment, error) {
+	var attachment model.ChatAttachment
+	err := r.db.Where("id = ?", id).First(&attachment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+func (r *chatRepository) FindAttachmentsByIDs(ids []string) ([]*model.ChatAttachment, error) {
+	var attachments []*model.ChatAttachment
+	err := r.db.Where("id IN ?", ids).Find(&attachments).Error
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (r *chatRepository) FindUnsentAttachments(before time.Time, limit int) ([]*model.ChatAttachment, error) {
+	var attachments []*model.ChatAttachment
+	err := r.db.Where("message_id IS NULL AND created_at < ?", before).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&attachments).Error
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (r *chatRepository) DeleteAttachment(id string) error {
+	return r.db.Where("id = ?", id).Delete(&model.ChatAttachment{}).Error
+}
+
+func (r *chatRepository) CountAttachmentsWithKey(key string) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.ChatAttachment{}).
+		Where("storage_key = ? OR thumbnail_key = ?", key, key).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *chatRepository) UpdateContent(messageID, content string, editedAt time.Time) error {
+	return r.db.Model(&model.ChatMessage{}).
+		Where("id = ? AND unsent_at IS NULL", messageID).
+		Updates(map[string]interface{}{"content": content, "edited_at": editedAt}).Error
+}
+
+func (r *chatRepository) Unsend(messageID string, at time.Time) ([]*model.ChatAttachment, error) {
+	var attachments []*model.ChatAttachment
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("message_id = ?", messageID).Find(&attachments).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("message_id = ?", messageID).Delete(&model.ChatAttachment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("message_id = ?", messageID).Delete(&model.ChatReaction{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.ChatMessage{}).
+			Where("id = ?", messageID).
+			Updates(map[string]interface{}{"content": "", "metadata": nil, "unsent_at": at}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// FindExpired runs one query per index: disappearing messages by expires_at first, then
+// messages past retention by created_at, skipping those the first query covers
+func (r *chatRepository) FindExpired(now time.Time, olderThan *time.Time, limit int) ([]*model.ChatMessage, error) {
+	var messages []*model.ChatMessage
+	err := r.db.Unscoped().Select("id", "conversation_id").
+		Where("expires_at <= ?", now).
+		Order("expires_at ASC").Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	if olderThan == nil || len(messages) >= limit {
+		return messages, nil
+	}
+
+	var old []*model.ChatMessage
+	err = r.db.Unscoped().Select("id", "conversation_id").
+		Where("created_at < ? AND (expires_at IS NULL OR expires_at > ?)", *olderThan, now).
+		Order("created_at ASC").Limit(limit - len(messages)).
+		Find(&old).Error
+	if err != nil {
+		return nil, err
+	}
+	return append(messages, old...), nil
+}
+
+func (r *chatRepository) DeleteMessages(messages []*model.ChatMessage) ([]*model.ChatAttachment, error) {
+	var attachments []*model.ChatAttachment
+	if len(messages) == 0 {
+		return attachments, nil
+	}
+	ids := make([]string, len(messages))
+	conversations := make(map[string]bool)
+	for i, msg := range messages {
+		ids[i] = msg.ID
+		if msg.ConversationID != nil {
+			conversations[*msg.ConversationID] = true
+		}
+	}
+	conversationIDs := make([]string, 0, len(conversations))
+	for id := range conversations {
+		conversationIDs = append(conversationIDs, id)
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("message_id IN ?", ids).Find(&attachments).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("message_id IN ?", ids).Delete(&model.ChatAttachment{}).Error; err != nil {
+			return err
+		}
+		// Reactions and deletions cascade; replies keep a quote of nothing and conversations
+		// lose their last message
+		if err := tx.Unscoped().Where("id IN ?", ids).Delete(&model.ChatMessage{}).Error; err != nil {
+			return err
+		}
+		if len(conversationIDs) == 0 {
+			return nil
+		}
+
+		err := tx.Exec(`UPDATE conversations SET last_message_id = (
+			SELECT m.id FROM chat_messages m
+			WHERE m.conversation_id = conversations.id AND m.deleted_at IS NULL
+			ORDER BY m.created_at DESC, m.id DESC LIMIT 1
+		) WHERE id IN ? AND last_message_id IS NULL`, conversationIDs).Error
+		if err != nil {
+			return err
+		}
+		// Deleted messages may still be counted as unread
+		return tx.Exec(`UPDATE conversation_participants cp SET unread_count = LEAST(cp.unread_count, (
+			SELECT count(*) FROM chat_messages m
+			WHERE m.conversation_id = cp.conversation_id AND m.sender_id <> cp.user_id AND m.deleted_at IS NULL
+		)) WHERE cp.conversation_id IN ? AND cp.unread_count > 0`, conversationIDs).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (r *chatRepository) HideForUser(messageID, userID string) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.ChatMessageDeletion{MessageID: messageID, UserID: userID}).Error
+}
+
+func (r *chatRepository) SetReaction(reaction *model.ChatReaction) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "message_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reaction", "created_at"}),
+	}).Create(reaction).Error
+}
+
+func (r *chatRepository) DeleteReaction(messageID, userID string) error {
+	return r.db.Where("message_id = ? AND user_id = ?", messageID, userID).Delete(&model.ChatReaction{}).Error
+}
+
+func (r *chatRepository) FindReactions(messageID string) ([]model.ChatReaction, error) {
+	var reactions []model.ChatReaction
+	err := r.db.Where("message_id = ?", messageID).Order("created_at ASC").Find(&reactions).Error
+	if err != nil {
+		return nil, err
+	}
+	return reactions, nil
+}
+
+func (r *chatRepository) Search(query ChatSearchQuery) ([]ChatSearchHit, error) {
+	headlineOptions := "StartSel=" + ChatSearchMatchStart + ", StopSel=" + ChatSearchMatchStop + ", MaxWords=20, MinWords=8"
+	tsQuery := "websearch_to_tsquery('" + chatSearchConfig + "', ?)"
+
+	q := r.db.Model(&model.ChatMessage{}).
+		Select("chat_messages.id, ts_headline('"+chatSearchConfig+"', chat_messages.content, "+tsQuery+", ?) AS headline", query.Query, headlineOptions).
+		Joins("JOIN conversation_participants cp ON cp.conversation_id = chat_messages.conversation_id AND cp.user_id = ?", query.UserID).
+		Where("to_tsvector('"+chatSearchConfig+"', chat_messages.content) @@ "+tsQuery, query.Query).
+		Where("chat_messages.unsent_at IS NULL AND chat_messages.message_type <> ?", model.ChatMessageTypeSystem).
+		Where(notHiddenFrom, query.UserID)
+	if query.ConversationID != "" {
+		q = q.Where("chat_messages.conversation_id = ?", query.ConversationID)
+	}
+	if query.From != nil {
+		q = q.Where("chat_messages.created_at >= ?", *query.From)
+	}
+	if query.To != nil {
+		q = q.Where("chat_messages.created_at < ?", *query.To)
+	}
+
+	var rows []struct {
+		ID       string
+		Headline string
+	}
+	err := q.Order("chat_messages.created_at DESC, chat_messages.id DESC").
+		Limit(query.Limit).
+		Offset(query.Offset).
+		Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+
+	ids := make([]string, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	var messages []*model.ChatMessage
+	err = r.messageQuery().
+		Where("id IN ?", ids).
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*model.ChatMessage, len(messages))
+	for _, msg := range messages {
+		byID[msg.ID] = msg
+	}
+
+	hits := make([]ChatSearchHit, 0, len(rows))
+	for _, row := range rows {
+		if msg, ok := byID[row.ID]; ok {
+			hits = append(hits, ChatSearchHit{Message: msg, Headline: row.Headline})
+		}
+	}
+	return hits, nil
+}
+
+func (r *chatRepository) FindAround(msg *model.ChatMessage, viewerID string, before, after int) ([]*model.ChatMessage, []*model.ChatMessage, error) {
+	query := func() *gorm.DB {
+		return r.messageQuery().
+			Where("conversation_id = ?", msg.ConversationID).
+			Where(notHiddenFrom, viewerID)
+	}
+
+	var older, newer []*model.ChatMessage
+	if before > 0 {
+		err := query().Where("(created_at, id) < (?, ?)", msg.CreatedAt, msg.ID).
+			Order("created_at DESC, id DESC").
+			Limit(before).
+			Find(&older).Error
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, j := 0, len(older)-1; i < j; i, j = i+1, j-1 {
+			older[i], older[j] = older[j], older[i]
+		}
+	}
+	if after > 0 {
+		err := query().Where("(created_at, id) > (?, ?)", msg.CreatedAt, msg.ID).
+			Order("created_at ASC, id ASC").
+			Limit(after).
+			Find(&newer).Error
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return older, newer, nil
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"yourapp/internal/model"
@@ -14,13 +15,74 @@ import (
 type CommentRepository interface {
 	Create(comment *model.Comment) error
 	FindByID(id string) (*model.Comment, error)
-	FindByPostID(postID string, limit, offset int) ([]*model.Comment, error)
-	FindByParentID(parentID string, limit, offset int) ([]*model.Comment, error)
+	FindByPostID(postID, sort string, limit, offset int, after *CommentCursor) ([]*model.Comment, error)
+	FindPinnedByPostID(postID string) (*model.Comment, error)
+	SetPinned(postID string, commentID *string) error
+	FindByParentID(parentID string, limit, offset int, after *CommentCursor) ([]*model.Comment, error)
+	FindDescendants(rootIDs []string, maxDepth, perParent, maxRows int) ([]*model.Comment, error)
 	Update(comment *model.Comment) error
 	Delete(id string) error
 	CountByPostID(postID string) (int64, error)
 	CountByPostIDs(postIDs []string) (map[string]int64, error)
 	CountByParentID(parentID string) (int64, error)
+	InvalidateReactionCaches(comment *model.Comment) // Clear cached lists sorted by the comment's reaction counts
+}
+
+// CommentCursor is the keyset position of the last comment on a page: the sort keys
+// of its sort mode (e.g. reaction count for "top"), then (created_at, id) as tie-breakers
+type CommentCursor struct {
+	Sort      string    `json:"s,omitempty"`
+	Keys      []int64   `json:"k,omitempty"`
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+// commentSort describes the ORDER BY of a sort mode. keys are SQL expressions ordered
+// before (created_at, id); all columns share one direction so a row comparison works as keyset.
+type commentSort struct {
+	keys    []string
+	sortKey func(c *model.Comment) []int64
+	desc    bool
+}
+
+var commentSorts = map[string]commentSort{
+	model.CommentSortNewest: {desc: true},
+	model.CommentSortOldest: {desc: false},
+	model.CommentSortTop: {
+		keys:    []string{"comments.reaction_count"},
+		sortKey: func(c *model.Comment) []int64 { return []int64{c.ReactionCount} },
+		desc:    true,
+	},
+	// Controversy = size of the minority side (positive vs negative reactions), then total
+	model.CommentSortControversial: {
+		keys: []string{
+			"LEAST(comments.reaction_count - comments.negative_reaction_count, comments.negative_reaction_count)",
+			"comments.reaction_count",
+		},
+		sortKey: func(c *model.Comment) []int64 {
+			minority := c.NegativeReactionCount
+			if positive := c.ReactionCount - c.NegativeReactionCount; positive < minority {
+				minority = positive
+			}
+			return []int64{minority, c.ReactionCount}
+		},
+		desc: true,
+	},
+}
+
+// IsValidCommentSort reports whether sort is a supported comment sort mode
+func IsValidCommentSort(sort string) bool {
+	_, ok := commentSorts[sort]
+	return ok
+}
+
+// NewCommentCursor builds the cursor pointing after comment in the given sort mode
+func NewCommentCursor(sort string, comment *model.Comment) CommentCursor {
+	cursor := CommentCursor{Sort: sort, CreatedAt: comment.CreatedAt, ID: comment.ID}
+	if spec, ok := commentSorts[sort]; ok && spec.sortKey != nil {
+		cursor.Keys = spec.sortKey(comment)
+	}
+	return cursor
 }
 
 type commentRepository struct {
@@ -36,6 +98,25 @@ const (
 	commentCacheExpiration     = 15 * time.Minute
 )
 
+// commentThreadSQL walks down from the given roots, taking the oldest perParent replies
+// of every comment per level, until maxDepth levels below the roots are loaded. The walk is
+// breadth first and Postgres only evaluates as many rows of it as the outer LIMIT fetches,
+// so expansion stops once maxRows replies are found.
+const commentThreadSQL = `WITH RECURSIVE thread AS (
+	SELECT id, 0 AS depth FROM comments WHERE id IN ? AND deleted_at IS NULL
+	UNION ALL
+	SELECT child.id, thread.depth + 1
+	FROM thread
+	CROSS JOIN LATERAL (
+		SELECT c.id FROM comments c
+		WHERE c.parent_id = thread.id AND c.deleted_at IS NULL
+		ORDER BY c.created_at ASC, c.id ASC
+		LIMIT ?
+	) child
+	WHERE thread.depth < ?
+)
+SELECT id FROM thread WHERE depth > 0 LIMIT ?`
+
 func NewCommentRepository(db *gorm.DB, redis *util.RedisClient) CommentRepository {
 	return &commentRepository{
 		db:    db,
@@ -45,7 +126,13 @@ func NewCommentRepository(db *gorm.DB, redis *util.RedisClient) CommentRepositor
 
 // Create creates a new comment and invalidates related caches
 func (r *commentRepository) Create(comment *model.Comment) error {
-	if err := r.db.Create(comment).Error; err != nil {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(comment).Error; err != nil {
+			return err
+		}
+		return adjustCommentCounters(tx, comment, 1)
+	})
+	if err != nil {
 		return err
 	}
 
@@ -54,8 +141,7 @@ func (r *commentRepository) Create(comment *model.Comment) error {
 		r.invalidatePostCache(comment.PostID)
 		r.invalidateCountCache(comment.PostID)
 		if comment.ParentID != nil {
-			r.invalidateParentCache(*comment.ParentID)
-			r.invalidateParentCountCache(*comment.ParentID)
+			r.invalidateThreadCache(*comment.ParentID)
 		}
 	}
 
@@ -80,9 +166,6 @@ func (r *commentRepository) FindByID(id string) (*model.Comment, error) {
 		return nil, err
 	}
 
-	// Load replies recursively
-	r.loadRepliesRecursive(&comment)
-
 	// Cache the result
 	if r.redis != nil {
 		r.cacheComment(&comment)
@@ -91,10 +174,22 @@ func (r *commentRepository) FindByID(id string) (*model.Comment, error) {
 	return &comment, nil
 }
 
-// FindByPostID finds comments by post ID with all nested replies
-func (r *commentRepository) FindByPostID(postID string, limit, offset int) ([]*model.Comment, error) {
+// FindByPostID finds a page of unpinned top-level comments in the given sort mode, with
+// their reply counts. When after is set the page starts after that cursor and offset is ignored.
+func (r *commentRepository) FindByPostID(postID, sort string, limit, offset int, after *CommentCursor) ([]*model.Comment, error) {
+	spec, ok := commentSorts[sort]
+	if !ok {
+		return nil, fmt.Errorf("invalid comment sort: %s", sort)
+	}
+	if after != nil && (after.Sort != sort || len(after.Keys) != len(spec.keys)) {
+		return nil, fmt.Errorf("cursor does not match sort %s", sort)
+	}
+
 	// Try cache first
-	cacheKey := fmt.Sprintf("%s%s:%d:%d", commentByPostCachePrefix, postID, limit, offset)
+	cacheKey := fmt.Sprintf("%s%s:%s:%d:%d", commentByPostCachePrefix, postID, sort, limit, offset)
+	if after != nil {
+		cacheKey = fmt.Sprintf("%s%s:%s:%d:%s", commentByPostCachePrefix, postID, sort, limit, util.EncodeCursor(after))
+	}
 	if r.redis != nil {
 		cached, err := r.getListFromCache(cacheKey)
 		if err == nil && cached != nil {
@@ -102,21 +197,34 @@ func (r *commentRepository) FindByPostID(postID string, limit, offset int) ([]*m
 		}
 	}
 
-	// Get top-level comments only (parent_id IS NULL)
-	var comments []*model.Comment
-	err := r.db.Preload("User").
-		Where("post_id = ? AND parent_id IS NULL", postID).
-		Order("created_at DESC").
-		Limit(limit).Offset(offset).
-		Find(&comments).Error
-	if err != nil {
-		return nil, err
+	// Get top-level comments only (parent_id IS NULL); the pinned one is served separately
+	query := r.db.Preload("User").
+		Where("comments.post_id = ? AND comments.parent_id IS NULL AND comments.is_pinned = ?", postID, false)
+
+	columns := append(append([]string{}, spec.keys...), "comments.created_at", "comments.id")
+	direction, op := " ASC", ">"
+	if spec.desc {
+		direction, op = " DESC", "<"
+	}
+
+	if after != nil {
+		args := make([]interface{}, 0, len(columns))
+		for _, k := range after.Keys {
+			args = append(args, k)
+		}
+		args = append(args, after.CreatedAt, after.ID)
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+		query = query.Where(fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ", "), op, placeholders), args...)
+	} else {
+		query = query.Offset(offset)
+	}
+	for _, col := range columns {
+		query = query.Order(col + direction)
 	}
 
-	// Load nested replies recursively for each top-level comment
-	for i := range comments {
-		r.loadRepliesRecursive(comments[i])
-		r.loadLikeCountRecursive(comments[i])
+	var comments []*model.Comment
+	if err := query.Limit(limit).Find(&comments).Error; err != nil {
+		return nil, err
 	}
 
 	// Cache the result
@@ -127,47 +235,71 @@ func (r *commentRepository) FindByPostID(postID string, limit, offset int) ([]*m
 	return comments, nil
 }
 
-// loadRepliesRecursive loads all nested replies for a comment recursively
-func (r *commentRepository) loadRepliesRecursive(comment *model.Comment) {
-	var replies []model.Comment
+// FindPinnedByPostID returns the post's pinned comment with its reply count
+func (r *commentRepository) FindPinnedByPostID(postID string) (*model.Comment, error) {
+	var comment model.Comment
 	err := r.db.Preload("User").
-		Preload("Parent").
-		Preload("Parent.User").
-		Where("parent_id = ?", comment.ID).
-		Order("created_at ASC").
-		Find(&replies).Error
-	
-	if err != nil || len(replies) == 0 {
-		return
-	}
-
-	// Recursively load replies for each reply
-	for i := range replies {
-		r.loadRepliesRecursive(&replies[i])
+		Where("comments.post_id = ? AND comments.parent_id IS NULL AND comments.is_pinned = ?", postID, true).
+		First(&comment).Error
+	if err != nil {
+		return nil, err
 	}
-
-	comment.Replies = replies
+	return &comment, nil
 }
 
-// loadLikeCountRecursive loads like counts for comment and all nested replies
-func (r *commentRepository) loadLikeCountRecursive(comment *model.Comment) {
-	// Load like count for this comment
-	var likeCount int64
-	r.db.Model(&model.Like{}).
-		Where("target_type = ? AND target_id = ?", model.TargetTypeComment, comment.ID).
-		Count(&likeCount)
-	comment.LikeCount = likeCount
+// SetPinned makes commentID the post's only pinned comment; nil unpins all
+func (r *commentRepository) SetPinned(postID string, commentID *string) error {
+	var pinnedIDs []string
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Comment{}).
+			Where("post_id = ? AND is_pinned = ?", postID, true).
+			Pluck("id", &pinnedIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Comment{}).
+			Where("post_id = ? AND is_pinned = ?", postID, true).
+			UpdateColumn("is_pinned", false).Error; err != nil {
+			return err
+		}
+		if commentID == nil {
+			return nil
+		}
+		result := tx.Model(&model.Comment{}).
+			Where("id = ? AND post_id = ? AND parent_id IS NULL", *commentID, postID).
+			UpdateColumn("is_pinned", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-	// Recursively load like counts for all replies
-	for i := range comment.Replies {
-		r.loadLikeCountRecursive(&comment.Replies[i])
+	// Invalidate caches
+	if r.redis != nil {
+		r.invalidatePostCache(postID)
+		for _, id := range pinnedIDs {
+			r.invalidateCommentCache(id)
+		}
+		if commentID != nil {
+			r.invalidateCommentCache(*commentID)
+		}
 	}
+	return nil
 }
 
-// FindByParentID finds replies to a comment
-func (r *commentRepository) FindByParentID(parentID string, limit, offset int) ([]*model.Comment, error) {
+// FindByParentID finds a page of direct replies to a comment (oldest first) with their reply counts.
+// When after is set the page starts after that cursor and offset is ignored.
+func (r *commentRepository) FindByParentID(parentID string, limit, offset int, after *CommentCursor) ([]*model.Comment, error) {
 	// Try cache first
 	cacheKey := fmt.Sprintf("%s%s:%d:%d", commentByParentCachePrefix, parentID, limit, offset)
+	if after != nil {
+		cacheKey = fmt.Sprintf("%s%s:%d:%s", commentByParentCachePrefix, parentID, limit, util.EncodeCursor(after))
+	}
 	if r.redis != nil {
 		cached, err := r.getListFromCache(cacheKey)
 		if err == nil && cached != nil {
@@ -176,24 +308,22 @@ func (r *commentRepository) FindByParentID(parentID string, limit, offset int) (
 	}
 
 	// If not in cache, get from database
+	query := r.db.Preload("User").
+		Where("comments.parent_id = ?", parentID)
+	if after != nil {
+		query = query.Where("(comments.created_at, comments.id) > (?, ?)", after.CreatedAt, after.ID)
+	} else {
+		query = query.Offset(offset)
+	}
+
 	var comments []*model.Comment
-	err := r.db.Preload("User").
-		Preload("Parent").
-		Preload("Parent.User").
-		Where("parent_id = ?", parentID).
-		Order("created_at ASC").
-		Limit(limit).Offset(offset).
+	err := query.Order("comments.created_at ASC, comments.id ASC").
+		Limit(limit).
 		Find(&comments).Error
 	if err != nil {
 		return nil, err
 	}
 
-	// Load nested replies and like counts for each comment
-	for i := range comments {
-		r.loadRepliesRecursive(comments[i])
-		r.loadLikeCountRecursive(comments[i])
-	}
-
 	// Cache the result
 	if r.redis != nil {
 		r.cacheCommentList(cacheKey, comments)
@@ -202,9 +332,29 @@ func (r *commentRepository) FindByParentID(parentID string, limit, offset int) (
 	return comments, nil
 }
 
+// FindDescendants loads up to maxDepth levels of replies below rootIDs, at most perParent
+// replies per comment and maxRows in total, in a single recursive query. Rows are flat
+// (oldest first) and carry their reply counts; the caller assembles the tree.
+func (r *commentRepository) FindDescendants(rootIDs []string, maxDepth, perParent, maxRows int) ([]*model.Comment, error) {
+	if len(rootIDs) == 0 || maxDepth < 1 || perParent < 1 || maxRows < 1 {
+		return []*model.Comment{}, nil
+	}
+
+	var comments []*model.Comment
+	err := r.db.Preload("User").
+		Where("comments.id IN (?)", gorm.Expr(commentThreadSQL, rootIDs, perParent, maxDepth, maxRows)).
+		Order("comments.created_at ASC, comments.id ASC").
+		Find(&comments).Error
+	if err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
 // Update updates a comment and invalidates cache
 func (r *commentRepository) Update(comment *model.Comment) error {
-	if err := r.db.Save(comment).Error; err != nil {
+	// Counters may be stale on a cached comment and are only changed atomically
+	if err := r.db.Omit(commentCounterColumns...).Save(comment).Error; err != nil {
 		return err
 	}
 
@@ -232,7 +382,14 @@ func (r *commentRepository) Delete(id string) error {
 	parentID := comment.ParentID
 
 	// Delete from database (soft delete)
-	if err := r.db.Delete(&comment).Error; err != nil {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&comment)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error // Already deleted concurrently; nothing to count
+		}
+		return adjustCommentCounters(tx, &comment, -1)
+	})
+	if err != nil {
 		return err
 	}
 
@@ -242,15 +399,14 @@ func (r *commentRepository) Delete(id string) error {
 		r.invalidatePostCache(postID)
 		r.invalidateCountCache(postID)
 		if parentID != nil {
-			r.invalidateParentCache(*parentID)
-			r.invalidateParentCountCache(*parentID)
+			r.invalidateThreadCache(*parentID)
 		}
 	}
 
 	return nil
 }
 
-// CountByPostID counts comments by post ID
+// CountByPostID returns the post's stored comment counter (includes replies)
 func (r *commentRepository) CountByPostID(postID string) (int64, error) {
 	// Try cache first
 	cacheKey := commentCountCachePrefix + "post:" + postID
@@ -265,9 +421,10 @@ func (r *commentRepository) CountByPostID(postID string) (int64, error) {
 	}
 
 	var count int64
-	err := r.db.Model(&model.Comment{}).
-		Where("post_id = ?", postID).
-		Count(&count).Error
+	err := r.db.Model(&model.Post{}).
+		Select(postCommentsCountColumn).
+		Where("id = ?", postID).
+		Scan(&count).Error
 	if err != nil {
 		return 0, err
 	}
@@ -280,26 +437,25 @@ func (r *commentRepository) CountByPostID(postID string) (int64, error) {
 	return count, nil
 }
 
-// CountByPostIDs counts comments for multiple posts in one query (includes replies)
+// CountByPostIDs reads the stored comment counters of multiple posts in one query (includes replies)
 func (r *commentRepository) CountByPostIDs(postIDs []string) (map[string]int64, error) {
 	if len(postIDs) == 0 {
 		return map[string]int64{}, nil
 	}
 	var results []struct {
-		PostID string
-		Count  int64
+		ID    string
+		Count int64
 	}
-	err := r.db.Model(&model.Comment{}).
-		Select("post_id, count(*) as count").
-		Where("post_id IN ?", postIDs).
-		Group("post_id").
+	err := r.db.Model(&model.Post{}).
+		Select("id, "+postCommentsCountColumn+" AS count").
+		Where("id IN ?", postIDs).
 		Find(&results).Error
 	if err != nil {
 		return nil, err
 	}
 	m := make(map[string]int64)
 	for _, row := range results {
-		m[row.PostID] = row.Count
+		m[row.ID] = row.Count
 	}
 	for _, id := range postIDs {
 		if _, ok := m[id]; !ok {
@@ -309,7 +465,7 @@ func (r *commentRepository) CountByPostIDs(postIDs []string) (map[string]int64,
 	return m, nil
 }
 
-// CountByParentID counts replies to a comment
+// CountByParentID returns the comment's stored reply counter
 func (r *commentRepository) CountByParentID(parentID string) (int64, error) {
 	// Try cache first
 	cacheKey := commentCountCachePrefix + "parent:" + parentID
@@ -325,8 +481,9 @@ func (r *commentRepository) CountByParentID(parentID string) (int64, error) {
 
 	var count int64
 	err := r.db.Model(&model.Comment{}).
-		Where("parent_id = ?", parentID).
-		Count(&count).Error
+		Select(commentReplyCountColumn).
+		Where("id = ?", parentID).
+		Scan(&count).Error
 	if err != nil {
 		return 0, err
 	}
@@ -339,6 +496,31 @@ func (r *commentRepository) CountByParentID(parentID string) (int64, error) {
 	return count, nil
 }
 
+// InvalidateReactionCaches clears the comment and the lists sorted by its reaction counts,
+// which LikeRepository updates together with the likes
+func (r *commentRepository) InvalidateReactionCaches(comment *model.Comment) {
+	if r.redis == nil {
+		return
+	}
+	r.invalidateCommentCache(comment.ID)
+	r.invalidatePostCache(comment.PostID)
+	if comment.ParentID != nil {
+		r.invalidateParentCache(*comment.ParentID)
+	}
+}
+
+// adjustCommentCounters moves the post's comment counter and the parent's reply counter
+// when a comment is created (delta 1) or deleted (delta -1)
+func adjustCommentCounters(tx *gorm.DB, comment *model.Comment, delta int64) error {
+	if err := adjustPostCounter(tx, comment.PostID, postCommentsCountColumn, delta); err != nil {
+		return err
+	}
+	if comment.ParentID != nil {
+		return adjustCommentCounter(tx, *comment.ParentID, commentReplyCountColumn, delta)
+	}
+	return nil
+}
+
 // Cache helpers
 func (r *commentRepository) cacheComment(comment *model.Comment) {
 	if r.redis == nil {
@@ -424,6 +606,22 @@ func (r *commentRepository) invalidateParentCache(parentID string) {
 	r.redis.DeletePattern(commentByParentCachePrefix + parentID + ":*")
 }
 
+// invalidateThreadCache clears parentID with its reply lists and count, plus the reply list
+// containing parentID itself (its reply_count changed)
+func (r *commentRepository) invalidateThreadCache(parentID string) {
+	if r.redis == nil {
+		return
+	}
+	r.invalidateCommentCache(parentID)
+	r.invalidateParentCache(parentID)
+	r.invalidateParentCountCache(parentID)
+
+	var grandparentID *string
+	if err := r.db.Model(&model.Comment{}).Select("parent_id").Where("id = ?", parentID).Scan(&grandparentID).Error; err == nil && grandparentID != nil {
+		r.invalidateParentCache(*grandparentID)
+	}
+}
+
 func (r *commentRepository) invalidateCountCache(postID string) {
 	if r.redis == nil {
 		return
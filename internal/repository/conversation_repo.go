@@ -0,0 +1,475 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"yourapp/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrAttachmentsUnavailable is returned when a message's attachments were already sent or are
+// not the sender's
+var ErrAttachmentsUnavailable = errors.New("attachments are not available")
+
+// ErrConversationNotFound is returned when two users have no direct conversation yet
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// selectPublicUser limits a preloaded user to the columns other users of a chat may see
+func selectPublicUser(db *gorm.DB) *gorm.DB {
+	return db.Select("id", "username", "full_name", "profile_photo")
+}
+
+type ConversationRepository interface {
+	// FindOrCreateDirect returns the direct conversation of two users, creating it (with both
+	// participants) on their first message
+	FindOrCreateDirect(userA, userB string) (*model.Conversation, error)
+	FindDirect(userA, userB string) (*model.Conversation, error)
+	// FindByID finds a conversation with its participants and last message
+	FindByID(id string) (*model.Conversation, error)
+	FindParticipant(conversationID, userID string) (*model.ConversationParticipant, error)
+	// FindByUserID finds a page of the user's unpinned conversations, most recently active first.
+	// When after is set the page starts after that cursor.
+	FindByUserID(userID string, archived bool, limit int, after *ConversationCursor) ([]*model.Conversation, error)
+	// FindPinnedByUserID finds the user's pinned conversations, most recently pinned first
+	FindPinnedByUserID(userID string) ([]*model.Conversation, error)
+	// FindRequestsByUserID finds a page of the message requests waiting for the user's answer,
+	// most recently active first. They are left out of the user's other conversation lists.
+	FindRequestsByUserID(userID string, limit int, after *ConversationCursor) ([]*model.Conversation, error)
+	CountRequests(userID string) (int64, error)
+	CountPinned(userID string) (int64, error)
+	// FindUnreadByUserID finds the user's inbox conversations with unread messages that they have
+	// not muted, most unread first
+	FindUnreadByUserID(userID string, limit int) ([]*model.Conversation, error)
+	// CountUnreadMessages sums the unread messages of those conversations
+	CountUnreadMessages(userID string) (int64, error)
+	// AddMessage stores msg in the conversation and moves the conversation's last message and
+	// activity forward, counting the message as unread for every other participant
+	AddMessage(conversation *model.Conversation, msg *model.ChatMessage) error
+	// CreateGroup creates a group conversation with its participants and first system message
+	CreateGroup(conversation *model.Conversation, participants []*model.ConversationParticipant, msg *model.ChatMessage) error
+	// UpdateConversation changes a group's title and/or avatar, or the disappearing timer, and
+	// records msg
+	UpdateConversation(conversationID string, updates map[string]interface{}, msg *model.ChatMessage) error
+	// AddParticipants adds users (existing participants are kept as they are) and records msg
+	AddParticipants(conversationID string, participants []*model.ConversationParticipant, msg *model.ChatMessage) error
+	// RemoveParticipant removes the user from the conversation and records msg for the others
+	RemoveParticipant(conversationID, userID string, msg *model.ChatMessage) error
+	// HandOverAndLeave makes the successor an admin and removes the leaving admin together, so
+	// the group is never left without an admin, and records left and then promoted
+	HandOverAndLeave(conversationID, userID, successorID string, left, promoted *model.ChatMessage) error
+	// SetRole changes a participant's role and records msg
+	SetRole(conversationID, userID, role string, msg *model.ChatMessage) error
+	// MarkRead marks the conversation read up to its last message for the user. The read time
+	// of the direct messages to the user is only recorded with receipts.
+	MarkRead(conversationID, userID string, receipts bool) error
+	// ClearUnread resets the unread count of a message request's recipient without recording a
+	// read position or read times, so its sender cannot tell the request was read
+	ClearUnread(conversationID, userID string) error
+	UpdateParticipant(conversationID, userID string, updates map[string]interface{}) error
+}
+
+// ConversationCursor is the keyset position of a conversation in an inbox page
+type ConversationCursor struct {
+	LastActivityAt time.Time `json:"t"`
+	ID             string    `json:"id"`
+}
+
+// NewConversationCursor builds the cursor pointing after conversation
+func NewConversationCursor(conversation *model.Conversation) ConversationCursor {
+	return ConversationCursor{LastActivityAt: conversation.LastActivityAt, ID: conversation.ID}
+}
+
+type conversationRepository struct {
+	db *gorm.DB
+}
+
+func NewConversationRepository(db *gorm.DB) ConversationRepository {
+	return &conversationRepository{db: db}
+}
+
+// FindOrCreateDirect finds the direct conversation by its key or creates it. Concurrent first
+// messages of the same pair end up in the same conversation thanks to the unique key.
+func (r *conversationRepository) FindOrCreateDirect(userA, userB string) (*model.Conversation, error) {
+	conversation, err := r.FindDirect(userA, userB)
+	if err == nil {
+		return conversation, nil
+	}
+	if !errors.Is(err, ErrConversationNotFound) {
+		return nil, err
+	}
+
+	key := model.DirectConversationKey(userA, userB)
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		conversation := &model.Conversation{
+			Type:           model.ConversationTypeDirect,
+			DirectKey:      &key,
+			LastActivityAt: time.Now(),
+		}
+		res := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "direct_key"}},
+			DoNothing: true,
+		}).Omit(clause.Associations).Create(conversation)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error // Created by a concurrent request
+		}
+
+		participants := []*model.ConversationParticipant{
+			{ConversationID: conversation.ID, UserID: userA, Role: model.ConversationRoleMember},
+			{ConversationID: conversation.ID, UserID: userB, Role: model.ConversationRoleMember},
+		}
+		return tx.Omit(clause.Associations).Create(&participants).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.FindDirect(userA, userB)
+}
+
+// FindDirect finds the direct conversation of two users, or returns ErrConversationNotFound
+func (r *conversationRepository) FindDirect(userA, userB string) (*model.Conversation, error) {
+	var conversation model.Conversation
+	err := r.db.Preload("Participants").
+		Where("direct_key = ?", model.DirectConversationKey(userA, userB)).
+		First(&conversation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// FindByID finds a conversation by ID
+func (r *conversationRepository) FindByID(id string) (*model.Conversation, error) {
+	var conversation model.Conversation
+	err := r.db.Preload("Participants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("conversation_participants.created_at ASC")
+	}).Preload("Participants.User", selectPublicUser).Preload("LastMessage.Attachments").
+		Where("id = ?", id).
+		First(&conversation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// FindParticipant finds the user's participation in a conversation
+func (r *conversationRepository) FindParticipant(conversationID, userID string) (*model.ConversationParticipant, error) {
+	var participant model.ConversationParticipant
+	err := r.db.Where("conversation_id = ? AND user_id = ?", conversationID, userID).First(&participant).Error
+	if err != nil {
+		return nil, err
+	}
+	return &participant, nil
+}
+
+// FindByUserID finds a page of the user's archived or unarchived conversations
+func (r *conversationRepository) FindByUserID(userID string, archived bool, limit int, after *ConversationCursor) ([]*model.Conversation, error) {
+	query := r.inboxQuery(userID).Where("cp.pinned_at IS NULL")
+	if archived {
+		query = query.Where("cp.archived_at IS NOT NULL")
+	} else {
+		query = query.Where("cp.archived_at IS NULL")
+	}
+	if after != nil {
+		query = query.Where("(conversations.last_activity_at, conversations.id) < (?, ?)", after.LastActivityAt, after.ID)
+	}
+
+	var conversations []*model.Conversation
+	err := query.Order("conversations.last_activity_at DESC, conversations.id DESC").
+		Limit(limit).
+		Find(&conversations).Error
+	if err != nil {
+		return nil, err
+	}
+	return conversations, nil
+}
+
+// FindPinnedByUserID finds the user's pinned conversations
+func (r *conversationRepository) FindPinnedByUserID(userID string) ([]*model.Conversation, error) {
+	var conversations []*model.Conversation
+	err := r.inboxQuery(userID).
+		Where("cp.pinned_at IS NOT NULL").
+		Order("cp.pinned_at DESC").
+		Find(&conversations).Error
+	if err != nil {
+		return nil, err
+	}
+	return conversations, nil
+}
+
+// FindRequestsByUserID finds a page of the user's pending message requests
+func (r *conversationRepository) FindRequestsByUserID(userID string, limit int, after *ConversationCursor) ([]*model.Conversation, error) {
+	query := r.participantQuery(userID).Where("cp.request_status = ?", model.MessageRequestPending)
+	if after != nil {
+		query = query.Where("(conversations.last_activity_at, conversations.id) < (?, ?)", after.LastActivityAt, after.ID)
+	}
+
+	var conversations []*model.Conversation
+	err := query.Order("conversations.last_activity_at DESC, conversations.id DESC").
+		Limit(limit).
+		Find(&conversations).Error
+	if err != nil {
+		return nil, err
+	}
+	return conversations, nil
+}
+
+// CountRequests counts the user's pending message requests
+func (r *conversationRepository) CountRequests(userID string) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.ConversationParticipant{}).
+		Where("user_id = ? AND request_status = ?", userID, model.MessageRequestPending).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *conversationRepository) FindUnreadByUserID(userID string, limit int) ([]*model.Conversation, error) {
+	var conversations []*model.Conversation
+	err := r.unreadQuery(r.inboxQuery(userID)).
+		Order("cp.unread_count DESC, conversations.last_activity_at DESC").
+		Limit(limit).
+		Find(&conversations).Error
+	if err != nil {
+		return nil, err
+	}
+	return conversations, nil
+}
+
+func (r *conversationRepository) CountUnreadMessages(userID string) (int64, error) {
+	var count int64
+	err := r.unreadQuery(r.db.Table("conversation_participants cp").
+		Where("cp.user_id = ? AND (cp.request_status IS NULL OR cp.request_status = ?)", userID, model.MessageRequestAccepted)).
+		Select("COALESCE(SUM(cp.unread_count), 0)").
+		Scan(&count).Error
+	return count, err
+}
+
+// unreadQuery narrows a query on the participant cp to unread conversations that are not muted
+func (r *conversationRepository) unreadQuery(query *gorm.DB) *gorm.DB {
+	return query.Where("cp.unread_count > 0").
+		Where("NOT (cp.muted AND (cp.muted_until IS NULL OR cp.muted_until > ?))", time.Now())
+}
+
+// inboxQuery selects the conversations the user participates in, as cp, leaving out the message
+// requests they did not accept
+func (r *conversationRepository) inboxQuery(userID string) *gorm.DB {
+	return r.participantQuery(userID).
+		Where("(cp.request_status IS NULL OR cp.request_status = ?)", model.MessageRequestAccepted)
+}
+
+// participantQuery selects every conversation the user participates in, as cp
+func (r *conversationRepository) participantQuery(userID string) *gorm.DB {
+	return r.db.Preload("Participants.User", selectPublicUser).Preload("LastMessage.Attachments").
+		Joins("JOIN conversation_participants cp ON cp.conversation_id = conversations.id AND cp.user_id = ?", userID)
+}
+
+// CountPinned counts the user's pinned conversations
+func (r *conversationRepository) CountPinned(userID string) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.ConversationParticipant{}).
+		Where("user_id = ? AND pinned_at IS NOT NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// AddMessage creates msg and updates the conversation and its participants in one transaction
+func (r *conversationRepository) AddMessage(conversation *model.Conversation, msg *model.ChatMessage) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return addMessage(tx, conversation.ID, msg)
+	})
+}
+
+// addMessage stores msg as the conversation's last message. It counts as unread for every other
+// participant and brings an archived conversation back to the inbox, except for recipients
+// who muted it.
+func addMessage(tx *gorm.DB, conversationID string, msg *model.ChatMessage) error {
+	msg.ConversationID = &conversationID
+	if err := tx.Omit(clause.Associations).Create(msg).Error; err != nil {
+		return err
+	}
+
+	if len(msg.Attachments) > 0 {
+		ids := make([]string, len(msg.Attachments))
+		for i, attachment := range msg.Attachments {
+			ids[i] = attachment.ID
+		}
+		res := tx.Model(&model.ChatAttachment{}).
+			Where("id IN ? AND uploader_id = ? AND message_id IS NULL", ids, msg.SenderID).
+			Update("message_id", msg.ID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != int64(len(ids)) {
+			return ErrAttachmentsUnavailable // Sent with another message meanwhile
+		}
+	}
+
+	err := tx.Model(&model.Conversation{}).Where("id = ?", conversationID).Updates(map[string]interface{}{
+		"last_message_id":  msg.ID,
+		"last_activity_at": msg.CreatedAt,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Model(&model.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id <> ?", conversationID, msg.SenderID).
+		Updates(map[string]interface{}{
+			"unread_count": gorm.Expr("unread_count + 1"),
+			"archived_at":  gorm.Expr("CASE WHEN muted AND (muted_until IS NULL OR muted_until > ?) THEN archived_at END", msg.CreatedAt),
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Model(&model.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ?", conversationID, msg.SenderID).
+		Update("archived_at", nil).Error
+}
+
+// CreateGroup creates the conversation, its participants and msg in one transaction
+func (r *conversationRepository) CreateGroup(conversation *model.Conversation, participants []*model.ConversationParticipant, msg *model.ChatMessage) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(conversation).Error; err != nil {
+			return err
+		}
+		for _, participant := range participants {
+			participant.ConversationID = conversation.ID
+		}
+		if err := tx.Omit(clause.Associations).Create(&participants).Error; err != nil {
+			return err
+		}
+		return addMessage(tx, conversation.ID, msg)
+	})
+}
+
+// UpdateConversation updates the conversation's columns and records msg
+func (r *conversationRepository) UpdateConversation(conversationID string, updates map[string]interface{}, msg *model.ChatMessage) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Conversation{}).Where("id = ?", conversationID).Updates(updates).Error; err != nil {
+			return err
+		}
+		return addMessage(tx, conversationID, msg)
+	})
+}
+
+// AddParticipants inserts the participants and records msg
+func (r *conversationRepository) AddParticipants(conversationID string, participants []*model.ConversationParticipant, msg *model.ChatMessage) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, participant := range participants {
+			participant.ConversationID = conversationID
+		}
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Omit(clause.Associations).
+			Create(&participants).Error
+		if err != nil {
+			return err
+		}
+		return addMessage(tx, conversationID, msg)
+	})
+}
+
+// RemoveParticipant deletes the participant and records msg
+func (r *conversationRepository) RemoveParticipant(conversationID, userID string, msg *model.ChatMessage) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+			Delete(&model.ConversationParticipant{}).Error
+		if err != nil {
+			return err
+		}
+		return addMessage(tx, conversationID, msg)
+	})
+}
+
+// HandOverAndLeave promotes the successor, deletes the leaving participant and records both messages
+func (r *conversationRepository) HandOverAndLeave(conversationID, userID, successorID string, left, promoted *model.ChatMessage) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.ConversationParticipant{}).
+			Where("conversation_id = ? AND user_id = ?", conversationID, successorID).
+			Update("role", model.ConversationRoleAdmin).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+			Delete(&model.ConversationParticipant{}).Error
+		if err != nil {
+			return err
+		}
+		if err := addMessage(tx, conversationID, left); err != nil {
+			return err
+		}
+		return addMessage(tx, conversationID, promoted)
+	})
+}
+
+// SetRole updates the participant's role and records msg
+func (r *conversationRepository) SetRole(conversationID, userID, role string, msg *model.ChatMessage) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.ConversationParticipant{}).
+			Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+			Update("role", role).Error
+		if err != nil {
+			return err
+		}
+		return addMessage(tx, conversationID, msg)
+	})
+}
+
+// MarkRead resets the user's unread count and marks the conversation's messages to them as read
+func (r *conversationRepository) MarkRead(conversationID, userID string, receipts bool) error {
+	return r.markRead(conversationID, userID, true, receipts)
+}
+
+// ClearUnread resets the user's unread count and marks the messages to them as read, leaving
+// their read position alone
+func (r *conversationRepository) ClearUnread(conversationID, userID string) error {
+	return r.markRead(conversationID, userID, false, false)
+}
+
+func (r *conversationRepository) markRead(conversationID, userID string, position, receipts bool) error {
+	now := time.Now()
+	participantUpdates := map[string]interface{}{}
+	if position {
+		participantUpdates["last_read_message_id"] = gorm.Expr("(SELECT last_message_id FROM conversations WHERE id = ?)", conversationID)
+		participantUpdates["last_read_at"] = now
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return readConversation(tx, conversationID, userID, participantUpdates, receipts, now)
+	})
+}
+
+// readConversation resets the user's unread count, together with participantUpdates, and marks
+// the conversation's messages to them as read. Their read time is only recorded with receipts.
+func readConversation(tx *gorm.DB, conversationID, userID string, participantUpdates map[string]interface{}, receipts bool, now time.Time) error {
+	participantUpdates["unread_count"] = 0
+	err := tx.Model(&model.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+		Updates(participantUpdates).Error
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"is_read":      true,
+		"delivered_at": gorm.Expr("COALESCE(delivered_at, ?)", now),
+	}
+	if receipts {
+		updates["read_at"] = now
+	}
+	return tx.Model(&model.ChatMessage{}).
+		Where("conversation_id = ? AND receiver_id = ? AND is_read = ?", conversationID, userID, false).
+		Updates(updates).Error
+}
+
+// UpdateParticipant updates the user's inbox state (archive, mute, pin, message request) in a
+// conversation
+func (r *conversationRepository) UpdateParticipant(conversationID, userID string, updates map[string]interface{}) error {
+	return r.db.Model(&model.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+		Updates(updates).Error
+}
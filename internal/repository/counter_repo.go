@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"fmt"
+
+	"yourapp/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Stored engagement counter columns. Writers adjust them in the same transaction as the
+// rows they count; CounterRepository.Reconcile recomputes them to repair any drift.
+const (
+	postLikesCountColumn    = "likes_count"
+	postCommentsCountColumn = "comments_count"
+	postViewsCountColumn    = "views_count"
+	postSharesCountColumn   = "shares_count"
+	commentReplyCountColumn = "reply_count"
+)
+
+// counterColumns are never written by Save-style updates of a (possibly cached) row
+var (
+	postCounterColumns    = []string{postLikesCountColumn, postCommentsCountColumn, postViewsCountColumn, postSharesCountColumn}
+	commentCounterColumns = []string{commentReplyCountColumn, "reaction_count", "negative_reaction_count"}
+)
+
+// counterReconcileBatchSize is how many rows Reconcile locks and recomputes per transaction
+const counterReconcileBatchSize = 500
+
+// counterDefinition describes how to recompute one stored counter
+type counterDefinition struct {
+	name   string // Reported name, e.g. post.likes_count
+	table  string
+	column string
+	actual string // Correlated subquery counting the real rows for t.id
+}
+
+var counterDefinitions = []counterDefinition{
+	{
+		name: "post.likes_count", table: "posts", column: postLikesCountColumn,
+		actual: "SELECT COUNT(*) FROM likes l WHERE l.target_type = 'post' AND l.target_id = t.id",
+	},
+	{
+		name: "post.comments_count", table: "posts", column: postCommentsCountColumn,
+		actual: "SELECT COUNT(*) FROM comments c WHERE c.post_id = t.id AND c.deleted_at IS NULL",
+	},
+	{
+		name: "post.views_count", table: "posts", column: postViewsCountColumn,
+		actual: "SELECT COUNT(*) FROM post_views v WHERE v.post_id = t.id",
+	},
+	{
+		name: "post.shares_count", table: "posts", column: postSharesCountColumn,
+		actual: "SELECT COUNT(*) FROM posts s WHERE s.shared_post_id = t.id AND s.deleted_at IS NULL",
+	},
+	{
+		name: "comment.reply_count", table: "comments", column: commentReplyCountColumn,
+		actual: "SELECT COUNT(*) FROM comments r WHERE r.parent_id = t.id AND r.deleted_at IS NULL",
+	},
+	{
+		name: "comment.reaction_count", table: "comments", column: "reaction_count",
+		actual: "SELECT COUNT(*) FROM likes l WHERE l.target_type = 'comment' AND l.target_id = t.id",
+	},
+	{
+		name: "comment.negative_reaction_count", table: "comments", column: "negative_reaction_count",
+		actual: fmt.Sprintf("SELECT COUNT(*) FROM likes l WHERE l.target_type = 'comment' AND l.target_id = t.id AND l.reaction IN ('%s', '%s')",
+			model.ReactionSad, model.ReactionAngry),
+	},
+}
+
+type CounterRepository interface {
+	// Reconcile recomputes every stored counter and returns how many rows were fixed, per counter
+	Reconcile() (map[string]int64, error)
+}
+
+type counterRepository struct {
+	db *gorm.DB
+}
+
+func NewCounterRepository(db *gorm.DB) CounterRepository {
+	return &counterRepository{db: db}
+}
+
+// Reconcile walks each counted table in keyed batches. Every batch locks its rows before
+// recomputing them, so a writer adjusting a counter waits and then applies its delta to the
+// repaired value. Only rows whose stored value differs from the real count are written, so
+// the affected row count is the number of counters that had drifted.
+func (r *counterRepository) Reconcile() (map[string]int64, error) {
+	fixed := make(map[string]int64, len(counterDefinitions))
+	for _, def := range counterDefinitions {
+		n, err := r.reconcileCounter(def)
+		if err != nil {
+			return fixed, fmt.Errorf("failed to reconcile %s: %w", def.name, err)
+		}
+		fixed[def.name] = n
+	}
+	return fixed, nil
+}
+
+// reconcileCounter repairs one counter, counterReconcileBatchSize rows per transaction
+func (r *counterRepository) reconcileCounter(def counterDefinition) (int64, error) {
+	sql := fmt.Sprintf(`UPDATE %s AS t SET %s = (%s) WHERE t.id IN ? AND t.%s <> (%s)`,
+		def.table, def.column, def.actual, def.column, def.actual)
+
+	var fixed int64
+	after := ""
+	for {
+		var ids []string
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			query := tx.Table(def.table).Clauses(clause.Locking{Strength: "UPDATE"}).
+				Order("id").Limit(counterReconcileBatchSize)
+			if after != "" {
+				query = query.Where("id > ?", after)
+			}
+			if err := query.Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			if len(ids) == 0 {
+				return nil
+			}
+			result := tx.Exec(sql, ids)
+			if result.Error != nil {
+				return result.Error
+			}
+			fixed += result.RowsAffected
+			return nil
+		})
+		if err != nil {
+			return fixed, err
+		}
+		if len(ids) < counterReconcileBatchSize {
+			return fixed, nil
+		}
+		after = ids[len(ids)-1]
+	}
+}
+
+// adjustPostCounter atomically moves one of a post's counters by delta (never below zero)
+func adjustPostCounter(tx *gorm.DB, postID, column string, delta int64) error {
+	return tx.Model(&model.Post{}).
+		Where("id = ?", postID).
+		UpdateColumn(column, gorm.Expr(fmt.Sprintf("GREATEST(%s + ?, 0)", column), delta)).Error
+}
+
+// adjustCommentCounter atomically moves one of a comment's counters by delta (never below zero)
+func adjustCommentCounter(tx *gorm.DB, commentID, column string, delta int64) error {
+	return tx.Model(&model.Comment{}).
+		Where("id = ?", commentID).
+		UpdateColumn(column, gorm.Expr(fmt.Sprintf("GREATEST(%s + ?, 0)", column), delta)).Error
+}
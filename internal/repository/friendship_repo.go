@@ -23,6 +23,11 @@ type FriendshipRepository interface {
 	Delete(id string) error
 	DeleteBySenderAndReceiver(senderID, receiverID string) error
 	CountPendingByReceiverID(receiverID string) (int64, error)
+	// BlockRequestSender declines the user's message request in the conversation and blocks its
+	// sender, replacing any friendship between the two, in one transaction
+	BlockRequestSender(userID, senderID, conversationID string) error
+	// HasMutualFriend reports whether two users have an accepted friend in common
+	HasMutualFriend(userA, userB string) (bool, error)
 }
 
 type friendshipRepository struct {
@@ -276,6 +281,43 @@ func (r *friendshipRepository) DeleteBySenderAndReceiver(senderID, receiverID st
 	return nil
 }
 
+// BlockRequestSender declines the request without a read position, then swaps the friendship for a block
+func (r *friendshipRepository) BlockRequestSender(userID, senderID, conversationID string) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := readConversation(tx, conversationID, userID, map[string]interface{}{
+			"request_status": model.MessageRequestDeclined,
+		}, false, time.Now())
+		if err != nil {
+			return err
+		}
+		err = tx.Where("(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)",
+			userID, senderID, senderID, userID).Delete(&model.Friendship{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&model.Friendship{
+			SenderID:   userID,
+			ReceiverID: senderID,
+			Status:     model.FriendshipStatusBlocked,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// Invalidate cache
+	if r.redis != nil {
+		for _, id := range []string{userID, senderID} {
+			r.invalidateUserCache(id)
+			r.invalidatePendingCache(id)
+			r.invalidateAcceptedCache(id)
+			r.invalidateCountCache(id)
+		}
+	}
+
+	return nil
+}
+
 // CountPendingByReceiverID counts pending requests for a user
 func (r *friendshipRepository) CountPendingByReceiverID(receiverID string) (int64, error) {
 	// Try cache first
@@ -305,6 +347,19 @@ func (r *friendshipRepository) CountPendingByReceiverID(receiverID string) (int6
 	return count, nil
 }
 
+// HasMutualFriend checks for a user who is an accepted friend of both users
+func (r *friendshipRepository) HasMutualFriend(userA, userB string) (bool, error) {
+	const friendsOf = `SELECT CASE WHEN sender_id = ? THEN receiver_id ELSE sender_id END
+		FROM friendships WHERE (sender_id = ? OR receiver_id = ?) AND status = ?`
+	var exists bool
+	err := r.db.Raw(
+		"SELECT EXISTS ("+friendsOf+" INTERSECT "+friendsOf+")",
+		userA, userA, userA, model.FriendshipStatusAccepted,
+		userB, userB, userB, model.FriendshipStatusAccepted,
+	).Scan(&exists).Error
+	return exists, err
+}
+
 // Cache helpers
 func (r *friendshipRepository) cacheFriendship(friendship *model.Friendship) {
 	if r.redis == nil {
@@ -9,6 +9,7 @@ import (
 	"yourapp/internal/util"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type LikeRepository interface {
@@ -16,10 +17,13 @@ type LikeRepository interface {
 	Update(like *model.Like) error
 	FindByID(id string) (*model.Like, error)
 	FindByTarget(targetType, targetID string) ([]*model.Like, error)
+	FindByTargetAndReaction(targetType, targetID, reaction string, limit, offset int) ([]*model.Like, error)
 	FindByUserAndTarget(userID, targetType, targetID string) (*model.Like, error)
 	CountByTarget(targetType, targetID string) (int64, error)
 	CountByTargets(targetType string, targetIDs []string) (map[string]int64, error)
+	CountReactionsByTargets(targetType string, targetIDs []string) (map[string]map[string]int64, error)
 	FindUserLikedTargets(userID, targetType string, targetIDs []string) (map[string]bool, error)
+	FindUserReactions(userID, targetType string, targetIDs []string) (map[string]string, error)
 	Delete(id string) error
 	DeleteByUserAndTarget(userID, targetType, targetID string) error
 }
@@ -30,10 +34,10 @@ type likeRepository struct {
 }
 
 const (
-	likeCachePrefix        = "like:"
-	likeByTargetCachePrefix = "like:target:"
-	likeCountCachePrefix   = "like:count:"
-	likeCacheExpiration    = 10 * time.Minute
+	likeCachePrefix          = "like:"
+	likeByTargetCachePrefix  = "like:target:"
+	likeReactionsCachePrefix = "like:reactions:"
+	likeCacheExpiration      = 10 * time.Minute
 )
 
 func NewLikeRepository(db *gorm.DB, redis *util.RedisClient) LikeRepository {
@@ -45,14 +49,20 @@ func NewLikeRepository(db *gorm.DB, redis *util.RedisClient) LikeRepository {
 
 // Create creates a new like and invalidates related caches
 func (r *likeRepository) Create(like *model.Like) error {
-	if err := r.db.Create(like).Error; err != nil {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(like).Error; err != nil {
+			return err
+		}
+		return r.adjustTargetCounter(tx, like, 1)
+	})
+	if err != nil {
 		return err
 	}
 
 	// Invalidate caches
 	if r.redis != nil {
 		r.invalidateTargetCache(like.TargetType, like.TargetID)
-		r.invalidateCountCache(like.TargetType, like.TargetID)
+		r.adjustReactionCache(like.TargetType, like.TargetID, map[string]int64{like.Reaction: 1})
 	}
 
 	return nil
@@ -60,14 +70,29 @@ func (r *likeRepository) Create(like *model.Like) error {
 
 // Update updates a like and invalidates cache
 func (r *likeRepository) Update(like *model.Like) error {
-	if err := r.db.Save(like).Error; err != nil {
+	// Read the stored reaction so the counters and breakdown cache can move the count across
+	var previous string
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Model(&model.Like{}).Select("reaction").Where("id = ?", like.ID).
+			Scan(&previous).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(like).Error; err != nil {
+			return err
+		}
+		return r.adjustNegativeCounter(tx, like, previous)
+	})
+	if err != nil {
 		return err
 	}
 
 	// Invalidate caches
 	if r.redis != nil {
 		r.invalidateTargetCache(like.TargetType, like.TargetID)
-		r.invalidateCountCache(like.TargetType, like.TargetID)
+		if previous != like.Reaction {
+			r.adjustReactionCache(like.TargetType, like.TargetID, reactionChange(previous, like.Reaction))
+		}
 	}
 
 	return nil
@@ -112,6 +137,25 @@ func (r *likeRepository) FindByTarget(targetType, targetID string) ([]*model.Lik
 	return likes, nil
 }
 
+// FindByTargetAndReaction returns one page of likes for a target, optionally only those with the given reaction
+func (r *likeRepository) FindByTargetAndReaction(targetType, targetID, reaction string, limit, offset int) ([]*model.Like, error) {
+	query := r.db.Preload("User").
+		Where("target_type = ? AND target_id = ?", targetType, targetID)
+	if reaction != "" {
+		query = query.Where("reaction = ?", reaction)
+	}
+
+	var likes []*model.Like
+	err := query.Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&likes).Error
+	if err != nil {
+		return nil, err
+	}
+	return likes, nil
+}
+
 // FindByUserAndTarget finds a like by user and target (to check if user already liked)
 func (r *likeRepository) FindByUserAndTarget(userID, targetType, targetID string) (*model.Like, error) {
 	var like model.Like
@@ -122,56 +166,46 @@ func (r *likeRepository) FindByUserAndTarget(userID, targetType, targetID string
 	return &like, nil
 }
 
-// CountByTarget counts likes for a target
+// CountByTarget returns the stored like counter of a target
 func (r *likeRepository) CountByTarget(targetType, targetID string) (int64, error) {
-	// Try cache first
-	cacheKey := fmt.Sprintf("%s%s:%s", likeCountCachePrefix, targetType, targetID)
-	if r.redis != nil {
-		cached, err := r.redis.Get(cacheKey)
-		if err == nil {
-			var count int64
-			if _, err := fmt.Sscanf(cached, "%d", &count); err == nil {
-				return count, nil
-			}
-		}
+	table, column, err := likeCounterColumn(targetType)
+	if err != nil {
+		return 0, err
 	}
-
 	var count int64
-	err := r.db.Model(&model.Like{}).
-		Where("target_type = ? AND target_id = ?", targetType, targetID).
-		Count(&count).Error
+	err = r.db.Table(table).
+		Select(column).
+		Where("id = ?", targetID).
+		Scan(&count).Error
 	if err != nil {
 		return 0, err
 	}
-
-	// Cache the count
-	if r.redis != nil {
-		r.redis.Set(cacheKey, fmt.Sprintf("%d", count), likeCacheExpiration)
-	}
-
 	return count, nil
 }
 
-// CountByTargets counts likes for multiple targets in one query
+// CountByTargets reads the stored like counters of multiple targets in one query
 func (r *likeRepository) CountByTargets(targetType string, targetIDs []string) (map[string]int64, error) {
 	if len(targetIDs) == 0 {
 		return map[string]int64{}, nil
 	}
+	table, column, err := likeCounterColumn(targetType)
+	if err != nil {
+		return nil, err
+	}
 	var results []struct {
-		TargetID string
-		Count    int64
+		ID    string
+		Count int64
 	}
-	err := r.db.Model(&model.Like{}).
-		Select("target_id, count(*) as count").
-		Where("target_type = ? AND target_id IN ?", targetType, targetIDs).
-		Group("target_id").
+	err = r.db.Table(table).
+		Select("id, "+column+" AS count").
+		Where("id IN ?", targetIDs).
 		Find(&results).Error
 	if err != nil {
 		return nil, err
 	}
 	m := make(map[string]int64)
 	for _, row := range results {
-		m[row.TargetID] = row.Count
+		m[row.ID] = row.Count
 	}
 	// Ensure all IDs have entry (0 if not found)
 	for _, id := range targetIDs {
@@ -201,6 +235,86 @@ func (r *likeRepository) FindUserLikedTargets(userID, targetType string, targetI
 	return m, nil
 }
 
+// CountReactionsByTargets returns the per-reaction like counts of each target.
+// Breakdowns are cached as Redis hashes (one field per reaction) and only the
+// targets missing from the cache are counted in the database.
+func (r *likeRepository) CountReactionsByTargets(targetType string, targetIDs []string) (map[string]map[string]int64, error) {
+	result := make(map[string]map[string]int64, len(targetIDs))
+	if len(targetIDs) == 0 {
+		return result, nil
+	}
+
+	missing := targetIDs
+	if r.redis != nil {
+		keys := make([]string, len(targetIDs))
+		for i, id := range targetIDs {
+			keys[i] = reactionCacheKey(targetType, id)
+		}
+		if cached, err := r.redis.HGetAllMany(keys); err == nil {
+			missing = make([]string, 0, len(targetIDs))
+			for i, id := range targetIDs {
+				fields, ok := cached[keys[i]]
+				if !ok {
+					missing = append(missing, id)
+					continue
+				}
+				result[id] = parseReactionCounts(fields)
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		TargetID string
+		Reaction string
+		Count    int64
+	}
+	err := r.db.Model(&model.Like{}).
+		Select("target_id, reaction, count(*) as count").
+		Where("target_type = ? AND target_id IN ?", targetType, missing).
+		Group("target_id, reaction").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range missing {
+		result[id] = make(map[string]int64)
+	}
+	for _, row := range rows {
+		result[row.TargetID][row.Reaction] = row.Count
+	}
+
+	if r.redis != nil {
+		for _, id := range missing {
+			r.cacheReactionCounts(targetType, id, result[id])
+		}
+	}
+
+	return result, nil
+}
+
+// FindUserReactions returns the user's reaction on each of the targets they reacted to
+func (r *likeRepository) FindUserReactions(userID, targetType string, targetIDs []string) (map[string]string, error) {
+	if len(targetIDs) == 0 {
+		return map[string]string{}, nil
+	}
+	var likes []model.Like
+	err := r.db.Select("target_id, reaction").
+		Where("user_id = ? AND target_type = ? AND target_id IN ?", userID, targetType, targetIDs).
+		Find(&likes).Error
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string, len(likes))
+	for _, like := range likes {
+		m[like.TargetID] = like.Reaction
+	}
+	return m, nil
+}
+
 // Delete deletes a like and invalidates cache
 func (r *likeRepository) Delete(id string) error {
 	// Get like first for cache invalidation
@@ -213,14 +327,25 @@ func (r *likeRepository) Delete(id string) error {
 	targetID := like.TargetID
 
 	// Delete from database
-	if err := r.db.Delete(&like).Error; err != nil {
+	removed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&like)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error // Already removed by a concurrent unlike; nothing to count
+		}
+		removed = true
+		return r.adjustTargetCounter(tx, &like, -1)
+	})
+	if err != nil {
 		return err
 	}
 
 	// Invalidate caches
 	if r.redis != nil {
 		r.invalidateTargetCache(targetType, targetID)
-		r.invalidateCountCache(targetType, targetID)
+		if removed {
+			r.adjustReactionCache(targetType, targetID, map[string]int64{like.Reaction: -1})
+		}
 	}
 
 	return nil
@@ -235,19 +360,74 @@ func (r *likeRepository) DeleteByUserAndTarget(userID, targetType, targetID stri
 	}
 
 	// Delete from database
-	if err := r.db.Delete(&like).Error; err != nil {
+	removed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&like)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error // Already removed by a concurrent unlike; nothing to count
+		}
+		removed = true
+		return r.adjustTargetCounter(tx, &like, -1)
+	})
+	if err != nil {
 		return err
 	}
 
 	// Invalidate caches
 	if r.redis != nil {
 		r.invalidateTargetCache(targetType, targetID)
-		r.invalidateCountCache(targetType, targetID)
+		if removed {
+			r.adjustReactionCache(targetType, targetID, map[string]int64{like.Reaction: -1})
+		}
 	}
 
 	return nil
 }
 
+// likeCounterColumn returns the table and stored counter column holding a target's like count
+func likeCounterColumn(targetType string) (string, string, error) {
+	switch targetType {
+	case model.TargetTypePost:
+		return "posts", postLikesCountColumn, nil
+	case model.TargetTypeComment:
+		return "comments", "reaction_count", nil
+	}
+	return "", "", fmt.Errorf("invalid target type: %s", targetType)
+}
+
+// adjustTargetCounter keeps posts.likes_count and the comment reaction counters used by
+// comment sorting in step with the likes table
+func (r *likeRepository) adjustTargetCounter(tx *gorm.DB, like *model.Like, delta int64) error {
+	switch like.TargetType {
+	case model.TargetTypePost:
+		return adjustPostCounter(tx, like.TargetID, postLikesCountColumn, delta)
+	case model.TargetTypeComment:
+		if err := adjustCommentCounter(tx, like.TargetID, "reaction_count", delta); err != nil {
+			return err
+		}
+		if model.IsNegativeReaction(like.Reaction) {
+			return adjustCommentCounter(tx, like.TargetID, "negative_reaction_count", delta)
+		}
+	}
+	return nil
+}
+
+// adjustNegativeCounter moves a comment's negative reaction count when a like changes
+// its reaction from previous
+func (r *likeRepository) adjustNegativeCounter(tx *gorm.DB, like *model.Like, previous string) error {
+	if like.TargetType != model.TargetTypeComment {
+		return nil
+	}
+	wasNegative, isNegative := model.IsNegativeReaction(previous), model.IsNegativeReaction(like.Reaction)
+	switch {
+	case isNegative && !wasNegative:
+		return adjustCommentCounter(tx, like.TargetID, "negative_reaction_count", 1)
+	case wasNegative && !isNegative:
+		return adjustCommentCounter(tx, like.TargetID, "negative_reaction_count", -1)
+	}
+	return nil
+}
+
 // Cache helpers
 func (r *likeRepository) cacheLikeList(key string, likes []*model.Like) {
 	if r.redis == nil {
@@ -287,9 +467,48 @@ func (r *likeRepository) invalidateTargetCache(targetType, targetID string) {
 	r.redis.Delete(fmt.Sprintf("%s%s:%s", likeByTargetCachePrefix, targetType, targetID))
 }
 
-func (r *likeRepository) invalidateCountCache(targetType, targetID string) {
+func reactionCacheKey(targetType, targetID string) string {
+	return fmt.Sprintf("%s%s:%s", likeReactionsCachePrefix, targetType, targetID)
+}
+
+// cacheReactionCounts stores a full breakdown. Every reaction gets a field, even at
+// zero, so an existing hash always means the breakdown is complete.
+func (r *likeRepository) cacheReactionCounts(targetType, targetID string, counts map[string]int64) {
+	fields := make(map[string]interface{}, len(model.Reactions))
+	for _, reaction := range model.Reactions {
+		fields[reaction] = counts[reaction]
+	}
+	r.redis.HSetWithExpiry(reactionCacheKey(targetType, targetID), fields, likeCacheExpiration)
+}
+
+// adjustReactionCache applies a like/unlike/reaction change to a cached breakdown.
+// Uncached breakdowns are left alone and get counted on the next read.
+func (r *likeRepository) adjustReactionCache(targetType, targetID string, deltas map[string]int64) {
 	if r.redis == nil {
 		return
 	}
-	r.redis.Delete(fmt.Sprintf("%s%s:%s", likeCountCachePrefix, targetType, targetID))
+	key := reactionCacheKey(targetType, targetID)
+	if err := r.redis.HIncrByIfExists(key, deltas); err != nil {
+		// Drop the hash rather than leave a stale breakdown behind
+		r.redis.Delete(key)
+	}
+}
+
+func reactionChange(from, to string) map[string]int64 {
+	deltas := map[string]int64{to: 1}
+	if from != "" {
+		deltas[from] = -1
+	}
+	return deltas
+}
+
+func parseReactionCounts(fields map[string]string) map[string]int64 {
+	counts := make(map[string]int64, len(fields))
+	for reaction, value := range fields {
+		var n int64
+		if _, err := fmt.Sscanf(value, "%d", &n); err == nil && n > 0 {
+			counts[reaction] = n
+		}
+	}
+	return counts
 }
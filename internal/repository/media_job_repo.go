@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"time"
+
+	"yourapp/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MediaJobRepository interface {
+	CreateBatch(jobs []*model.MediaJob) error
+	FindByPostID(postID string) ([]*model.MediaJob, error)
+	ClaimDue(limit int, lease time.Duration) ([]*model.MediaJob, error) // Atomically moves due pending jobs to processing
+	RenewLeases(ids []string, lease time.Duration) error                // Extends the leases of jobs still being processed
+	Updates(id string, updates map[string]interface{}) error
+	RequeueExpired() (int64, error) // Re-queues processing jobs whose lease ran out (e.g. after a crash)
+	FindPostsToFinalize(limit int) ([]string, error)
+}
+
+type mediaJobRepository struct {
+	db *gorm.DB
+}
+
+func NewMediaJobRepository(db *gorm.DB) MediaJobRepository {
+	return &mediaJobRepository{db: db}
+}
+
+func (r *mediaJobRepository) CreateBatch(jobs []*model.MediaJob) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	return r.db.Create(&jobs).Error
+}
+
+func (r *mediaJobRepository) FindByPostID(postID string) ([]*model.MediaJob, error) {
+	var jobs []*model.MediaJob
+	err := r.db.Where("post_id = ?", postID).Order("position ASC").Find(&jobs).Error
+	return jobs, err
+}
+
+// ClaimDue locks due jobs with SKIP LOCKED so several workers/instances never pick the same job.
+// The claimed jobs are leased for the given duration.
+func (r *mediaJobRepository) ClaimDue(limit int, lease time.Duration) ([]*model.MediaJob, error) {
+	var jobs []*model.MediaJob
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_run_at <= ?", model.MediaJobStatusPending, time.Now()).
+			Order("next_run_at ASC").
+			Limit(limit).
+			Find(&jobs).Error; err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			return nil
+		}
+
+		leaseUntil := time.Now().Add(lease)
+		ids := make([]string, len(jobs))
+		for i, job := range jobs {
+			ids[i] = job.ID
+			job.Status = model.MediaJobStatusProcessing
+			job.Attempts++
+			job.LeaseUntil = &leaseUntil
+		}
+		return tx.Model(&model.MediaJob{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"status":      model.MediaJobStatusProcessing,
+				"attempts":    gorm.Expr("attempts + 1"),
+				"lease_until": leaseUntil,
+				"updated_at":  time.Now(),
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (r *mediaJobRepository) Updates(id string, updates map[string]interface{}) error {
+	return r.db.Model(&model.MediaJob{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// RenewLeases extends the leases of the jobs that are still processing
+func (r *mediaJobRepository) RenewLeases(ids []string, lease time.Duration) error {
+	return r.db.Model(&model.MediaJob{}).
+		Where("id IN ? AND status = ?", ids, model.MediaJobStatusProcessing).
+		Update("lease_until", time.Now().Add(lease)).Error
+}
+
+// RequeueExpired re-queues processing jobs whose worker stopped renewing their lease; a
+// processing job without a lease counts as expired
+func (r *mediaJobRepository) RequeueExpired() (int64, error) {
+	now := time.Now()
+	result := r.db.Model(&model.MediaJob{}).
+		Where("status = ? AND (lease_until IS NULL OR lease_until < ?)", model.MediaJobStatusProcessing, now).
+		Updates(map[string]interface{}{
+			"status":      model.MediaJobStatusPending,
+			"next_run_at": now,
+			"lease_until": nil,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// FindPostsToFinalize returns processing posts whose media jobs have all reached a terminal state
+func (r *mediaJobRepository) FindPostsToFinalize(limit int) ([]string, error) {
+	var postIDs []string
+	err := r.db.Raw(`
+		SELECT p.id FROM posts p
+		WHERE p.media_status = ? AND p.deleted_at IS NULL
+		AND EXISTS (SELECT 1 FROM media_jobs j WHERE j.post_id = p.id)
+		AND NOT EXISTS (
+			SELECT 1 FROM media_jobs j
+			WHERE j.post_id = p.id AND j.status IN (?, ?)
+		)
+		LIMIT ?`,
+		model.PostMediaStatusProcessing, model.MediaJobStatusPending, model.MediaJobStatusProcessing, limit,
+	).Scan(&postIDs).Error
+	return postIDs, err
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"yourapp/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrUploadsUnavailable is returned when an upload was consumed, aborted or changed meanwhile
+var ErrUploadsUnavailable = errors.New("uploads are not available")
+
+type MediaUploadRepository interface {
+	Create(upload *model.MediaUpload) error
+	FindByID(id string) (*model.MediaUpload, error)
+	FindByIDs(ids []string) ([]*model.MediaUpload, error)
+	// AdvanceOffset moves an uploading upload from the expected offset to offset, setting status.
+	// It returns false when the upload is no longer uploading at the expected offset.
+	AdvanceOffset(id string, expected, offset int64, status string) (bool, error)
+	Consume(userID string, ids []string) ([]*model.MediaUpload, error) // Claim completed uploads all at once
+	Delete(id string) error
+	// DeleteUnconsumed deletes the upload unless it was consumed; false means it was (or is gone)
+	DeleteUnconsumed(id string) (bool, error)
+	FindExpired(before time.Time) ([]*model.MediaUpload, error)
+}
+
+type mediaUploadRepository struct {
+	db *gorm.DB
+}
+
+func NewMediaUploadRepository(db *gorm.DB) MediaUploadRepository {
+	return &mediaUploadRepository{db: db}
+}
+
+func (r *mediaUploadRepository) Create(upload *model.MediaUpload) error {
+	return r.db.Create(upload).Error
+}
+
+func (r *mediaUploadRepository) FindByID(id string) (*model.MediaUpload, error) {
+	var upload model.MediaUpload
+	err := r.db.Where("id = ?", id).First(&upload).Error
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+func (r *mediaUploadRepository) FindByIDs(ids []string) ([]*model.MediaUpload, error) {
+	var uploads []*model.MediaUpload
+	if len(ids) == 0 {
+		return uploads, nil
+	}
+	err := r.db.Where("id IN ?", ids).Find(&uploads).Error
+	return uploads, err
+}
+
+// AdvanceOffset is a compare-and-set on the offset, so of two chunks written at the same
+// offset only one is committed
+func (r *mediaUploadRepository) AdvanceOffset(id string, expected, offset int64, status string) (bool, error) {
+	result := r.db.Model(&model.MediaUpload{}).
+		Where("id = ? AND upload_offset = ? AND status = ?", id, expected, model.MediaUploadStatusUploading).
+		Updates(map[string]interface{}{
+			"upload_offset": offset,
+			"status":        status,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// Consume marks the user's completed uploads as consumed in one statement. Unless every one of
+// them was still completed, nothing is claimed and ErrUploadsUnavailable is returned.
+func (r *mediaUploadRepository) Consume(userID string, ids []string) ([]*model.MediaUpload, error) {
+	var uploads []*model.MediaUpload
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&uploads).Clauses(clause.Returning{}).
+			Where("id IN ? AND user_id = ? AND status = ?", ids, userID, model.MediaUploadStatusCompleted).
+			Update("status", model.MediaUploadStatusConsumed)
+		if result.Error != nil {
+			return result.Error
+		}
+		if int(result.RowsAffected) != len(ids) {
+			return ErrUploadsUnavailable
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
+
+func (r *mediaUploadRepository) Delete(id string) error {
+	return r.db.Where("id = ?", id).Delete(&model.MediaUpload{}).Error
+}
+
+// DeleteUnconsumed checks the status in the same statement, so it cannot race Consume
+func (r *mediaUploadRepository) DeleteUnconsumed(id string) (bool, error) {
+	result := r.db.Where("id = ? AND status <> ?", id, model.MediaUploadStatusConsumed).Delete(&model.MediaUpload{})
+	return result.RowsAffected == 1, result.Error
+}
+
+// FindExpired returns uploads that were never attached to a post before their expiry
+func (r *mediaUploadRepository) FindExpired(before time.Time) ([]*model.MediaUpload, error) {
+	var uploads []*model.MediaUpload
+	err := r.db.Where("expires_at < ? AND status <> ?", before, model.MediaUploadStatusConsumed).
+		Limit(500).Find(&uploads).Error
+	return uploads, err
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"yourapp/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationPreferenceRepository interface {
+	// FindByUserID returns the types the user configured; unconfigured types have no row
+	FindByUserID(userID string) ([]*model.NotificationPreference, error)
+	// FindByUserAndType returns the user's preference for a type, or the default one
+	FindByUserAndType(userID, notifType string) (*model.NotificationPreference, error)
+	Upsert(prefs []*model.NotificationPreference) error
+	// FindSettings returns the user's settings, or the default ones
+	FindSettings(userID string) (*model.NotificationSettings, error)
+	// FindSettingsByUserIDs returns the settings of each user, or the default ones
+	FindSettingsByUserIDs(userIDs []string) (map[string]*model.NotificationSettings, error)
+	UpsertSettings(settings *model.NotificationSettings) error
+	// FindDigestCandidates returns users whose email digest is due and who have unread
+	// notifications or chat messages
+	FindDigestCandidates(now time.Time, limit int) ([]*DigestCandidate, error)
+	// ClaimDigest records now as the user's last digest unless another run already sent one
+	// within the interval; false means the digest is not due (anymore)
+	ClaimDigest(userID string, interval time.Duration, now time.Time) (bool, error)
+	// ReleaseDigest undoes the claim made at claimedAt when the digest could not be queued
+	ReleaseDigest(userID string, claimedAt time.Time, previous *time.Time) error
+	SetDigestFrequency(userID, frequency string) error
+}
+
+// DigestCandidate is a user due for an email digest
+type DigestCandidate struct {
+	UserID    string
+	Frequency string
+}
+
+type notificationPreferenceRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationPreferenceRepository(db *gorm.DB) NotificationPreferenceRepository {
+	return &notificationPreferenceRepository{db: db}
+}
+
+// FindByUserID finds all stored preferences of a user
+func (r *notificationPreferenceRepository) FindByUserID(userID string) ([]*model.NotificationPreference, error) {
+	var prefs []*model.NotificationPreference
+	if err := r.db.Where("user_id = ?", userID).Find(&prefs).Error; err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// FindByUserAndType finds the user's preference for a notification type
+func (r *notificationPreferenceRepository) FindByUserAndType(userID, notifType string) (*model.NotificationPreference, error) {
+	var pref model.NotificationPreference
+	err := r.db.Where("user_id = ? AND type = ?", userID, notifType).First(&pref).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.DefaultNotificationChannels(userID, notifType), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+// Upsert stores the channels of each preference, replacing the previous ones of the same type
+func (r *notificationPreferenceRepository) Upsert(prefs []*model.NotificationPreference) error {
+	if len(prefs) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "type"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"in_app":     gorm.Expr("EXCLUDED.in_app"),
+			"email":      gorm.Expr("EXCLUDED.email"),
+			"push":       gorm.Expr("EXCLUDED.push"),
+			"updated_at": time.Now(),
+		}),
+	}).Create(&prefs).Error
+}
+
+// FindSettings finds the user's notification settings
+func (r *notificationPreferenceRepository) FindSettings(userID string) (*model.NotificationSettings, error) {
+	var settings model.NotificationSettings
+	err := r.db.Where("user_id = ?", userID).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.DefaultNotificationSettings(userID), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// FindSettingsByUserIDs finds the notification settings of several users at once
+func (r *notificationPreferenceRepository) FindSettingsByUserIDs(userIDs []string) (map[string]*model.NotificationSettings, error) {
+	result := make(map[string]*model.NotificationSettings, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+	var found []*model.NotificationSettings
+	if err := r.db.Where("user_id IN ?", userIDs).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	for _, settings := range found {
+		result[settings.UserID] = settings
+	}
+	for _, userID := range userIDs {
+		if result[userID] == nil {
+			result[userID] = model.DefaultNotificationSettings(userID)
+		}
+	}
+	return result, nil
+}
+
+// UpsertSettings creates or replaces the user's notification settings
+func (r *notificationPreferenceRepository) UpsertSettings(settings *model.NotificationSettings) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"quiet_hours_enabled", "quiet_hours_start", "quiet_hours_end", "timezone", "digest_frequency", "updated_at"}),
+	}).Create(settings).Error
+}
+
+// FindDigestCandidates finds verified, active users whose last digest (or sign-up) and last
+// login are older than their digest interval and who have something unread.
+// Users without settings get the default weekly digest.
+func (r *notificationPreferenceRepository) FindDigestCandidates(now time.Time, limit int) ([]*DigestCandidate, error) {
+	dailyCutoff := now.Add(-model.DigestInterval(model.DigestFrequencyDaily))
+	weeklyCutoff := now.Add(-model.DigestInterval(model.DigestFrequencyWeekly))
+
+	var candidates []*DigestCandidate
+	err := r.db.Raw(`
+		WITH users_with_frequency AS (
+			SELECT u.id, u.created_at, u.last_login, s.last_digest_at,
+				COALESCE(s.digest_frequency, ?) AS frequency
+			FROM users u
+			LEFT JOIN notification_settings s ON s.user_id = u.id
+			WHERE u.deleted_at IS NULL AND u.is_verified AND u.is_active AND NOT u.is_banned
+		), due AS (
+			SELECT id, frequency, created_at, last_login, last_digest_at,
+				CASE frequency WHEN ? THEN ?::timestamp ELSE ?::timestamp END AS cutoff
+			FROM users_with_frequency
+			WHERE frequency IN (?, ?)
+		)
+		SELECT id AS user_id, frequency
+		FROM due
+		WHERE COALESCE(last_digest_at, created_at) < cutoff
+			AND COALESCE(last_login, created_at) < cutoff
+			AND (
+				EXISTS (SELECT 1 FROM notifications n WHERE n.user_id = due.id AND n.is_read = false)
+				OR EXISTS (SELECT 1 FROM chat_messages m WHERE m.receiver_id = due.id AND m.is_read = false AND m.deleted_at IS NULL)
+			)
+		ORDER BY id
+		LIMIT ?`,
+		model.DigestFrequencyWeekly,
+		model.DigestFrequencyDaily, dailyCutoff, weeklyCutoff,
+		model.DigestFrequencyDaily, model.DigestFrequencyWeekly,
+		limit,
+	).Scan(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// ClaimDigest sets last_digest_at (creating default settings if needed) only when the previous
+// digest is older than interval, so concurrent runs send each digest once
+func (r *notificationPreferenceRepository) ClaimDigest(userID string, interval time.Duration, now time.Time) (bool, error) {
+	settings := model.DefaultNotificationSettings(userID)
+	settings.LastDigestAt = &now
+	res := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_digest_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			gorm.Expr("notification_settings.last_digest_at IS NULL OR notification_settings.last_digest_at < ?", now.Add(-interval)),
+		}},
+	}).Create(settings)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// ReleaseDigest restores the user's previous last_digest_at, unless another run claimed a digest since
+func (r *notificationPreferenceRepository) ReleaseDigest(userID string, claimedAt time.Time, previous *time.Time) error {
+	return r.db.Model(&model.NotificationSettings{}).
+		Where("user_id = ? AND last_digest_at = ?", userID, claimedAt).
+		UpdateColumn("last_digest_at", previous).Error
+}
+
+// SetDigestFrequency changes only the digest frequency, creating default settings if needed
+func (r *notificationPreferenceRepository) SetDigestFrequency(userID, frequency string) error {
+	settings := model.DefaultNotificationSettings(userID)
+	settings.DigestFrequency = frequency
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"digest_frequency", "updated_at"}),
+	}).Create(settings).Error
+}
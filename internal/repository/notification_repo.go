@@ -2,6 +2,7 @@ package repository
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,19 +10,40 @@ import (
 	"yourapp/internal/util"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type NotificationRepository interface {
 	Create(notification *model.Notification) error
+	// CreateForPendingFriendship creates a friend request notification unless the friendship is
+	// no longer pending. The friendship row stays locked until the insert commits, so accepting
+	// or rejecting the request always sees, and can delete, the notification.
+	CreateForPendingFriendship(notification *model.Notification, friendshipID string) (bool, error)
 	FindByID(id string) (*model.Notification, error)
 	FindByUserID(userID string, limit, offset int) ([]*model.Notification, error)
 	FindUnreadByUserID(userID string) ([]*model.Notification, error)
 	CountUnreadByUserID(userID string) (int64, error)
+	// Group folds an actor into the notification group of notification.GroupKey, creating the
+	// notification when the group has no unread one. render is called inside the transaction,
+	// after the actor count and latest actors were updated, to fill title, message and data.
+	Group(notification *model.Notification, actor model.NotificationActor, render func(*model.Notification)) (*NotificationGroupResult, error)
 	MarkAsRead(id string) error
 	MarkAllAsRead(userID string) error
 	Delete(id string) error
 	DeleteByUserID(userID string) error
 	DeleteByTargetIDAndType(targetID, notifType string) error
+	// IsDelivered reports whether a queued message with the dedupe key was delivered before
+	IsDelivered(dedupeKey string) (bool, error)
+	// MarkDelivered records the dedupe key of a delivered message
+	MarkDelivered(dedupeKey string) error
+	DeleteDeliveriesBefore(before time.Time) (int64, error)
+}
+
+// NotificationGroupResult is the grouped notification after an actor was folded into it
+type NotificationGroupResult struct {
+	Notification *model.Notification
+	Created      bool // The group had no unread notification; a new one was created
+	NewActor     bool // The actor was not part of the group yet (ActorCount was incremented)
 }
 
 type notificationRepository struct {
@@ -60,6 +82,41 @@ func (r *notificationRepository) Create(notification *model.Notification) error
 	return nil
 }
 
+// CreateForPendingFriendship creates the notification while holding a share lock on the pending friendship
+func (r *notificationRepository) CreateForPendingFriendship(notification *model.Notification, friendshipID string) (bool, error) {
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var ids []string
+		if err := tx.Model(&model.Friendship{}).
+			Clauses(clause.Locking{Strength: "SHARE"}).
+			Where("id = ? AND status = ?", friendshipID, model.FriendshipStatusPending).
+			Limit(1).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Create(notification).Error; err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	if err != nil || !created {
+		return false, err
+	}
+
+	// Invalidate cache
+	if r.redis != nil {
+		r.invalidateUserCache(notification.UserID)
+		r.invalidateUnreadCache(notification.UserID)
+		r.invalidateCountCache(notification.UserID)
+	}
+
+	return true, nil
+}
+
 // FindByID finds a notification by ID
 func (r *notificationRepository) FindByID(id string) (*model.Notification, error) {
 	var notification model.Notification
@@ -112,6 +169,99 @@ func (r *notificationRepository) FindUnreadByUserID(userID string) ([]*model.Not
 	return notifications, nil
 }
 
+// Group folds an actor into the notification group, retrying once when a concurrent first
+// activity wins the insert on the group key so the actor joins that notification instead
+func (r *notificationRepository) Group(
+	notification *model.Notification,
+	actor model.NotificationActor,
+	render func(*model.Notification),
+) (*NotificationGroupResult, error) {
+	var result *NotificationGroupResult
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if result, err = r.groupOnce(notification, actor, render); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Invalidate cache
+	if r.redis != nil {
+		r.invalidateUserCache(notification.UserID)
+		r.invalidateUnreadCache(notification.UserID)
+		r.invalidateCountCache(notification.UserID)
+	}
+
+	return result, nil
+}
+
+func (r *notificationRepository) groupOnce(
+	template *model.Notification,
+	actor model.NotificationActor,
+	render func(*model.Notification),
+) (*NotificationGroupResult, error) {
+	result := &NotificationGroupResult{}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var notification model.Notification
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("group_key = ?", *template.GroupKey).
+			First(&notification).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			notification = *template
+			notification.ActorCount = 1
+			notification.LatestActors = []model.NotificationActor{actor}
+			notification.SenderID = &actor.ID
+			render(&notification)
+			if err := tx.Omit(clause.Associations).Create(&notification).Error; err != nil {
+				return err
+			}
+			groupActor := &model.NotificationGroupActor{NotificationID: notification.ID, UserID: actor.ID}
+			if err := tx.Omit(clause.Associations).Create(groupActor).Error; err != nil {
+				return err
+			}
+			result.Created = true
+			result.NewActor = true
+			result.Notification = &notification
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		groupActor := &model.NotificationGroupActor{NotificationID: notification.ID, UserID: actor.ID}
+		res := tx.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(groupActor)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 1 {
+			result.NewActor = true
+			notification.ActorCount++
+		}
+
+		latest := []model.NotificationActor{actor}
+		for _, previous := range notification.LatestActors {
+			if previous.ID != actor.ID && len(latest) < model.NotificationLatestActorsLimit {
+				latest = append(latest, previous)
+			}
+		}
+		notification.LatestActors = latest
+		notification.SenderID = &actor.ID
+		notification.CreatedAt = time.Now() // Resurface the notification at the top of the list
+		render(&notification)
+		if err := tx.Omit(clause.Associations).Save(&notification).Error; err != nil {
+			return err
+		}
+		result.Notification = &notification
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 // CountUnreadByUserID counts unread notifications for a user
 func (r *notificationRepository) CountUnreadByUserID(userID string) (int64, error) {
 	// Try cache first
@@ -141,7 +291,8 @@ func (r *notificationRepository) CountUnreadByUserID(userID string) (int64, erro
 	return count, nil
 }
 
-// MarkAsRead marks a notification as read
+// MarkAsRead marks a notification as read. Reading a grouped notification closes its group,
+// so later activity starts a new notification.
 func (r *notificationRepository) MarkAsRead(id string) error {
 	// Get notification first for cache invalidation
 	var notification model.Notification
@@ -153,8 +304,9 @@ func (r *notificationRepository) MarkAsRead(id string) error {
 	err := r.db.Model(&model.Notification{}).
 		Where("id = ?", id).
 		Updates(map[string]interface{}{
-			"is_read": true,
-			"read_at": now,
+			"is_read":   true,
+			"read_at":   now,
+			"group_key": nil,
 		}).Error
 	if err != nil {
 		return err
@@ -176,8 +328,9 @@ func (r *notificationRepository) MarkAllAsRead(userID string) error {
 	err := r.db.Model(&model.Notification{}).
 		Where("user_id = ? AND is_read = ?", userID, false).
 		Updates(map[string]interface{}{
-			"is_read": true,
-			"read_at": now,
+			"is_read":   true,
+			"read_at":   now,
+			"group_key": nil,
 		}).Error
 	if err != nil {
 		return err
@@ -311,3 +464,21 @@ func (r *notificationRepository) invalidateCountCache(userID string) {
 	}
 	r.redis.Delete(notificationCountCachePrefix + userID)
 }
+
+// IsDelivered checks whether the dedupe key was recorded
+func (r *notificationRepository) IsDelivered(dedupeKey string) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.NotificationDelivery{}).Where("dedupe_key = ?", dedupeKey).Count(&count).Error
+	return count > 0, err
+}
+
+// MarkDelivered inserts the dedupe key unless it already exists
+func (r *notificationRepository) MarkDelivered(dedupeKey string) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.NotificationDelivery{DedupeKey: dedupeKey}).Error
+}
+
+// DeleteDeliveriesBefore deletes dedupe keys older than the broker could still redeliver
+func (r *notificationRepository) DeleteDeliveriesBefore(before time.Time) (int64, error) {
+	res := r.db.Where("created_at < ?", before).Delete(&model.NotificationDelivery{})
+	return res.RowsAffected, res.Error
+}
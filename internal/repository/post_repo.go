@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"sort"
@@ -13,8 +14,12 @@ import (
 
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrProfilePinsChanged is returned when the user's profile pins changed since the caller read them
+var ErrProfilePinsChanged = errors.New("profile pins changed")
+
 type PostRepository interface {
 	Create(post *model.Post) error
 	FindByID(id string) (*model.Post, error)
@@ -27,6 +32,11 @@ type PostRepository interface {
 	CountByUserID(userID string) (int64, error)
 	CountByGroupID(groupID string) (int64, error)
 	UpdatePostEngagementScore(postID string) // Update engagement score in Redis
+	FindProfilePinned(userID string) ([]*model.Post, error)
+	SetProfilePins(userID string, expected, postIDs []string) error // Replace the user's profile pins, still equal to expected, with postIDs (in order)
+	SetGroupPinned(postID string, pinned bool) error
+	UpdateMediaStatus(postID, from, to string) (bool, error) // Moves media_status from -> to; false if it was not `from`
+	FindTopByUserIDs(userIDs []string, since time.Time, limit int) ([]*model.Post, error)
 }
 
 type postRepository struct {
@@ -46,6 +56,10 @@ const (
 	postEngagementCacheExpiration = 30 * time.Minute // Longer cache for engagement scores
 )
 
+// postStateColumns are only changed through their own methods (SetProfilePins, SetGroupPinned,
+// UpdateMediaStatus), so Update never writes them back from a possibly stale post
+var postStateColumns = []string{"profile_pin", "is_pinned", "media_status"}
+
 func NewPostRepository(db *gorm.DB, redis *util.RedisClient) PostRepository {
 	return &postRepository{
 		db:    db,
@@ -55,7 +69,16 @@ func NewPostRepository(db *gorm.DB, redis *util.RedisClient) PostRepository {
 
 // Create creates a new post and invalidates related caches
 func (r *postRepository) Create(post *model.Post) error {
-	if err := r.db.Create(post).Error; err != nil {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(post).Error; err != nil {
+			return err
+		}
+		if post.SharedPostID != nil {
+			return adjustPostCounter(tx, *post.SharedPostID, postSharesCountColumn, 1)
+		}
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 
@@ -126,7 +149,7 @@ func (r *postRepository) FindByUserID(userID string, limit, offset int) ([]*mode
 	err := r.db.Preload("User").Preload("Group").Preload("SharedPost").
 		Preload("Tags.TaggedUser").Preload("Location").
 		Where("user_id = ?", userID).
-		Order("created_at DESC").
+		Order("profile_pin ASC NULLS LAST, created_at DESC").
 		Limit(limit).Offset(offset).
 		Find(&posts).Error
 	if err != nil {
@@ -414,27 +437,15 @@ func (r *postRepository) UpdatePostEngagementScore(postID string) {
 		return
 	}
 
-	// Get current counts
-	var likeCount, commentCount, viewCount int64
-	r.db.Model(&model.Like{}).
-		Where("target_type = ? AND target_id = ?", "post", postID).
-		Count(&likeCount)
-	r.db.Model(&model.Comment{}).
-		Where("post_id = ?", postID).
-		Count(&commentCount)
-	r.db.Model(&model.PostView{}).
-		Where("post_id = ?", postID).
-		Count(&viewCount)
-
-	// Calculate engagement score
-	score := float64((likeCount * 2) + (commentCount * 3) + (viewCount * 1))
-
-	// Get post for created_at (for newness boost)
+	// Get post for its stored counters and created_at (for newness boost)
 	var post model.Post
 	if err := r.db.Where("id = ?", postID).First(&post).Error; err != nil {
 		return
 	}
 
+	// Calculate engagement score
+	score := float64((post.LikesCount * 2) + (post.CommentsCount * 3) + (post.ViewsCount * 1))
+
 	tieBreakScore := float64(post.CreatedAt.Unix()) / 1000000.0
 	// Newness boost: posts from last 48h get extra score so they stay near top
 	hoursSinceCreated := time.Since(post.CreatedAt).Hours()
@@ -452,16 +463,144 @@ func (r *postRepository) UpdatePostEngagementScore(postID string) {
 	r.redis.ZAdd(postEngagementSortedSetKey, finalScore, postID)
 }
 
-// Update updates a post and updates cache instead of invalidating
+// FindProfilePinned returns the posts pinned on a user's profile, in pin order
+func (r *postRepository) FindProfilePinned(userID string) ([]*model.Post, error) {
+	var posts []*model.Post
+	err := r.db.Preload("User").Preload("Group").Preload("SharedPost").
+		Preload("Tags.TaggedUser").Preload("Location").
+		Where("user_id = ? AND profile_pin IS NOT NULL", userID).
+		Order("profile_pin ASC").
+		Find(&posts).Error
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// SetProfilePins clears the user's existing profile pins and pins postIDs in the given order.
+// The user's row is locked so concurrent changes are serialized; if the pins are no longer
+// expected (in order), nothing changes and ErrProfilePinsChanged is returned.
+func (r *postRepository) SetProfilePins(userID string, expected, postIDs []string) error {
+	var previous []string
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var user model.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").Where("id = ?", userID).First(&user).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Post{}).
+			Where("user_id = ? AND profile_pin IS NOT NULL", userID).
+			Order("profile_pin ASC").
+			Pluck("id", &previous).Error; err != nil {
+			return err
+		}
+		if len(previous) != len(expected) {
+			return ErrProfilePinsChanged
+		}
+		for i := range previous {
+			if previous[i] != expected[i] {
+				return ErrProfilePinsChanged
+			}
+		}
+		if err := tx.Model(&model.Post{}).
+			Where("user_id = ? AND profile_pin IS NOT NULL", userID).
+			Update("profile_pin", nil).Error; err != nil {
+			return err
+		}
+		for i, id := range postIDs {
+			if err := tx.Model(&model.Post{}).
+				Where("id = ? AND user_id = ?", id, userID).
+				Update("profile_pin", i).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Invalidate caches for every post whose pin state may have changed
+	if r.redis != nil {
+		for _, id := range append(previous, postIDs...) {
+			r.redis.Delete(postCachePrefix + id)
+		}
+		r.invalidateUserCache(userID)
+	}
+
+	return nil
+}
+
+// SetGroupPinned pins or unpins a post within its group
+func (r *postRepository) SetGroupPinned(postID string, pinned bool) error {
+	var post model.Post
+	if err := r.db.Where("id = ?", postID).First(&post).Error; err != nil {
+		return err
+	}
+
+	if err := r.db.Model(&model.Post{}).
+		Where("id = ?", postID).
+		Update("is_pinned", pinned).Error; err != nil {
+		return err
+	}
+
+	if r.redis != nil {
+		r.redis.Delete(postCachePrefix + postID)
+		if post.GroupID != nil {
+			r.invalidateGroupCache(*post.GroupID)
+		}
+	}
+
+	return nil
+}
+
+// FindTopByUserIDs finds the most engaging posts of the given users created since a time,
+// ranked by the stored counters (comments and shares weigh more than likes)
+func (r *postRepository) FindTopByUserIDs(userIDs []string, since time.Time, limit int) ([]*model.Post, error) {
+	if len(userIDs) == 0 {
+		return []*model.Post{}, nil
+	}
+	var posts []*model.Post
+	err := r.db.Preload("User").
+		Where("user_id IN ? AND created_at >= ? AND media_status = ?", userIDs, since, model.PostMediaStatusReady).
+		Order(postLikesCountColumn + " + 2 * " + postCommentsCountColumn + " + 3 * " + postSharesCountColumn + " DESC, created_at DESC").
+		Limit(limit).
+		Find(&posts).Error
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// UpdateMediaStatus transitions the post's media processing status.
+// The conditional update lets concurrent workers agree on who finalizes a post.
+func (r *postRepository) UpdateMediaStatus(postID, from, to string) (bool, error) {
+	result := r.db.Model(&model.Post{}).
+		Where("id = ? AND media_status = ?", postID, from).
+		Update("media_status", to)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	r.invalidatePostCache(postID)
+	return true, nil
+}
+
+// Update updates a post's content and invalidates its caches
 func (r *postRepository) Update(post *model.Post) error {
-	if err := r.db.Save(post).Error; err != nil {
+	// Counters may be stale on a cached post and are only changed atomically
+	omit := append(append([]string{}, postCounterColumns...), postStateColumns...)
+	if err := r.db.Omit(omit...).Save(post).Error; err != nil {
 		return err
 	}
 
 	// Update caches
 	if r.redis != nil {
-		// Update individual post cache
-		r.cachePost(post)
+		// The omitted columns of post may be stale, so the next read reloads it
+		r.invalidatePostCache(post.ID)
 
 		// Update engagement score if needed
 		if post.GroupID == nil {
@@ -492,7 +631,17 @@ func (r *postRepository) Delete(id string) error {
 	groupID := post.GroupID
 
 	// Delete from database (soft delete)
-	if err := r.db.Delete(&post).Error; err != nil {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&post)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		if post.SharedPostID != nil {
+			return adjustPostCounter(tx, *post.SharedPostID, postSharesCountColumn, -1)
+		}
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"time"
+
+	"yourapp/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PostSubscriptionRepository interface {
+	// Ensure subscribes the user unless a subscription (possibly muted) already exists
+	Ensure(postID, userID, reason string) error
+	// Upsert creates or updates the user's subscription with the given mute state
+	Upsert(postID, userID, reason string, muted bool) error
+	FindByPostAndUser(postID, userID string) (*model.PostSubscription, error)
+	FindByPostID(postID string) ([]*model.PostSubscription, error)
+	Delete(postID, userID string) error
+}
+
+type postSubscriptionRepository struct {
+	db *gorm.DB
+}
+
+func NewPostSubscriptionRepository(db *gorm.DB) PostSubscriptionRepository {
+	return &postSubscriptionRepository{db: db}
+}
+
+var postSubscriptionConflictColumns = []clause.Column{{Name: "post_id"}, {Name: "user_id"}}
+
+// Ensure inserts a subscription and leaves an existing one (and its mute state) untouched
+func (r *postSubscriptionRepository) Ensure(postID, userID, reason string) error {
+	sub := &model.PostSubscription{PostID: postID, UserID: userID, Reason: reason}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   postSubscriptionConflictColumns,
+		DoNothing: true,
+	}).Create(sub).Error
+}
+
+// Upsert sets the mute state of the user's subscription, creating it if needed.
+// The original reason is kept for existing subscriptions.
+func (r *postSubscriptionRepository) Upsert(postID, userID, reason string, muted bool) error {
+	sub := &model.PostSubscription{PostID: postID, UserID: userID, Reason: reason, Muted: muted}
+	return r.db.Clauses(clause.OnConflict{
+		Columns: postSubscriptionConflictColumns,
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"muted":      muted,
+			"updated_at": time.Now(),
+		}),
+	}).Create(sub).Error
+}
+
+// FindByPostAndUser finds the user's subscription to a post
+func (r *postSubscriptionRepository) FindByPostAndUser(postID, userID string) (*model.PostSubscription, error) {
+	var sub model.PostSubscription
+	err := r.db.Where("post_id = ? AND user_id = ?", postID, userID).First(&sub).Error
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// FindByPostID returns all subscriptions of a post, muted ones included
+func (r *postSubscriptionRepository) FindByPostID(postID string) ([]*model.PostSubscription, error) {
+	var subs []*model.PostSubscription
+	err := r.db.Where("post_id = ?", postID).
+		Order("created_at ASC").
+		Find(&subs).Error
+	if err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// Delete removes the user's subscription to a post
+func (r *postSubscriptionRepository) Delete(postID, userID string) error {
+	return r.db.Where("post_id = ? AND user_id = ?", postID, userID).Delete(&model.PostSubscription{}).Error
+}
@@ -46,8 +46,14 @@ func (r *postViewRepository) CreateOrUpdate(view *model.PostView) error {
 	err := r.db.Where("post_id = ? AND user_id = ?", view.PostID, view.UserID).First(&existingView).Error
 	
 	if err == gorm.ErrRecordNotFound {
-		// Create new view
-		if err := r.db.Create(view).Error; err != nil {
+		// Create new view and count it on the post
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(view).Error; err != nil {
+				return err
+			}
+			return adjustPostCounter(tx, view.PostID, postViewsCountColumn, 1)
+		})
+		if err != nil {
 			return err
 		}
 	} else if err != nil {
@@ -121,7 +127,7 @@ func (r *postViewRepository) FindByPostAndUser(postID, userID string) (*model.Po
 	return &view, nil
 }
 
-// CountByPostID counts views for a post
+// CountByPostID returns the post's stored view counter
 func (r *postViewRepository) CountByPostID(postID string) (int64, error) {
 	// Try cache first
 	cacheKey := postViewCountCachePrefix + postID
@@ -136,9 +142,10 @@ func (r *postViewRepository) CountByPostID(postID string) (int64, error) {
 	}
 
 	var count int64
-	err := r.db.Model(&model.PostView{}).
-		Where("post_id = ?", postID).
-		Count(&count).Error
+	err := r.db.Model(&model.Post{}).
+		Select(postViewsCountColumn).
+		Where("id = ?", postID).
+		Scan(&count).Error
 	if err != nil {
 		return 0, err
 	}
@@ -154,7 +161,14 @@ func (r *postViewRepository) CountByPostID(postID string) (int64, error) {
 // DeleteByPostAndUser deletes a view
 func (r *postViewRepository) DeleteByPostAndUser(postID, userID string) error {
 	// Delete from database
-	if err := r.db.Where("post_id = ? AND user_id = ?", postID, userID).Delete(&model.PostView{}).Error; err != nil {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("post_id = ? AND user_id = ?", postID, userID).Delete(&model.PostView{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return adjustPostCounter(tx, postID, postViewsCountColumn, -result.RowsAffected)
+	})
+	if err != nil {
 		return err
 	}
 
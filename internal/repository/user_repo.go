@@ -13,6 +13,7 @@ import (
 type UserRepository interface {
 	Create(user *model.User) error
 	FindByID(id string) (*model.User, error)
+	FindByIDs(ids []string) ([]*model.User, error)
 	FindByEmail(email string) (*model.User, error)
 	FindByUsername(username string) (*model.User, error)
 	FindByGoogleID(googleID string) (*model.User, error)
@@ -56,6 +57,15 @@ func (r *userRepository) FindByID(id string) (*model.User, error) {
 	return &user, nil
 }
 
+func (r *userRepository) FindByIDs(ids []string) ([]*model.User, error) {
+	var users []*model.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.db.Where("id IN ?", ids).Find(&users).Error
+	return users, err
+}
+
 func (r *userRepository) FindByEmail(email string) (*model.User, error) {
 	var user model.User
 	err := r.db.Where("email = ?", email).First(&user).Error
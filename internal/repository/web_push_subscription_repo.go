@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"yourapp/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrWebPushEndpointTaken is returned when the endpoint is registered to another user
+var ErrWebPushEndpointTaken = errors.New("push endpoint belongs to another user")
+
+type WebPushSubscriptionRepository interface {
+	// Upsert stores a subscription; an endpoint the user registered before gets its new keys.
+	// An endpoint of another user is left alone and ErrWebPushEndpointTaken is returned.
+	Upsert(sub *model.WebPushSubscription) error
+	FindByUserID(userID string) ([]*model.WebPushSubscription, error)
+	DeleteByEndpoint(endpoint string) error
+	DeleteByUserAndEndpoint(userID, endpoint string) error
+	MarkUsed(id string) error
+}
+
+type webPushSubscriptionRepository struct {
+	db *gorm.DB
+}
+
+func NewWebPushSubscriptionRepository(db *gorm.DB) WebPushSubscriptionRepository {
+	return &webPushSubscriptionRepository{db: db}
+}
+
+// Upsert creates the subscription or updates the user's one with the same endpoint
+func (r *webPushSubscriptionRepository) Upsert(sub *model.WebPushSubscription) error {
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "endpoint"}},
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "web_push_subscriptions.user_id = excluded.user_id"}}},
+		DoUpdates: clause.AssignmentColumns([]string{"p256dh", "auth", "user_agent", "updated_at"}),
+	}).Create(sub)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebPushEndpointTaken
+	}
+	return nil
+}
+
+// FindByUserID finds all devices of a user
+func (r *webPushSubscriptionRepository) FindByUserID(userID string) ([]*model.WebPushSubscription, error) {
+	var subs []*model.WebPushSubscription
+	if err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// DeleteByEndpoint removes a subscription the push service reported as expired
+func (r *webPushSubscriptionRepository) DeleteByEndpoint(endpoint string) error {
+	return r.db.Where("endpoint = ?", endpoint).Delete(&model.WebPushSubscription{}).Error
+}
+
+// DeleteByUserAndEndpoint removes one of the user's devices
+func (r *webPushSubscriptionRepository) DeleteByUserAndEndpoint(userID, endpoint string) error {
+	return r.db.Where("user_id = ? AND endpoint = ?", userID, endpoint).Delete(&model.WebPushSubscription{}).Error
+}
+
+// MarkUsed records a successful delivery
+func (r *webPushSubscriptionRepository) MarkUsed(id string) error {
+	return r.db.Model(&model.WebPushSubscription{}).
+		Where("id = ?", id).
+		UpdateColumn("last_used_at", time.Now()).Error
+}
@@ -0,0 +1,397 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"yourapp/internal/model"
+	"yourapp/internal/util"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxChatImageSize is the maximum size of an image attached to a chat message
+	MaxChatImageSize = 10 * 1024 * 1024 // 10MB
+	// MaxChatAudioSize is the maximum size of a voice note
+	MaxChatAudioSize = 10 * 1024 * 1024 // 10MB
+	// MaxChatFileSize is the maximum size of any other file attached to a chat message
+	MaxChatFileSize = 25 * 1024 * 1024 // 25MB
+	// MaxChatAttachments is how many attachments one message can carry
+	MaxChatAttachments = 10
+
+	// chatAttachmentURLExpiry is how long a signed attachment URL stays valid
+	chatAttachmentURLExpiry = time.Hour
+	// chatAttachmentExpiry is how long an uploaded attachment may stay unsent
+	chatAttachmentExpiry = 24 * time.Hour
+	// chatAttachmentCleanupBatch bounds how many expired attachments one cleanup run removes
+	chatAttachmentCleanupBatch = 500
+
+	chatAttachmentFolder = util.LocalPrivatePrefix + "chat/attachments"
+	chatThumbnailFolder  = util.LocalPrivatePrefix + "chat/thumbnails"
+)
+
+// chatAttachmentType is an accepted attachment file type
+type chatAttachmentType struct {
+	kind     string
+	mimeType string
+	sniffed  []string // Content types http.DetectContentType may report for the file
+	// signature checks the start of files http.DetectContentType does not recognize
+	signature func(head []byte) bool
+}
+
+// chatAttachmentTypes are the accepted attachment types by extension. The file's content must
+// match its extension, so e.g. an executable renamed to .pdf is rejected.
+var chatAttachmentTypes = map[string]chatAttachmentType{
+	".jpg":  {model.ChatAttachmentImage, "image/jpeg", []string{"image/jpeg"}, nil},
+	".jpeg": {model.ChatAttachmentImage, "image/jpeg", []string{"image/jpeg"}, nil},
+	".png":  {model.ChatAttachmentImage, "image/png", []string{"image/png"}, nil},
+	".webp": {model.ChatAttachmentImage, "image/webp", []string{"image/webp"}, nil},
+	".gif":  {model.ChatAttachmentImage, "image/gif", []string{"image/gif"}, nil},
+
+	".mp3":  {model.ChatAttachmentAudio, "audio/mpeg", []string{"audio/mpeg"}, isMP3},
+	".m4a":  {model.ChatAttachmentAudio, "audio/mp4", []string{"audio/mp4", "video/mp4"}, isMP4},
+	".aac":  {model.ChatAttachmentAudio, "audio/aac", []string{"audio/aac"}, isADTS},
+	".ogg":  {model.ChatAttachmentAudio, "audio/ogg", []string{"application/ogg", "audio/ogg"}, nil},
+	".oga":  {model.ChatAttachmentAudio, "audio/ogg", []string{"application/ogg", "audio/ogg"}, nil},
+	".opus": {model.ChatAttachmentAudio, "audio/ogg", []string{"application/ogg", "audio/ogg"}, nil},
+	".webm": {model.ChatAttachmentAudio, "audio/webm", []string{"video/webm", "audio/webm"}, nil},
+	".wav":  {model.ChatAttachmentAudio, "audio/wav", []string{"audio/wave"}, nil},
+
+	".pdf":  {model.ChatAttachmentFile, "application/pdf", []string{"application/pdf"}, nil},
+	".txt":  {model.ChatAttachmentFile, "text/plain", []string{"text/plain"}, nil},
+	".csv":  {model.ChatAttachmentFile, "text/csv", []string{"text/plain"}, nil},
+	".zip":  {model.ChatAttachmentFile, "application/zip", []string{"application/zip"}, nil},
+	".docx": {model.ChatAttachmentFile, "application/vnd.openxmlformats-officedocument.wordprocessingml.document", []string{"application/zip"}, nil},
+	".xlsx": {model.ChatAttachmentFile, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", []string{"application/zip"}, nil},
+	".pptx": {model.ChatAttachmentFile, "application/vnd.openxmlformats-officedocument.presentationml.presentation", []string{"application/zip"}, nil},
+	".doc":  {model.ChatAttachmentFile, "application/msword", nil, isOLE2},
+	".xls":  {model.ChatAttachmentFile, "application/vnd.ms-excel", nil, isOLE2},
+	".ppt":  {model.ChatAttachmentFile, "application/vnd.ms-powerpoint", nil, isOLE2},
+}
+
+// UploadAttachment validates and stores a file for a later message of the user. Images are
+// re-encoded without metadata and get a thumbnail.
+func (s *chatService) UploadAttachment(userID, filename, tmpPath string, size int64) (*model.ChatAttachment, error) {
+	if s.mediaStore == nil {
+		return nil, errors.New("attachments are not available")
+	}
+	filename = filepath.Base(strings.TrimSpace(filename))
+	fileType, ok := chatAttachmentTypes[util.GetFileExt(filename)]
+	if !ok {
+		return nil, fmt.Errorf("file %s is not a supported attachment type", filename)
+	}
+	if size <= 0 {
+		return nil, errors.New("file is empty")
+	}
+	maxSize := int64(MaxChatFileSize)
+	switch fileType.kind {
+	case model.ChatAttachmentImage:
+		maxSize = MaxChatImageSize
+	case model.ChatAttachmentAudio:
+		maxSize = MaxChatAudioSize
+	}
+	if size > maxSize {
+		return nil, fmt.Errorf("file %s exceeds %dMB limit", filename, maxSize/(1024*1024))
+	}
+	if err := checkAttachmentContent(tmpPath, fileType); err != nil {
+		return nil, fmt.Errorf("file %s does not match its type", filename)
+	}
+
+	attachment := &model.ChatAttachment{
+		ID:         uuid.New().String(),
+		UploaderID: userID,
+		Kind:       fileType.kind,
+		Filename:   filename,
+		MimeType:   fileType.mimeType,
+		Size:       size,
+	}
+	if fileType.kind == model.ChatAttachmentImage {
+		if err := s.storeAttachmentImage(attachment, tmpPath); err != nil {
+			log.Printf("[CHAT] Failed to store image attachment of user %s: %v", userID, err)
+			return nil, errors.New("failed to upload attachment")
+		}
+	} else {
+		stored, err := s.mediaStore.Put(util.NewMediaKey(chatAttachmentFolder, filename), tmpPath, util.PutOptions{
+			ContentType: fileType.mimeType,
+		})
+		if err != nil {
+			log.Printf("[CHAT] Failed to store attachment of user %s: %v", userID, err)
+			return nil, errors.New("failed to upload attachment")
+		}
+		attachment.StorageKey = stored.Key
+	}
+
+	if err := s.chatRepo.CreateAttachment(attachment); err != nil {
+		s.deleteAttachmentFiles(attachment)
+		return nil, errors.New("failed to upload attachment")
+	}
+	s.signAttachment(attachment)
+	return attachment, nil
+}
+
+// checkAttachmentContent sniffs the start of the file and checks it against the file type
+func checkAttachmentContent(filePath string, fileType chatAttachmentType) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := f.Read(head)
+	if err != nil {
+		return err
+	}
+	sniffed := http.DetectContentType(head[:n])
+	if i := strings.Index(sniffed, ";"); i >= 0 {
+		sniffed = sniffed[:i]
+	}
+	for _, allowed := range fileType.sniffed {
+		if sniffed == allowed {
+			return nil
+		}
+	}
+	if fileType.signature != nil && fileType.signature(head[:n]) {
+		return nil
+	}
+	return fmt.Errorf("content type %s does not match", sniffed)
+}
+
+// isMP3 checks for an ID3 tag or an MPEG audio frame header (frame sync with a layer set)
+func isMP3(head []byte) bool {
+	if bytes.HasPrefix(head, []byte("ID3")) {
+		return true
+	}
+	return len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0 && head[1]&0x06 != 0
+}
+
+// isMP4 checks for the ftyp box that starts MP4 and M4A files
+func isMP4(head []byte) bool {
+	return len(head) >= 8 && string(head[4:8]) == "ftyp"
+}
+
+// isADTS checks for an ADTS header, the framing of raw AAC files (frame sync with layer 0)
+func isADTS(head []byte) bool {
+	return len(head) >= 2 && head[0] == 0xFF && head[1]&0xF6 == 0xF0
+}
+
+// isOLE2 checks for the compound file header of legacy Office documents
+func isOLE2(head []byte) bool {
+	return bytes.HasPrefix(head, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1})
+}
+
+// storeAttachmentImage stores the full-size and thumbnail variants of an image attachment
+func (s *chatService) storeAttachmentImage(attachment *model.ChatAttachment, filePath string) error {
+	processed, err := util.ProcessImage(filePath)
+	if err != nil {
+		return err
+	}
+	defer processed.Cleanup()
+
+	attachment.Width = processed.Width
+	attachment.Height = processed.Height
+	attachment.Blurhash = processed.Blurhash
+	for _, v := range processed.Variants {
+		var folder string
+		switch v.Name {
+		case util.ImageVariantFull:
+			folder = chatAttachmentFolder
+		case util.ImageVariantThumbnail:
+			folder = chatThumbnailFolder
+		default:
+			continue
+		}
+		stored, err := s.mediaStore.Put(path.Join(folder, attachment.ID+util.GetFileExt(v.Path)), v.Path, util.PutOptions{
+			ContentType: util.DetectMimeType(v.Path),
+		})
+		if err != nil {
+			s.deleteAttachmentFiles(attachment)
+			return err
+		}
+		if v.Name == util.ImageVariantFull {
+			attachment.StorageKey = stored.Key
+			attachment.MimeType = util.DetectMimeType(v.Path)
+		} else {
+			attachment.ThumbnailKey = &stored.Key
+		}
+	}
+	if attachment.StorageKey == "" {
+		s.deleteAttachmentFiles(attachment)
+		return errors.New("image pipeline returned no full-size variant")
+	}
+	return nil
+}
+
+// GetAttachment returns the attachment with fresh signed URLs. Sent attachments are only
+// available to participants of the message's conversation, unsent ones only to their uploader.
+func (s *chatService) GetAttachment(userID, attachmentID string) (*model.ChatAttachment, error) {
+	attachment, err := s.chatRepo.FindAttachmentByID(attachmentID)
+	if err != nil {
+		return nil, errors.New("attachment not found")
+	}
+	if attachment.MessageID == nil {
+		if attachment.UploaderID != userID {
+			return nil, errors.New("attachment not found")
+		}
+	} else {
+		msg, err := s.chatRepo.FindByID(*attachment.MessageID)
+		if err != nil || msg.ConversationID == nil {
+			return nil, errors.New("attachment not found")
+		}
+		if _, err := s.findParticipant(userID, *msg.ConversationID); err != nil {
+			return nil, errors.New("attachment not found")
+		}
+	}
+	s.signAttachment(attachment)
+	return attachment, nil
+}
+
+// prepareAttachments loads the sender's unsent attachments onto msg and sets its message type
+func (s *chatService) prepareAttachments(msg *model.ChatMessage, attachmentIDs []string) error {
+	if len(attachmentIDs) == 0 {
+		return nil
+	}
+	if len(attachmentIDs) > MaxChatAttachments {
+		return fmt.Errorf("a message can have at most %d attachments", MaxChatAttachments)
+	}
+	seen := make(map[string]bool, len(attachmentIDs))
+	for _, id := range attachmentIDs {
+		if seen[id] {
+			return errors.New("duplicate attachment")
+		}
+		seen[id] = true
+	}
+
+	attachments, err := s.chatRepo.FindAttachmentsByIDs(attachmentIDs)
+	if err != nil {
+		return errors.New("failed to get attachments")
+	}
+	if len(attachments) != len(attachmentIDs) {
+		return errors.New("attachment not found")
+	}
+
+	kinds := make(map[string]int)
+	msg.Attachments = make([]model.ChatAttachment, 0, len(attachments))
+	for _, attachment := range attachments {
+		if attachment.UploaderID != msg.SenderID {
+			return errors.New("attachment not found")
+		}
+		if attachment.MessageID != nil {
+			return errors.New("attachment was already sent")
+		}
+		kinds[attachment.Kind]++
+		msg.Attachments = append(msg.Attachments, *attachment)
+	}
+
+	switch {
+	case kinds[model.ChatAttachmentAudio] > 0:
+		if len(attachments) > 1 {
+			return errors.New("a voice note must be sent on its own")
+		}
+		msg.MessageType = model.ChatMessageTypeAudio
+	case kinds[model.ChatAttachmentImage] == len(attachments):
+		msg.MessageType = model.ChatMessageTypeImage
+	default:
+		msg.MessageType = model.ChatMessageTypeFile
+	}
+	return nil
+}
+
+// signMessageAttachments sets signed URLs on the attachments of the messages
+func (s *chatService) signMessageAttachments(messages ...*model.ChatMessage) {
+	for _, msg := range messages {
+		if msg == nil {
+			continue
+		}
+		for i := range msg.Attachments {
+			s.signAttachment(&msg.Attachments[i])
+		}
+	}
+}
+
+func (s *chatService) signConversationAttachments(conversations ...*model.Conversation) {
+	for _, conversation := range conversations {
+		s.signMessageAttachments(conversation.LastMessage)
+	}
+}
+
+func (s *chatService) signAttachment(attachment *model.ChatAttachment) {
+	if s.mediaStore == nil {
+		return
+	}
+	url, err := s.mediaStore.SignedURL(attachment.StorageKey, chatAttachmentURLExpiry)
+	if err != nil {
+		log.Printf("[CHAT] Failed to sign attachment %s: %v", attachment.ID, err)
+		return
+	}
+	attachment.URL = url
+	if attachment.ThumbnailKey != nil {
+		if url, err := s.mediaStore.SignedURL(*attachment.ThumbnailKey, chatAttachmentURLExpiry); err == nil {
+			attachment.ThumbnailURL = url
+		}
+	}
+}
+
+// CleanupAttachments deletes attachments that were uploaded but never sent
+func (s *chatService) CleanupAttachments() (int, error) {
+	attachments, err := s.chatRepo.FindUnsentAttachments(time.Now().Add(-chatAttachmentExpiry), chatAttachmentCleanupBatch)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, attachment := range attachments {
+		if err := s.chatRepo.DeleteAttachment(attachment.ID); err != nil {
+			log.Printf("[CHAT] Failed to delete expired attachment %s: %v", attachment.ID, err)
+			continue
+		}
+		s.deleteAttachmentFiles(attachment)
+		deleted++
+	}
+	return deleted, nil
+}
+
+// deleteAttachmentFiles deletes the files of a deleted attachment, unless a forwarded copy
+// still uses them
+func (s *chatService) deleteAttachmentFiles(attachment *model.ChatAttachment) {
+	if s.mediaStore == nil {
+		return
+	}
+	keys := []string{attachment.StorageKey}
+	if attachment.ThumbnailKey != nil {
+		keys = append(keys, *attachment.ThumbnailKey)
+	}
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if count, err := s.chatRepo.CountAttachmentsWithKey(key); err != nil || count > 0 {
+			continue
+		}
+		if err := s.mediaStore.Delete(key); err != nil {
+			log.Printf("[CHAT] Failed to delete attachment file %s: %v", key, err)
+		}
+	}
+}
+
+// attachmentPreview describes a message without text in pushes
+func attachmentPreview(msg *model.ChatMessage) string {
+	switch msg.MessageType {
+	case model.ChatMessageTypeImage:
+		return "📷 Foto"
+	case model.ChatMessageTypeAudio:
+		return "🎤 Pesan suara"
+	}
+	if len(msg.Attachments) > 0 {
+		return "📎 " + msg.Attachments[0].Filename
+	}
+	return ""
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"yourapp/internal/model"
+)
+
+// chatEditWindow is how long after sending a message its sender can edit it
+const chatEditWindow = 15 * time.Minute
+
+// EditMessage changes the content of the user's message while the edit window is open
+func (s *chatService) EditMessage(userID, messageID, content string) (*model.ChatMessage, error) {
+	msg, conversation, err := s.findMessage(userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.SenderID != userID || msg.MessageType == model.ChatMessageTypeSystem {
+		return nil, errors.New("you can only edit your own messages")
+	}
+	if msg.IsUnsent() {
+		return nil, errors.New("message was unsent")
+	}
+	if msg.IsForwarded {
+		return nil, errors.New("forwarded messages cannot be edited")
+	}
+	if time.Since(msg.CreatedAt) > chatEditWindow {
+		return nil, errors.New("messages can only be edited within 15 minutes")
+	}
+	if strings.TrimSpace(content) == "" && len(msg.Attachments) == 0 {
+		return nil, errors.New("message content cannot be empty")
+	}
+	if content == msg.Content {
+		s.presentMessages(msg)
+		return msg, nil
+	}
+	if err := s.checkRequestEdit(conversation, userID, content); err != nil {
+		return nil, err
+	}
+
+	editedAt := time.Now()
+	if err := s.chatRepo.UpdateContent(msg.ID, content, editedAt); err != nil {
+		return nil, errors.New("failed to edit message")
+	}
+	msg.Content = content
+	msg.EditedAt = &editedAt
+	s.presentMessages(msg)
+
+	s.notifyParticipants(conversation, "chat_message_edited", map[string]interface{}{
+		"conversation_id": conversation.ID,
+		"message_id":      msg.ID,
+		"content":         msg.Content,
+		"edited_at":       msg.EditedAt,
+	})
+	return msg, nil
+}
+
+// UnsendMessage removes the user's message for everyone, leaving a tombstone in its place
+func (s *chatService) UnsendMessage(userID, messageID string) (*model.ChatMessage, error) {
+	msg, conversation, err := s.findMessage(userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.SenderID != userID || msg.MessageType == model.ChatMessageTypeSystem {
+		return nil, errors.New("you can only unsend your own messages")
+	}
+	if msg.IsUnsent() {
+		return msg, nil
+	}
+
+	unsentAt := time.Now()
+	attachments, err := s.chatRepo.Unsend(msg.ID, unsentAt)
+	if err != nil {
+		return nil, errors.New("failed to unsend message")
+	}
+	for _, attachment := range attachments {
+		s.deleteAttachmentFiles(attachment)
+	}
+	msg.Content = ""
+	msg.Metadata = nil
+	msg.Attachments = nil
+	msg.Reactions = nil
+	msg.UnsentAt = &unsentAt
+
+	s.notifyParticipants(conversation, "chat_message_unsent", map[string]interface{}{
+		"conversation_id": conversation.ID,
+		"message_id":      msg.ID,
+		"unsent_at":       msg.UnsentAt,
+	})
+	return msg, nil
+}
+
+// DeleteMessageForMe hides the message from the user only. The user's other sessions are told
+// to drop it too.
+func (s *chatService) DeleteMessageForMe(userID, messageID string) error {
+	msg, _, err := s.findMessage(userID, messageID)
+	if err != nil {
+		return err
+	}
+	if err := s.chatRepo.HideForUser(msg.ID, userID); err != nil {
+		return errors.New("failed to delete message")
+	}
+	if s.wsHub != nil {
+		s.wsHub.BroadcastToUser(userID, map[string]interface{}{
+			"type": "chat_message_deleted",
+			"payload": map[string]interface{}{
+				"conversation_id": msg.ConversationID,
+				"message_id":      msg.ID,
+			},
+		})
+	}
+	return nil
+}
+
+// ReactToMessage sets the user's reaction to the message, replacing any previous one
+func (s *chatService) ReactToMessage(userID, messageID, reaction string) ([]model.ChatReaction, error) {
+	if !isValidReaction(reaction) {
+		return nil, errors.New("invalid reaction type")
+	}
+	msg, conversation, err := s.findReactableMessage(userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.chatRepo.SetReaction(&model.ChatReaction{MessageID: msg.ID, UserID: userID, Reaction: reaction}); err != nil {
+		return nil, errors.New("failed to react to message")
+	}
+	return s.afterReactionChange(conversation, msg, userID, reaction)
+}
+
+// RemoveReaction removes the user's reaction to the message
+func (s *chatService) RemoveReaction(userID, messageID string) ([]model.ChatReaction, error) {
+	msg, conversation, err := s.findReactableMessage(userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.chatRepo.DeleteReaction(msg.ID, userID); err != nil {
+		return nil, errors.New("failed to remove reaction")
+	}
+	return s.afterReactionChange(conversation, msg, userID, "")
+}
+
+// afterReactionChange sends the message's reactions to the participants and returns them
+func (s *chatService) afterReactionChange(conversation *model.Conversation, msg *model.ChatMessage, userID, reaction string) ([]model.ChatReaction, error) {
+	reactions, err := s.chatRepo.FindReactions(msg.ID)
+	if err != nil {
+		return nil, errors.New("failed to get reactions")
+	}
+	s.notifyParticipants(conversation, "chat_reaction", map[string]interface{}{
+		"conversation_id": conversation.ID,
+		"message_id":      msg.ID,
+		"user_id":         userID,
+		"reaction":        reaction, // Empty when the reaction was removed
+		"reactions":       reactions,
+	})
+	return reactions, nil
+}
+
+// ForwardMessage copies the message's content and attachments into a new message of the user.
+// The copy does not reveal where it was forwarded from.
+func (s *chatService) ForwardMessage(userID, messageID string, req *ForwardMessageRequest) (*model.ChatMessage, error) {
+	original, _, err := s.findMessage(userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if original.IsUnsent() || original.MessageType == model.ChatMessageTypeSystem {
+		return nil, errors.New("cannot forward this message")
+	}
+
+	msg := &model.ChatMessage{
+		SenderID:    userID,
+		MessageType: original.MessageType,
+		Content:     original.Content,
+		IsForwarded: true,
+	}
+	// The copies share the original's files and are attached to the new message when it is sent
+	for _, attachment := range original.Attachments {
+		attachment.ID = ""
+		attachment.MessageID = nil
+		attachment.UploaderID = userID
+		attachment.CreatedAt = time.Time{}
+		if err := s.chatRepo.CreateAttachment(&attachment); err != nil {
+			return nil, errors.New("failed to forward message")
+		}
+		msg.Attachments = append(msg.Attachments, attachment)
+	}
+	return s.sendTo(req.ConversationID, req.ReceiverID, msg)
+}
+
+// checkReply checks that a reply quotes a message of the conversation it is sent to
+func (s *chatService) checkReply(conversation *model.Conversation, msg *model.ChatMessage) error {
+	if msg.ReplyToMessageID == nil {
+		return nil
+	}
+	quoted, err := s.chatRepo.FindByID(*msg.ReplyToMessageID)
+	if err != nil || quoted.ConversationID == nil || *quoted.ConversationID != conversation.ID {
+		return errors.New("replied message not found")
+	}
+	if quoted.IsUnsent() || quoted.MessageType == model.ChatMessageTypeSystem {
+		return errors.New("cannot reply to this message")
+	}
+	return nil
+}
+
+// presentMessages fills the computed fields of messages about to be returned: the quote of
+// replies and signed attachment URLs
+func (s *chatService) presentMessages(messages ...*model.ChatMessage) {
+	for _, msg := range messages {
+		if msg != nil {
+			msg.SetQuote()
+		}
+	}
+	s.signMessageAttachments(messages...)
+}
+
+func (s *chatService) findReactableMessage(userID, messageID string) (*model.ChatMessage, *model.Conversation, error) {
+	msg, conversation, err := s.findMessage(userID, messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if msg.IsUnsent() || msg.MessageType == model.ChatMessageTypeSystem {
+		return nil, nil, errors.New("cannot react to this message")
+	}
+	return msg, conversation, nil
+}
+
+// findMessage returns the message and its conversation, or an error when the user is not a
+// participant
+func (s *chatService) findMessage(userID, messageID string) (*model.ChatMessage, *model.Conversation, error) {
+	msg, err := s.chatRepo.FindByID(messageID)
+	if err != nil || msg.ConversationID == nil {
+		return nil, nil, errors.New("message not found")
+	}
+	conversation, err := s.findConversation(userID, *msg.ConversationID)
+	if err != nil {
+		return nil, nil, errors.New("message not found")
+	}
+	return msg, conversation, nil
+}
+
+// notifyParticipants sends a change to a message to every participant of its conversation,
+// including the user's own other sessions
+func (s *chatService) notifyParticipants(conversation *model.Conversation, eventType string, payload map[string]interface{}) {
+	if s.wsHub == nil {
+		return
+	}
+	for _, participant := range conversation.Participants {
+		s.wsHub.BroadcastToUser(participant.UserID, map[string]interface{}{
+			"type":    eventType,
+			"payload": payload,
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"unicode/utf8"
+
+	"yourapp/internal/model"
+)
+
+// maxMessageRequestLength limits the first message to someone who is not a friend, in characters
+const maxMessageRequestLength = 500
+
+// checkDirectMessage decides whether msg may be sent to the other participant of a direct
+// conversation, which is nil before the pair's first message. Friends can always chat; others
+// need an accepted message request. Replying to a request accepts it. It returns whether msg
+// starts a new message request, which the receiver's settings must allow.
+func (s *chatService) checkDirectMessage(conversation *model.Conversation, msg *model.ChatMessage) (bool, error) {
+	senderID, receiverID := msg.SenderID, *msg.ReceiverID
+	friendship, err := s.friendRepo.FindBySenderAndReceiver(senderID, receiverID)
+	if err == nil && friendship.Status == model.FriendshipStatusBlocked {
+		return false, errors.New("cannot send messages to this user")
+	}
+
+	var recipient *model.ConversationParticipant
+	if conversation != nil {
+		recipient = conversation.RequestRecipient()
+	}
+	if err == nil && friendship.Status == model.FriendshipStatusAccepted {
+		// Friends no longer need the request that was sent before they became friends
+		if recipient != nil {
+			s.acceptRequest(conversation, recipient)
+		}
+		return false, nil
+	}
+	if conversation != nil && conversation.RequestAccepted() {
+		return false, nil
+	}
+	if recipient != nil {
+		if recipient.UserID == senderID {
+			s.acceptRequest(conversation, recipient)
+			return false, nil
+		}
+		return false, errors.New("message request is waiting for a reply")
+	}
+
+	if err := s.checkRequestAllowed(senderID, receiverID); err != nil {
+		return false, err
+	}
+	if len(msg.Attachments) > 0 {
+		return false, errors.New("message requests can only contain text")
+	}
+	if utf8.RuneCountInString(msg.Content) > maxMessageRequestLength {
+		return false, fmt.Errorf("message requests can be at most %d characters", maxMessageRequestLength)
+	}
+	return true, nil
+}
+
+// checkRequestEdit applies the message request rules to an edit by the user who sent the
+// request: it cannot change while the recipient has not answered it, and it never grows past
+// maxMessageRequestLength
+func (s *chatService) checkRequestEdit(conversation *model.Conversation, userID, content string) error {
+	recipient := conversation.RequestRecipient()
+	if recipient == nil || recipient.UserID == userID {
+		return nil
+	}
+	if *recipient.RequestStatus == model.MessageRequestPending {
+		return errors.New("message request is waiting for a reply")
+	}
+	if utf8.RuneCountInString(content) > maxMessageRequestLength {
+		return fmt.Errorf("message requests can be at most %d characters", maxMessageRequestLength)
+	}
+	return nil
+}
+
+// checkRequestAllowed checks the receiver's setting for who can send them message requests
+func (s *chatService) checkRequestAllowed(senderID, receiverID string) error {
+	settings, err := s.chatRepo.FindSettings(receiverID)
+	if err != nil {
+		return errors.New("failed to send message")
+	}
+	switch settings.MessageRequests {
+	case model.MessageRequestsNobody:
+		return errors.New("user does not accept message requests")
+	case model.MessageRequestsFriendsOfFriends:
+		mutual, err := s.friendRepo.HasMutualFriend(senderID, receiverID)
+		if err != nil {
+			return errors.New("failed to send message")
+		}
+		if !mutual {
+			return errors.New("user only accepts message requests from friends of friends")
+		}
+	}
+	return nil
+}
+
+// startRequest makes the conversation a message request to the receiver before its first
+// message is added
+func (s *chatService) startRequest(conversation *model.Conversation, receiverID string) error {
+	status := model.MessageRequestPending
+	if err := s.convRepo.UpdateParticipant(conversation.ID, receiverID, map[string]interface{}{"request_status": status}); err != nil {
+		return errors.New("failed to send message")
+	}
+	if participant := conversation.Participant(receiverID); participant != nil {
+		participant.RequestStatus = &status
+	}
+	return nil
+}
+
+// acceptRequest accepts the conversation's message request for its recipient. A failure only
+// leaves the request open, so it is logged.
+func (s *chatService) acceptRequest(conversation *model.Conversation, recipient *model.ConversationParticipant) {
+	status := model.MessageRequestAccepted
+	if err := s.convRepo.UpdateParticipant(conversation.ID, recipient.UserID, map[string]interface{}{"request_status": status}); err != nil {
+		log.Printf("[CHAT] Failed to accept message request %s: %v", conversation.ID, err)
+		return
+	}
+	recipient.RequestStatus = &status
+}
+
+// AcceptMessageRequest moves a message request into the user's inbox and lets its sender
+// keep chatting
+func (s *chatService) AcceptMessageRequest(userID, conversationID string) (*model.Conversation, error) {
+	if _, _, err := s.findRequest(userID, conversationID); err != nil {
+		return nil, err
+	}
+	conversation, err := s.updateParticipant(userID, conversationID, map[string]interface{}{
+		"request_status": model.MessageRequestAccepted,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.wsHub != nil {
+		for _, other := range conversation.Participants {
+			if other.UserID == userID {
+				continue
+			}
+			s.wsHub.BroadcastToUser(other.UserID, map[string]interface{}{
+				"type": "chat_request_accepted",
+				"payload": map[string]interface{}{
+					"conversation_id": conversationID,
+					"user_id":         userID,
+				},
+			})
+		}
+	}
+	return conversation, nil
+}
+
+// DeclineMessageRequest removes a message request from the user's requests. Its sender is not
+// told and cannot send more messages.
+func (s *chatService) DeclineMessageRequest(userID, conversationID string) error {
+	_, participant, err := s.findRequest(userID, conversationID)
+	if err != nil {
+		return err
+	}
+	if *participant.RequestStatus == model.MessageRequestDeclined {
+		return nil
+	}
+	if err := s.convRepo.UpdateParticipant(conversationID, userID, map[string]interface{}{
+		"request_status": model.MessageRequestDeclined,
+	}); err != nil {
+		return errors.New("failed to decline message request")
+	}
+	// Clear the unread count without telling the sender their messages were read
+	if err := s.convRepo.ClearUnread(conversationID, userID); err != nil {
+		log.Printf("[CHAT] Failed to mark declined request %s read: %v", conversationID, err)
+	}
+	return nil
+}
+
+// BlockMessageRequest declines a message request and blocks its sender, who can then neither
+// message the user nor send them a friend request
+func (s *chatService) BlockMessageRequest(userID, conversationID string) error {
+	conversation, _, err := s.findRequest(userID, conversationID)
+	if err != nil {
+		return err
+	}
+	for _, other := range conversation.Participants {
+		if other.UserID == userID {
+			continue
+		}
+		if err := s.friendRepo.BlockRequestSender(userID, other.UserID, conversationID); err != nil {
+			return errors.New("failed to block user")
+		}
+	}
+	return nil
+}
+
+// findRequest returns a direct conversation that is a pending or declined message request to
+// the user, with the user's participation
+func (s *chatService) findRequest(userID, conversationID string) (*model.Conversation, *model.ConversationParticipant, error) {
+	conversation, err := s.findConversation(userID, conversationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	participant := conversation.Participant(userID)
+	if !participant.IsRequest() {
+		return nil, nil, errors.New("message request not found")
+	}
+	return conversation, participant, nil
+}
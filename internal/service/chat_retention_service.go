@@ -0,0 +1,136 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"yourapp/internal/model"
+)
+
+// chatExpiryBatch is how many expired messages are deleted at a time
+const chatExpiryBatch = 500
+
+// SetDisappearingTimer changes after how long new messages of the conversation disappear for
+// everyone. Messages sent before the change keep their timer. Only admins can change the timer
+// of a group.
+func (s *chatService) SetDisappearingTimer(userID, conversationID string, seconds int) (*model.Conversation, error) {
+	if !isDisappearingTimer(seconds) {
+		return nil, errors.New("disappear_after must be 0 (off), 86400 (24 hours), 604800 (7 days) or 7776000 (90 days)")
+	}
+	conversation, err := s.findConversation(userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if conversation.IsGroup() && conversation.Participant(userID).Role != model.ConversationRoleAdmin {
+		return nil, errors.New("only group admins can do this")
+	}
+	if !conversation.IsGroup() && conversation.RequestRecipient() != nil {
+		return nil, errors.New("cannot change the timer of a message request")
+	}
+	if conversation.DisappearAfter == seconds {
+		s.presentConversations(userID, conversation)
+		return conversation, nil
+	}
+
+	actor := conversation.Participant(userID).User
+	content := userDisplayName(&actor) + " turned off disappearing messages"
+	if seconds > 0 {
+		content = userDisplayName(&actor) + " set disappearing messages to " + disappearingTimerName(seconds)
+	}
+	msg := systemMessage(userID, model.ChatEventTimerChanged, nil, content)
+	msg.Metadata["disappear_after"] = seconds
+	if err := s.convRepo.UpdateConversation(conversationID, map[string]interface{}{"disappear_after": seconds}, msg); err != nil {
+		return nil, errors.New("failed to update conversation")
+	}
+	return s.afterGroupChange(userID, conversationID, msg.ID)
+}
+
+// ExpireMessages permanently deletes disappearing messages that expired and, when retention is
+// positive, every message older than it. Their attachment files are deleted and connected
+// participants are told to remove the messages.
+func (s *chatService) ExpireMessages(retention time.Duration) (int, error) {
+	now := time.Now()
+	var olderThan *time.Time
+	if retention > 0 {
+		before := now.Add(-retention)
+		olderThan = &before
+	}
+
+	deleted := 0
+	for {
+		messages, err := s.chatRepo.FindExpired(now, olderThan, chatExpiryBatch)
+		if err != nil {
+			return deleted, err
+		}
+		if len(messages) == 0 {
+			return deleted, nil
+		}
+		attachments, err := s.chatRepo.DeleteMessages(messages)
+		if err != nil {
+			return deleted, err
+		}
+		for _, attachment := range attachments {
+			s.deleteAttachmentFiles(attachment)
+		}
+		s.notifyExpired(messages)
+		deleted += len(messages)
+		if len(messages) < chatExpiryBatch {
+			return deleted, nil
+		}
+	}
+}
+
+// notifyExpired sends the IDs of deleted messages to the participants of their conversations
+func (s *chatService) notifyExpired(messages []*model.ChatMessage) {
+	if s.wsHub == nil {
+		return
+	}
+	expired := make(map[string][]string)
+	for _, msg := range messages {
+		if msg.ConversationID != nil {
+			expired[*msg.ConversationID] = append(expired[*msg.ConversationID], msg.ID)
+		}
+	}
+	for conversationID, messageIDs := range expired {
+		conversation, err := s.convRepo.FindByID(conversationID)
+		if err != nil {
+			log.Printf("[CHAT] Failed to get conversation %s of expired messages: %v", conversationID, err)
+			continue
+		}
+		s.signConversationAttachments(conversation)
+		s.notifyParticipants(conversation, "chat_messages_expired", map[string]interface{}{
+			"conversation_id": conversationID,
+			"message_ids":     messageIDs,
+			"last_message":    conversation.LastMessage,
+		})
+	}
+}
+
+// setExpiry starts the disappearing timer of a message sent to the conversation
+func setExpiry(conversation *model.Conversation, msg *model.ChatMessage) {
+	if conversation.DisappearAfter <= 0 || msg.MessageType == model.ChatMessageTypeSystem {
+		return
+	}
+	expiresAt := time.Now().Add(time.Duration(conversation.DisappearAfter) * time.Second)
+	msg.ExpiresAt = &expiresAt
+}
+
+func isDisappearingTimer(seconds int) bool {
+	for _, timer := range model.DisappearingTimers {
+		if timer == seconds {
+			return true
+		}
+	}
+	return false
+}
+
+// disappearingTimerName describes a timer for system messages, e.g. "7 days"
+func disappearingTimerName(seconds int) string {
+	d := time.Duration(seconds) * time.Second
+	if d%(24*time.Hour) == 0 && d >= 48*time.Hour {
+		return strconv.Itoa(int(d/(24*time.Hour))) + " days"
+	}
+	return strconv.Itoa(int(d/time.Hour)) + " hours"
+}
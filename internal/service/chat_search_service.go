@@ -0,0 +1,152 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"yourapp/internal/model"
+	"yourapp/internal/repository"
+)
+
+const (
+	// maxChatSearchQueryLength limits a chat search query, in characters
+	maxChatSearchQueryLength = 200
+	// MaxChatSearchContext is how many messages around each search hit can be returned
+	MaxChatSearchContext = 5
+	// MaxChatMessageContext is how many messages on each side of a message its context can have
+	MaxChatMessageContext = 50
+)
+
+// ChatSearchOptions is a chat message search
+type ChatSearchOptions struct {
+	Query          string
+	ConversationID string     // Only search this conversation
+	From           *time.Time // Only messages sent at or after From
+	To             *time.Time // Only messages sent before To
+	Context        int        // Messages to include before and after each hit
+	Limit          int
+	Offset         int
+}
+
+// ChatSearchResult is a message matching a search. Snippet is HTML-escaped content around the
+// match with the matched words in <mark> tags.
+type ChatSearchResult struct {
+	Message *model.ChatMessage   `json:"message"`
+	Snippet string               `json:"snippet"`
+	Before  []*model.ChatMessage `json:"before,omitempty"`
+	After   []*model.ChatMessage `json:"after,omitempty"`
+}
+
+// MessageContext is a message with the messages sent right before and after it, to jump to it
+// in its conversation
+type MessageContext struct {
+	Message *model.ChatMessage   `json:"message"`
+	Before  []*model.ChatMessage `json:"before"`
+	After   []*model.ChatMessage `json:"after"`
+}
+
+// SearchMessages searches the messages of the user's conversations, newest first
+func (s *chatService) SearchMessages(userID string, opts ChatSearchOptions) ([]*ChatSearchResult, error) {
+	query := strings.TrimSpace(opts.Query)
+	if query == "" {
+		return nil, errors.New("search query is required")
+	}
+	if utf8.RuneCountInString(query) > maxChatSearchQueryLength {
+		return nil, fmt.Errorf("search query can be at most %d characters", maxChatSearchQueryLength)
+	}
+	if opts.From != nil && opts.To != nil && !opts.From.Before(*opts.To) {
+		return nil, errors.New("from must be before to")
+	}
+	if opts.ConversationID != "" {
+		if _, err := s.findParticipant(userID, opts.ConversationID); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 20
+	}
+	if opts.Limit > 50 {
+		opts.Limit = 50
+	}
+	if opts.Offset < 0 {
+		opts.Offset = 0
+	}
+	if opts.Context < 0 {
+		opts.Context = 0
+	}
+	if opts.Context > MaxChatSearchContext {
+		opts.Context = MaxChatSearchContext
+	}
+
+	hits, err := s.chatRepo.Search(repository.ChatSearchQuery{
+		UserID:         userID,
+		Query:          query,
+		ConversationID: opts.ConversationID,
+		From:           opts.From,
+		To:             opts.To,
+		Limit:          opts.Limit,
+		Offset:         opts.Offset,
+	})
+	if err != nil {
+		return nil, errors.New("failed to search messages")
+	}
+
+	results := make([]*ChatSearchResult, 0, len(hits))
+	var messages []*model.ChatMessage
+	for _, hit := range hits {
+		result := &ChatSearchResult{Message: hit.Message, Snippet: searchSnippet(hit.Headline)}
+		if opts.Context > 0 {
+			result.Before, result.After, err = s.chatRepo.FindAround(hit.Message, userID, opts.Context, opts.Context)
+			if err != nil {
+				return nil, errors.New("failed to search messages")
+			}
+		}
+		messages = append(messages, result.Message)
+		messages = append(messages, result.Before...)
+		messages = append(messages, result.After...)
+		results = append(results, result)
+	}
+	s.presentMessages(messages...)
+	s.hideReadTimes(userID, messages)
+	return results, nil
+}
+
+// GetMessageContext returns up to size messages on each side of the message
+func (s *chatService) GetMessageContext(userID, messageID string, size int) (*MessageContext, error) {
+	msg, _, err := s.findMessage(userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		size = 20
+	}
+	if size > MaxChatMessageContext {
+		size = MaxChatMessageContext
+	}
+
+	before, after, err := s.chatRepo.FindAround(msg, userID, size, size)
+	if err != nil {
+		return nil, errors.New("failed to get messages")
+	}
+	if before == nil {
+		before = []*model.ChatMessage{}
+	}
+	if after == nil {
+		after = []*model.ChatMessage{}
+	}
+	messages := append(append([]*model.ChatMessage{msg}, before...), after...)
+	s.presentMessages(messages...)
+	s.hideReadTimes(userID, messages)
+	return &MessageContext{Message: msg, Before: before, After: after}, nil
+}
+
+// searchSnippet escapes a search headline for HTML and marks its matched words
+func searchSnippet(headline string) string {
+	snippet := html.EscapeString(headline)
+	snippet = strings.ReplaceAll(snippet, repository.ChatSearchMatchStart, "<mark>")
+	return strings.ReplaceAll(snippet, repository.ChatSearchMatchStop, "</mark>")
+}
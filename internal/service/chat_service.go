@@ -2,39 +2,287 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"yourapp/internal/model"
 	"yourapp/internal/repository"
+	"yourapp/internal/util"
+)
+
+// MaxPinnedConversations is how many conversations a user can pin to the top of the inbox
+const MaxPinnedConversations = 5
+
+const (
+	// maxGroupTitleLength limits a group conversation's title, in characters
+	maxGroupTitleLength = 100
+	// maxClientMessageIDLength limits the client-generated ID of a message
+	maxClientMessageIDLength = 64
+	// typingThrottle is how often a user's "typing" state is relayed per conversation
+	typingThrottle = 3 * time.Second
+	// typingExpiry is how long clients show a typing indicator without a newer event
+	typingExpiry = 6 * time.Second
 )
 
 type ChatService interface {
-	SendMessage(senderID, receiverID, content string) (*model.ChatMessage, error)
+	// SendMessage sends a direct message
+	SendMessage(senderID, receiverID string, content *MessageContent) (*model.ChatMessage, error)
 	GetConversation(userID, otherUserID string, limit, offset int) ([]*model.ChatMessage, error)
 	MarkAsRead(userID, senderID string) error
 	GetUnreadCount(userID string) (int64, error)
 	GetUnreadCountBySenders(userID string) (map[string]int64, error)
+	GetConversations(userID string, opts ConversationListOptions) (*ConversationPage, error)
+	MarkConversationRead(userID, conversationID string) error
+	ArchiveConversation(userID, conversationID string, archived bool) (*model.Conversation, error)
+	// MuteConversation mutes until the given time, or until unmuted when until is nil
+	MuteConversation(userID, conversationID string, muted bool, until *time.Time) (*model.Conversation, error)
+	PinConversation(userID, conversationID string, pinned bool) (*model.Conversation, error)
+	// SendConversationMessage sends a message to a direct or group conversation of the sender
+	SendConversationMessage(senderID, conversationID string, content *MessageContent) (*model.ChatMessage, error)
+	// SendClientMessage sends a message with a client-generated ID; resending the same ID is a no-op
+	SendClientMessage(senderID string, req *ClientMessageRequest) (*model.ChatMessage, error)
+	// SetTyping relays whether the user is typing to the other participants, at most once per
+	// typingThrottle while typing
+	SetTyping(userID, conversationID string, typing bool) error
+	// MarkDelivered marks the direct messages waiting for the user delivered and tells their senders
+	MarkDelivered(userID string) error
+	GetSettings(userID string) (*model.ChatSettings, error)
+	UpdateSettings(userID string, req *ChatSettingsRequest) (*model.ChatSettings, error)
+	// GetConversationByID returns the conversation with its participants; each participant's
+	// last_read_message_id is their read state
+	GetConversationByID(userID, conversationID string) (*model.Conversation, error)
+	GetConversationMessages(userID, conversationID string, limit, offset int) ([]*model.ChatMessage, error)
+	// CreateGroup creates a group conversation of the creator (its admin) and their friends
+	CreateGroup(creatorID string, req *CreateGroupChatRequest) (*model.Conversation, error)
+	UpdateGroup(userID, conversationID string, req *UpdateGroupChatRequest) (*model.Conversation, error)
+	AddGroupMembers(userID, conversationID string, memberIDs []string) (*model.Conversation, error)
+	RemoveGroupMember(userID, conversationID, memberID string) (*model.Conversation, error)
+	// LeaveGroup removes the user from the group. When the last admin leaves, the longest
+	// standing member becomes admin.
+	LeaveGroup(userID, conversationID string) error
+	SetGroupMemberRole(userID, conversationID, memberID, role string) (*model.Conversation, error)
+	// UploadAttachment stores a file from tmpPath to be sent with one of the user's next messages
+	UploadAttachment(userID, filename, tmpPath string, size int64) (*model.ChatAttachment, error)
+	// GetAttachment returns an attachment with signed URLs if the user may see it
+	GetAttachment(userID, attachmentID string) (*model.ChatAttachment, error)
+	// CleanupAttachments deletes uploads that were not sent within a day
+	CleanupAttachments() (int, error)
+	// EditMessage edits the user's message within 15 minutes of sending it
+	EditMessage(userID, messageID, content string) (*model.ChatMessage, error)
+	// UnsendMessage removes the user's message for everyone and returns its tombstone
+	UnsendMessage(userID, messageID string) (*model.ChatMessage, error)
+	DeleteMessageForMe(userID, messageID string) error
+	// ReactToMessage sets the user's reaction and returns all reactions to the message
+	ReactToMessage(userID, messageID, reaction string) ([]model.ChatReaction, error)
+	RemoveReaction(userID, messageID string) ([]model.ChatReaction, error)
+	// ForwardMessage sends a copy of a message the user can see to another conversation, marked
+	// as forwarded
+	ForwardMessage(userID, messageID string, req *ForwardMessageRequest) (*model.ChatMessage, error)
+	// SearchMessages full-text searches the messages of the user's conversations
+	SearchMessages(userID string, opts ChatSearchOptions) ([]*ChatSearchResult, error)
+	// GetMessageContext returns the messages around a message, e.g. to jump to a search result
+	GetMessageContext(userID, messageID string, size int) (*MessageContext, error)
+	// SetDisappearingTimer sets after how many seconds new messages of the conversation are
+	// deleted for everyone (0 turns it off)
+	SetDisappearingTimer(userID, conversationID string, seconds int) (*model.Conversation, error)
+	// ExpireMessages deletes expired disappearing messages and messages older than retention
+	ExpireMessages(retention time.Duration) (int, error)
+	// AcceptMessageRequest moves a message request from a non-friend into the user's inbox
+	AcceptMessageRequest(userID, conversationID string) (*model.Conversation, error)
+	DeclineMessageRequest(userID, conversationID string) error
+	// BlockMessageRequest declines a message request and blocks its sender
+	BlockMessageRequest(userID, conversationID string) error
+}
+
+// MessageContent is the content of a new message. Text is optional when it has attachments;
+// a reply quotes a message of the same conversation.
+type MessageContent struct {
+	Content          string   `json:"content"`
+	AttachmentIDs    []string `json:"attachment_ids"`
+	ReplyToMessageID string   `json:"reply_to_message_id"`
+}
+
+// ChatSettingsRequest is the request body for changing chat settings
+type ChatSettingsRequest struct {
+	HideReadReceipts *bool   `json:"hide_read_receipts"`
+	MessageRequests  *string `json:"message_requests"`
+}
+
+// ClientMessageRequest is a message sent over the WebSocket. It goes to the conversation, or
+// to the direct conversation with the receiver.
+type ClientMessageRequest struct {
+	ClientID       string `json:"client_id"`
+	ConversationID string `json:"conversation_id"`
+	ReceiverID     string `json:"receiver_id"`
+	MessageContent
+}
+
+// ForwardMessageRequest is the request body for forwarding a message to a conversation, or to
+// the direct conversation with the receiver
+type ForwardMessageRequest struct {
+	ConversationID string `json:"conversation_id"`
+	ReceiverID     string `json:"receiver_id"`
+}
+
+// CreateGroupChatRequest is the request body for creating a group chat
+type CreateGroupChatRequest struct {
+	Title     string   `json:"title" binding:"required"`
+	AvatarURL *string  `json:"avatar_url"`
+	MemberIDs []string `json:"member_ids" binding:"required,min=1"`
+}
+
+// UpdateGroupChatRequest is the request body for changing a group chat's title or avatar. An empty
+// avatar_url removes the avatar.
+type UpdateGroupChatRequest struct {
+	Title     *string `json:"title"`
+	AvatarURL *string `json:"avatar_url"`
+}
+
+// ConversationListOptions selects a page of the inbox
+type ConversationListOptions struct {
+	Limit    int
+	Cursor   string
+	Archived bool // List archived conversations instead of the inbox
+	Requests bool // List pending message requests instead of the inbox
+}
+
+// ConversationPage is a page of the inbox. Pinned conversations are only returned with the
+// first page and are not part of the paginated list.
+type ConversationPage struct {
+	Pinned        []*model.Conversation
+	Conversations []*model.Conversation
+	NextCursor    string // Empty on the last page
+	RequestCount  int64  // Pending message requests, with the first page of the inbox
 }
 
 type chatService struct {
-	chatRepo   repository.ChatRepository
-	userRepo   repository.UserRepository
-	friendRepo repository.FriendshipRepository
+	chatRepo       repository.ChatRepository
+	convRepo       repository.ConversationRepository
+	userRepo       repository.UserRepository
+	friendRepo     repository.FriendshipRepository
+	webPushService WebPushService
+	mediaStore     util.MediaStore
+	wsHub          interface {
+		BroadcastToUser(string, map[string]interface{})
+		GetClientCount(userID string) int
+	}
+
+	typingMu     sync.Mutex
+	typingSentAt map[string]time.Time // Last relayed "typing" by user and conversation
 }
 
 func NewChatService(
 	chatRepo repository.ChatRepository,
+	convRepo repository.ConversationRepository,
 	userRepo repository.UserRepository,
 	friendRepo repository.FriendshipRepository,
+	webPushService WebPushService,
+	mediaStore util.MediaStore,
+	wsHub interface {
+		BroadcastToUser(string, map[string]interface{})
+		GetClientCount(userID string) int
+	},
 ) ChatService {
 	return &chatService{
-		chatRepo:   chatRepo,
-		userRepo:   userRepo,
-		friendRepo: friendRepo,
+		chatRepo:       chatRepo,
+		convRepo:       convRepo,
+		userRepo:       userRepo,
+		friendRepo:     friendRepo,
+		webPushService: webPushService,
+		mediaStore:     mediaStore,
+		wsHub:          wsHub,
+		typingSentAt:   make(map[string]time.Time),
+	}
+}
+
+func (s *chatService) SendMessage(senderID, receiverID string, content *MessageContent) (*model.ChatMessage, error) {
+	msg, err := s.newMessage(senderID, content)
+	if err != nil {
+		return nil, err
 	}
+	msg.ReceiverID = &receiverID
+	return s.sendDirect(msg)
 }
 
-func (s *chatService) SendMessage(senderID, receiverID, content string) (*model.ChatMessage, error) {
-	if content == "" {
+// SendConversationMessage sends a message to any conversation the sender participates in
+func (s *chatService) SendConversationMessage(senderID, conversationID string, content *MessageContent) (*model.ChatMessage, error) {
+	msg, err := s.newMessage(senderID, content)
+	if err != nil {
+		return nil, err
+	}
+	return s.sendToConversation(conversationID, msg)
+}
+
+// newMessage builds an unsaved message of the sender with its attachments loaded
+func (s *chatService) newMessage(senderID string, content *MessageContent) (*model.ChatMessage, error) {
+	msg := &model.ChatMessage{
+		SenderID:    senderID,
+		MessageType: model.ChatMessageTypeText,
+		Content:     content.Content,
+	}
+	if content.ReplyToMessageID != "" {
+		replyTo := content.ReplyToMessageID
+		msg.ReplyToMessageID = &replyTo
+	}
+	if err := s.prepareAttachments(msg, content.AttachmentIDs); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// SendClientMessage sends a message identified by the client's own ID. A resend of the same
+// client ID, e.g. after a reconnect, returns the stored message without sending it again.
+func (s *chatService) SendClientMessage(senderID string, req *ClientMessageRequest) (*model.ChatMessage, error) {
+	clientID := strings.TrimSpace(req.ClientID)
+	if clientID == "" {
+		return nil, errors.New("client_id is required")
+	}
+	if len(clientID) > maxClientMessageIDLength {
+		return nil, fmt.Errorf("client_id can be at most %d characters", maxClientMessageIDLength)
+	}
+	if existing, err := s.chatRepo.FindByClientID(senderID, clientID); err == nil {
+		s.presentMessages(existing)
+		return existing, nil
+	}
+
+	msg, err := s.newMessage(senderID, &req.MessageContent)
+	if err != nil {
+		return nil, err
+	}
+	msg.ClientMessageID = &clientID
+	saved, err := s.sendTo(req.ConversationID, req.ReceiverID, msg)
+	if err != nil {
+		// A concurrent resend of the same message may have stored it first
+		if existing, findErr := s.chatRepo.FindByClientID(senderID, clientID); findErr == nil {
+			s.presentMessages(existing)
+			return existing, nil
+		}
+		return nil, err
+	}
+	return saved, nil
+}
+
+// sendTo sends msg to the conversation, or to the direct conversation with the receiver
+func (s *chatService) sendTo(conversationID, receiverID string, msg *model.ChatMessage) (*model.ChatMessage, error) {
+	switch {
+	case conversationID != "":
+		return s.sendToConversation(conversationID, msg)
+	case receiverID != "":
+		msg.ReceiverID = &receiverID
+		return s.sendDirect(msg)
+	default:
+		return nil, errors.New("conversation_id or receiver_id is required")
+	}
+}
+
+// sendDirect sends msg to its receiver, starting their direct conversation if needed
+func (s *chatService) sendDirect(msg *model.ChatMessage) (*model.ChatMessage, error) {
+	senderID, receiverID := msg.SenderID, *msg.ReceiverID
+	if strings.TrimSpace(msg.Content) == "" && len(msg.Attachments) == 0 {
 		return nil, errors.New("message content cannot be empty")
 	}
 	if senderID == receiverID {
@@ -43,21 +291,264 @@ func (s *chatService) SendMessage(senderID, receiverID, content string) (*model.
 	if _, err := s.userRepo.FindByID(receiverID); err != nil {
 		return nil, errors.New("receiver not found")
 	}
-	// Check friendship - only friends can chat
-	friendship, fErr := s.friendRepo.FindBySenderAndReceiver(senderID, receiverID)
-	if fErr != nil || friendship.Status != "accepted" {
-		return nil, errors.New("can only chat with friends")
+	existing, err := s.convRepo.FindDirect(senderID, receiverID)
+	if err != nil && !errors.Is(err, repository.ErrConversationNotFound) {
+		return nil, fmt.Errorf("failed to load conversation: %w", err)
+	}
+	request, err := s.checkDirectMessage(existing, msg)
+	if err != nil {
+		return nil, err
 	}
 
-	msg := &model.ChatMessage{
-		SenderID:   senderID,
-		ReceiverID: receiverID,
-		Content:    content,
+	conversation, err := s.convRepo.FindOrCreateDirect(senderID, receiverID)
+	if err != nil {
+		return nil, errors.New("failed to start conversation")
+	}
+	if request {
+		if err := s.startRequest(conversation, receiverID); err != nil {
+			return nil, err
+		}
+	}
+	return s.addMessage(conversation, msg)
+}
+
+// sendToConversation sends msg to a conversation of its sender
+func (s *chatService) sendToConversation(conversationID string, msg *model.ChatMessage) (*model.ChatMessage, error) {
+	if strings.TrimSpace(msg.Content) == "" && len(msg.Attachments) == 0 {
+		return nil, errors.New("message content cannot be empty")
+	}
+	conversation, err := s.findConversation(msg.SenderID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !conversation.IsGroup() {
+		for _, participant := range conversation.Participants {
+			if participant.UserID != msg.SenderID {
+				receiverID := participant.UserID
+				msg.ReceiverID = &receiverID
+			}
+		}
+		if msg.ReceiverID == nil {
+			return nil, errors.New("conversation not found")
+		}
+		request, err := s.checkDirectMessage(conversation, msg)
+		if err != nil {
+			return nil, err
+		}
+		if request {
+			if err := s.startRequest(conversation, *msg.ReceiverID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s.addMessage(conversation, msg)
+}
+
+// SetTyping tells the other participants whether the user is typing in the conversation
+func (s *chatService) SetTyping(userID, conversationID string, typing bool) error {
+	key := userID + ":" + conversationID
+	now := time.Now()
+	s.typingMu.Lock()
+	if typing && now.Sub(s.typingSentAt[key]) < typingThrottle {
+		s.typingMu.Unlock()
+		return nil
+	}
+	s.typingMu.Unlock()
+
+	conversation, err := s.findConversation(userID, conversationID)
+	if err != nil {
+		return err
+	}
+
+	s.typingMu.Lock()
+	if typing {
+		s.typingSentAt[key] = now
+	} else {
+		delete(s.typingSentAt, key)
 	}
-	if err := s.chatRepo.Create(msg); err != nil {
+	for k, sentAt := range s.typingSentAt {
+		if now.Sub(sentAt) > typingExpiry {
+			delete(s.typingSentAt, k)
+		}
+	}
+	s.typingMu.Unlock()
+
+	if s.wsHub == nil {
+		return nil
+	}
+	for _, participant := range conversation.Participants {
+		if participant.UserID == userID {
+			continue
+		}
+		s.wsHub.BroadcastToUser(participant.UserID, map[string]interface{}{
+			"type": "chat_typing",
+			"payload": map[string]interface{}{
+				"conversation_id": conversationID,
+				"user_id":         userID,
+				"typing":          typing,
+				"expires_in":      int(typingExpiry.Seconds()), // Hide the indicator after this many seconds
+			},
+		})
+	}
+	return nil
+}
+
+// addMessage stores msg in the conversation and delivers it to the participants
+func (s *chatService) addMessage(conversation *model.Conversation, msg *model.ChatMessage) (*model.ChatMessage, error) {
+	if err := s.checkReply(conversation, msg); err != nil {
 		return nil, err
 	}
-	return s.chatRepo.FindByID(msg.ID)
+	setExpiry(conversation, msg)
+	if err := s.convRepo.AddMessage(conversation, msg); err != nil {
+		if errors.Is(err, repository.ErrAttachmentsUnavailable) {
+			return nil, errors.New("attachment was already sent")
+		}
+		return nil, errors.New("failed to send message")
+	}
+	// A direct message reaching a connected receiver is delivered right away
+	if msg.ReceiverID != nil && s.wsHub != nil && s.wsHub.GetClientCount(*msg.ReceiverID) > 0 {
+		if err := s.chatRepo.MarkDelivered(msg.ID, time.Now()); err != nil {
+			log.Printf("[CHAT] Failed to mark message %s delivered: %v", msg.ID, err)
+		}
+	}
+	saved, err := s.chatRepo.FindByID(msg.ID)
+	if err != nil {
+		return nil, errors.New("failed to get message")
+	}
+	s.presentMessages(saved)
+	s.deliverMessage(conversation, saved)
+	return saved, nil
+}
+
+// MarkDelivered marks the direct messages sent to the user while they were offline delivered
+func (s *chatService) MarkDelivered(userID string) error {
+	now := time.Now()
+	messages, err := s.chatRepo.MarkDeliveredTo(userID, now)
+	if err != nil {
+		return err
+	}
+	if s.wsHub == nil {
+		return nil
+	}
+
+	// One event per sender and conversation
+	type thread struct{ senderID, conversationID string }
+	delivered := make(map[thread][]string)
+	for _, msg := range messages {
+		key := thread{senderID: msg.SenderID}
+		if msg.ConversationID != nil {
+			key.conversationID = *msg.ConversationID
+		}
+		delivered[key] = append(delivered[key], msg.ID)
+	}
+	for key, messageIDs := range delivered {
+		s.wsHub.BroadcastToUser(key.senderID, map[string]interface{}{
+			"type": "chat_delivered",
+			"payload": map[string]interface{}{
+				"conversation_id": key.conversationID,
+				"receiver_id":     userID,
+				"message_ids":     messageIDs,
+				"delivered_at":    now,
+			},
+		})
+	}
+	return nil
+}
+
+// deliverMessage fans msg out over WebSocket to every participant of the conversation.
+// Participants without an open tab get a web push instead, unless they muted the conversation
+// or it is a message request to them.
+func (s *chatService) deliverMessage(conversation *model.Conversation, msg *model.ChatMessage) {
+	now := time.Now()
+	for i := range conversation.Participants {
+		participant := &conversation.Participants[i]
+		if s.wsHub != nil {
+			s.wsHub.BroadcastToUser(participant.UserID, map[string]interface{}{
+				"type":    "chat_message",
+				"payload": chatMessagePayload(msg),
+			})
+		}
+		if s.webPushService != nil && msg.MessageType != model.ChatMessageTypeSystem &&
+			participant.UserID != msg.SenderID && !participant.IsMuted(now) && !participant.IsRequest() {
+			go s.pushChatMessage(conversation, msg, participant.UserID)
+		}
+	}
+}
+
+func chatMessagePayload(msg *model.ChatMessage) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                  msg.ID,
+		"conversation_id":     msg.ConversationID,
+		"sender_id":           msg.SenderID,
+		"receiver_id":         msg.ReceiverID,
+		"client_id":           msg.ClientMessageID,
+		"reply_to_message_id": msg.ReplyToMessageID,
+		"reply_to":            msg.Quote,
+		"forwarded":           msg.IsForwarded,
+		"message_type":        msg.MessageType,
+		"content":             msg.Content,
+		"metadata":            msg.Metadata,
+		"attachments":         msg.Attachments,
+		"reactions":           msg.Reactions,
+		"delivered_at":        msg.DeliveredAt,
+		"edited_at":           msg.EditedAt,
+		"unsent_at":           msg.UnsentAt,
+		"expires_at":          msg.ExpiresAt,
+		"created_at":          msg.CreatedAt,
+		"sender":              msg.Sender,
+	}
+}
+
+func (s *chatService) pushChatMessage(conversation *model.Conversation, msg *model.ChatMessage, userID string) {
+	senderName := userDisplayName(&msg.Sender)
+	preview := msg.Content
+	if preview == "" {
+		preview = attachmentPreview(msg)
+	}
+	if utf8.RuneCountInString(preview) > 100 {
+		preview = string([]rune(preview)[:100]) + "..."
+	}
+
+	message := &WebPushMessage{
+		Type:  "chat_message",
+		Title: senderName,
+		Body:  preview,
+		Tag:   "chat:" + conversation.ID, // One notification per conversation on the device
+		URL:   "/chat?with_user_id=" + msg.SenderID,
+		Data: map[string]interface{}{
+			"message_id":      msg.ID,
+			"conversation_id": conversation.ID,
+			"sender_id":       msg.SenderID,
+		},
+	}
+	if conversation.IsGroup() {
+		if conversation.Title != nil {
+			message.Title = *conversation.Title
+		}
+		message.Body = senderName + ": " + preview
+		message.URL = "/chat?conversation_id=" + conversation.ID
+	}
+
+	if err := s.webPushService.SendToOfflineUser(userID, message); err != nil {
+		log.Printf("[WEB PUSH] Failed to push chat message %s: %v", msg.ID, err)
+	}
+}
+
+// userDisplayName names the user in pushes and system messages
+func userDisplayName(user *model.User) string {
+	if user.FullName != "" {
+		return user.FullName
+	}
+	if user.Username != nil && *user.Username != "" {
+		return *user.Username
+	}
+	return "Seseorang"
+}
+
+func (s *chatService) areFriends(userID, otherUserID string) bool {
+	friendship, err := s.friendRepo.FindBySenderAndReceiver(userID, otherUserID)
+	return err == nil && friendship.Status == "accepted"
 }
 
 func (s *chatService) GetConversation(userID, otherUserID string, limit, offset int) ([]*model.ChatMessage, error) {
@@ -67,13 +558,133 @@ func (s *chatService) GetConversation(userID, otherUserID string, limit, offset
 	if limit > 100 {
 		limit = 100
 	}
-	return s.chatRepo.GetConversation(userID, otherUserID, limit, offset)
+	messages, err := s.chatRepo.GetConversation(userID, otherUserID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	s.presentMessages(messages...)
+	return s.hideReadTimes(userID, messages), nil
 }
 
 func (s *chatService) MarkAsRead(userID, senderID string) error {
+	if conversation, err := s.convRepo.FindDirect(userID, senderID); err == nil {
+		if participant := conversation.Participant(userID); participant != nil {
+			return s.markRead(participant)
+		}
+	}
 	return s.chatRepo.MarkAsRead(userID, senderID)
 }
 
+// markRead marks the conversation read for the participant and, unless they hide read
+// receipts, tells the other participants. Reading a message request records neither receipts
+// nor a read position until it is accepted.
+func (s *chatService) markRead(participant *model.ConversationParticipant) error {
+	if participant.IsRequest() {
+		if err := s.convRepo.ClearUnread(participant.ConversationID, participant.UserID); err != nil {
+			return errors.New("failed to mark conversation read")
+		}
+		return nil
+	}
+	settings, err := s.chatRepo.FindSettings(participant.UserID)
+	if err != nil {
+		return errors.New("failed to mark conversation read")
+	}
+	receipts := !settings.HideReadReceipts
+	if err := s.convRepo.MarkRead(participant.ConversationID, participant.UserID, receipts); err != nil {
+		return errors.New("failed to mark conversation read")
+	}
+	if receipts && participant.UnreadCount > 0 {
+		s.notifyRead(participant.UserID, participant.ConversationID)
+	}
+	return nil
+}
+
+// notifyRead sends the reader's new read position to the other participants, except to those
+// who hide read receipts themselves
+func (s *chatService) notifyRead(readerID, conversationID string) {
+	if s.wsHub == nil {
+		return
+	}
+	conversation, err := s.convRepo.FindByID(conversationID)
+	if err != nil {
+		return
+	}
+	reader := conversation.Participant(readerID)
+	if reader == nil {
+		return
+	}
+
+	var others []string
+	for _, participant := range conversation.Participants {
+		if participant.UserID != readerID {
+			others = append(others, participant.UserID)
+		}
+	}
+	hiding, err := s.chatRepo.FindHidingReadReceipts(others)
+	if err != nil {
+		log.Printf("[CHAT] Failed to get read receipt settings: %v", err)
+		return
+	}
+	for _, userID := range others {
+		if hiding[userID] {
+			continue
+		}
+		s.wsHub.BroadcastToUser(userID, map[string]interface{}{
+			"type": "chat_read",
+			"payload": map[string]interface{}{
+				"conversation_id":      conversationID,
+				"user_id":              readerID,
+				"last_read_message_id": reader.LastReadMessageID,
+				"read_at":              reader.LastReadAt,
+			},
+		})
+	}
+}
+
+// hideReadTimes drops the read times from the messages of a user who hides read receipts
+func (s *chatService) hideReadTimes(userID string, messages []*model.ChatMessage) []*model.ChatMessage {
+	settings, err := s.chatRepo.FindSettings(userID)
+	if err == nil && !settings.HideReadReceipts {
+		return messages
+	}
+	for _, msg := range messages {
+		msg.ReadAt = nil
+	}
+	return messages
+}
+
+// GetSettings returns the user's chat settings
+func (s *chatService) GetSettings(userID string) (*model.ChatSettings, error) {
+	settings, err := s.chatRepo.FindSettings(userID)
+	if err != nil {
+		return nil, errors.New("failed to get chat settings")
+	}
+	return settings, nil
+}
+
+// UpdateSettings changes the user's chat settings
+func (s *chatService) UpdateSettings(userID string, req *ChatSettingsRequest) (*model.ChatSettings, error) {
+	settings, err := s.chatRepo.FindSettings(userID)
+	if err != nil {
+		return nil, errors.New("failed to get chat settings")
+	}
+	if req.HideReadReceipts != nil {
+		settings.HideReadReceipts = *req.HideReadReceipts
+	}
+	if req.MessageRequests != nil {
+		switch *req.MessageRequests {
+		case model.MessageRequestsEveryone, model.MessageRequestsFriendsOfFriends, model.MessageRequestsNobody:
+			settings.MessageRequests = *req.MessageRequests
+		default:
+			return nil, errors.New("message_requests must be everyone, friends_of_friends or nobody")
+		}
+	}
+	if err := s.chatRepo.UpsertSettings(settings); err != nil {
+		return nil, errors.New("failed to update chat settings")
+	}
+	return settings, nil
+}
+
 func (s *chatService) GetUnreadCount(userID string) (int64, error) {
 	return s.chatRepo.GetUnreadCount(userID)
 }
@@ -81,3 +692,593 @@ func (s *chatService) GetUnreadCount(userID string) (int64, error) {
 func (s *chatService) GetUnreadCountBySenders(userID string) (map[string]int64, error) {
 	return s.chatRepo.GetUnreadCountBySenders(userID)
 }
+
+// GetConversations returns a page of the user's inbox (or archive, or message requests), most
+// recently active first
+func (s *chatService) GetConversations(userID string, opts ConversationListOptions) (*ConversationPage, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = 20
+	}
+	if opts.Limit > 50 {
+		opts.Limit = 50
+	}
+
+	var after *repository.ConversationCursor
+	if opts.Cursor != "" {
+		var cursor repository.ConversationCursor
+		if err := util.DecodeCursor(opts.Cursor, &cursor); err != nil || cursor.ID == "" {
+			return nil, util.ErrInvalidCursor
+		}
+		after = &cursor
+	}
+
+	page := &ConversationPage{Pinned: []*model.Conversation{}}
+	if after == nil && !opts.Archived && !opts.Requests {
+		pinned, err := s.convRepo.FindPinnedByUserID(userID)
+		if err != nil {
+			return nil, errors.New("failed to get conversations")
+		}
+		page.Pinned = pinned
+		if page.RequestCount, err = s.convRepo.CountRequests(userID); err != nil {
+			return nil, errors.New("failed to get conversations")
+		}
+	}
+
+	// One extra row tells whether there is a next page
+	var conversations []*model.Conversation
+	var err error
+	if opts.Requests {
+		conversations, err = s.convRepo.FindRequestsByUserID(userID, opts.Limit+1, after)
+	} else {
+		conversations, err = s.convRepo.FindByUserID(userID, opts.Archived, opts.Limit+1, after)
+	}
+	if err != nil {
+		return nil, errors.New("failed to get conversations")
+	}
+	if len(conversations) > opts.Limit {
+		conversations = conversations[:opts.Limit]
+		page.NextCursor = util.EncodeCursor(repository.NewConversationCursor(conversations[len(conversations)-1]))
+	}
+	page.Conversations = conversations
+
+	s.presentConversations(userID, append(page.Pinned, page.Conversations...)...)
+	return page, nil
+}
+
+// MarkConversationRead marks the conversation read up to its last message
+func (s *chatService) MarkConversationRead(userID, conversationID string) error {
+	participant, err := s.findParticipant(userID, conversationID)
+	if err != nil {
+		return err
+	}
+	return s.markRead(participant)
+}
+
+// ArchiveConversation moves the conversation out of (or back into) the inbox. Archiving unpins it.
+func (s *chatService) ArchiveConversation(userID, conversationID string, archived bool) (*model.Conversation, error) {
+	if _, err := s.findParticipant(userID, conversationID); err != nil {
+		return nil, err
+	}
+	updates := map[string]interface{}{"archived_at": nil}
+	if archived {
+		updates["archived_at"] = time.Now()
+		updates["pinned_at"] = nil
+	}
+	return s.updateParticipant(userID, conversationID, updates)
+}
+
+// MuteConversation silences push notifications of the conversation
+func (s *chatService) MuteConversation(userID, conversationID string, muted bool, until *time.Time) (*model.Conversation, error) {
+	if _, err := s.findParticipant(userID, conversationID); err != nil {
+		return nil, err
+	}
+	if muted && until != nil && !until.After(time.Now()) {
+		return nil, errors.New("mute end must be in the future")
+	}
+	if !muted {
+		until = nil
+	}
+	return s.updateParticipant(userID, conversationID, map[string]interface{}{
+		"muted":       muted,
+		"muted_until": until,
+	})
+}
+
+// PinConversation keeps the conversation at the top of the inbox. Pinning unarchives it.
+func (s *chatService) PinConversation(userID, conversationID string, pinned bool) (*model.Conversation, error) {
+	participant, err := s.findParticipant(userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !pinned {
+		return s.updateParticipant(userID, conversationID, map[string]interface{}{"pinned_at": nil})
+	}
+	if participant.PinnedAt == nil {
+		count, err := s.convRepo.CountPinned(userID)
+		if err != nil {
+			return nil, errors.New("failed to pin conversation")
+		}
+		if count >= MaxPinnedConversations {
+			return nil, errors.New("you can pin up to 5 conversations")
+		}
+	}
+	return s.updateParticipant(userID, conversationID, map[string]interface{}{
+		"pinned_at":   time.Now(),
+		"archived_at": nil,
+	})
+}
+
+// findParticipant returns the user's participation, or an error when they are not in the conversation
+func (s *chatService) findParticipant(userID, conversationID string) (*model.ConversationParticipant, error) {
+	participant, err := s.convRepo.FindParticipant(conversationID, userID)
+	if err != nil {
+		return nil, errors.New("conversation not found")
+	}
+	return participant, nil
+}
+
+func (s *chatService) updateParticipant(userID, conversationID string, updates map[string]interface{}) (*model.Conversation, error) {
+	if err := s.convRepo.UpdateParticipant(conversationID, userID, updates); err != nil {
+		return nil, errors.New("failed to update conversation")
+	}
+	conversation, err := s.convRepo.FindByID(conversationID)
+	if err != nil {
+		return nil, errors.New("failed to get conversation")
+	}
+	s.presentConversations(userID, conversation)
+	return conversation, nil
+}
+
+// findConversation returns the conversation, or an error when the user is not a participant
+func (s *chatService) findConversation(userID, conversationID string) (*model.Conversation, error) {
+	conversation, err := s.convRepo.FindByID(conversationID)
+	if err != nil || conversation.Participant(userID) == nil {
+		return nil, errors.New("conversation not found")
+	}
+	return conversation, nil
+}
+
+// findGroupAsAdmin returns the group conversation, or an error when the user is not its admin
+func (s *chatService) findGroupAsAdmin(userID, conversationID string) (*model.Conversation, error) {
+	conversation, err := s.findConversation(userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !conversation.IsGroup() {
+		return nil, errors.New("conversation is not a group")
+	}
+	if conversation.Participant(userID).Role != model.ConversationRoleAdmin {
+		return nil, errors.New("only group admins can do this")
+	}
+	return conversation, nil
+}
+
+// GetConversationByID returns the conversation as seen by the user
+func (s *chatService) GetConversationByID(userID, conversationID string) (*model.Conversation, error) {
+	conversation, err := s.findConversation(userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	s.presentConversations(userID, conversation)
+	return conversation, nil
+}
+
+// presentConversations prepares conversations for the user: their own inbox state, signed
+// attachment URLs and only the read positions they may see
+func (s *chatService) presentConversations(userID string, conversations ...*model.Conversation) {
+	var userIDs []string
+	for _, conversation := range conversations {
+		userIDs = append(userIDs, conversation.ParticipantIDs()...)
+	}
+	hiding := s.hidingReadReceipts(userIDs)
+	for _, conversation := range conversations {
+		conversation.SetViewer(userID)
+		s.signConversationAttachments(conversation)
+		conversation.Participants = visibleReadPositions(userID, conversation.Participants, hiding)
+	}
+}
+
+// hidingReadReceipts returns which of the users hide their read receipts. When the settings
+// cannot be loaded every user counts as hiding them, so no hidden read position is shown.
+func (s *chatService) hidingReadReceipts(userIDs []string) map[string]bool {
+	hiding, err := s.chatRepo.FindHidingReadReceipts(userIDs)
+	if err != nil {
+		log.Printf("[CHAT] Failed to get read receipt settings: %v", err)
+		hiding = make(map[string]bool, len(userIDs))
+		for _, id := range userIDs {
+			hiding[id] = true
+		}
+	}
+	return hiding
+}
+
+// visibleReadPositions returns a copy of the participants without the read positions the
+// viewer may not see: those of users hiding read receipts, or all others' when the viewer hides them
+func visibleReadPositions(viewerID string, participants []model.ConversationParticipant, hiding map[string]bool) []model.ConversationParticipant {
+	visible := make([]model.ConversationParticipant, len(participants))
+	copy(visible, participants)
+	for i := range visible {
+		if visible[i].UserID != viewerID && (hiding[viewerID] || hiding[visible[i].UserID]) {
+			visible[i].LastReadMessageID = nil
+			visible[i].LastReadAt = nil
+		}
+	}
+	return visible
+}
+
+// GetConversationMessages returns a page of the conversation's messages, oldest first
+func (s *chatService) GetConversationMessages(userID, conversationID string, limit, offset int) ([]*model.ChatMessage, error) {
+	if _, err := s.findParticipant(userID, conversationID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	messages, err := s.chatRepo.FindByConversationID(conversationID, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	s.presentMessages(messages...)
+	return s.hideReadTimes(userID, messages), nil
+}
+
+// CreateGroup creates the group with the creator as admin and the other members as members
+func (s *chatService) CreateGroup(creatorID string, req *CreateGroupChatRequest) (*model.Conversation, error) {
+	title, err := normalizeGroupTitle(req.Title)
+	if err != nil {
+		return nil, err
+	}
+	creator, err := s.userRepo.FindByID(creatorID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	members, err := s.findNewMembers(creatorID, req.MemberIDs, map[string]bool{creatorID: true})
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, errors.New("a group needs at least one other member")
+	}
+	if len(members)+1 > model.MaxGroupMembers {
+		return nil, fmt.Errorf("a group can have up to %d members", model.MaxGroupMembers)
+	}
+
+	conversation := &model.Conversation{
+		Type:           model.ConversationTypeGroup,
+		Title:          &title,
+		AvatarURL:      normalizeAvatarURL(req.AvatarURL),
+		CreatedByID:    &creatorID,
+		LastActivityAt: time.Now(),
+	}
+	participants := []*model.ConversationParticipant{{UserID: creatorID, Role: model.ConversationRoleAdmin}}
+	for _, member := range members {
+		participants = append(participants, &model.ConversationParticipant{UserID: member.ID, Role: model.ConversationRoleMember})
+	}
+	msg := systemMessage(creatorID, model.ChatEventGroupCreated, nil,
+		fmt.Sprintf("%s created the group \"%s\"", userDisplayName(creator), title))
+	if err := s.convRepo.CreateGroup(conversation, participants, msg); err != nil {
+		return nil, errors.New("failed to create group")
+	}
+
+	return s.afterGroupChange(creatorID, conversation.ID, msg.ID)
+}
+
+// UpdateGroup changes the group's title and/or avatar
+func (s *chatService) UpdateGroup(userID, conversationID string, req *UpdateGroupChatRequest) (*model.Conversation, error) {
+	conversation, err := s.findGroupAsAdmin(userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	var changes []string
+	if req.Title != nil {
+		title, err := normalizeGroupTitle(*req.Title)
+		if err != nil {
+			return nil, err
+		}
+		if conversation.Title == nil || *conversation.Title != title {
+			updates["title"] = title
+			changes = append(changes, fmt.Sprintf("changed the group name to \"%s\"", title))
+		}
+	}
+	if req.AvatarURL != nil {
+		avatarURL := normalizeAvatarURL(req.AvatarURL)
+		if avatarURL == nil && conversation.AvatarURL != nil {
+			updates["avatar_url"] = nil
+			changes = append(changes, "removed the group photo")
+		} else if avatarURL != nil && (conversation.AvatarURL == nil || *conversation.AvatarURL != *avatarURL) {
+			updates["avatar_url"] = *avatarURL
+			changes = append(changes, "changed the group photo")
+		}
+	}
+	if len(updates) == 0 {
+		s.presentConversations(userID, conversation)
+		return conversation, nil
+	}
+
+	actor := conversation.Participant(userID).User
+	msg := systemMessage(userID, model.ChatEventGroupUpdated, nil,
+		userDisplayName(&actor)+" "+strings.Join(changes, " and "))
+	if err := s.convRepo.UpdateConversation(conversationID, updates, msg); err != nil {
+		return nil, errors.New("failed to update group")
+	}
+	return s.afterGroupChange(userID, conversationID, msg.ID)
+}
+
+// AddGroupMembers adds friends of the admin to the group
+func (s *chatService) AddGroupMembers(userID, conversationID string, memberIDs []string) (*model.Conversation, error) {
+	conversation, err := s.findGroupAsAdmin(userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(conversation.Participants))
+	for _, participant := range conversation.Participants {
+		existing[participant.UserID] = true
+	}
+	members, err := s.findNewMembers(userID, memberIDs, existing)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, errors.New("users are already in the group")
+	}
+	if len(conversation.Participants)+len(members) > model.MaxGroupMembers {
+		return nil, fmt.Errorf("a group can have up to %d members", model.MaxGroupMembers)
+	}
+
+	participants := make([]*model.ConversationParticipant, 0, len(members))
+	names := make([]string, 0, len(members))
+	userIDs := make([]string, 0, len(members))
+	for _, member := range members {
+		participants = append(participants, &model.ConversationParticipant{UserID: member.ID, Role: model.ConversationRoleMember})
+		names = append(names, userDisplayName(member))
+		userIDs = append(userIDs, member.ID)
+	}
+	actor := conversation.Participant(userID).User
+	msg := systemMessage(userID, model.ChatEventMembersAdded, userIDs,
+		fmt.Sprintf("%s added %s", userDisplayName(&actor), strings.Join(names, ", ")))
+	if err := s.convRepo.AddParticipants(conversationID, participants, msg); err != nil {
+		return nil, errors.New("failed to add members")
+	}
+	return s.afterGroupChange(userID, conversationID, msg.ID)
+}
+
+// RemoveGroupMember removes another member from the group
+func (s *chatService) RemoveGroupMember(userID, conversationID, memberID string) (*model.Conversation, error) {
+	if memberID == userID {
+		return nil, errors.New("use leave to remove yourself from the group")
+	}
+	conversation, err := s.findGroupAsAdmin(userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	member := conversation.Participant(memberID)
+	if member == nil {
+		return nil, errors.New("user is not a member of the group")
+	}
+
+	actor := conversation.Participant(userID).User
+	msg := systemMessage(userID, model.ChatEventMemberRemoved, []string{memberID},
+		fmt.Sprintf("%s removed %s", userDisplayName(&actor), userDisplayName(&member.User)))
+	if err := s.convRepo.RemoveParticipant(conversationID, memberID, msg); err != nil {
+		return nil, errors.New("failed to remove member")
+	}
+	s.notifyConversationRemoved(memberID, conversationID)
+	return s.afterGroupChange(userID, conversationID, msg.ID)
+}
+
+// LeaveGroup removes the user from the group, handing the admin role on when needed
+func (s *chatService) LeaveGroup(userID, conversationID string) error {
+	conversation, err := s.findConversation(userID, conversationID)
+	if err != nil {
+		return err
+	}
+	if !conversation.IsGroup() {
+		return errors.New("conversation is not a group")
+	}
+
+	leaving := conversation.Participant(userID)
+	if leaving.Role == model.ConversationRoleAdmin {
+		var successor *model.ConversationParticipant
+		hasOtherAdmin := false
+		for i := range conversation.Participants {
+			participant := &conversation.Participants[i]
+			if participant.UserID == userID {
+				continue
+			}
+			if participant.Role == model.ConversationRoleAdmin {
+				hasOtherAdmin = true
+				break
+			}
+			if successor == nil {
+				successor = participant // Participants are ordered by join time
+			}
+		}
+		if !hasOtherAdmin && successor != nil {
+			return s.handOverAndLeave(conversationID, leaving, successor)
+		}
+	}
+
+	msg := systemMessage(userID, model.ChatEventMemberLeft, []string{userID},
+		fmt.Sprintf("%s left the group", userDisplayName(&leaving.User)))
+	if err := s.convRepo.RemoveParticipant(conversationID, userID, msg); err != nil {
+		return errors.New("failed to leave group")
+	}
+	s.afterLeave(userID, conversationID, msg.ID)
+	return nil
+}
+
+// handOverAndLeave removes the last admin of a group while making the successor an admin
+func (s *chatService) handOverAndLeave(conversationID string, leaving, successor *model.ConversationParticipant) error {
+	left := systemMessage(leaving.UserID, model.ChatEventMemberLeft, []string{leaving.UserID},
+		fmt.Sprintf("%s left the group", userDisplayName(&leaving.User)))
+	promoted := systemMessage(leaving.UserID, model.ChatEventRoleChanged, []string{successor.UserID},
+		fmt.Sprintf("%s is now an admin", userDisplayName(&successor.User)))
+	promoted.Metadata["role"] = model.ConversationRoleAdmin
+	if err := s.convRepo.HandOverAndLeave(conversationID, leaving.UserID, successor.UserID, left, promoted); err != nil {
+		return errors.New("failed to leave group")
+	}
+	s.afterLeave(leaving.UserID, conversationID, left.ID, promoted.ID)
+	return nil
+}
+
+// afterLeave tells the user they left the group and delivers the group change to the others
+func (s *chatService) afterLeave(userID, conversationID string, messageIDs ...string) {
+	s.notifyConversationRemoved(userID, conversationID)
+	if _, err := s.afterGroupChange("", conversationID, messageIDs...); err != nil {
+		log.Printf("[CHAT] Failed to deliver leave of group %s: %v", conversationID, err)
+	}
+}
+
+// SetGroupMemberRole makes a member an admin, or an admin a member again
+func (s *chatService) SetGroupMemberRole(userID, conversationID, memberID, role string) (*model.Conversation, error) {
+	if role != model.ConversationRoleAdmin && role != model.ConversationRoleMember {
+		return nil, errors.New("role must be admin or member")
+	}
+	conversation, err := s.findGroupAsAdmin(userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	member := conversation.Participant(memberID)
+	if member == nil {
+		return nil, errors.New("user is not a member of the group")
+	}
+	if member.Role == role {
+		s.presentConversations(userID, conversation)
+		return conversation, nil
+	}
+	if role == model.ConversationRoleMember {
+		admins := 0
+		for _, participant := range conversation.Participants {
+			if participant.Role == model.ConversationRoleAdmin {
+				admins++
+			}
+		}
+		if admins <= 1 {
+			return nil, errors.New("a group needs at least one admin")
+		}
+	}
+
+	actor := conversation.Participant(userID).User
+	content := fmt.Sprintf("%s made %s an admin", userDisplayName(&actor), userDisplayName(&member.User))
+	if role == model.ConversationRoleMember {
+		content = fmt.Sprintf("%s removed %s as admin", userDisplayName(&actor), userDisplayName(&member.User))
+	}
+	msg := systemMessage(userID, model.ChatEventRoleChanged, []string{memberID}, content)
+	msg.Metadata["role"] = role
+	if err := s.convRepo.SetRole(conversationID, memberID, role, msg); err != nil {
+		return nil, errors.New("failed to change role")
+	}
+	return s.afterGroupChange(userID, conversationID, msg.ID)
+}
+
+// findNewMembers loads the users to add to a group, skipping duplicates and users in skip.
+// Only friends of the adding user can be added.
+func (s *chatService) findNewMembers(userID string, memberIDs []string, skip map[string]bool) ([]*model.User, error) {
+	var members []*model.User
+	seen := make(map[string]bool, len(memberIDs))
+	for _, memberID := range memberIDs {
+		if skip[memberID] || seen[memberID] {
+			continue
+		}
+		seen[memberID] = true
+		if len(seen) > model.MaxGroupMembers {
+			return nil, fmt.Errorf("a group can have up to %d members", model.MaxGroupMembers)
+		}
+
+		member, err := s.userRepo.FindByID(memberID)
+		if err != nil {
+			return nil, errors.New("user not found")
+		}
+		if !s.areFriends(userID, memberID) {
+			return nil, errors.New("can only add friends to a group")
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// afterGroupChange delivers the change's system messages and the updated conversation to the
+// members, and returns the conversation as seen by userID
+func (s *chatService) afterGroupChange(userID, conversationID string, messageIDs ...string) (*model.Conversation, error) {
+	conversation, err := s.convRepo.FindByID(conversationID)
+	if err != nil {
+		return nil, errors.New("failed to get conversation")
+	}
+	for _, messageID := range messageIDs {
+		if msg, err := s.chatRepo.FindByID(messageID); err == nil {
+			s.deliverMessage(conversation, msg)
+		}
+	}
+	s.signConversationAttachments(conversation)
+	hiding := s.hidingReadReceipts(conversation.ParticipantIDs())
+	if s.wsHub != nil {
+		for _, participant := range conversation.Participants {
+			view := *conversation
+			view.SetViewer(participant.UserID)
+			view.Participants = visibleReadPositions(participant.UserID, conversation.Participants, hiding)
+			s.wsHub.BroadcastToUser(participant.UserID, map[string]interface{}{
+				"type":    "conversation_updated",
+				"payload": &view,
+			})
+		}
+	}
+	conversation.SetViewer(userID)
+	conversation.Participants = visibleReadPositions(userID, conversation.Participants, hiding)
+	return conversation, nil
+}
+
+// notifyConversationRemoved tells a user who left or was removed to drop the conversation
+func (s *chatService) notifyConversationRemoved(userID, conversationID string) {
+	if s.wsHub == nil {
+		return
+	}
+	s.wsHub.BroadcastToUser(userID, map[string]interface{}{
+		"type":    "conversation_removed",
+		"payload": map[string]interface{}{"conversation_id": conversationID},
+	})
+}
+
+// systemMessage builds a membership or settings change message written on behalf of actorID
+func systemMessage(actorID, event string, userIDs []string, content string) *model.ChatMessage {
+	metadata := map[string]interface{}{
+		"event":    event,
+		"actor_id": actorID,
+	}
+	if len(userIDs) > 0 {
+		metadata["user_ids"] = userIDs
+	}
+	return &model.ChatMessage{
+		SenderID:    actorID,
+		MessageType: model.ChatMessageTypeSystem,
+		Content:     content,
+		Metadata:    metadata,
+	}
+}
+
+func normalizeGroupTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return "", errors.New("group title cannot be empty")
+	}
+	if utf8.RuneCountInString(title) > maxGroupTitleLength {
+		return "", fmt.Errorf("group title can be at most %d characters", maxGroupTitleLength)
+	}
+	return title, nil
+}
+
+// normalizeAvatarURL trims the URL, treating an empty one as no avatar
+func normalizeAvatarURL(avatarURL *string) *string {
+	if avatarURL == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*avatarURL)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
@@ -3,16 +3,20 @@ package service
 import (
 	"errors"
 	"fmt"
+	"log"
 
 	"yourapp/internal/model"
 	"yourapp/internal/repository"
+	"yourapp/internal/util"
 )
 
 type CommentService interface {
 	CreateComment(userID string, req CreateCommentRequest) (*model.Comment, error)
-	GetCommentByID(commentID string) (*model.Comment, error)
-	GetCommentsByPostID(postID string, limit, offset int) ([]*model.Comment, int64, error)
-	GetRepliesByCommentID(commentID string, limit, offset int) ([]*model.Comment, int64, error)
+	GetCommentByID(commentID, viewerID string) (*model.Comment, error)
+	GetCommentsByPostID(postID string, opts CommentListOptions) (*CommentPage, error)
+	GetRepliesByCommentID(commentID string, opts CommentListOptions) (*CommentPage, error)
+	PinComment(userID, commentID string) (*model.Comment, error)
+	UnpinComment(userID, commentID string) error
 	UpdateComment(userID, commentID string, req UpdateCommentRequest) (*model.Comment, error)
 	DeleteComment(userID, commentID string) error
 	GetCommentCount(postID string) (int64, error)
@@ -23,9 +27,40 @@ type commentService struct {
 	commentRepo         repository.CommentRepository
 	userRepo            repository.UserRepository
 	postRepo            repository.PostRepository
+	subscriptionRepo    repository.PostSubscriptionRepository
+	likeService         LikeService
 	notificationService NotificationService
 }
 
+// Bounds for how much of a thread is loaded along with each page of comments
+const (
+	DefaultCommentReplyDepth = 2 // Levels of replies loaded below each comment
+	MaxCommentReplyDepth     = 5
+	DefaultRepliesPerComment = 3 // Replies loaded per comment on each level
+	MaxRepliesPerComment     = 20
+	MaxThreadReplies         = 200 // Replies loaded per request across all threads and levels
+)
+
+// CommentListOptions controls paging and how much of each thread is loaded
+type CommentListOptions struct {
+	Sort    string // newest (default), oldest, top, controversial; applies to top-level comments
+	Limit   int
+	Offset  int    // Ignored when Cursor is set
+	Cursor  string // NextCursor of the previous page
+	Depth   int    // Levels of replies to load below each comment (0 = none)
+	Replies int    // Replies to load per comment on each level
+
+	ViewerID string // Fills UserReaction when set
+}
+
+// CommentPage is one page of comments with their bounded reply trees
+type CommentPage struct {
+	Sort       string
+	Comments   []*model.Comment
+	Total      int64
+	NextCursor string // Empty on the last page
+}
+
 type CreateCommentRequest struct {
 	PostID   string  `json:"post_id" binding:"required"`
 	ParentID *string `json:"parent_id,omitempty"` // For replies
@@ -41,12 +76,16 @@ func NewCommentService(
 	commentRepo repository.CommentRepository,
 	userRepo repository.UserRepository,
 	postRepo repository.PostRepository,
+	subscriptionRepo repository.PostSubscriptionRepository,
+	likeService LikeService,
 	notificationService NotificationService,
 ) CommentService {
 	return &commentService{
 		commentRepo:         commentRepo,
 		userRepo:            userRepo,
 		postRepo:            postRepo,
+		subscriptionRepo:    subscriptionRepo,
+		likeService:         likeService,
 		notificationService: notificationService,
 	}
 }
@@ -101,101 +140,323 @@ func (s *commentService) CreateComment(userID string, req CreateCommentRequest)
 
 	// Send notifications
 	if s.notificationService != nil && sender != nil {
-		// If this is a reply (parent_id is not null)
-		if req.ParentID != nil && *req.ParentID != "" && parentComment != nil {
-			// Only send notification if replying to someone else's comment
-			if parentComment.UserID != userID {
-				// Send notification to the parent comment owner
-				go func() {
-					if err := s.notificationService.SendCommentReplyNotification(
-						parentComment.UserID, // receiver (parent comment owner)
-						userID,               // sender (person who replied)
-						sender.FullName,      // sender name
-						comment.ID,           // new comment ID
-						req.PostID,           // post ID
-						req.Content,          // comment content
-					); err != nil {
-						// Log error but don't fail the comment creation
-						fmt.Printf("Failed to send comment reply notification: %v\n", err)
-					}
-				}()
-			}
-		} else {
-			// This is a new comment (not a reply)
-			// Send notification to post owner if comment is not from post owner
-			if post.UserID != userID {
-				go func() {
-					if err := s.notificationService.SendPostCommentNotification(
-						post.UserID,     // receiver (post owner)
-						userID,          // sender (person who commented)
-						sender.FullName, // sender name
-						comment.ID,      // new comment ID
-						req.PostID,      // post ID
-						req.Content,     // comment content
-					); err != nil {
-						// Log error but don't fail the comment creation
-						fmt.Printf("Failed to send post comment notification: %v\n", err)
-					}
-				}()
-			}
-		}
+		go s.notifyCommentActivity(post, parentComment, comment, sender)
+	}
+
+	// Commenters follow the thread from now on (a muted subscription stays muted)
+	if err := s.subscriptionRepo.Ensure(post.ID, userID, model.PostSubscriptionReasonCommented); err != nil {
+		log.Printf("Failed to subscribe user %s to post %s: %v", userID, post.ID, err)
 	}
 
 	// Reload with relationships
 	return s.commentRepo.FindByID(comment.ID)
 }
 
-// GetCommentByID gets a comment by ID
-func (s *commentService) GetCommentByID(commentID string) (*model.Comment, error) {
+// notifyCommentActivity sends the direct notification (reply -> parent comment author,
+// top-level comment -> post owner) and a thread_activity notification to every other
+// subscriber of the post. Users who muted the post get neither.
+func (s *commentService) notifyCommentActivity(post *model.Post, parent, comment *model.Comment, sender *model.User) {
+	subs, err := s.subscriptionRepo.FindByPostID(post.ID)
+	if err != nil {
+		log.Printf("Failed to load subscribers of post %s: %v", post.ID, err)
+	}
+	muted := make(map[string]bool)
+	for _, sub := range subs {
+		if sub.Muted {
+			muted[sub.UserID] = true
+		}
+	}
+
+	// The commenter and anyone who already got a notification for this comment are skipped
+	notified := map[string]bool{comment.UserID: true}
+	if parent != nil {
+		if !notified[parent.UserID] && !muted[parent.UserID] {
+			notified[parent.UserID] = true
+			if err := s.notificationService.SendCommentReplyNotification(
+				parent.UserID, sender.ID, sender.FullName, comment.ID, post.ID, comment.Content,
+			); err != nil {
+				log.Printf("Failed to send comment reply notification: %v", err)
+			}
+		}
+	} else if !notified[post.UserID] && !muted[post.UserID] {
+		notified[post.UserID] = true
+		if err := s.notificationService.SendPostCommentNotification(
+			post.UserID, sender.ID, sender.FullName, comment.ID, post.ID, comment.Content,
+		); err != nil {
+			log.Printf("Failed to send post comment notification: %v", err)
+		}
+	}
+
+	for _, sub := range subs {
+		if sub.Muted || notified[sub.UserID] {
+			continue
+		}
+		notified[sub.UserID] = true
+		if err := s.notificationService.SendThreadActivityNotification(
+			sub.UserID, sender.ID, sender.FullName, comment.ID, post.ID, comment.Content,
+		); err != nil {
+			log.Printf("Failed to send thread activity notification to %s: %v", sub.UserID, err)
+		}
+	}
+}
+
+// GetCommentByID gets a comment by ID with its first replies
+func (s *commentService) GetCommentByID(commentID, viewerID string) (*model.Comment, error) {
 	comment, err := s.commentRepo.FindByID(commentID)
 	if err != nil {
 		return nil, errors.New("comment not found")
 	}
+
+	if err := s.loadThreads([]*model.Comment{comment}, DefaultCommentReplyDepth, DefaultRepliesPerComment, viewerID); err != nil {
+		return nil, errors.New("failed to get replies")
+	}
 	return comment, nil
 }
 
-// GetCommentsByPostID gets comments for a post
-func (s *commentService) GetCommentsByPostID(postID string, limit, offset int) ([]*model.Comment, int64, error) {
+// GetCommentsByPostID gets a page of top-level comments for a post, each with a bounded reply tree
+func (s *commentService) GetCommentsByPostID(postID string, opts CommentListOptions) (*CommentPage, error) {
 	// Validate post exists
 	if _, err := s.postRepo.FindByID(postID); err != nil {
-		return nil, 0, errors.New("post not found")
+		return nil, errors.New("post not found")
 	}
 
-	// Get comments
-	comments, err := s.commentRepo.FindByPostID(postID, limit, offset)
+	opts = normalizeCommentListOptions(opts)
+	if opts.Sort == "" {
+		opts.Sort = model.CommentSortNewest
+	}
+	if !repository.IsValidCommentSort(opts.Sort) {
+		return nil, errors.New("invalid sort: use top, newest, oldest or controversial")
+	}
+	after, err := decodeCommentCursor(opts.Cursor, opts.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch one extra row to know whether there is a next page
+	comments, err := s.commentRepo.FindByPostID(postID, opts.Sort, opts.Limit+1, opts.Offset, after)
 	if err != nil {
-		return nil, 0, errors.New("failed to get comments")
+		return nil, errors.New("failed to get comments")
+	}
+
+	page := &CommentPage{Sort: opts.Sort}
+	if len(comments) > opts.Limit {
+		comments = comments[:opts.Limit]
+		page.NextCursor = encodeCommentCursor(opts.Sort, comments[len(comments)-1])
 	}
 
+	// The author-pinned comment leads the first page regardless of sort
+	if after == nil && opts.Offset == 0 {
+		if pinned, err := s.commentRepo.FindPinnedByPostID(postID); err == nil {
+			comments = append([]*model.Comment{pinned}, comments...)
+		}
+	}
+
+	if err := s.loadThreads(comments, opts.Depth, opts.Replies, opts.ViewerID); err != nil {
+		return nil, errors.New("failed to get replies")
+	}
+	page.Comments = comments
+
 	// Get total count
-	total, err := s.commentRepo.CountByPostID(postID)
+	page.Total, err = s.commentRepo.CountByPostID(postID)
 	if err != nil {
-		return nil, 0, errors.New("failed to get comment count")
+		return nil, errors.New("failed to get comment count")
 	}
 
-	return comments, total, nil
+	return page, nil
 }
 
-// GetRepliesByCommentID gets replies to a comment
-func (s *commentService) GetRepliesByCommentID(commentID string, limit, offset int) ([]*model.Comment, int64, error) {
+// GetRepliesByCommentID gets a page of direct replies to a comment, each with a bounded reply tree
+func (s *commentService) GetRepliesByCommentID(commentID string, opts CommentListOptions) (*CommentPage, error) {
 	// Validate comment exists
 	if _, err := s.commentRepo.FindByID(commentID); err != nil {
-		return nil, 0, errors.New("comment not found")
+		return nil, errors.New("comment not found")
 	}
 
-	// Get replies
-	replies, err := s.commentRepo.FindByParentID(commentID, limit, offset)
+	// Replies are always chronological
+	opts = normalizeCommentListOptions(opts)
+	after, err := decodeCommentCursor(opts.Cursor, model.CommentSortOldest)
 	if err != nil {
-		return nil, 0, errors.New("failed to get replies")
+		return nil, err
 	}
 
+	replies, err := s.commentRepo.FindByParentID(commentID, opts.Limit+1, opts.Offset, after)
+	if err != nil {
+		return nil, errors.New("failed to get replies")
+	}
+
+	page := &CommentPage{Sort: model.CommentSortOldest}
+	if len(replies) > opts.Limit {
+		replies = replies[:opts.Limit]
+		page.NextCursor = encodeCommentCursor(model.CommentSortOldest, replies[len(replies)-1])
+	}
+	if err := s.loadThreads(replies, opts.Depth, opts.Replies, opts.ViewerID); err != nil {
+		return nil, errors.New("failed to get replies")
+	}
+	page.Comments = replies
+
 	// Get total count
-	total, err := s.commentRepo.CountByParentID(commentID)
+	page.Total, err = s.commentRepo.CountByParentID(commentID)
+	if err != nil {
+		return nil, errors.New("failed to get reply count")
+	}
+
+	return page, nil
+}
+
+// loadThreads attaches up to depth levels of replies (perParent per comment and level,
+// MaxThreadReplies in total, nearest levels first) to roots using one recursive query, and fills like counts and reaction breakdowns
+// (plus the viewer's reactions) for the whole tree in one batch each.
+// Comments with unloaded replies get HasMoreReplies and a cursor for GET /comments/:id/replies.
+func (s *commentService) loadThreads(roots []*model.Comment, depth, perParent int, viewerID string) error {
+	if len(roots) == 0 {
+		return nil
+	}
+
+	rootIDs := make([]string, len(roots))
+	for i, c := range roots {
+		rootIDs[i] = c.ID
+	}
+	descendants, err := s.commentRepo.FindDescendants(rootIDs, depth, perParent, MaxThreadReplies)
 	if err != nil {
-		return nil, 0, errors.New("failed to get reply count")
+		return fmt.Errorf("failed to load replies: %w", err)
 	}
 
-	return replies, total, nil
+	all := append(append([]*model.Comment{}, roots...), descendants...)
+	if s.likeService != nil {
+		ids := make([]string, len(all))
+		for i, c := range all {
+			ids[i] = c.ID
+		}
+		if counts, err := s.likeService.GetLikeCountsBatch(model.TargetTypeComment, ids); err == nil {
+			for _, c := range all {
+				c.LikeCount = counts[c.ID]
+			}
+		}
+		reactionCounts, _ := s.likeService.GetReactionCountsBatch(model.TargetTypeComment, ids)
+		userReactions := make(map[string]string)
+		if viewerID != "" {
+			userReactions, _ = s.likeService.GetUserReactions(viewerID, model.TargetTypeComment, ids)
+		}
+		for _, c := range all {
+			c.SetReactions(reactionCounts[c.ID], userReactions[c.ID])
+		}
+	}
+
+	// Descendants are ordered oldest first, so children keep chronological order
+	children := make(map[string][]*model.Comment)
+	for _, c := range descendants {
+		if c.ParentID != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		}
+	}
+	for _, root := range roots {
+		attachReplies(root, children)
+	}
+	return nil
+}
+
+// attachReplies copies loaded children into comment.Replies bottom-up (Replies holds values)
+func attachReplies(comment *model.Comment, children map[string][]*model.Comment) {
+	kids := children[comment.ID]
+	comment.Replies = nil
+	for _, child := range kids {
+		attachReplies(child, children)
+		comment.Replies = append(comment.Replies, *child)
+	}
+
+	comment.HasMoreReplies = comment.ReplyCount > int64(len(kids))
+	comment.RepliesCursor = ""
+	if comment.HasMoreReplies && len(kids) > 0 {
+		comment.RepliesCursor = encodeCommentCursor(model.CommentSortOldest, kids[len(kids)-1])
+	}
+}
+
+func normalizeCommentListOptions(opts CommentListOptions) CommentListOptions {
+	if opts.Limit < 1 {
+		opts.Limit = 20
+	}
+	if opts.Offset < 0 {
+		opts.Offset = 0
+	}
+	if opts.Depth < 0 {
+		opts.Depth = 0
+	}
+	if opts.Depth > MaxCommentReplyDepth {
+		opts.Depth = MaxCommentReplyDepth
+	}
+	if opts.Replies < 1 {
+		opts.Replies = DefaultRepliesPerComment
+	}
+	if opts.Replies > MaxRepliesPerComment {
+		opts.Replies = MaxRepliesPerComment
+	}
+	return opts
+}
+
+func encodeCommentCursor(sort string, comment *model.Comment) string {
+	return util.EncodeCursor(repository.NewCommentCursor(sort, comment))
+}
+
+// decodeCommentCursor parses a cursor and checks it was issued for the same sort mode
+func decodeCommentCursor(token, sort string) (*repository.CommentCursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+	var cursor repository.CommentCursor
+	if err := util.DecodeCursor(token, &cursor); err != nil || cursor.ID == "" || cursor.Sort != sort {
+		return nil, util.ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// PinComment pins a top-level comment to the top of its post's comments (post author only).
+// Any previously pinned comment on the post is unpinned.
+func (s *commentService) PinComment(userID, commentID string) (*model.Comment, error) {
+	comment, err := s.commentRepo.FindByID(commentID)
+	if err != nil {
+		return nil, errors.New("comment not found")
+	}
+	if comment.ParentID != nil {
+		return nil, errors.New("only top-level comments can be pinned")
+	}
+
+	post, err := s.postRepo.FindByID(comment.PostID)
+	if err != nil {
+		return nil, errors.New("post not found")
+	}
+	if post.UserID != userID {
+		return nil, errors.New("unauthorized: only the post author can pin comments")
+	}
+
+	if err := s.commentRepo.SetPinned(post.ID, &comment.ID); err != nil {
+		return nil, errors.New("failed to pin comment")
+	}
+
+	return s.commentRepo.FindByID(comment.ID)
+}
+
+// UnpinComment removes the pin from a comment (post author only)
+func (s *commentService) UnpinComment(userID, commentID string) error {
+	comment, err := s.commentRepo.FindByID(commentID)
+	if err != nil {
+		return errors.New("comment not found")
+	}
+
+	post, err := s.postRepo.FindByID(comment.PostID)
+	if err != nil {
+		return errors.New("post not found")
+	}
+	if post.UserID != userID {
+		return errors.New("unauthorized: only the post author can unpin comments")
+	}
+	if !comment.IsPinned {
+		return errors.New("comment is not pinned")
+	}
+
+	if err := s.commentRepo.SetPinned(post.ID, nil); err != nil {
+		return errors.New("failed to unpin comment")
+	}
+	return nil
 }
 
 // UpdateComment updates a comment
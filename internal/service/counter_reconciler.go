@@ -0,0 +1,88 @@
+package service
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"yourapp/internal/repository"
+)
+
+const counterReconcileInterval = 1 * time.Hour
+
+// CounterReport summarizes one reconciliation run
+type CounterReport struct {
+	Fixed      map[string]int64 `json:"fixed"` // Rows repaired per counter, e.g. post.likes_count
+	TotalFixed int64            `json:"total_fixed"`
+	StartedAt  time.Time        `json:"started_at"`
+	Duration   string           `json:"duration"`
+}
+
+// CounterReconciler periodically recomputes the denormalized like/comment/view/share
+// counters from the underlying rows and repairs any drift.
+type CounterReconciler struct {
+	counterRepo repository.CounterRepository
+	mu          sync.Mutex // Serializes runs (ticker and on-demand)
+}
+
+func NewCounterReconciler(counterRepo repository.CounterRepository) *CounterReconciler {
+	return &CounterReconciler{counterRepo: counterRepo}
+}
+
+// Start runs the reconciliation in the background every counterReconcileInterval
+func (r *CounterReconciler) Start() {
+	log.Println("Counter reconciler started")
+
+	go func() {
+		ticker := time.NewTicker(counterReconcileInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			r.run()
+		}
+	}()
+}
+
+func (r *CounterReconciler) run() {
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("[COUNTER RECONCILER] PANIC while reconciling: %v", rec)
+		}
+	}()
+
+	if _, err := r.Reconcile(); err != nil {
+		log.Printf("[COUNTER RECONCILER] %v", err)
+	}
+}
+
+// Reconcile repairs every counter now and logs how many were fixed
+func (r *CounterReconciler) Reconcile() (*CounterReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := &CounterReport{StartedAt: time.Now()}
+	fixed, err := r.counterRepo.Reconcile()
+	report.Fixed = fixed
+	for _, n := range fixed {
+		report.TotalFixed += n
+	}
+	report.Duration = time.Since(report.StartedAt).Round(time.Millisecond).String()
+	if err != nil {
+		return report, err
+	}
+
+	if report.TotalFixed > 0 {
+		names := make([]string, 0, len(fixed))
+		for name, n := range fixed {
+			if n > 0 {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			log.Printf("[COUNTER RECONCILER] Fixed %d %s counter(s)", fixed[name], name)
+		}
+	}
+	log.Printf("[COUNTER RECONCILER] Run finished in %s, %d counter(s) fixed", report.Duration, report.TotalFixed)
+	return report, nil
+}
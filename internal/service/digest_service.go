@@ -0,0 +1,326 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"yourapp/internal/config"
+	"yourapp/internal/model"
+	"yourapp/internal/repository"
+	"yourapp/internal/util"
+)
+
+const (
+	digestInterval          = 1 * time.Hour
+	digestBatchSize         = 100
+	digestNotificationLimit = 5
+	digestChatLimit         = 5
+	digestTopPostLimit      = 3
+	digestExcerptLength     = 140
+)
+
+// DigestService periodically emails users who have not been around a summary of their
+// unread notifications, unread chats and the top posts of their friends.
+// Online users and users in their quiet hours are skipped until a later run.
+type DigestService struct {
+	prefRepo       repository.NotificationPreferenceRepository
+	notifRepo      repository.NotificationRepository
+	convRepo       repository.ConversationRepository
+	friendshipRepo repository.FriendshipRepository
+	postRepo       repository.PostRepository
+	userRepo       repository.UserRepository
+	rabbitMQ       *util.RabbitMQClient
+	emailService   EmailService
+	hub            interface{ GetClientCount(userID string) int }
+	cfg            *config.Config
+	mu             sync.Mutex // Serializes runs
+}
+
+func NewDigestService(
+	prefRepo repository.NotificationPreferenceRepository,
+	notifRepo repository.NotificationRepository,
+	convRepo repository.ConversationRepository,
+	friendshipRepo repository.FriendshipRepository,
+	postRepo repository.PostRepository,
+	userRepo repository.UserRepository,
+	rabbitMQ *util.RabbitMQClient,
+	emailService EmailService,
+	hub interface{ GetClientCount(userID string) int },
+	cfg *config.Config,
+) *DigestService {
+	return &DigestService{
+		prefRepo:       prefRepo,
+		notifRepo:      notifRepo,
+		convRepo:       convRepo,
+		friendshipRepo: friendshipRepo,
+		postRepo:       postRepo,
+		userRepo:       userRepo,
+		rabbitMQ:       rabbitMQ,
+		emailService:   emailService,
+		hub:            hub,
+		cfg:            cfg,
+	}
+}
+
+// Start sends the due digests in the background every digestInterval
+func (s *DigestService) Start() {
+	log.Println("Email digest scheduler started")
+
+	go func() {
+		ticker := time.NewTicker(digestInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.run()
+		}
+	}()
+}
+
+func (s *DigestService) run() {
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("[EMAIL DIGEST] PANIC while sending digests: %v", rec)
+		}
+	}()
+
+	sent, err := s.RunDue()
+	if err != nil {
+		log.Printf("[EMAIL DIGEST] %v", err)
+	}
+	if sent > 0 {
+		log.Printf("[EMAIL DIGEST] Queued %d digests", sent)
+	}
+}
+
+// RunDue sends every digest that is due now and returns how many were queued
+func (s *DigestService) RunDue() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Claims are released by comparing with the stored value, which has microsecond precision
+	now := time.Now().Truncate(time.Microsecond)
+	sent := 0
+	skipped := make(map[string]bool)
+	for {
+		candidates, err := s.prefRepo.FindDigestCandidates(now, digestBatchSize+len(skipped))
+		if err != nil {
+			return sent, fmt.Errorf("failed to find digest candidates: %w", err)
+		}
+
+		pending := make([]*repository.DigestCandidate, 0, len(candidates))
+		userIDs := make([]string, 0, len(candidates))
+		for _, candidate := range candidates {
+			if !skipped[candidate.UserID] {
+				pending = append(pending, candidate)
+				userIDs = append(userIDs, candidate.UserID)
+			}
+		}
+		if len(pending) == 0 {
+			return sent, nil
+		}
+		users, settings, err := s.loadCandidates(userIDs)
+		if err != nil {
+			return sent, err
+		}
+
+		for _, candidate := range pending {
+			ok := false
+			if user := users[candidate.UserID]; user != nil {
+				ok, err = s.sendDigest(candidate, user, settings[candidate.UserID], now)
+				if err != nil {
+					log.Printf("[EMAIL DIGEST] Failed to send digest to user %s: %v", candidate.UserID, err)
+				}
+			}
+			if ok {
+				sent++
+			} else {
+				// Not claimed, so the candidate query would return the user again
+				skipped[candidate.UserID] = true
+			}
+		}
+		if len(candidates) < digestBatchSize+len(skipped) {
+			return sent, nil
+		}
+	}
+}
+
+// loadCandidates loads the users and notification settings of a batch of candidates
+func (s *DigestService) loadCandidates(userIDs []string) (map[string]*model.User, map[string]*model.NotificationSettings, error) {
+	found, err := s.userRepo.FindByIDs(userIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get digest users: %w", err)
+	}
+	users := make(map[string]*model.User, len(found))
+	for _, user := range found {
+		users[user.ID] = user
+	}
+	settings, err := s.prefRepo.FindSettingsByUserIDs(userIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get digest settings: %w", err)
+	}
+	return users, settings, nil
+}
+
+// sendDigest claims and queues one user's digest; false means it was not sent this run
+func (s *DigestService) sendDigest(candidate *repository.DigestCandidate, user *model.User, settings *model.NotificationSettings, now time.Time) (bool, error) {
+	if s.hub != nil && s.hub.GetClientCount(user.ID) > 0 {
+		return false, nil
+	}
+	if settings.InQuietHours(now) || user.Email == "" {
+		return false, nil
+	}
+
+	interval := model.DigestInterval(candidate.Frequency)
+	digest, err := s.buildDigest(user, candidate.Frequency, now.Add(-interval))
+	if err != nil {
+		return false, err
+	}
+	if digest == nil {
+		return false, nil
+	}
+
+	// Claim first so concurrent runs never send the same digest, and give the claim back when
+	// the digest could not be queued so the next run retries it
+	claimed, err := s.prefRepo.ClaimDigest(user.ID, interval, now)
+	if err != nil || !claimed {
+		return false, err
+	}
+	if err := s.queueDigest(user.Email, digest); err != nil {
+		if releaseErr := s.prefRepo.ReleaseDigest(user.ID, now, settings.LastDigestAt); releaseErr != nil {
+			log.Printf("[EMAIL DIGEST] Failed to release digest claim of user %s: %v", user.ID, releaseErr)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// buildDigest gathers the user's unread activity, returning nil when there is nothing to report
+func (s *DigestService) buildDigest(user *model.User, frequency string, since time.Time) (*DigestEmail, error) {
+	digest := &DigestEmail{
+		Name:           user.FullName,
+		Period:         "mingguan",
+		UnsubscribeURL: strings.TrimRight(s.cfg.APIURL, "/") + "/api/v1/notifications/digest/unsubscribe?token=" + util.SignUnsubscribeToken(s.cfg.DigestUnsubscribeSecret, user.ID),
+	}
+	if frequency == model.DigestFrequencyDaily {
+		digest.Period = "harian"
+	}
+
+	notifications, err := s.notifRepo.FindUnreadByUserID(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unread notifications: %w", err)
+	}
+	digest.UnreadNotificationCount = int64(len(notifications))
+	for i, n := range notifications {
+		if i == digestNotificationLimit {
+			break
+		}
+		digest.Notifications = append(digest.Notifications, DigestNotification{Title: n.Title, Message: n.Message, CreatedAt: n.CreatedAt})
+	}
+
+	if digest.UnreadChatCount, err = s.convRepo.CountUnreadMessages(user.ID); err != nil {
+		return nil, fmt.Errorf("failed to count unread chats: %w", err)
+	}
+	if digest.UnreadChatCount > 0 {
+		conversations, err := s.convRepo.FindUnreadByUserID(user.ID, digestChatLimit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get unread chats: %w", err)
+		}
+		for _, conversation := range conversations {
+			participant := conversation.Participant(user.ID)
+			if participant == nil {
+				continue
+			}
+			digest.UnreadChats = append(digest.UnreadChats, DigestChat{
+				Name:  digestConversationName(conversation, user.ID),
+				Count: participant.UnreadCount,
+			})
+		}
+	}
+
+	if digest.UnreadNotificationCount == 0 && digest.UnreadChatCount == 0 {
+		return nil, nil
+	}
+
+	friendships, err := s.friendshipRepo.FindAcceptedByUserID(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get friends: %w", err)
+	}
+	friendIDs := make([]string, 0, len(friendships))
+	for _, f := range friendships {
+		if f.SenderID == user.ID {
+			friendIDs = append(friendIDs, f.ReceiverID)
+		} else {
+			friendIDs = append(friendIDs, f.SenderID)
+		}
+	}
+	posts, err := s.postRepo.FindTopByUserIDs(friendIDs, since, digestTopPostLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top posts: %w", err)
+	}
+	for _, post := range posts {
+		excerpt := ""
+		if post.Content != nil {
+			excerpt = truncateExcerpt(*post.Content, digestExcerptLength)
+		}
+		digest.TopPosts = append(digest.TopPosts, DigestPost{
+			AuthorName:    post.User.FullName,
+			Excerpt:       excerpt,
+			URL:           strings.TrimRight(s.cfg.ClientURL, "/") + "/posts/" + post.ID,
+			LikesCount:    post.LikesCount,
+			CommentsCount: post.CommentsCount,
+		})
+	}
+
+	return digest, nil
+}
+
+// queueDigest publishes the digest on the email queue, sending it directly when RabbitMQ is unavailable
+func (s *DigestService) queueDigest(to string, digest *DigestEmail) error {
+	if s.rabbitMQ != nil {
+		body, err := json.Marshal(digest)
+		if err != nil {
+			return err
+		}
+		err = s.rabbitMQ.PublishEmail(util.EmailMessage{
+			To:      to,
+			Subject: "Ringkasan aktivitas Anda",
+			Body:    string(body),
+			Type:    "digest",
+		})
+		if err == nil {
+			return nil
+		}
+		log.Printf("[EMAIL DIGEST] Failed to publish digest, sending directly: %v", err)
+	}
+	return s.emailService.SendDigestEmail(to, digest)
+}
+
+// truncateExcerpt shortens text to at most max runes on a single line
+func truncateExcerpt(text string, max int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	return string([]rune(text)[:max]) + "…"
+}
+
+// digestConversationName names a conversation in the digest: the group title, or the other
+// participant of a direct conversation
+func digestConversationName(conversation *model.Conversation, userID string) string {
+	if conversation.IsGroup() {
+		if conversation.Title != nil && *conversation.Title != "" {
+			return *conversation.Title
+		}
+		return "Grup"
+	}
+	for i := range conversation.Participants {
+		if conversation.Participants[i].UserID != userID {
+			return userDisplayName(&conversation.Participants[i].User)
+		}
+	}
+	return "Seseorang"
+}
@@ -1,8 +1,12 @@
 package service
 
 import (
+	"bytes"
 	"fmt"
+	"html"
+	"html/template"
 	"net/smtp"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,6 +19,8 @@ type EmailService interface {
 	SendResetPasswordEmail(to, resetLink string) error
 	SendVerificationEmail(to, token string) error
 	SendWelcomeEmail(to, name string) error
+	SendNotificationEmail(to, title, message string) error
+	SendDigestEmail(to string, digest *DigestEmail) error
 }
 
 type emailService struct {
@@ -35,6 +41,11 @@ func (s *emailService) sendEmail(to, subject, body string) error {
 
 // sendEmailHTML mengirim email multipart dengan versi HTML dan plain text.
 func (s *emailService) sendEmailHTML(to, subject, htmlBody, textBody string) error {
+	return s.sendEmailHTMLWithHeaders(to, subject, htmlBody, textBody, nil)
+}
+
+// sendEmailHTMLWithHeaders sama seperti sendEmailHTML dengan header tambahan (misalnya List-Unsubscribe).
+func (s *emailService) sendEmailHTMLWithHeaders(to, subject, htmlBody, textBody string, extraHeaders map[string]string) error {
 	if s.config.SMTPUsername == "" || s.config.SMTPPassword == "" {
 		// In development, just log the email
 		fmt.Printf("[EMAIL] To: %s, Subject: %s\nBody: %s\n", to, subject, textBody)
@@ -55,8 +66,16 @@ func (s *emailService) sendEmailHTML(to, subject, htmlBody, textBody string) err
 
 	// Create multipart message with HTML and plain text
 	boundary := "----=_NextPart_" + fmt.Sprintf("%d", time.Now().UnixNano())
-	headers := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/alternative; boundary=\"%s\"\r\n\r\n",
-		fromHeader, to, subject, boundary)
+	headers := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n", fromHeader, to, subject)
+	names := make([]string, 0, len(extraHeaders))
+	for name := range extraHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		headers += fmt.Sprintf("%s: %s\r\n", name, extraHeaders[name])
+	}
+	headers += fmt.Sprintf("MIME-Version: 1.0\r\nContent-Type: multipart/alternative; boundary=\"%s\"\r\n\r\n", boundary)
 
 	// Plain text part
 	textPart := fmt.Sprintf("--%s\r\nContent-Type: text/plain; charset=UTF-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n%s\r\n",
@@ -672,3 +691,227 @@ Tim %s
 
 	return s.sendEmailHTML(to, subject, htmlBody, textBody)
 }
+
+// SendNotificationEmail mengirim notifikasi aplikasi (misalnya permintaan pertemanan) ke email pengguna.
+func (s *emailService) SendNotificationEmail(to, title, message string) error {
+	subject := fmt.Sprintf("%s - %s", title, s.config.EmailName)
+	notificationsURL := strings.TrimRight(s.config.ClientURL, "/") + "/notifications"
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="id">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+</head>
+<body style="margin: 0; padding: 0; font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background-color: #f4f6f8;">
+    <table role="presentation" cellpadding="0" cellspacing="0" border="0" width="100%%" style="background-color: #f4f6f8; padding: 40px 20px;">
+        <tr>
+            <td align="center">
+                <table role="presentation" cellpadding="0" cellspacing="0" border="0" width="600" style="max-width: 600px; width: 100%%; background-color: #ffffff; border: 1px solid #e5e7eb; border-radius: 4px; box-shadow: 0 2px 4px rgba(0, 0, 0, 0.05);">
+                    <!-- Header -->
+                    <tr>
+                        <td style="background-color: #1e3a8a; padding: 30px 40px; border-bottom: 3px solid #1e40af;">
+                            <h1 style="margin: 0; color: #ffffff; font-size: 24px; font-weight: 600; letter-spacing: 0.5px;">%s</h1>
+                        </td>
+                    </tr>
+
+                    <!-- Content -->
+                    <tr>
+                        <td style="padding: 40px;">
+                            <p style="margin: 0 0 12px; color: #1f2937; font-size: 18px; line-height: 1.5; font-weight: 600;">
+                                %s
+                            </p>
+                            <p style="margin: 0 0 32px; color: #374151; font-size: 15px; line-height: 1.7;">
+                                %s
+                            </p>
+                            <a href="%s" style="display: inline-block; background-color: #1e3a8a; color: #ffffff; text-decoration: none; font-size: 14px; font-weight: 600; padding: 12px 24px; border-radius: 4px;">
+                                Lihat Notifikasi
+                            </a>
+                        </td>
+                    </tr>
+
+                    <!-- Footer -->
+                    <tr>
+                        <td style="padding: 24px 40px; background-color: #f8fafc; border-top: 1px solid #e5e7eb;">
+                            <p style="margin: 0; color: #9ca3af; font-size: 11px; line-height: 1.6;">
+                                Anda menerima email ini karena notifikasi email aktif untuk jenis notifikasi ini. Atur preferensi notifikasi Anda di halaman pengaturan.<br>
+                                © %d %s. Hak Cipta Dilindungi.
+                            </p>
+                        </td>
+                    </tr>
+                </table>
+            </td>
+        </tr>
+    </table>
+</body>
+</html>
+`, s.config.EmailName, html.EscapeString(title), html.EscapeString(message), notificationsURL, time.Now().Year(), s.config.EmailName)
+
+	textBody := fmt.Sprintf(`
+%s
+
+%s
+
+Lihat notifikasi: %s
+
+Atur preferensi notifikasi Anda di halaman pengaturan.
+
+Tim %s
+`, title, message, notificationsURL, s.config.EmailName)
+
+	return s.sendEmailHTML(to, subject, htmlBody, textBody)
+}
+
+// DigestEmail berisi ringkasan aktivitas yang belum dibaca untuk email digest.
+type DigestEmail struct {
+	Name                    string               `json:"name"`
+	Period                  string               `json:"period"`
+	Notifications           []DigestNotification `json:"notifications"`
+	UnreadNotificationCount int64                `json:"unread_notification_count"`
+	UnreadChats             []DigestChat         `json:"unread_chats"`
+	UnreadChatCount         int64                `json:"unread_chat_count"`
+	TopPosts                []DigestPost         `json:"top_posts"`
+	UnsubscribeURL          string               `json:"unsubscribe_url"`
+}
+
+type DigestNotification struct {
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type DigestChat struct {
+	Name  string `json:"name"` // Group title or the other participant of a direct conversation
+	Count int64  `json:"count"`
+}
+
+type DigestPost struct {
+	AuthorName    string `json:"author_name"`
+	Excerpt       string `json:"excerpt"`
+	URL           string `json:"url"`
+	LikesCount    int64  `json:"likes_count"`
+	CommentsCount int64  `json:"comments_count"`
+}
+
+var digestEmailTemplate = template.Must(template.New("digest").Parse(`
+<!DOCTYPE html>
+<html lang="id">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+</head>
+<body style="margin: 0; padding: 0; font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; background-color: #f4f6f8;">
+    <table role="presentation" cellpadding="0" cellspacing="0" border="0" width="100%" style="background-color: #f4f6f8; padding: 40px 20px;">
+        <tr>
+            <td align="center">
+                <table role="presentation" cellpadding="0" cellspacing="0" border="0" width="600" style="max-width: 600px; width: 100%; background-color: #ffffff; border: 1px solid #e5e7eb; border-radius: 4px; box-shadow: 0 2px 4px rgba(0, 0, 0, 0.05);">
+                    <!-- Header -->
+                    <tr>
+                        <td style="background-color: #1e3a8a; padding: 30px 40px; border-bottom: 3px solid #1e40af;">
+                            <h1 style="margin: 0; color: #ffffff; font-size: 24px; font-weight: 600; letter-spacing: 0.5px;">{{.AppName}}</h1>
+                        </td>
+                    </tr>
+
+                    <!-- Content -->
+                    <tr>
+                        <td style="padding: 40px;">
+                            <p style="margin: 0 0 24px; color: #1f2937; font-size: 18px; line-height: 1.5; font-weight: 600;">
+                                Halo {{.Name}}, ini ringkasan {{.Period}} Anda
+                            </p>
+                            {{if .Notifications}}
+                            <h2 style="margin: 0 0 12px; color: #1e3a8a; font-size: 16px;">{{.UnreadNotificationCount}} notifikasi belum dibaca</h2>
+                            {{range .Notifications}}
+                            <p style="margin: 0 0 12px; color: #374151; font-size: 14px; line-height: 1.6;">
+                                <strong>{{.Title}}</strong><br>{{.Message}}
+                            </p>
+                            {{end}}
+                            {{end}}
+                            {{if .UnreadChats}}
+                            <h2 style="margin: 24px 0 12px; color: #1e3a8a; font-size: 16px;">{{.UnreadChatCount}} pesan belum dibaca</h2>
+                            {{range .UnreadChats}}
+                            <p style="margin: 0 0 8px; color: #374151; font-size: 14px; line-height: 1.6;">{{.Name}}: {{.Count}} pesan</p>
+                            {{end}}
+                            {{end}}
+                            {{if .TopPosts}}
+                            <h2 style="margin: 24px 0 12px; color: #1e3a8a; font-size: 16px;">Post populer dari teman Anda</h2>
+                            {{range .TopPosts}}
+                            <p style="margin: 0 0 12px; color: #374151; font-size: 14px; line-height: 1.6;">
+                                <strong>{{.AuthorName}}</strong><br>{{.Excerpt}}<br>
+                                <span style="color: #6b7280; font-size: 12px;">{{.LikesCount}} suka · {{.CommentsCount}} komentar</span>
+                                · <a href="{{.URL}}" style="color: #1e40af; font-size: 12px;">Lihat post</a>
+                            </p>
+                            {{end}}
+                            {{end}}
+                            <a href="{{.NotificationsURL}}" style="display: inline-block; margin-top: 16px; background-color: #1e3a8a; color: #ffffff; text-decoration: none; font-size: 14px; font-weight: 600; padding: 12px 24px; border-radius: 4px;">
+                                Buka Aplikasi
+                            </a>
+                        </td>
+                    </tr>
+
+                    <!-- Footer -->
+                    <tr>
+                        <td style="padding: 24px 40px; background-color: #f8fafc; border-top: 1px solid #e5e7eb;">
+                            <p style="margin: 0; color: #9ca3af; font-size: 11px; line-height: 1.6;">
+                                Anda menerima email ini karena ringkasan aktivitas email aktif.
+                                <a href="{{.UnsubscribeURL}}" style="color: #6b7280;">Berhenti berlangganan</a><br>
+                                © {{.Year}} {{.AppName}}. Hak Cipta Dilindungi.
+                            </p>
+                        </td>
+                    </tr>
+                </table>
+            </td>
+        </tr>
+    </table>
+</body>
+</html>
+`))
+
+// SendDigestEmail mengirim ringkasan notifikasi, pesan, dan post populer yang belum dilihat pengguna,
+// lengkap dengan tautan berhenti berlangganan satu klik.
+func (s *emailService) SendDigestEmail(to string, digest *DigestEmail) error {
+	subject := fmt.Sprintf("Ringkasan aktivitas Anda - %s", s.config.EmailName)
+	notificationsURL := strings.TrimRight(s.config.ClientURL, "/") + "/notifications"
+
+	var htmlBody bytes.Buffer
+	err := digestEmailTemplate.Execute(&htmlBody, struct {
+		*DigestEmail
+		AppName          string
+		NotificationsURL string
+		Year             int
+	}{digest, s.config.EmailName, notificationsURL, time.Now().Year()})
+	if err != nil {
+		return fmt.Errorf("failed to render digest email: %w", err)
+	}
+
+	var textBody strings.Builder
+	fmt.Fprintf(&textBody, "Halo %s, ini ringkasan %s Anda\n\n", digest.Name, digest.Period)
+	if len(digest.Notifications) > 0 {
+		fmt.Fprintf(&textBody, "%d notifikasi belum dibaca:\n", digest.UnreadNotificationCount)
+		for _, n := range digest.Notifications {
+			fmt.Fprintf(&textBody, "- %s: %s\n", n.Title, n.Message)
+		}
+		textBody.WriteString("\n")
+	}
+	if len(digest.UnreadChats) > 0 {
+		fmt.Fprintf(&textBody, "%d pesan belum dibaca:\n", digest.UnreadChatCount)
+		for _, chat := range digest.UnreadChats {
+			fmt.Fprintf(&textBody, "- %s: %d pesan\n", chat.Name, chat.Count)
+		}
+		textBody.WriteString("\n")
+	}
+	if len(digest.TopPosts) > 0 {
+		textBody.WriteString("Post populer dari teman Anda:\n")
+		for _, post := range digest.TopPosts {
+			fmt.Fprintf(&textBody, "- %s: %s (%s)\n", post.AuthorName, post.Excerpt, post.URL)
+		}
+		textBody.WriteString("\n")
+	}
+	fmt.Fprintf(&textBody, "Buka aplikasi: %s\n\nBerhenti berlangganan: %s\n\nTim %s\n", notificationsURL, digest.UnsubscribeURL, s.config.EmailName)
+
+	headers := map[string]string{
+		"List-Unsubscribe":      "<" + digest.UnsubscribeURL + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+	return s.sendEmailHTMLWithHeaders(to, subject, htmlBody.String(), textBody.String(), headers)
+}
@@ -76,6 +76,16 @@ func (w *EmailWorker) processEmailMessage(msg amqp.Delivery) error {
 		return w.emailService.SendVerificationEmail(emailMsg.To, emailMsg.Body)
 	case "welcome":
 		return w.emailService.SendWelcomeEmail(emailMsg.To, emailMsg.Subject) // Using Subject as name
+	case "notification":
+		// Subject holds the notification title, Body its message
+		return w.emailService.SendNotificationEmail(emailMsg.To, emailMsg.Subject, emailMsg.Body)
+	case "digest":
+		// Body holds the JSON encoded DigestEmail
+		var digest DigestEmail
+		if err := json.Unmarshal([]byte(emailMsg.Body), &digest); err != nil {
+			return err
+		}
+		return w.emailService.SendDigestEmail(emailMsg.To, &digest)
 	default:
 		// Generic email
 		return w.emailService.SendOTPEmail(emailMsg.To, emailMsg.Body)
@@ -28,6 +28,7 @@ type GroupService interface {
 	UpdateMemberRole(adminID, groupID, targetUserID, role string) error
 	RemoveMember(adminID, groupID, targetUserID string) error
 	IsMember(groupID, userID string) (bool, error)
+	IsModerator(groupID, userID string) (bool, error)
 }
 
 type groupService struct {
@@ -348,3 +349,15 @@ func (s *groupService) RemoveMember(adminID, groupID, targetUserID string) error
 func (s *groupService) IsMember(groupID, userID string) (bool, error) {
 	return s.groupRepo.IsMember(groupID, userID)
 }
+
+// IsModerator checks if a user is an active admin or moderator of a group
+func (s *groupService) IsModerator(groupID, userID string) (bool, error) {
+	member, err := s.groupRepo.GetMember(groupID, userID)
+	if err != nil {
+		return false, err
+	}
+	if member.Status != "active" {
+		return false, nil
+	}
+	return member.Role == "admin" || member.Role == "moderator", nil
+}
@@ -12,10 +12,12 @@ type LikeService interface {
 	LikeComment(userID, commentID string, reaction string) (*model.Like, error)
 	UnlikePost(userID, postID string) error
 	UnlikeComment(userID, commentID string) error
-	GetLikesByTarget(targetType, targetID string, limit, offset int) ([]*model.Like, int64, error)
+	GetLikesByTarget(targetType, targetID, reaction string, limit, offset int) ([]*model.Like, int64, error)
 	GetLikeCount(targetType, targetID string) (int64, error)
 	GetLikeCountsBatch(targetType string, targetIDs []string) (map[string]int64, error)
 	GetUserLikedTargets(userID, targetType string, targetIDs []string) (map[string]bool, error)
+	GetReactionCountsBatch(targetType string, targetIDs []string) (map[string]map[string]int64, error)
+	GetUserReactions(userID, targetType string, targetIDs []string) (map[string]string, error)
 	CheckUserLiked(userID, targetType, targetID string) (bool, *model.Like, error)
 }
 
@@ -99,7 +101,8 @@ func (s *likeService) LikeComment(userID, commentID string, reaction string) (*m
 	}
 
 	// Validate comment exists
-	if _, err := s.commentRepo.FindByID(commentID); err != nil {
+	comment, err := s.commentRepo.FindByID(commentID)
+	if err != nil {
 		return nil, errors.New("comment not found")
 	}
 
@@ -120,6 +123,7 @@ func (s *likeService) LikeComment(userID, commentID string, reaction string) (*m
 			if err := s.likeRepo.Update(existing); err != nil {
 				return nil, errors.New("failed to update reaction")
 			}
+			s.commentRepo.InvalidateReactionCaches(comment)
 		}
 		return existing, nil
 	}
@@ -135,6 +139,7 @@ func (s *likeService) LikeComment(userID, commentID string, reaction string) (*m
 	if err := s.likeRepo.Create(like); err != nil {
 		return nil, errors.New("failed to like comment")
 	}
+	s.commentRepo.InvalidateReactionCaches(comment)
 
 	return like, nil
 }
@@ -171,15 +176,34 @@ func (s *likeService) UnlikeComment(userID, commentID string) error {
 		return errors.New("failed to unlike comment")
 	}
 
+	if comment, err := s.commentRepo.FindByID(commentID); err == nil {
+		s.commentRepo.InvalidateReactionCaches(comment)
+	}
+
 	return nil
 }
 
-// GetLikesByTarget gets likes for a target (post or comment)
-func (s *likeService) GetLikesByTarget(targetType, targetID string, limit, offset int) ([]*model.Like, int64, error) {
+// GetLikesByTarget gets likes for a target (post or comment), optionally only those with one reaction
+func (s *likeService) GetLikesByTarget(targetType, targetID, reaction string, limit, offset int) ([]*model.Like, int64, error) {
 	// Validate target type
 	if targetType != model.TargetTypePost && targetType != model.TargetTypeComment {
 		return nil, 0, errors.New("invalid target type")
 	}
+	if reaction != "" && !isValidReaction(reaction) {
+		return nil, 0, errors.New("invalid reaction type")
+	}
+
+	if reaction != "" {
+		likes, err := s.likeRepo.FindByTargetAndReaction(targetType, targetID, reaction, limit, offset)
+		if err != nil {
+			return nil, 0, errors.New("failed to get likes")
+		}
+		counts, err := s.likeRepo.CountReactionsByTargets(targetType, []string{targetID})
+		if err != nil {
+			return nil, 0, errors.New("failed to get like count")
+		}
+		return likes, counts[targetID][reaction], nil
+	}
 
 	// Get likes
 	likes, err := s.likeRepo.FindByTarget(targetType, targetID)
@@ -221,6 +245,16 @@ func (s *likeService) GetUserLikedTargets(userID, targetType string, targetIDs [
 	return s.likeRepo.FindUserLikedTargets(userID, targetType, targetIDs)
 }
 
+// GetReactionCountsBatch gets per-reaction counts for multiple targets
+func (s *likeService) GetReactionCountsBatch(targetType string, targetIDs []string) (map[string]map[string]int64, error) {
+	return s.likeRepo.CountReactionsByTargets(targetType, targetIDs)
+}
+
+// GetUserReactions returns the user's reaction on each target they reacted to
+func (s *likeService) GetUserReactions(userID, targetType string, targetIDs []string) (map[string]string, error) {
+	return s.likeRepo.FindUserReactions(userID, targetType, targetIDs)
+}
+
 // CheckUserLiked checks if user has liked a target
 func (s *likeService) CheckUserLiked(userID, targetType, targetID string) (bool, *model.Like, error) {
 	like, err := s.likeRepo.FindByUserAndTarget(userID, targetType, targetID)
@@ -232,16 +266,7 @@ func (s *likeService) CheckUserLiked(userID, targetType, targetID string) (bool,
 
 // isValidReaction validates reaction type
 func isValidReaction(reaction string) bool {
-	validReactions := []string{
-		model.ReactionLike,
-		model.ReactionLove,
-		model.ReactionHaha,
-		model.ReactionWow,
-		model.ReactionSad,
-		model.ReactionAngry,
-	}
-
-	for _, valid := range validReactions {
+	for _, valid := range model.Reactions {
 		if reaction == valid {
 			return true
 		}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"yourapp/internal/model"
+	"yourapp/internal/repository"
+	"yourapp/internal/util"
+
+	"github.com/google/uuid"
+)
+
+const (
+	mediaJobSubdir      = "media-jobs"
+	mediaJobInputFolder = util.LocalPrivatePrefix + "media-jobs" // Media store folder of job source files
+	mediaJobMaxAttempts = 5
+)
+
+type MediaJobService interface {
+	// EnqueueFiles persists in-memory files (multipart uploads) and queues them for processing
+	EnqueueFiles(post *model.Post, files []util.FileData) error
+	// EnqueueUploads takes over the files of consumed resumable uploads and queues them for processing
+	EnqueueUploads(post *model.Post, uploads []*model.MediaUpload) error
+	GetMediaStatus(postID, viewerID string) (*PostMediaStatus, error)
+}
+
+type mediaJobService struct {
+	jobRepo    repository.MediaJobRepository
+	postRepo   repository.PostRepository
+	mediaStore util.MediaStore
+}
+
+func NewMediaJobService(jobRepo repository.MediaJobRepository, postRepo repository.PostRepository, mediaStore util.MediaStore) MediaJobService {
+	return &mediaJobService{
+		jobRepo:    jobRepo,
+		postRepo:   postRepo,
+		mediaStore: mediaStore,
+	}
+}
+
+// PostMediaStatus summarizes media processing for a post
+type PostMediaStatus struct {
+	PostID  string            `json:"post_id"`
+	Status  string            `json:"status"`
+	Total   int               `json:"total"`
+	Done    int               `json:"done"`
+	Failed  int               `json:"failed"`
+	Pending int               `json:"pending"`
+	Jobs    []*model.MediaJob `json:"jobs,omitempty"` // Only returned to the post owner
+}
+
+func (s *mediaJobService) EnqueueFiles(post *model.Post, files []util.FileData) error {
+	dir, err := mediaJobDir()
+	if err != nil {
+		return err
+	}
+
+	jobs := make([]*model.MediaJob, 0, len(files))
+	for i, file := range files {
+		job := s.newJob(post, i, file.Filename)
+		tmpPath := filepath.Join(dir, job.ID+util.GetFileExt(file.Filename))
+		if err := os.WriteFile(tmpPath, file.Data, 0644); err != nil {
+			s.removeJobInputs(jobs)
+			return fmt.Errorf("error writing media file: %w", err)
+		}
+		err := s.storeJobInput(job, tmpPath)
+		os.Remove(tmpPath)
+		if err != nil {
+			s.removeJobInputs(jobs)
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return s.enqueue(post, jobs)
+}
+
+func (s *mediaJobService) EnqueueUploads(post *model.Post, uploads []*model.MediaUpload) error {
+	jobs := make([]*model.MediaJob, 0, len(uploads))
+	for i, upload := range uploads {
+		job := s.newJob(post, i, upload.Filename)
+		if err := s.storeJobInput(job, upload.TmpPath); err != nil {
+			s.removeJobInputs(jobs)
+			return err
+		}
+		os.Remove(upload.TmpPath)
+		jobs = append(jobs, job)
+	}
+
+	return s.enqueue(post, jobs)
+}
+
+func (s *mediaJobService) newJob(post *model.Post, position int, filename string) *model.MediaJob {
+	mediaType := model.MediaTypeImage
+	if util.IsVideoFile(filename) {
+		mediaType = model.MediaTypeVideo
+	}
+	id := uuid.New().String()
+	return &model.MediaJob{
+		ID:          id,
+		PostID:      post.ID,
+		UserID:      post.UserID,
+		MediaType:   mediaType,
+		Filename:    filename,
+		InputKey:    path.Join(mediaJobInputFolder, id+util.GetFileExt(filename)),
+		Position:    position,
+		Status:      model.MediaJobStatusPending,
+		MaxAttempts: mediaJobMaxAttempts,
+		NextRunAt:   time.Now(),
+	}
+}
+
+// storeJobInput puts the source file of job into the media store, where the worker of any
+// instance can fetch it
+func (s *mediaJobService) storeJobInput(job *model.MediaJob, filePath string) error {
+	if s.mediaStore == nil {
+		return errors.New("media processing is not available")
+	}
+	if _, err := s.mediaStore.Put(job.InputKey, filePath, util.PutOptions{
+		ContentType: util.DetectMimeType(job.Filename),
+	}); err != nil {
+		return fmt.Errorf("error storing media file: %w", err)
+	}
+	return nil
+}
+
+func (s *mediaJobService) enqueue(post *model.Post, jobs []*model.MediaJob) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	if _, err := s.postRepo.UpdateMediaStatus(post.ID, model.PostMediaStatusReady, model.PostMediaStatusProcessing); err != nil {
+		s.removeJobInputs(jobs)
+		return fmt.Errorf("failed to update post media status: %w", err)
+	}
+	post.MediaStatus = model.PostMediaStatusProcessing
+
+	if err := s.jobRepo.CreateBatch(jobs); err != nil {
+		s.removeJobInputs(jobs)
+		s.postRepo.UpdateMediaStatus(post.ID, model.PostMediaStatusProcessing, model.PostMediaStatusFailed)
+		post.MediaStatus = model.PostMediaStatusFailed
+		return fmt.Errorf("failed to create media jobs: %w", err)
+	}
+
+	return nil
+}
+
+// GetMediaStatus returns the media processing status of a post
+func (s *mediaJobService) GetMediaStatus(postID, viewerID string) (*PostMediaStatus, error) {
+	post, err := s.postRepo.FindByID(postID)
+	if err != nil {
+		return nil, errors.New("post not found")
+	}
+
+	jobs, err := s.jobRepo.FindByPostID(postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media jobs: %w", err)
+	}
+
+	status := &PostMediaStatus{
+		PostID: postID,
+		Status: post.MediaStatus,
+		Total:  len(jobs),
+	}
+	if status.Status == "" {
+		status.Status = model.PostMediaStatusReady
+	}
+	for _, job := range jobs {
+		switch job.Status {
+		case model.MediaJobStatusDone:
+			status.Done++
+		case model.MediaJobStatusFailed:
+			status.Failed++
+		default:
+			status.Pending++
+		}
+	}
+	if post.UserID == viewerID {
+		status.Jobs = jobs
+	}
+
+	return status, nil
+}
+
+// mediaJobDir returns the local scratch directory for source files of media jobs
+func mediaJobDir() (string, error) {
+	tmpDir, err := util.TmpDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(tmpDir, mediaJobSubdir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create media job directory: %w", err)
+	}
+	return dir, nil
+}
+
+func (s *mediaJobService) removeJobInputs(jobs []*model.MediaJob) {
+	for _, job := range jobs {
+		_ = s.mediaStore.Delete(job.InputKey)
+	}
+}
@@ -0,0 +1,371 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"yourapp/internal/model"
+	"yourapp/internal/repository"
+	"yourapp/internal/util"
+
+	"github.com/google/uuid"
+)
+
+const (
+	mediaWorkerPollInterval = 5 * time.Second
+	mediaWorkerBatchSize    = 5
+	mediaJobLease           = 2 * time.Minute  // A job whose lease is not renewed in time is assumed lost (crash/restart)
+	mediaJobLeaseRenewal    = 30 * time.Second // How often the worker renews the leases of the jobs it claimed
+	mediaJobBaseBackoff     = 30 * time.Second
+	mediaJobMaxBackoff      = 30 * time.Minute
+)
+
+// MediaWorker polls the media_jobs table, uploads queued files and finalizes posts
+// once every job of the post has finished (successfully or permanently failed).
+type MediaWorker struct {
+	jobRepo             repository.MediaJobRepository
+	postRepo            repository.PostRepository
+	postService         PostService
+	notificationService NotificationService
+	mediaStore          util.MediaStore
+	wsHub               interface {
+		BroadcastToAll(map[string]interface{})
+	}
+}
+
+func NewMediaWorker(
+	jobRepo repository.MediaJobRepository,
+	postRepo repository.PostRepository,
+	postService PostService,
+	notificationService NotificationService,
+	mediaStore util.MediaStore,
+	wsHub interface {
+		BroadcastToAll(map[string]interface{})
+	},
+) *MediaWorker {
+	return &MediaWorker{
+		jobRepo:             jobRepo,
+		postRepo:            postRepo,
+		postService:         postService,
+		notificationService: notificationService,
+		mediaStore:          mediaStore,
+		wsHub:               wsHub,
+	}
+}
+
+// Start starts polling for media jobs in the background
+func (w *MediaWorker) Start() {
+	log.Println("Media worker started, polling for jobs...")
+
+	go func() {
+		ticker := time.NewTicker(mediaWorkerPollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			w.poll()
+		}
+	}()
+}
+
+func (w *MediaWorker) poll() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[MEDIA WORKER] PANIC while polling: %v", r)
+		}
+	}()
+
+	if n, err := w.jobRepo.RequeueExpired(); err != nil {
+		log.Printf("[MEDIA WORKER] Failed to requeue stale jobs: %v", err)
+	} else if n > 0 {
+		log.Printf("[MEDIA WORKER] Requeued %d stale job(s)", n)
+	}
+
+	jobs, err := w.jobRepo.ClaimDue(mediaWorkerBatchSize, mediaJobLease)
+	if err != nil {
+		log.Printf("[MEDIA WORKER] Failed to claim jobs: %v", err)
+		return
+	}
+
+	stop := w.keepLeases(jobs)
+	for _, job := range jobs {
+		w.processJob(job)
+	}
+	stop()
+
+	// Finalize from the table rather than from this batch, so posts whose last job
+	// finished right before a restart are picked up as well
+	postIDs, err := w.jobRepo.FindPostsToFinalize(mediaWorkerBatchSize)
+	if err != nil {
+		log.Printf("[MEDIA WORKER] Failed to find posts to finalize: %v", err)
+		return
+	}
+	for _, postID := range postIDs {
+		w.finalizePost(postID)
+	}
+}
+
+// keepLeases renews the leases of a claimed batch in the background until the returned function
+// is called. Jobs waiting for their turn are renewed too; finished jobs are left alone.
+func (w *MediaWorker) keepLeases(jobs []*model.MediaJob) func() {
+	if len(jobs) == 0 {
+		return func() {}
+	}
+	ids := make([]string, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(mediaJobLeaseRenewal)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := w.jobRepo.RenewLeases(ids, mediaJobLease); err != nil {
+					log.Printf("[MEDIA WORKER] Failed to renew job leases: %v", err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (w *MediaWorker) processJob(job *model.MediaJob) {
+	log.Printf("[MEDIA WORKER] Processing %s job %s for post %s (attempt %d/%d)", job.MediaType, job.ID, job.PostID, job.Attempts, job.MaxAttempts)
+
+	// Work on a local copy; the source lives in the media store so any instance can take the job
+	dir, err := mediaJobDir()
+	if err != nil {
+		w.failJob(job, err.Error(), job.Attempts >= job.MaxAttempts)
+		return
+	}
+	filePath := filepath.Join(dir, job.ID+util.GetFileExt(job.Filename))
+	defer os.Remove(filePath)
+	if err := w.mediaStore.Get(job.InputKey, filePath); err != nil {
+		log.Printf("[MEDIA WORKER] Failed to fetch source of job %s: %v", job.ID, err)
+		w.failJob(job, "failed to fetch source file: "+err.Error(), job.Attempts >= job.MaxAttempts)
+		return
+	}
+
+	media, key, err := w.storeMedia(job, filePath)
+	if err != nil {
+		log.Printf("[MEDIA WORKER] Job %s failed: %v", job.ID, err)
+		w.failJob(job, err.Error(), job.Attempts >= job.MaxAttempts)
+		return
+	}
+
+	result, err := json.Marshal(media)
+	if err != nil {
+		log.Printf("[MEDIA WORKER] Failed to serialize result of job %s: %v", job.ID, err)
+		return
+	}
+	if err := w.jobRepo.Updates(job.ID, map[string]interface{}{
+		"status":     model.MediaJobStatusDone,
+		"result_url": media.URL,
+		"result_key": key,
+		"result":     string(result),
+		"last_error": nil,
+	}); err != nil {
+		log.Printf("[MEDIA WORKER] Failed to mark job %s done: %v", job.ID, err)
+		return
+	}
+	w.removeInput(job)
+}
+
+// removeInput deletes the source file of a job that will not run again
+func (w *MediaWorker) removeInput(job *model.MediaJob) {
+	if err := w.mediaStore.Delete(job.InputKey); err != nil {
+		log.Printf("[MEDIA WORKER] Failed to delete source of job %s: %v", job.ID, err)
+	}
+}
+
+// storeMedia puts the job's source file, fetched to filePath, into the media store and returns
+// the resulting media object together with the store key of its full-size file
+func (w *MediaWorker) storeMedia(job *model.MediaJob, filePath string) (*model.PostMedia, string, error) {
+	if job.MediaType == model.MediaTypeVideo {
+		key := util.NewMediaKey("posts/videos", job.Filename)
+		stored, err := w.mediaStore.Put(key, filePath, util.PutOptions{
+			ContentType: util.DetectMimeType(job.Filename),
+			Hints:       util.VideoDeliveryHints,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		return &model.PostMedia{Type: model.MediaTypeVideo, URL: stored.URL}, stored.Key, nil
+	}
+	return w.storeImage(filePath)
+}
+
+// storeImage runs the image pipeline (orientation, metadata stripping, variants,
+// blurhash) and uploads every variant under posts/images/<id>/
+func (w *MediaWorker) storeImage(filePath string) (*model.PostMedia, string, error) {
+	processed, err := util.ProcessImage(filePath)
+	if err != nil {
+		return nil, "", err
+	}
+	defer processed.Cleanup()
+
+	media := &model.PostMedia{
+		Type:     model.MediaTypeImage,
+		Width:    processed.Width,
+		Height:   processed.Height,
+		Blurhash: processed.Blurhash,
+		Variants: make(map[string]model.MediaVariant, len(processed.Variants)),
+	}
+
+	dir := path.Join("posts/images", uuid.New().String())
+	var storedKeys []string
+	var fullKey string
+	for _, v := range processed.Variants {
+		key := path.Join(dir, v.Name+util.GetFileExt(v.Path))
+		stored, err := w.mediaStore.Put(key, v.Path, util.PutOptions{
+			ContentType: util.DetectMimeType(v.Path),
+			Hints:       util.ImageVariantDeliveryHints,
+		})
+		if err != nil {
+			// Don't leave a partial set of variants behind; the retry uploads a fresh set
+			for _, k := range storedKeys {
+				_ = w.mediaStore.Delete(k)
+			}
+			return nil, "", err
+		}
+		storedKeys = append(storedKeys, stored.Key)
+
+		media.Variants[v.Name] = model.MediaVariant{URL: stored.URL, Width: v.Width, Height: v.Height}
+		if v.Name == util.ImageVariantFull {
+			media.URL = stored.URL
+			fullKey = stored.Key
+		}
+	}
+
+	return media, fullKey, nil
+}
+
+// failJob records a failure and either schedules a retry with exponential backoff or fails permanently
+func (w *MediaWorker) failJob(job *model.MediaJob, reason string, permanent bool) {
+	updates := map[string]interface{}{"last_error": reason}
+	if permanent {
+		updates["status"] = model.MediaJobStatusFailed
+	} else {
+		backoff := mediaJobBaseBackoff * time.Duration(1<<uint(job.Attempts-1))
+		if backoff > mediaJobMaxBackoff {
+			backoff = mediaJobMaxBackoff
+		}
+		updates["status"] = model.MediaJobStatusPending
+		updates["next_run_at"] = time.Now().Add(backoff)
+		log.Printf("[MEDIA WORKER] Job %s will be retried in %v", job.ID, backoff)
+	}
+
+	if err := w.jobRepo.Updates(job.ID, updates); err != nil {
+		log.Printf("[MEDIA WORKER] Failed to update job %s: %v", job.ID, err)
+		return
+	}
+	if permanent {
+		w.removeInput(job)
+	}
+}
+
+// finalizePost attaches processed media to the post once all of its jobs are terminal
+func (w *MediaWorker) finalizePost(postID string) {
+	jobs, err := w.jobRepo.FindByPostID(postID)
+	if err != nil {
+		log.Printf("[MEDIA WORKER] Failed to load jobs for post %s: %v", postID, err)
+		return
+	}
+
+	var imageURLs, videoURLs []string
+	var media []model.PostMedia
+	failed := 0
+	for _, job := range jobs {
+		switch job.Status {
+		case model.MediaJobStatusDone:
+			item, ok := jobMedia(job)
+			if !ok {
+				continue
+			}
+			media = append(media, item)
+			if item.Type == model.MediaTypeVideo {
+				videoURLs = append(videoURLs, item.URL)
+			} else {
+				imageURLs = append(imageURLs, item.URL)
+			}
+		case model.MediaJobStatusFailed:
+			failed++
+		default:
+			return // Still in progress
+		}
+	}
+
+	post, err := w.postRepo.FindByID(postID)
+	if err != nil {
+		log.Printf("[MEDIA WORKER] Failed to load post %s: %v", postID, err)
+		return
+	}
+
+	succeeded := len(imageURLs) + len(videoURLs)
+	if succeeded > 0 {
+		updateReq := UpdatePostRequest{Media: media}
+		if len(imageURLs) > 0 {
+			updateReq.ImageURLs = imageURLs
+		}
+		if len(videoURLs) > 0 {
+			updateReq.VideoURLs = videoURLs
+		}
+		if _, err := w.postService.UpdatePost(post.UserID, postID, updateReq); err != nil {
+			log.Printf("[MEDIA WORKER] Failed to update post %s with media URLs: %v", postID, err)
+			return
+		}
+	}
+
+	status := model.PostMediaStatusReady
+	if succeeded == 0 {
+		status = model.PostMediaStatusFailed
+	}
+	// Only the worker that wins the transition sends notifications
+	ok, err := w.postRepo.UpdateMediaStatus(postID, model.PostMediaStatusProcessing, status)
+	if err != nil {
+		log.Printf("[MEDIA WORKER] Failed to update media status of post %s: %v", postID, err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	log.Printf("[MEDIA WORKER] Post %s finalized: %d image(s), %d video(s), %d failed", postID, len(imageURLs), len(videoURLs), failed)
+
+	if w.notificationService != nil {
+		if succeeded > 0 {
+			if len(videoURLs) > 0 {
+				_ = w.notificationService.SendPostUploadCompletedNotification(post.UserID, postID, succeeded, "video")
+			} else {
+				_ = w.notificationService.SendPostUploadCompletedNotification(post.UserID, postID, succeeded)
+			}
+		}
+		if failed > 0 {
+			_ = w.notificationService.SendPostUploadFailedNotification(post.UserID, postID, failed, len(jobs))
+		}
+	}
+	if succeeded > 0 && w.wsHub != nil {
+		w.wsHub.BroadcastToAll(map[string]interface{}{
+			"type":    "new_post",
+			"post_id": postID,
+		})
+	}
+}
+
+// jobMedia returns the processed media object recorded by a finished job
+func jobMedia(job *model.MediaJob) (model.PostMedia, bool) {
+	if job.Result == nil {
+		return model.PostMedia{}, false
+	}
+	var item model.PostMedia
+	if err := json.Unmarshal([]byte(*job.Result), &item); err != nil || item.URL == "" {
+		return model.PostMedia{}, false
+	}
+	return item, true
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"yourapp/internal/model"
+	"yourapp/internal/repository"
+	"yourapp/internal/util"
+)
+
+type NotificationPreferenceService interface {
+	GetPreferences(userID string) (*NotificationPreferences, error)
+	UpdatePreferences(userID string, req *UpdateNotificationPreferencesRequest) (*NotificationPreferences, error)
+	// CheckUnsubscribeToken validates a signed unsubscribe token without changing anything
+	CheckUnsubscribeToken(token string) error
+	// UnsubscribeDigest turns the email digest off for the user of a signed unsubscribe token
+	UnsubscribeDigest(token string) error
+}
+
+// NotificationPreferences is the user's effective channel choice for every notification type
+type NotificationPreferences struct {
+	Preferences     []*model.NotificationPreference `json:"preferences"`
+	QuietHours      *model.NotificationSettings     `json:"quiet_hours"`
+	DigestFrequency string                          `json:"digest_frequency"` // off, daily, weekly
+}
+
+// UpdateNotificationPreferencesRequest changes the listed types, quiet hours and/or digest
+// frequency; omitted types and fields keep their current value
+type UpdateNotificationPreferencesRequest struct {
+	Preferences     []NotificationPreferenceInput `json:"preferences"`
+	QuietHours      *QuietHoursInput              `json:"quiet_hours"`
+	DigestFrequency *string                       `json:"digest_frequency"`
+}
+
+type NotificationPreferenceInput struct {
+	Type  string `json:"type" binding:"required"`
+	InApp *bool  `json:"in_app"`
+	Email *bool  `json:"email"`
+	Push  *bool  `json:"push"`
+	Off   bool   `json:"off"` // Disables every channel of the type
+}
+
+type QuietHoursInput struct {
+	Enabled  *bool   `json:"enabled"`
+	Start    *string `json:"start"` // HH:MM
+	End      *string `json:"end"`   // HH:MM
+	Timezone *string `json:"timezone"`
+}
+
+type notificationPreferenceService struct {
+	prefRepo          repository.NotificationPreferenceRepository
+	unsubscribeSecret string
+}
+
+func NewNotificationPreferenceService(prefRepo repository.NotificationPreferenceRepository, unsubscribeSecret string) NotificationPreferenceService {
+	return &notificationPreferenceService{
+		prefRepo:          prefRepo,
+		unsubscribeSecret: unsubscribeSecret,
+	}
+}
+
+// GetPreferences returns the channels of every notification type (defaults for unconfigured
+// types) together with the user's quiet hours
+func (s *notificationPreferenceService) GetPreferences(userID string) (*NotificationPreferences, error) {
+	stored, err := s.prefRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, errors.New("failed to get notification preferences")
+	}
+	byType := make(map[string]*model.NotificationPreference, len(stored))
+	for _, pref := range stored {
+		byType[pref.Type] = pref
+	}
+
+	prefs := make([]*model.NotificationPreference, 0, len(model.NotificationTypes))
+	for _, notifType := range model.NotificationTypes {
+		if pref, ok := byType[notifType]; ok {
+			prefs = append(prefs, pref)
+		} else {
+			prefs = append(prefs, model.DefaultNotificationChannels(userID, notifType))
+		}
+	}
+
+	settings, err := s.prefRepo.FindSettings(userID)
+	if err != nil {
+		return nil, errors.New("failed to get notification preferences")
+	}
+
+	return &NotificationPreferences{Preferences: prefs, QuietHours: settings, DigestFrequency: settings.DigestFrequency}, nil
+}
+
+// UpdatePreferences validates and stores the requested changes, then returns the new preferences
+func (s *notificationPreferenceService) UpdatePreferences(userID string, req *UpdateNotificationPreferencesRequest) (*NotificationPreferences, error) {
+	current, err := s.GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	byType := make(map[string]*model.NotificationPreference, len(current.Preferences))
+	for _, pref := range current.Preferences {
+		byType[pref.Type] = pref
+	}
+
+	updated := make([]*model.NotificationPreference, 0, len(req.Preferences))
+	for _, input := range req.Preferences {
+		pref, ok := byType[input.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown notification type: %s", input.Type)
+		}
+		if input.Off {
+			pref.InApp, pref.Email, pref.Push = false, false, false
+		} else {
+			if input.InApp != nil {
+				pref.InApp = *input.InApp
+			}
+			if input.Email != nil {
+				pref.Email = *input.Email
+			}
+			if input.Push != nil {
+				pref.Push = *input.Push
+			}
+		}
+		pref.UserID = userID
+		updated = append(updated, pref)
+	}
+
+	var settings *model.NotificationSettings
+	if req.QuietHours != nil {
+		settings = current.QuietHours
+		if err := applyQuietHours(settings, req.QuietHours); err != nil {
+			return nil, err
+		}
+	}
+	if req.DigestFrequency != nil {
+		if model.DigestInterval(*req.DigestFrequency) == 0 && *req.DigestFrequency != model.DigestFrequencyOff {
+			return nil, errors.New("digest frequency must be off, daily or weekly")
+		}
+		settings = current.QuietHours
+		settings.DigestFrequency = *req.DigestFrequency
+	}
+
+	if err := s.prefRepo.Upsert(updated); err != nil {
+		return nil, errors.New("failed to update notification preferences")
+	}
+	if settings != nil {
+		if err := s.prefRepo.UpsertSettings(settings); err != nil {
+			return nil, errors.New("failed to update notification settings")
+		}
+	}
+
+	return s.GetPreferences(userID)
+}
+
+// applyQuietHours copies the provided quiet hours fields onto settings after validating them
+func applyQuietHours(settings *model.NotificationSettings, input *QuietHoursInput) error {
+	if input.Start != nil {
+		if _, err := model.ParseClock(*input.Start); err != nil {
+			return errors.New("quiet hours start must be in HH:MM format")
+		}
+		settings.QuietHoursStart = *input.Start
+	}
+	if input.End != nil {
+		if _, err := model.ParseClock(*input.End); err != nil {
+			return errors.New("quiet hours end must be in HH:MM format")
+		}
+		settings.QuietHoursEnd = *input.End
+	}
+	if input.Timezone != nil {
+		if _, err := time.LoadLocation(*input.Timezone); err != nil || *input.Timezone == "" {
+			return errors.New("invalid timezone")
+		}
+		settings.Timezone = *input.Timezone
+	}
+	if input.Enabled != nil {
+		settings.QuietHoursEnabled = *input.Enabled
+	}
+	if settings.QuietHoursEnabled && settings.QuietHoursStart == settings.QuietHoursEnd {
+		return errors.New("quiet hours start and end must differ")
+	}
+	return nil
+}
+
+// CheckUnsubscribeToken validates the token of a digest unsubscribe link
+func (s *notificationPreferenceService) CheckUnsubscribeToken(token string) error {
+	_, err := util.VerifyUnsubscribeToken(s.unsubscribeSecret, token)
+	return err
+}
+
+// UnsubscribeDigest handles the one-click unsubscribe link of a digest email
+func (s *notificationPreferenceService) UnsubscribeDigest(token string) error {
+	userID, err := util.VerifyUnsubscribeToken(s.unsubscribeSecret, token)
+	if err != nil {
+		return err
+	}
+	if err := s.prefRepo.SetDigestFrequency(userID, model.DigestFrequencyOff); err != nil {
+		return errors.New("failed to unsubscribe from email digest")
+	}
+	return nil
+}
@@ -4,11 +4,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"time"
+	"unicode/utf8"
 
 	"yourapp/internal/model"
 	"yourapp/internal/repository"
 	"yourapp/internal/util"
+
+	"github.com/google/uuid"
 )
 
 type NotificationService interface {
@@ -18,11 +22,12 @@ type NotificationService interface {
 	SendFriendRemovedNotification(receiverID, senderID, senderName string) error
 	SendCommentReplyNotification(receiverID, senderID, senderName, commentID, postID string, commentContent string) error
 	SendPostCommentNotification(receiverID, senderID, senderName, commentID, postID string, commentContent string) error
+	SendThreadActivityNotification(receiverID, senderID, senderName, commentID, postID string, commentContent string) error
 	SendPostUploadCompletedNotification(userID, postID string, mediaCount int, mediaType ...string) error
+	SendPostUploadFailedNotification(userID, postID string, failedCount, totalCount int) error
 	SendPostLikedNotification(receiverID, senderID, senderName, postID string) error
 	SendRoleUpdatedNotification(receiverID, senderID, senderName, newRole string) error
 	SendRolePurchasedNotification(userID, roleName, roleLabel string, orderID string) error
-	CheckPostLikedNotificationExists(senderID, postID string) (bool, error)
 	GetNotificationsByUserID(userID string, limit, offset int) ([]*model.Notification, error)
 	GetUnreadNotifications(userID string) ([]*model.Notification, error)
 	GetUnreadCount(userID string) (int64, error)
@@ -30,39 +35,69 @@ type NotificationService interface {
 	MarkAllAsRead(userID string) error
 	DeleteNotification(notificationID, userID string) error
 	DeleteByTargetIDAndType(targetID, notifType string) error
+	// HandleNotificationMessage delivers a queued notification unless its dedupe key was delivered before
+	HandleNotificationMessage(msg *NotificationMessage) error
+	PruneNotificationDeliveries(olderThan time.Duration) (int64, error)
 	SetWSHub(hub interface {
 		BroadcastToUser(string, map[string]interface{})
 	})
+	// SetQueueEnabled routes notifications through RabbitMQ once a worker consumes the queue
+	SetQueueEnabled(enabled bool)
 }
 
 type notificationService struct {
-	notifRepo repository.NotificationRepository
-	rabbitMQ  *util.RabbitMQClient
-	wsHub     interface {
+	notifRepo    repository.NotificationRepository
+	prefRepo     repository.NotificationPreferenceRepository
+	userRepo     repository.UserRepository
+	rabbitMQ     *util.RabbitMQClient
+	emailService EmailService
+	webPush      WebPushService
+	wsHub        interface {
 		BroadcastToUser(string, map[string]interface{})
 	} // WebSocket hub interface
+	queueEnabled bool
 }
 
-// NotificationMessage represents the message structure for RabbitMQ
+// NotificationMessage is a notification queued on RabbitMQ for the NotificationWorker
 type NotificationMessage struct {
+	DedupeKey string                 `json:"dedupe_key"` // Unique per notification; handled keys are skipped
 	UserID    string                 `json:"user_id"`
 	Type      string                 `json:"type"`
 	Title     string                 `json:"title"`
 	Message   string                 `json:"message"`
 	Data      map[string]interface{} `json:"data,omitempty"`
+	Group     *NotificationGroup     `json:"group,omitempty"` // Set for notifications grouped per target
 	Timestamp time.Time              `json:"timestamp"`
+	Attempts  int                    `json:"attempts,omitempty"` // Failed deliveries so far
 }
 
-const (
-	NotificationQueueName = "notification_queue"
-	NotificationExchange  = "notification_exchange"
-)
+// NotificationGroup is one actor's activity that is grouped with earlier activity of the same
+// type on the same target while the recipient has not read the notification yet. The message
+// is rendered from the group when it is delivered (see groupedMessage).
+type NotificationGroup struct {
+	TargetID string                  `json:"target_id"`
+	Actor    model.NotificationActor `json:"actor"`
+	// Repeatable pushes the update even when the actor is already part of the group
+	// (another comment is news, liking again after an unlike is not)
+	Repeatable bool `json:"repeatable"`
+}
 
-func NewNotificationService(notifRepo repository.NotificationRepository, rabbitMQ *util.RabbitMQClient) NotificationService {
+func NewNotificationService(
+	notifRepo repository.NotificationRepository,
+	prefRepo repository.NotificationPreferenceRepository,
+	userRepo repository.UserRepository,
+	rabbitMQ *util.RabbitMQClient,
+	emailService EmailService,
+	webPush WebPushService,
+) NotificationService {
 	return &notificationService{
-		notifRepo: notifRepo,
-		rabbitMQ:  rabbitMQ,
-		wsHub:     nil, // Will be set via SetWSHub
+		notifRepo:    notifRepo,
+		prefRepo:     prefRepo,
+		userRepo:     userRepo,
+		rabbitMQ:     rabbitMQ,
+		emailService: emailService,
+		webPush:      webPush,
+		wsHub:        nil, // Will be set via SetWSHub
 	}
 }
 
@@ -73,11 +108,97 @@ func (s *notificationService) SetWSHub(hub interface {
 	s.wsHub = hub
 }
 
-// sendNotification sends notification via RabbitMQ and saves to DB
+// SetQueueEnabled makes notifications go through the notification queue instead of being
+// delivered by the caller
+func (s *notificationService) SetQueueEnabled(enabled bool) {
+	s.queueEnabled = enabled && s.rabbitMQ != nil
+}
+
+// sendNotification queues a notification for delivery
 func (s *notificationService) sendNotification(
 	userID, notifType, title, message string,
 	data map[string]interface{},
 ) error {
+	return s.dispatch(&NotificationMessage{
+		UserID:  userID,
+		Type:    notifType,
+		Title:   title,
+		Message: message,
+		Data:    data,
+	})
+}
+
+// dispatch publishes the notification to the notification queue. When the queue is disabled or
+// the broker does not confirm the message, the notification is delivered synchronously.
+func (s *notificationService) dispatch(msg *NotificationMessage) error {
+	msg.DedupeKey = uuid.NewString()
+	msg.Timestamp = time.Now()
+
+	if s.queueEnabled {
+		body, err := json.Marshal(msg)
+		if err == nil {
+			err = s.rabbitMQ.PublishNotification(msg.DedupeKey, body)
+		}
+		if err == nil {
+			return nil
+		}
+		log.Printf("[NOTIFICATION QUEUE] Failed to queue %s notification for user %s, delivering synchronously: %v", msg.Type, msg.UserID, err)
+	}
+	return s.deliver(msg)
+}
+
+// HandleNotificationMessage delivers a notification consumed from the queue, skipping messages
+// whose dedupe key was delivered before. The key is only recorded after delivery succeeded, so a
+// failure or crash never loses a notification; a crash in between may deliver it twice.
+func (s *notificationService) HandleNotificationMessage(msg *NotificationMessage) error {
+	delivered, err := s.notifRepo.IsDelivered(msg.DedupeKey)
+	if err != nil {
+		return fmt.Errorf("failed to check notification message: %w", err)
+	}
+	if delivered {
+		log.Printf("[NOTIFICATION QUEUE] Skipping duplicate notification message %s", msg.DedupeKey)
+		return nil
+	}
+
+	if err := s.deliver(msg); err != nil {
+		return err
+	}
+	// Failing here only risks a duplicate on redelivery; retrying the delivery would cause one
+	if err := s.notifRepo.MarkDelivered(msg.DedupeKey); err != nil {
+		log.Printf("[NOTIFICATION QUEUE] Failed to record notification message %s: %v", msg.DedupeKey, err)
+	}
+	return nil
+}
+
+// PruneNotificationDeliveries forgets dedupe keys of messages handled longer ago than olderThan
+func (s *notificationService) PruneNotificationDeliveries(olderThan time.Duration) (int64, error) {
+	return s.notifRepo.DeleteDeliveriesBefore(time.Now().Add(-olderThan))
+}
+
+func (s *notificationService) deliver(msg *NotificationMessage) error {
+	if msg.Group != nil {
+		return s.deliverGroupedNotification(msg)
+	}
+	return s.deliverNotification(msg.UserID, msg.Type, msg.Title, msg.Message, msg.Data)
+}
+
+// deliverNotification delivers a notification on the channels the user chose for its type:
+// in-app (saved to DB and pushed over WebSocket), email and/or web push
+func (s *notificationService) deliverNotification(
+	userID, notifType, title, message string,
+	data map[string]interface{},
+) error {
+	channels := s.channelsFor(userID, notifType)
+	if !channels.InApp {
+		if channels.Email {
+			go s.sendEmailNotification(userID, title, message)
+		}
+		if channels.Push {
+			go s.sendWebPush(&model.Notification{UserID: userID, Type: notifType, Title: title, Message: message})
+		}
+		return nil
+	}
+
 	// Create notification in database
 	notification := &model.Notification{
 		UserID:  userID,
@@ -97,8 +218,8 @@ func (s *notificationService) sendNotification(
 			if commentID, ok := data["comment_id"].(string); ok {
 				notification.TargetID = &commentID
 			}
-		} else if notifType == model.NotificationTypePostUploadCompleted || notifType == model.NotificationTypePostLiked {
-			// For post upload completed and post liked, use post_id as target_id
+		} else if notifType == model.NotificationTypePostUploadCompleted || notifType == model.NotificationTypePostUploadFailed || notifType == model.NotificationTypePostLiked {
+			// For post upload completed/failed and post liked, use post_id as target_id
 			if postID, ok := data["post_id"].(string); ok {
 				notification.TargetID = &postID
 			}
@@ -119,52 +240,155 @@ func (s *notificationService) sendNotification(
 		}
 	}
 
-	if err := s.notifRepo.Create(notification); err != nil {
+	// A friend request may have been accepted or rejected while its notification was queued
+	if friendshipID, ok := data["friendship_id"].(string); ok && notifType == model.NotificationTypeFriendRequest {
+		created, err := s.notifRepo.CreateForPendingFriendship(notification, friendshipID)
+		if err != nil {
+			return fmt.Errorf("failed to create notification: %w", err)
+		}
+		if !created {
+			log.Printf("[NOTIFICATION] Skipping friend request notification for answered friendship %s", friendshipID)
+			return nil
+		}
+	} else if err := s.notifRepo.Create(notification); err != nil {
 		return fmt.Errorf("failed to create notification: %w", err)
 	}
 
-	// Push directly to WebSocket (simplified, no RabbitMQ for now)
-	// TODO: Re-enable RabbitMQ for async processing later
+	s.pushNotification(notification, false)
+	if channels.Email {
+		go s.sendEmailNotification(userID, title, message)
+	}
+	if channels.Push {
+		go s.sendWebPush(notification)
+	}
+
+	return nil
+}
 
-	// Push to WebSocket if hub is available
-	// IMPORTANT: WebSocket only sends notification data, NOT friendship status
-	// Frontend must always fetch status from DB via API, never from WebSocket
-	if s.wsHub != nil {
-		// Prepare notification payload for WebSocket
-		// Format: direct notification object (not wrapped in payload)
-		// NOTE: This only contains notification info, NOT friendship status
-		// Frontend will trigger DB refresh when receiving friendship-related notifications
-		wsPayload := map[string]interface{}{
-			"id":         notification.ID,
-			"user_id":    notification.UserID,
-			"type":       notification.Type,
-			"title":      notification.Title,
-			"message":    notification.Message,
-			"is_read":    notification.IsRead,
-			"created_at": notification.CreatedAt.Format(time.RFC3339),
-		}
-		if notification.TargetID != nil {
-			wsPayload["target_id"] = *notification.TargetID
+// channelsFor resolves the channels of a notification type for the user. During the user's
+// quiet hours email and web push are skipped, not sent later; the in-app notification is
+// still stored and shows up in the digest while unread. Account notifications are exempt.
+func (s *notificationService) channelsFor(userID, notifType string) *model.NotificationPreference {
+	pref, err := s.prefRepo.FindByUserAndType(userID, notifType)
+	if err != nil {
+		log.Printf("Failed to load notification preferences for user %s: %v", userID, err)
+		return model.DefaultNotificationChannels(userID, notifType)
+	}
+	if (pref.Email || pref.Push) && !model.IsAccountNotification(notifType) {
+		settings, err := s.prefRepo.FindSettings(userID)
+		if err == nil && settings.InQuietHours(time.Now()) {
+			quiet := *pref
+			quiet.Email = false
+			quiet.Push = false
+			return &quiet
 		}
+	}
+	return pref
+}
+
+// sendEmailNotification queues the notification on the email queue, sending it directly
+// when RabbitMQ is unavailable
+func (s *notificationService) sendEmailNotification(userID, title, message string) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || user.Email == "" || !user.IsVerified {
+		return
+	}
 
-		// Add sender_id if available
-		if notification.SenderID != nil {
-			wsPayload["sender_id"] = *notification.SenderID
+	if s.rabbitMQ != nil {
+		emailMsg := util.EmailMessage{
+			To:      user.Email,
+			Subject: title,
+			Body:    message,
+			Type:    "notification",
+		}
+		err := s.rabbitMQ.PublishEmail(emailMsg)
+		if err == nil {
+			return
 		}
+		log.Printf("Failed to publish notification email, sending directly: %v", err)
+	}
 
-		// Add data if available
-		if notification.Data != "" {
-			var dataMap map[string]interface{}
-			if err := json.Unmarshal([]byte(notification.Data), &dataMap); err == nil {
-				wsPayload["data"] = dataMap
-			}
+	if s.emailService != nil {
+		if err := s.emailService.SendNotificationEmail(user.Email, title, message); err != nil {
+			log.Printf("Failed to send notification email to %s: %v", user.Email, err)
 		}
+	}
+}
 
-		// Broadcast to user via WebSocket
-		s.wsHub.BroadcastToUser(userID, wsPayload)
+// sendWebPush delivers the notification to the user's devices when no WebSocket client is
+// connected. The notification ID is the tag, so an updated grouped notification replaces
+// the earlier one on the device.
+func (s *notificationService) sendWebPush(notification *model.Notification) {
+	if s.webPush == nil {
+		return
 	}
+	message := &WebPushMessage{
+		Type:  notification.Type,
+		Title: notification.Title,
+		Body:  notification.Message,
+		Tag:   notification.ID,
+		URL:   "/notifications",
+		Data:  map[string]interface{}{},
+	}
+	if notification.ID != "" {
+		message.Data["notification_id"] = notification.ID
+	}
+	if notification.TargetID != nil {
+		message.Data["target_id"] = *notification.TargetID
+	}
+	if err := s.webPush.SendToOfflineUser(notification.UserID, message); err != nil {
+		log.Printf("[WEB PUSH] Failed to deliver notification to user %s: %v", notification.UserID, err)
+	}
+}
 
-	return nil
+// pushNotification sends a notification to the user's WebSocket connections.
+// updated marks a payload that replaces an earlier notification with the same ID.
+func (s *notificationService) pushNotification(notification *model.Notification, updated bool) {
+	// IMPORTANT: WebSocket only sends notification data, NOT friendship status
+	// Frontend must always fetch status from DB via API, never from WebSocket
+	if s.wsHub == nil {
+		return
+	}
+
+	// Prepare notification payload for WebSocket
+	// Format: direct notification object (not wrapped in payload)
+	// NOTE: This only contains notification info, NOT friendship status
+	// Frontend will trigger DB refresh when receiving friendship-related notifications
+	wsPayload := map[string]interface{}{
+		"id":         notification.ID,
+		"user_id":    notification.UserID,
+		"type":       notification.Type,
+		"title":      notification.Title,
+		"message":    notification.Message,
+		"is_read":    notification.IsRead,
+		"created_at": notification.CreatedAt.Format(time.RFC3339),
+	}
+	if updated {
+		wsPayload["updated"] = true
+	}
+	if notification.TargetID != nil {
+		wsPayload["target_id"] = *notification.TargetID
+	}
+	if len(notification.LatestActors) > 0 {
+		wsPayload["actor_count"] = notification.ActorCount
+		wsPayload["latest_actors"] = notification.LatestActors
+	}
+
+	// Add sender_id if available
+	if notification.SenderID != nil {
+		wsPayload["sender_id"] = *notification.SenderID
+	}
+
+	// Add data if available
+	if notification.Data != "" {
+		var dataMap map[string]interface{}
+		if err := json.Unmarshal([]byte(notification.Data), &dataMap); err == nil {
+			wsPayload["data"] = dataMap
+		}
+	}
+
+	// Broadcast to user via WebSocket
+	s.wsHub.BroadcastToUser(notification.UserID, wsPayload)
 }
 
 // SendFriendRequestNotification sends a friend request notification
@@ -255,10 +479,7 @@ func (s *notificationService) SendCommentReplyNotification(
 	receiverID, senderID, senderName, commentID, postID, commentContent string,
 ) error {
 	// Truncate comment content if too long
-	previewContent := commentContent
-	if len(previewContent) > 100 {
-		previewContent = previewContent[:100] + "..."
-	}
+	previewContent := commentPreview(commentContent)
 
 	title := "New Reply to Your Comment"
 	message := fmt.Sprintf("%s replied to your comment: %s", senderName, previewContent)
@@ -284,10 +505,7 @@ func (s *notificationService) SendPostCommentNotification(
 	receiverID, senderID, senderName, commentID, postID, commentContent string,
 ) error {
 	// Truncate comment content if too long
-	previewContent := commentContent
-	if len(previewContent) > 100 {
-		previewContent = previewContent[:100] + "..."
-	}
+	previewContent := commentPreview(commentContent)
 
 	title := "New Comment on Your Post"
 	message := fmt.Sprintf("%s commented on your post: %s", senderName, previewContent)
@@ -308,6 +526,123 @@ func (s *notificationService) SendPostCommentNotification(
 	)
 }
 
+// deliverGroupedNotification creates the notification for the (recipient, type, target) group or
+// updates its unread notification in place, then pushes it over WebSocket. Updates replace the
+// earlier payload on the client instead of adding a new notification. Only the activity that
+// starts a group is emailed.
+func (s *notificationService) deliverGroupedNotification(msg *NotificationMessage) error {
+	g := msg.Group
+	channels := s.channelsFor(msg.UserID, msg.Type)
+	if !channels.InApp {
+		message := groupedMessage(msg, []model.NotificationActor{g.Actor}, 1)
+		if channels.Email {
+			go s.sendEmailNotification(msg.UserID, msg.Title, message)
+		}
+		if channels.Push {
+			go s.sendWebPush(&model.Notification{UserID: msg.UserID, Type: msg.Type, Title: msg.Title, Message: message})
+		}
+		return nil
+	}
+
+	groupKey := model.NotificationGroupKey(msg.UserID, msg.Type, g.TargetID)
+	notification := &model.Notification{
+		UserID:   msg.UserID,
+		Type:     msg.Type,
+		TargetID: &g.TargetID,
+		GroupKey: &groupKey,
+		IsRead:   false,
+	}
+
+	result, err := s.notifRepo.Group(notification, g.Actor, func(n *model.Notification) {
+		data := make(map[string]interface{}, len(msg.Data)+2)
+		for k, v := range msg.Data {
+			data[k] = v
+		}
+		data["actor_count"] = n.ActorCount
+		data["latest_actors"] = n.LatestActors
+		if dataJSON, err := json.Marshal(data); err == nil {
+			n.Data = string(dataJSON)
+		}
+		n.Title = msg.Title
+		n.Message = groupedMessage(msg, n.LatestActors, n.ActorCount)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to group notification: %w", err)
+	}
+
+	if result.Created || result.NewActor || g.Repeatable {
+		s.pushNotification(result.Notification, !result.Created)
+		if channels.Push {
+			go s.sendWebPush(result.Notification)
+		}
+	}
+	if result.Created && channels.Email {
+		go s.sendEmailNotification(msg.UserID, result.Notification.Title, result.Notification.Message)
+	}
+	return nil
+}
+
+// groupedMessage renders the message of a grouped notification for its latest actors and the
+// total number of actors
+func groupedMessage(msg *NotificationMessage, latest []model.NotificationActor, actorCount int) string {
+	switch msg.Type {
+	case model.NotificationTypeThreadActivity:
+		content, _ := msg.Data["comment_content"].(string)
+		return threadActivityMessage(latest, actorCount, commentPreview(content))
+	case model.NotificationTypePostLiked:
+		return postLikedMessage(latest, actorCount)
+	default:
+		return msg.Message
+	}
+}
+
+// commentPreview truncates comment content for notification messages without splitting a character
+func commentPreview(content string) string {
+	if utf8.RuneCountInString(content) > 100 {
+		return string([]rune(content)[:100]) + "..."
+	}
+	return content
+}
+
+// SendThreadActivityNotification tells a subscriber about a new comment on a post they follow.
+// While the previous thread_activity notification for the post is unread, new comments are
+// grouped into it ("Ana and 4 others commented ...").
+func (s *notificationService) SendThreadActivityNotification(
+	receiverID, senderID, senderName, commentID, postID, commentContent string,
+) error {
+	return s.dispatch(&NotificationMessage{
+		UserID: receiverID,
+		Type:   model.NotificationTypeThreadActivity,
+		Title:  "New Activity on a Post You Follow",
+		Data: map[string]interface{}{
+			"sender_id":       senderID,
+			"sender_name":     senderName,
+			"comment_id":      commentID,
+			"post_id":         postID,
+			"comment_content": commentContent,
+		},
+		Group: &NotificationGroup{
+			TargetID:   postID,
+			Actor:      model.NotificationActor{ID: senderID, Name: senderName},
+			Repeatable: true,
+		},
+	})
+}
+
+// threadActivityMessage renders "Ana commented ...", "Ana and 1 other commented ..." or
+// "Ana and 4 others commented ..." for the latest actor and the total number of actors
+func threadActivityMessage(latest []model.NotificationActor, actorCount int, preview string) string {
+	name := latest[0].Name
+	switch others := actorCount - 1; {
+	case others <= 0:
+		return fmt.Sprintf("%s commented on a post you follow: %s", name, preview)
+	case others == 1:
+		return fmt.Sprintf("%s and 1 other commented on a post you follow", name)
+	default:
+		return fmt.Sprintf("%s and %d others commented on a post you follow", name, others)
+	}
+}
+
 // SendPostUploadCompletedNotification sends a post upload completed notification
 // mediaType is optional; defaults to "gambar" (image). Pass "video" for video uploads.
 func (s *notificationService) SendPostUploadCompletedNotification(userID, postID string, mediaCount int, mediaType ...string) error {
@@ -332,61 +667,55 @@ func (s *notificationService) SendPostUploadCompletedNotification(userID, postID
 	)
 }
 
-// CheckPostLikedNotificationExists checks if a post liked notification already exists for this sender and post
-func (s *notificationService) CheckPostLikedNotificationExists(senderID, postID string) (bool, error) {
-	// Check if notification with type "post_liked" exists for this post and sender
-	// We'll check by looking for notifications with target_id = postID and sender_id = senderID
-	notifications, err := s.notifRepo.FindByUserID(senderID, 100, 0) // Get recent notifications
-	if err != nil {
-		return false, err
+// SendPostUploadFailedNotification tells the author that some or all media of a post could not be processed
+func (s *notificationService) SendPostUploadFailedNotification(userID, postID string, failedCount, totalCount int) error {
+	title := "Upload Gagal"
+	message := "Media post gagal diupload. Silakan coba lagi."
+	if failedCount < totalCount {
+		message = fmt.Sprintf("%d dari %d media gagal diupload", failedCount, totalCount)
 	}
-
-	// Check if any notification matches: type = post_liked, target_id = postID, sender_id = senderID
-	for _, notif := range notifications {
-		if notif.Type == model.NotificationTypePostLiked &&
-			notif.TargetID != nil && *notif.TargetID == postID &&
-			notif.SenderID != nil && *notif.SenderID == senderID {
-			return true, nil
-		}
-	}
-
-	return false, nil
-}
-
-// SendPostLikedNotification sends a post liked notification (only once per user per post)
-func (s *notificationService) SendPostLikedNotification(receiverID, senderID, senderName, postID string) error {
-	// Check if notification already exists by querying receiver's notifications
-	// Get recent notifications for the receiver
-	notifications, err := s.notifRepo.FindByUserID(receiverID, 1000, 0)
-	if err == nil {
-		// Check if any notification matches: type = post_liked, target_id = postID, sender_id = senderID
-		for _, notif := range notifications {
-			if notif.Type == model.NotificationTypePostLiked &&
-				notif.TargetID != nil && *notif.TargetID == postID &&
-				notif.SenderID != nil && *notif.SenderID == senderID {
-				// Notification already exists, don't send again
-				return nil
-			}
-		}
-	}
-
-	title := "Post Disukai"
-	message := fmt.Sprintf("%s menyukai post Anda", senderName)
 	data := map[string]interface{}{
-		"post_id":     postID,
-		"sender_id":   senderID,
-		"sender_name": senderName,
+		"post_id":      postID,
+		"failed_count": failedCount,
+		"total_count":  totalCount,
 	}
 
 	return s.sendNotification(
-		receiverID,
-		model.NotificationTypePostLiked,
+		userID,
+		model.NotificationTypePostUploadFailed,
 		title,
 		message,
 		data,
 	)
 }
 
+// SendPostLikedNotification tells the author that their post was liked. Likes are grouped per
+// post while the notification is unread ("Ana dan 4 lainnya menyukai post Anda").
+func (s *notificationService) SendPostLikedNotification(receiverID, senderID, senderName, postID string) error {
+	return s.dispatch(&NotificationMessage{
+		UserID: receiverID,
+		Type:   model.NotificationTypePostLiked,
+		Title:  "Post Disukai",
+		Data: map[string]interface{}{
+			"post_id":     postID,
+			"sender_id":   senderID,
+			"sender_name": senderName,
+		},
+		Group: &NotificationGroup{
+			TargetID: postID,
+			Actor:    model.NotificationActor{ID: senderID, Name: senderName},
+		},
+	})
+}
+
+// postLikedMessage renders "Ana menyukai post Anda" or "Ana dan 4 lainnya menyukai post Anda"
+func postLikedMessage(latest []model.NotificationActor, actorCount int) string {
+	if actorCount <= 1 {
+		return fmt.Sprintf("%s menyukai post Anda", latest[0].Name)
+	}
+	return fmt.Sprintf("%s dan %d lainnya menyukai post Anda", latest[0].Name, actorCount-1)
+}
+
 // SendRolePurchasedNotification sends a notification when user successfully purchases/upgrades role
 func (s *notificationService) SendRolePurchasedNotification(userID, roleName, roleLabel, orderID string) error {
 	title := "Role Berhasil Dibeli"
@@ -3,159 +3,178 @@ package service
 import (
 	"encoding/json"
 	"log"
+	"sync"
+	"time"
 
 	"yourapp/internal/util"
-	"yourapp/internal/websocket"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-// NotificationWorker consumes notification messages from RabbitMQ and pushes to WebSocket
+const (
+	notificationWorkerReconnectDelay = 5 * time.Second
+	notificationDeliveryRetention    = 7 * 24 * time.Hour // Longer than a message can wait in the queue
+	notificationDeliveryPruneEvery   = 1 * time.Hour
+	notificationMaxAttempts          = 3
+)
+
+// NotificationWorker consumes notification messages from RabbitMQ and delivers them (in-app,
+// WebSocket, email and web push) with a fixed number of concurrent consumers. A message that
+// fails is republished with its attempt count until notificationMaxAttempts; a message that
+// keeps failing, or cannot be decoded, is dead-lettered.
 type NotificationWorker struct {
 	notificationService NotificationService
-	rabbitMQ           *util.RabbitMQClient
-	wsHub              *websocket.Hub
-	stopChan           chan bool
+	rabbitMQ            *util.RabbitMQClient
+	concurrency         int
+	stopChan            chan struct{}
 }
 
 // NewNotificationWorker creates a new notification worker
 func NewNotificationWorker(
 	notificationService NotificationService,
 	rabbitMQ *util.RabbitMQClient,
-	wsHub *websocket.Hub,
+	concurrency int,
 ) *NotificationWorker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
 	return &NotificationWorker{
 		notificationService: notificationService,
 		rabbitMQ:            rabbitMQ,
-		wsHub:               wsHub,
-		stopChan:            make(chan bool),
+		concurrency:         concurrency,
+		stopChan:            make(chan struct{}),
 	}
 }
 
-// Start starts consuming notification messages from RabbitMQ
+// Start starts consuming notification messages from RabbitMQ. The worker reconnects by itself
+// when the broker connection is lost later on.
 func (w *NotificationWorker) Start() error {
 	if w.rabbitMQ == nil {
 		return nil // RabbitMQ not available, worker will not start
 	}
 
-	channel := w.rabbitMQ.GetChannel()
-	if channel == nil {
-		return nil
-	}
-
-	// Ensure notification exchange and queue exist
-	// This is already done in notification service, but we ensure it here too
-	notificationExchange := "notification_exchange"
-	notificationQueue := "notification_queue"
-
-	// Declare exchange
-	if err := channel.ExchangeDeclare(
-		notificationExchange,
-		"direct",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	); err != nil {
-		return err
-	}
-
-	// Declare queue
-	queue, err := channel.QueueDeclare(
-		notificationQueue,
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+	channel, deliveries, err := w.rabbitMQ.ConsumeNotifications(w.concurrency)
 	if err != nil {
 		return err
 	}
 
-	// Bind queue to exchange
-	if err := channel.QueueBind(
-		notificationQueue,
-		"notification",
-		notificationExchange,
-		false,
-		nil,
-	); err != nil {
-		return err
-	}
+	log.Printf("Notification worker started with %d consumers", w.concurrency)
+	go w.run(channel, deliveries)
+	go w.pruneDeliveries()
+	return nil
+}
 
-	// Consume messages
-	msgs, err := channel.Consume(
-		queue.Name,
-		"notification_worker",
-		false, // auto-ack
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		return err
-	}
+// run consumes until the worker is stopped, reopening the consumer whenever its channel closes
+func (w *NotificationWorker) run(channel *amqp.Channel, deliveries <-chan amqp.Delivery) {
+	for {
+		w.consume(deliveries)
+		channel.Close()
 
-	// Start consuming in a goroutine
-	go func() {
-		log.Println("Notification worker started, consuming messages...")
 		for {
 			select {
 			case <-w.stopChan:
 				log.Println("Notification worker stopped")
 				return
-			case msg, ok := <-msgs:
-				if !ok {
-					log.Println("Notification queue closed")
+			case <-time.After(notificationWorkerReconnectDelay):
+			}
+
+			var err error
+			channel, deliveries, err = w.rabbitMQ.ConsumeNotifications(w.concurrency)
+			if err == nil {
+				log.Println("[NOTIFICATION WORKER] Consumer reconnected")
+				break
+			}
+			log.Printf("[NOTIFICATION WORKER] Failed to reconnect consumer: %v", err)
+		}
+	}
+}
+
+// consume handles deliveries with w.concurrency goroutines until the deliveries channel closes
+// or the worker is stopped. Unacknowledged deliveries are requeued by the broker.
+func (w *NotificationWorker) consume(deliveries <-chan amqp.Delivery) {
+	var wg sync.WaitGroup
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-w.stopChan:
 					return
-				}
-				if err := w.processNotificationMessage(msg); err != nil {
-					log.Printf("Error processing notification message: %v", err)
-					// Don't ack on error, let RabbitMQ requeue
-					msg.Nack(false, true)
-				} else {
-					msg.Ack(false)
+				case msg, ok := <-deliveries:
+					if !ok {
+						log.Println("[NOTIFICATION WORKER] Notification queue closed")
+						return
+					}
+					w.handle(msg)
 				}
 			}
+		}()
+	}
+	wg.Wait()
+}
+
+// handle delivers one message and acknowledges, requeues or dead-letters it
+func (w *NotificationWorker) handle(msg amqp.Delivery) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("[NOTIFICATION WORKER] PANIC while handling message %s: %v", msg.MessageId, rec)
+			msg.Nack(false, false)
 		}
 	}()
 
-	return nil
-}
-
-// processNotificationMessage processes a notification message from RabbitMQ
-func (w *NotificationWorker) processNotificationMessage(msg amqp.Delivery) error {
 	var notificationMsg NotificationMessage
-	if err := json.Unmarshal(msg.Body, &notificationMsg); err != nil {
-		return err
+	if err := json.Unmarshal(msg.Body, &notificationMsg); err != nil || notificationMsg.DedupeKey == "" {
+		log.Printf("[NOTIFICATION WORKER] Dead-lettering malformed message %s: %v", msg.MessageId, err)
+		msg.Nack(false, false)
+		return
 	}
 
-	// Push to WebSocket hub for realtime delivery
-	// BroadcastToUser already wraps as {type:"notification", payload:...}
-	// so we pass the notification data directly — no extra wrapping
-	if w.wsHub != nil {
-		payload := map[string]interface{}{
-			"type":    notificationMsg.Type,
-			"user_id": notificationMsg.UserID,
+	if err := w.notificationService.HandleNotificationMessage(&notificationMsg); err != nil {
+		notificationMsg.Attempts++
+		log.Printf("[NOTIFICATION WORKER] Failed to deliver %s notification for user %s (attempt %d/%d): %v",
+			notificationMsg.Type, notificationMsg.UserID, notificationMsg.Attempts, notificationMaxAttempts, err)
+		if notificationMsg.Attempts >= notificationMaxAttempts {
+			msg.Nack(false, false) // Dead-letter
+			return
 		}
-		// Copy all fields from notificationMsg into payload
-		msgBytes, err := json.Marshal(notificationMsg)
-		if err == nil {
-			var msgMap map[string]interface{}
-			if json.Unmarshal(msgBytes, &msgMap) == nil {
-				for k, v := range msgMap {
-					payload[k] = v
-				}
+		w.retry(msg, &notificationMsg)
+		return
+	}
+
+	msg.Ack(false)
+}
+
+// retry republishes a failed message with its attempt count and acknowledges the original.
+// Redelivery flags cannot count attempts, because a message is also redelivered when a
+// consumer disconnects before acknowledging it.
+func (w *NotificationWorker) retry(msg amqp.Delivery, notificationMsg *NotificationMessage) {
+	body, err := json.Marshal(notificationMsg)
+	if err == nil {
+		err = w.rabbitMQ.PublishNotification(notificationMsg.DedupeKey, body)
+	}
+	if err != nil {
+		log.Printf("[NOTIFICATION WORKER] Failed to republish message %s, requeueing: %v", msg.MessageId, err)
+		msg.Nack(false, true)
+		return
+	}
+	msg.Ack(false)
+}
+
+// pruneDeliveries periodically forgets the dedupe keys of old messages
+func (w *NotificationWorker) pruneDeliveries() {
+	ticker := time.NewTicker(notificationDeliveryPruneEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			if _, err := w.notificationService.PruneNotificationDeliveries(notificationDeliveryRetention); err != nil {
+				log.Printf("[NOTIFICATION WORKER] Failed to prune notification deliveries: %v", err)
 			}
 		}
-		w.wsHub.BroadcastToUser(notificationMsg.UserID, payload)
-		log.Printf("Notification pushed to WebSocket for user: %s, type: %s", notificationMsg.UserID, notificationMsg.Type)
 	}
-
-	return nil
 }
 
 // Stop stops the notification worker
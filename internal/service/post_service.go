@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 
 	"yourapp/internal/model"
 	"yourapp/internal/repository"
@@ -20,12 +21,21 @@ type PostService interface {
 	DeletePost(userID string, postID string) error
 	CountPostsByUserID(userID string) (int64, error)
 	CountPostsByGroupID(groupID string) (int64, error)
+
+	// Pinning
+	GetProfilePins(userID string) ([]*model.Post, error)
+	PinPostToProfile(userID, postID string) ([]*model.Post, error)
+	UnpinPostFromProfile(userID, postID string) ([]*model.Post, error)
+	ReorderProfilePins(userID string, postIDs []string) ([]*model.Post, error)
+	SetGroupPin(userID, postID string, pinned bool) (*model.Post, error)
 }
 
 type postService struct {
-	postRepo       repository.PostRepository
-	userRepo       repository.UserRepository
-	friendshipRepo repository.FriendshipRepository
+	postRepo         repository.PostRepository
+	userRepo         repository.UserRepository
+	friendshipRepo   repository.FriendshipRepository
+	subscriptionRepo repository.PostSubscriptionRepository
+	groupService     GroupService
 }
 
 type CreatePostRequest struct {
@@ -34,7 +44,6 @@ type CreatePostRequest struct {
 	VideoURLs    []string               `json:"video_urls,omitempty"` // Array of video URLs
 	SharedPostID *string                `json:"shared_post_id,omitempty"`
 	GroupID      *string                `json:"group_id,omitempty"`
-	IsPinned     *bool                  `json:"is_pinned,omitempty"`
 	Tags         []string               `json:"tags,omitempty"` // Array of user IDs to tag
 	Location     *CreateLocationRequest `json:"location,omitempty"`
 }
@@ -46,21 +55,29 @@ type CreateLocationRequest struct {
 }
 
 type UpdatePostRequest struct {
-	Content   *string  `json:"content,omitempty"`
-	ImageURLs []string `json:"image_urls,omitempty"` // Array of image URLs
-	VideoURLs []string `json:"video_urls,omitempty"` // Array of video URLs
-	IsPinned  *bool    `json:"is_pinned,omitempty"`
+	Content   *string           `json:"content,omitempty"`
+	ImageURLs []string          `json:"image_urls,omitempty"` // Array of image URLs
+	VideoURLs []string          `json:"video_urls,omitempty"` // Array of video URLs
+	Media     []model.PostMedia `json:"-"`                    // Processed media, set by the media worker only
+}
+
+type ReorderProfilePinsRequest struct {
+	PostIDs []string `json:"post_ids"`
 }
 
 func NewPostService(
 	postRepo repository.PostRepository,
 	userRepo repository.UserRepository,
 	friendshipRepo repository.FriendshipRepository,
+	subscriptionRepo repository.PostSubscriptionRepository,
+	groupService GroupService,
 ) PostService {
 	return &postService{
-		postRepo:       postRepo,
-		userRepo:       userRepo,
-		friendshipRepo: friendshipRepo,
+		postRepo:         postRepo,
+		userRepo:         userRepo,
+		friendshipRepo:   friendshipRepo,
+		subscriptionRepo: subscriptionRepo,
+		groupService:     groupService,
 	}
 }
 
@@ -114,9 +131,8 @@ func (s *postService) CreatePost(userID string, req CreatePostRequest) (*model.P
 		GroupID:      req.GroupID,
 		IsPinned:     false,
 	}
-
-	if req.IsPinned != nil {
-		post.IsPinned = *req.IsPinned
+	if err := post.SetMedia(syncPostMedia(nil, req.ImageURLs, req.VideoURLs)); err != nil {
+		return nil, fmt.Errorf("failed to serialize media: %w", err)
 	}
 
 	// Validate: must have either content, image URLs, or video URLs
@@ -128,6 +144,11 @@ func (s *postService) CreatePost(userID string, req CreatePostRequest) (*model.P
 		return nil, fmt.Errorf("failed to create post: %w", err)
 	}
 
+	// Authors follow their own posts' threads
+	if err := s.subscriptionRepo.Ensure(post.ID, userID, model.PostSubscriptionReasonAuthor); err != nil {
+		log.Printf("Failed to subscribe author to post %s: %v", post.ID, err)
+	}
+
 	// Reload with relationships
 	return s.postRepo.FindByID(post.ID)
 }
@@ -235,10 +256,15 @@ func (s *postService) UpdatePost(userID string, postID string, req UpdatePostReq
 			post.VideoURLs = "[]"
 		}
 	}
-	if req.IsPinned != nil {
-		post.IsPinned = *req.IsPinned
+	if req.Media != nil {
+		if err := post.SetMedia(req.Media); err != nil {
+			return nil, fmt.Errorf("failed to serialize media: %w", err)
+		}
+	} else if req.ImageURLs != nil || req.VideoURLs != nil {
+		if err := post.SetMedia(syncPostMedia(post.GetMedia(), post.GetImageURLs(), post.GetVideoURLs())); err != nil {
+			return nil, fmt.Errorf("failed to serialize media: %w", err)
+		}
 	}
-
 	// Ensure JSONB fields are always valid JSON before saving.
 	// FindByID may return a cached post where these fields are empty strings
 	// (due to MarshalJSON/Unmarshal type mismatch in cache layer).
@@ -248,6 +274,9 @@ func (s *postService) UpdatePost(userID string, postID string, req UpdatePostReq
 	if post.VideoURLs == "" {
 		post.VideoURLs = "[]"
 	}
+	if post.Media == "" {
+		post.Media = "[]"
+	}
 
 	if err := s.postRepo.Update(post); err != nil {
 		return nil, fmt.Errorf("failed to update post: %w", err)
@@ -256,6 +285,32 @@ func (s *postService) UpdatePost(userID string, postID string, req UpdatePostReq
 	return s.postRepo.FindByID(post.ID)
 }
 
+// syncPostMedia rebuilds the media list after image/video URLs were set directly,
+// keeping processed metadata (size, blurhash, variants) for URLs that are still present
+func syncPostMedia(existing []model.PostMedia, imageURLs, videoURLs []string) []model.PostMedia {
+	byURL := make(map[string]model.PostMedia, len(existing))
+	for _, m := range existing {
+		byURL[m.URL] = m
+	}
+
+	media := make([]model.PostMedia, 0, len(imageURLs)+len(videoURLs))
+	for _, url := range imageURLs {
+		if m, ok := byURL[url]; ok && m.Type == model.MediaTypeImage {
+			media = append(media, m)
+		} else {
+			media = append(media, model.PostMedia{Type: model.MediaTypeImage, URL: url})
+		}
+	}
+	for _, url := range videoURLs {
+		if m, ok := byURL[url]; ok && m.Type == model.MediaTypeVideo {
+			media = append(media, m)
+		} else {
+			media = append(media, model.PostMedia{Type: model.MediaTypeVideo, URL: url})
+		}
+	}
+	return media
+}
+
 // DeletePost deletes a post (owner or admin can delete)
 func (s *postService) DeletePost(userID string, postID string) error {
 	// Get existing post
@@ -294,3 +349,140 @@ func (s *postService) CountPostsByUserID(userID string) (int64, error) {
 func (s *postService) CountPostsByGroupID(groupID string) (int64, error) {
 	return s.postRepo.CountByGroupID(groupID)
 }
+
+// GetProfilePins returns the posts pinned on a user's profile, in pin order
+func (s *postService) GetProfilePins(userID string) ([]*model.Post, error) {
+	return s.postRepo.FindProfilePinned(userID)
+}
+
+// PinPostToProfile pins one of the user's own posts to the top of their profile
+func (s *postService) PinPostToProfile(userID, postID string) ([]*model.Post, error) {
+	post, err := s.postRepo.FindByID(postID)
+	if err != nil {
+		return nil, errors.New("post not found")
+	}
+	if post.UserID != userID {
+		return nil, errors.New("unauthorized: you can only pin your own posts")
+	}
+	if post.GroupID != nil {
+		return nil, errors.New("group posts cannot be pinned to your profile")
+	}
+
+	pinned, err := s.postRepo.FindProfilePinned(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pinned posts: %w", err)
+	}
+
+	ids := make([]string, 0, len(pinned)+1)
+	for _, p := range pinned {
+		if p.ID == postID {
+			return pinned, nil // Already pinned
+		}
+		ids = append(ids, p.ID)
+	}
+	if len(ids) >= model.MaxProfilePinnedPosts {
+		return nil, fmt.Errorf("you can pin at most %d posts to your profile", model.MaxProfilePinnedPosts)
+	}
+
+	// Newly pinned post goes first
+	if err := s.setProfilePins(userID, pinned, append([]string{postID}, ids...)); err != nil {
+		return nil, fmt.Errorf("failed to pin post: %w", err)
+	}
+
+	return s.postRepo.FindProfilePinned(userID)
+}
+
+// UnpinPostFromProfile removes a post from the user's profile pins
+func (s *postService) UnpinPostFromProfile(userID, postID string) ([]*model.Post, error) {
+	pinned, err := s.postRepo.FindProfilePinned(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pinned posts: %w", err)
+	}
+
+	ids := make([]string, 0, len(pinned))
+	found := false
+	for _, p := range pinned {
+		if p.ID == postID {
+			found = true
+			continue
+		}
+		ids = append(ids, p.ID)
+	}
+	if !found {
+		return nil, errors.New("post is not pinned to your profile")
+	}
+
+	if err := s.setProfilePins(userID, pinned, ids); err != nil {
+		return nil, fmt.Errorf("failed to unpin post: %w", err)
+	}
+
+	return s.postRepo.FindProfilePinned(userID)
+}
+
+// ReorderProfilePins reorders the user's profile pins. postIDs must contain exactly the currently pinned posts.
+func (s *postService) ReorderProfilePins(userID string, postIDs []string) ([]*model.Post, error) {
+	pinned, err := s.postRepo.FindProfilePinned(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pinned posts: %w", err)
+	}
+
+	if len(postIDs) != len(pinned) {
+		return nil, errors.New("post_ids must contain exactly your pinned posts")
+	}
+	current := make(map[string]bool, len(pinned))
+	for _, p := range pinned {
+		current[p.ID] = true
+	}
+	for _, id := range postIDs {
+		if !current[id] {
+			return nil, errors.New("post_ids must contain exactly your pinned posts")
+		}
+		delete(current, id) // Reject duplicates
+	}
+
+	if err := s.setProfilePins(userID, pinned, postIDs); err != nil {
+		return nil, fmt.Errorf("failed to reorder pinned posts: %w", err)
+	}
+
+	return s.postRepo.FindProfilePinned(userID)
+}
+
+// setProfilePins replaces the user's profile pins, which must still be pinned (in order).
+// Concurrent changes are rejected instead of overwritten, so the pin limit checked against
+// pinned holds.
+func (s *postService) setProfilePins(userID string, pinned []*model.Post, postIDs []string) error {
+	expected := make([]string, len(pinned))
+	for i, p := range pinned {
+		expected[i] = p.ID
+	}
+	err := s.postRepo.SetProfilePins(userID, expected, postIDs)
+	if errors.Is(err, repository.ErrProfilePinsChanged) {
+		return errors.New("your pinned posts changed, please try again")
+	}
+	return err
+}
+
+// SetGroupPin pins or unpins a post within its group (group admins/moderators only)
+func (s *postService) SetGroupPin(userID, postID string, pinned bool) (*model.Post, error) {
+	post, err := s.postRepo.FindByID(postID)
+	if err != nil {
+		return nil, errors.New("post not found")
+	}
+	if post.GroupID == nil {
+		return nil, errors.New("post does not belong to a group")
+	}
+
+	if s.groupService == nil {
+		return nil, errors.New("group pinning is not available")
+	}
+	canModerate, err := s.groupService.IsModerator(*post.GroupID, userID)
+	if err != nil || !canModerate {
+		return nil, errors.New("only group admins or moderators can pin posts")
+	}
+
+	if err := s.postRepo.SetGroupPinned(postID, pinned); err != nil {
+		return nil, fmt.Errorf("failed to update pin: %w", err)
+	}
+
+	return s.postRepo.FindByID(postID)
+}
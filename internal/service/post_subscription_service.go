@@ -0,0 +1,109 @@
+package service
+
+import (
+	"errors"
+
+	"yourapp/internal/model"
+	"yourapp/internal/repository"
+)
+
+type PostSubscriptionService interface {
+	GetSubscription(userID, postID string) (*PostSubscriptionStatus, error)
+	FollowPost(userID, postID string) (*PostSubscriptionStatus, error)
+	UnfollowPost(userID, postID string) (*PostSubscriptionStatus, error)
+	MutePost(userID, postID string) (*PostSubscriptionStatus, error)
+	UnmutePost(userID, postID string) (*PostSubscriptionStatus, error)
+}
+
+// PostSubscriptionStatus is the viewer's notification state for a post
+type PostSubscriptionStatus struct {
+	PostID     string `json:"post_id"`
+	Subscribed bool   `json:"subscribed"`
+	Muted      bool   `json:"muted"`
+	Reason     string `json:"reason,omitempty"` // author, commented, followed
+}
+
+type postSubscriptionService struct {
+	subscriptionRepo repository.PostSubscriptionRepository
+	postRepo         repository.PostRepository
+}
+
+func NewPostSubscriptionService(
+	subscriptionRepo repository.PostSubscriptionRepository,
+	postRepo repository.PostRepository,
+) PostSubscriptionService {
+	return &postSubscriptionService{
+		subscriptionRepo: subscriptionRepo,
+		postRepo:         postRepo,
+	}
+}
+
+// GetSubscription returns whether the user follows and/or muted the post
+func (s *postSubscriptionService) GetSubscription(userID, postID string) (*PostSubscriptionStatus, error) {
+	if _, err := s.postRepo.FindByID(postID); err != nil {
+		return nil, errors.New("post not found")
+	}
+	return s.status(userID, postID), nil
+}
+
+// FollowPost subscribes the user to thread activity on a post (and unmutes it)
+func (s *postSubscriptionService) FollowPost(userID, postID string) (*PostSubscriptionStatus, error) {
+	if _, err := s.postRepo.FindByID(postID); err != nil {
+		return nil, errors.New("post not found")
+	}
+	if err := s.subscriptionRepo.Upsert(postID, userID, model.PostSubscriptionReasonFollowed, false); err != nil {
+		return nil, errors.New("failed to follow post")
+	}
+	return s.status(userID, postID), nil
+}
+
+// UnfollowPost removes the user's subscription; commenting again subscribes them again
+func (s *postSubscriptionService) UnfollowPost(userID, postID string) (*PostSubscriptionStatus, error) {
+	if err := s.subscriptionRepo.Delete(postID, userID); err != nil {
+		return nil, errors.New("failed to unfollow post")
+	}
+	return s.status(userID, postID), nil
+}
+
+// MutePost silences all comment notifications of a post for the user, including
+// replies to their own comments, until they unmute or follow it again
+func (s *postSubscriptionService) MutePost(userID, postID string) (*PostSubscriptionStatus, error) {
+	post, err := s.postRepo.FindByID(postID)
+	if err != nil {
+		return nil, errors.New("post not found")
+	}
+	if err := s.subscriptionRepo.Upsert(postID, userID, subscriptionReasonFor(post, userID), true); err != nil {
+		return nil, errors.New("failed to mute post")
+	}
+	return s.status(userID, postID), nil
+}
+
+// UnmutePost re-enables comment notifications of a post for the user
+func (s *postSubscriptionService) UnmutePost(userID, postID string) (*PostSubscriptionStatus, error) {
+	post, err := s.postRepo.FindByID(postID)
+	if err != nil {
+		return nil, errors.New("post not found")
+	}
+	if err := s.subscriptionRepo.Upsert(postID, userID, subscriptionReasonFor(post, userID), false); err != nil {
+		return nil, errors.New("failed to unmute post")
+	}
+	return s.status(userID, postID), nil
+}
+
+func (s *postSubscriptionService) status(userID, postID string) *PostSubscriptionStatus {
+	status := &PostSubscriptionStatus{PostID: postID}
+	if sub, err := s.subscriptionRepo.FindByPostAndUser(postID, userID); err == nil {
+		status.Subscribed = !sub.Muted
+		status.Muted = sub.Muted
+		status.Reason = sub.Reason
+	}
+	return status
+}
+
+// subscriptionReasonFor is the reason recorded when muting/unmuting creates the subscription
+func subscriptionReasonFor(post *model.Post, userID string) string {
+	if post.UserID == userID {
+		return model.PostSubscriptionReasonAuthor
+	}
+	return model.PostSubscriptionReasonFollowed
+}
@@ -0,0 +1,323 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"yourapp/internal/model"
+	"yourapp/internal/repository"
+	"yourapp/internal/util"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxResumableImageSize is the maximum size of a single image uploaded in chunks
+	MaxResumableImageSize = 10 * 1024 * 1024 // 10MB
+	// MaxResumableVideoSize is the maximum size of a single video uploaded in chunks.
+	// Higher than the multipart limit because chunks are streamed to disk instead of memory.
+	MaxResumableVideoSize = 200 * 1024 * 1024 // 200MB
+	// MaxUploadChunkSize is the maximum body size accepted by a single PATCH request
+	MaxUploadChunkSize = 8 * 1024 * 1024 // 8MB
+
+	uploadExpiry      = 24 * time.Hour
+	uploadSubdir      = "uploads"
+	uploadPartExt     = ".part"
+	maxUploadsPerPost = 10
+)
+
+// ErrUploadOffsetMismatch is returned when a chunk does not start at the upload's current offset
+var ErrUploadOffsetMismatch = errors.New("upload offset mismatch")
+
+var (
+	resumableImageExts = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".gif": true}
+	resumableVideoExts = map[string]bool{".mp4": true, ".mov": true, ".avi": true, ".webm": true, ".mkv": true, ".3gp": true}
+)
+
+type UploadService interface {
+	CreateUpload(userID string, req CreateUploadRequest) (*model.MediaUpload, error)
+	GetUpload(userID, uploadID string) (*model.MediaUpload, error)
+	AppendChunk(userID, uploadID string, offset int64, chunk io.Reader) (*model.MediaUpload, error)
+	AbortUpload(userID, uploadID string) error
+
+	// ConsumeUploads claims completed uploads for a post. The caller becomes responsible
+	// for the tmp files and must call ReleaseUpload once they have been processed.
+	ConsumeUploads(userID string, uploadIDs []string) ([]*model.MediaUpload, error)
+	ReleaseUpload(upload *model.MediaUpload)
+	CleanupExpired() (int, error)
+}
+
+type uploadService struct {
+	uploadRepo repository.MediaUploadRepository
+}
+
+func NewUploadService(uploadRepo repository.MediaUploadRepository) UploadService {
+	return &uploadService{
+		uploadRepo: uploadRepo,
+	}
+}
+
+type CreateUploadRequest struct {
+	Filename string `json:"filename" binding:"required"`
+	Size     int64  `json:"size" binding:"required"`
+}
+
+// CreateUpload registers a new resumable upload and creates its empty part file
+func (s *uploadService) CreateUpload(userID string, req CreateUploadRequest) (*model.MediaUpload, error) {
+	filename := filepath.Base(strings.TrimSpace(req.Filename))
+	ext := util.GetFileExt(filename)
+
+	var mediaType string
+	var maxSize int64
+	switch {
+	case resumableImageExts[ext]:
+		mediaType, maxSize = model.MediaTypeImage, MaxResumableImageSize
+	case resumableVideoExts[ext]:
+		mediaType, maxSize = model.MediaTypeVideo, MaxResumableVideoSize
+	default:
+		return nil, fmt.Errorf("file %s is not a supported image or video format", filename)
+	}
+
+	if req.Size <= 0 {
+		return nil, errors.New("size must be greater than 0")
+	}
+	if req.Size > maxSize {
+		return nil, fmt.Errorf("file %s exceeds %dMB limit", filename, maxSize/(1024*1024))
+	}
+
+	dir, err := uploadDir()
+	if err != nil {
+		return nil, err
+	}
+
+	upload := &model.MediaUpload{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Filename:  filename,
+		MediaType: mediaType,
+		MimeType:  util.DetectMimeType(filename),
+		Size:      req.Size,
+		Status:    model.MediaUploadStatusUploading,
+		ExpiresAt: time.Now().Add(uploadExpiry),
+	}
+	upload.TmpPath = filepath.Join(dir, upload.ID+ext+uploadPartExt)
+
+	f, err := os.OpenFile(upload.TmpPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload file: %w", err)
+	}
+	f.Close()
+
+	if err := s.uploadRepo.Create(upload); err != nil {
+		os.Remove(upload.TmpPath)
+		return nil, fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	return upload, nil
+}
+
+// GetUpload returns an upload owned by the user
+func (s *uploadService) GetUpload(userID, uploadID string) (*model.MediaUpload, error) {
+	upload, err := s.uploadRepo.FindByID(uploadID)
+	if err != nil {
+		return nil, errors.New("upload not found")
+	}
+	if upload.UserID != userID {
+		return nil, errors.New("upload not found")
+	}
+	return upload, nil
+}
+
+// AppendChunk writes a chunk at the given offset. The offset must equal the number of
+// bytes already received, so a client that lost a response can HEAD the upload and resume.
+// The chunk is written without holding a lock; committing the new offset is a compare-and-set
+// on the expected offset, so of two chunks sent for the same offset only one counts.
+func (s *uploadService) AppendChunk(userID, uploadID string, offset int64, chunk io.Reader) (*model.MediaUpload, error) {
+	upload, err := s.GetUpload(userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Status != model.MediaUploadStatusUploading {
+		return nil, errors.New("upload is already completed")
+	}
+	if offset != upload.Offset {
+		return upload, ErrUploadOffsetMismatch
+	}
+
+	written, err := writeChunk(upload, offset, chunk)
+	if err != nil {
+		return nil, err
+	}
+
+	status := model.MediaUploadStatusUploading
+	if offset+written == upload.Size {
+		status = model.MediaUploadStatusCompleted
+	}
+	ok, err := s.uploadRepo.AdvanceOffset(upload.ID, offset, offset+written, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save upload offset: %w", err)
+	}
+	if !ok {
+		// Another chunk for this offset was committed first
+		current, err := s.uploadRepo.FindByID(upload.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get upload: %w", err)
+		}
+		return current, ErrUploadOffsetMismatch
+	}
+	upload.Offset = offset + written
+	upload.Status = status
+	return upload, nil
+}
+
+// writeChunk writes the chunk into the upload's part file at offset, never past the declared
+// size. Bytes past the committed offset are overwritten by the next chunk for that offset.
+func writeChunk(upload *model.MediaUpload, offset int64, chunk io.Reader) (int64, error) {
+	f, err := os.OpenFile(upload.TmpPath, os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open upload file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to prepare upload file: %w", err)
+	}
+	written, err := io.Copy(f, io.LimitReader(chunk, upload.Size-offset))
+	if err != nil && written == 0 {
+		return 0, fmt.Errorf("failed to write chunk: %w", err)
+	}
+	return written, nil // An interrupted chunk keeps what arrived
+}
+
+// AbortUpload cancels an unfinished or unused upload and removes its file
+func (s *uploadService) AbortUpload(userID, uploadID string) error {
+	upload, err := s.GetUpload(userID, uploadID)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.discardUpload(upload)
+	if err != nil {
+		return fmt.Errorf("failed to abort upload: %w", err)
+	}
+	if !deleted {
+		return errors.New("upload is already attached to a post")
+	}
+	return nil
+}
+
+// discardUpload removes an upload that was not consumed, with its tmp file. It returns false
+// when the upload was consumed meanwhile and now belongs to a post.
+func (s *uploadService) discardUpload(upload *model.MediaUpload) (bool, error) {
+	deleted, err := s.uploadRepo.DeleteUnconsumed(upload.ID)
+	if err != nil || !deleted {
+		return false, err
+	}
+	if err := os.Remove(upload.TmpPath); err != nil && !os.IsNotExist(err) {
+		log.Printf("[UPLOAD] Failed to remove tmp file %s: %v", upload.TmpPath, err)
+	}
+	return true, nil
+}
+
+// ConsumeUploads validates and claims completed uploads, preserving the requested order
+func (s *uploadService) ConsumeUploads(userID string, uploadIDs []string) ([]*model.MediaUpload, error) {
+	if len(uploadIDs) > maxUploadsPerPost {
+		return nil, fmt.Errorf("maximum %d files allowed", maxUploadsPerPost)
+	}
+
+	found, err := s.uploadRepo.FindByIDs(uploadIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get uploads: %w", err)
+	}
+	byID := make(map[string]*model.MediaUpload, len(found))
+	for _, u := range found {
+		byID[u.ID] = u
+	}
+
+	uploads := make([]*model.MediaUpload, 0, len(uploadIDs))
+	seen := make(map[string]bool, len(uploadIDs))
+	for _, id := range uploadIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		u, ok := byID[id]
+		if !ok || u.UserID != userID {
+			return nil, fmt.Errorf("upload %s not found", id)
+		}
+		if u.Status != model.MediaUploadStatusCompleted {
+			return nil, fmt.Errorf("upload %s is not completed", id)
+		}
+		uploads = append(uploads, u)
+	}
+
+	ids := make([]string, len(uploads))
+	for i, u := range uploads {
+		ids[i] = u.ID
+	}
+	claimed, err := s.uploadRepo.Consume(userID, ids)
+	if errors.Is(err, repository.ErrUploadsUnavailable) {
+		return nil, errors.New("uploads are no longer available")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim upload: %w", err)
+	}
+	// Keep the requested order
+	byID = make(map[string]*model.MediaUpload, len(claimed))
+	for _, u := range claimed {
+		byID[u.ID] = u
+	}
+	for i, id := range ids {
+		uploads[i] = byID[id]
+	}
+
+	return uploads, nil
+}
+
+// ReleaseUpload removes the upload's tmp file and record
+func (s *uploadService) ReleaseUpload(upload *model.MediaUpload) {
+	if err := os.Remove(upload.TmpPath); err != nil && !os.IsNotExist(err) {
+		log.Printf("[UPLOAD] Failed to remove tmp file %s: %v", upload.TmpPath, err)
+	}
+	if err := s.uploadRepo.Delete(upload.ID); err != nil {
+		log.Printf("[UPLOAD] Failed to delete upload %s: %v", upload.ID, err)
+	}
+}
+
+// CleanupExpired removes uploads that were abandoned or never attached to a post
+func (s *uploadService) CleanupExpired() (int, error) {
+	uploads, err := s.uploadRepo.FindExpired(time.Now())
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, u := range uploads {
+		deleted, err := s.discardUpload(u)
+		if err != nil {
+			log.Printf("[UPLOAD] Failed to delete upload %s: %v", u.ID, err)
+		}
+		if deleted {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// uploadDir returns the directory holding in-progress resumable uploads
+func uploadDir() (string, error) {
+	tmpDir, err := util.TmpDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(tmpDir, uploadSubdir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	return dir, nil
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"yourapp/internal/model"
+	"yourapp/internal/repository"
+	"yourapp/internal/util"
+)
+
+type WebPushService interface {
+	// Enabled reports whether VAPID keys are configured
+	Enabled() bool
+	PublicKey() string
+	Subscribe(userID string, req *WebPushSubscribeRequest, userAgent string) error
+	Unsubscribe(userID, endpoint string) error
+	GetSubscriptions(userID string) ([]*model.WebPushSubscription, error)
+	// SendToOfflineUser pushes the message to all of the user's devices, but only when the user
+	// has no live WebSocket client (connected clients already got the realtime event)
+	SendToOfflineUser(userID string, message *WebPushMessage) error
+}
+
+// WebPushSubscribeRequest is the browser's PushSubscription.toJSON()
+type WebPushSubscribeRequest struct {
+	Endpoint string `json:"endpoint" binding:"required"`
+	Keys     struct {
+		P256dh string `json:"p256dh" binding:"required"`
+		Auth   string `json:"auth" binding:"required"`
+	} `json:"keys" binding:"required"`
+}
+
+// WebPushMessage is the JSON payload the service worker receives in its push event
+type WebPushMessage struct {
+	Type  string                 `json:"type"`
+	Title string                 `json:"title"`
+	Body  string                 `json:"body"`
+	Tag   string                 `json:"tag,omitempty"` // Same tag replaces the earlier notification on the device
+	URL   string                 `json:"url,omitempty"` // Path to open on click
+	Data  map[string]interface{} `json:"data,omitempty"`
+}
+
+type webPushService struct {
+	subscriptionRepo repository.WebPushSubscriptionRepository
+	client           *util.WebPushClient
+	hub              interface {
+		GetClientCount(string) int
+	}
+	allowLoopback bool
+}
+
+// NewWebPushService creates the Web Push service; client is nil when VAPID keys are not configured,
+// in which case subscriptions are still stored but nothing is sent. allowLoopback (development
+// only) accepts endpoints on loopback hosts.
+func NewWebPushService(
+	subscriptionRepo repository.WebPushSubscriptionRepository,
+	client *util.WebPushClient,
+	hub interface {
+		GetClientCount(string) int
+	},
+	allowLoopback bool,
+) WebPushService {
+	return &webPushService{
+		subscriptionRepo: subscriptionRepo,
+		client:           client,
+		hub:              hub,
+		allowLoopback:    allowLoopback,
+	}
+}
+
+// Enabled reports whether push messages can be sent
+func (s *webPushService) Enabled() bool {
+	return s.client != nil
+}
+
+// PublicKey returns the VAPID public key for PushManager.subscribe
+func (s *webPushService) PublicKey() string {
+	if s.client == nil {
+		return ""
+	}
+	return s.client.PublicKey()
+}
+
+// Subscribe registers (or refreshes) a device of the user
+func (s *webPushService) Subscribe(userID string, req *WebPushSubscribeRequest, userAgent string) error {
+	sub := util.WebPushSubscription{Endpoint: req.Endpoint, P256dh: req.Keys.P256dh, Auth: req.Keys.Auth}
+	if err := util.ValidateWebPushSubscription(sub, s.allowLoopback); err != nil {
+		return err
+	}
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+
+	err := s.subscriptionRepo.Upsert(&model.WebPushSubscription{
+		UserID:    userID,
+		Endpoint:  sub.Endpoint,
+		P256dh:    sub.P256dh,
+		Auth:      sub.Auth,
+		UserAgent: userAgent,
+	})
+	if errors.Is(err, repository.ErrWebPushEndpointTaken) {
+		// Another account subscribed this browser; it must unsubscribe (e.g. on logout) first
+		return errors.New("push subscription is registered to another account")
+	}
+	if err != nil {
+		return errors.New("failed to save push subscription")
+	}
+	return nil
+}
+
+// Unsubscribe removes one of the user's devices
+func (s *webPushService) Unsubscribe(userID, endpoint string) error {
+	if err := s.subscriptionRepo.DeleteByUserAndEndpoint(userID, endpoint); err != nil {
+		return errors.New("failed to remove push subscription")
+	}
+	return nil
+}
+
+// GetSubscriptions lists the user's registered devices
+func (s *webPushService) GetSubscriptions(userID string) ([]*model.WebPushSubscription, error) {
+	subs, err := s.subscriptionRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, errors.New("failed to get push subscriptions")
+	}
+	return subs, nil
+}
+
+// SendToOfflineUser delivers the message to every device of an offline user.
+// Subscriptions the push service reports as gone (404/410) are deleted.
+func (s *webPushService) SendToOfflineUser(userID string, message *WebPushMessage) error {
+	if s.client == nil {
+		return nil
+	}
+	if s.hub != nil && s.hub.GetClientCount(userID) > 0 {
+		return nil
+	}
+
+	subs, err := s.subscriptionRepo.FindByUserID(userID)
+	if err != nil {
+		return fmt.Errorf("failed to load push subscriptions: %w", err)
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode push message: %w", err)
+	}
+
+	var lastErr error
+	for _, sub := range subs {
+		err := s.client.Send(util.WebPushSubscription{Endpoint: sub.Endpoint, P256dh: sub.P256dh, Auth: sub.Auth}, payload, 0)
+		switch {
+		case errors.Is(err, util.ErrWebPushSubscriptionGone):
+			log.Printf("[WEB PUSH] Removing expired subscription %s of user %s", sub.ID, userID)
+			if err := s.subscriptionRepo.DeleteByEndpoint(sub.Endpoint); err != nil {
+				log.Printf("[WEB PUSH] Failed to remove subscription %s: %v", sub.ID, err)
+			}
+		case err != nil:
+			log.Printf("[WEB PUSH] Failed to push to subscription %s: %v", sub.ID, err)
+			lastErr = err
+		default:
+			if err := s.subscriptionRepo.MarkUsed(sub.ID); err != nil {
+				log.Printf("[WEB PUSH] Failed to update subscription %s: %v", sub.ID, err)
+			}
+		}
+	}
+	return lastErr
+}
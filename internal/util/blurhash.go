@@ -0,0 +1,116 @@
+package util
+
+import (
+	"image"
+	"math"
+	"strings"
+)
+
+const blurhashChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// EncodeBlurhash computes a BlurHash (https://blurha.sh) placeholder for img.
+// Pass a small image (a few dozen pixels); cost grows with pixel count × components.
+func EncodeBlurhash(img image.Image, xComponents, yComponents int) string {
+	if xComponents < 1 || xComponents > 9 || yComponents < 1 || yComponents > 9 {
+		return ""
+	}
+
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width == 0 || height == 0 {
+		return ""
+	}
+
+	// Convert to linear RGB once
+	pixels := make([][3]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			pixels[y*width+x] = [3]float64{
+				sRGBToLinear(int(r >> 8)),
+				sRGBToLinear(int(g >> 8)),
+				sRGBToLinear(int(bl >> 8)),
+			}
+		}
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1.0
+			}
+			var f [3]float64
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					basis := normalisation *
+						math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(height))
+					p := pixels[y*width+x]
+					f[0] += basis * p[0]
+					f[1] += basis * p[1]
+					f[2] += basis * p[2]
+				}
+			}
+			scale := 1.0 / float64(width*height)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(encode83((xComponents-1)+(yComponents-1)*9, 1))
+
+	dc, ac := factors[0], factors[1:]
+	maxValue := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = math.Max(actualMax, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantisedMax := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantisedMax+1) / 166
+		sb.WriteString(encode83(quantisedMax, 1))
+	} else {
+		sb.WriteString(encode83(0, 1))
+	}
+
+	sb.WriteString(encode83((linearToSRGB(dc[0])<<16)+(linearToSRGB(dc[1])<<8)+linearToSRGB(dc[2]), 4))
+	for _, f := range ac {
+		quant := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+		}
+		sb.WriteString(encode83(quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2))
+	}
+
+	return sb.String()
+}
+
+func encode83(value, length int) string {
+	out := make([]byte, length)
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		out[i-1] = blurhashChars[digit]
+	}
+	return string(out)
+}
+
+func sRGBToLinear(v int) float64 {
+	f := float64(v) / 255
+	if f <= 0.04045 {
+		return f / 12.92
+	}
+	return math.Pow((f+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
@@ -0,0 +1,487 @@
+package util
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Register WebP decoder
+)
+
+// Image variant names
+const (
+	ImageVariantThumbnail = "thumbnail"
+	ImageVariantMedium    = "medium"
+	ImageVariantFull      = "full"
+)
+
+// imageVariantSizes is the maximum long edge (px) of each generated variant.
+// Images are never upscaled, so small uploads produce identical variants.
+var imageVariantSizes = []struct {
+	Name    string
+	MaxEdge int
+}{
+	{ImageVariantThumbnail, 320},
+	{ImageVariantMedium, 720},
+	{ImageVariantFull, 1600},
+}
+
+const (
+	imageJPEGQuality   = 82
+	blurhashXComponent = 4
+	blurhashYComponent = 3
+	blurhashSampleEdge = 32 // Blurhash is computed on a tiny copy; detail is irrelevant
+
+	// Decoding allocates width*height pixels no matter how small the file is, so
+	// images are rejected from their header before they are decoded
+	maxImagePixels = 50_000_000
+	maxGIFFrames   = 500
+)
+
+// ImageVariant is a generated image file in the tmp directory
+type ImageVariant struct {
+	Name   string
+	Path   string
+	Width  int
+	Height int
+}
+
+// ProcessedImage is the result of running an upload through the image pipeline
+type ProcessedImage struct {
+	Width    int // Dimensions of the oriented original
+	Height   int
+	Blurhash string
+	Variants []ImageVariant
+}
+
+// Cleanup removes the generated variant files
+func (p *ProcessedImage) Cleanup() {
+	for _, v := range p.Variants {
+		os.Remove(v.Path)
+	}
+}
+
+// ProcessImage decodes an uploaded image, applies its EXIF orientation, drops all
+// metadata (by re-encoding) and writes thumbnail/medium/full variants to the tmp directory.
+// Animated GIFs are re-encoded frame by frame into a single full variant so the animation survives.
+func ProcessImage(srcPath string) (*ProcessedImage, error) {
+	format, err := detectImageFormat(srcPath)
+	if err != nil {
+		return nil, err
+	}
+	if format == "gif" {
+		return processGIF(srcPath)
+	}
+
+	img, err := decodeOriented(srcPath)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := ensureTmpDir()
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep transparency for PNGs with alpha; everything else becomes JPEG
+	usePNG := format == "png" && !isOpaque(img)
+	ext := ".jpg"
+	if usePNG {
+		ext = ".png"
+	}
+
+	bounds := img.Bounds()
+	result := &ProcessedImage{
+		Width:    bounds.Dx(),
+		Height:   bounds.Dy(),
+		Blurhash: EncodeBlurhash(resizeToFit(img, blurhashSampleEdge), blurhashXComponent, blurhashYComponent),
+	}
+
+	base := uuid.New().String()
+	for _, size := range imageVariantSizes {
+		resized := resizeToFit(img, size.MaxEdge)
+		variantPath := filepath.Join(tmpDir, base+"."+size.Name+ext)
+		if err := writeImage(variantPath, resized, usePNG); err != nil {
+			result.Cleanup()
+			return nil, err
+		}
+		rb := resized.Bounds()
+		result.Variants = append(result.Variants, ImageVariant{
+			Name:   size.Name,
+			Path:   variantPath,
+			Width:  rb.Dx(),
+			Height: rb.Dy(),
+		})
+	}
+
+	return result, nil
+}
+
+func processGIF(srcPath string) (*ProcessedImage, error) {
+	f, err := os.Open(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	defer f.Close()
+
+	// Every frame is decoded into memory, so check the frames before decoding any
+	if err := checkGIFFrames(bufio.NewReader(f)); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+	anim, err := gif.DecodeAll(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding GIF: %w", err)
+	}
+
+	tmpDir, err := ensureTmpDir()
+	if err != nil {
+		return nil, err
+	}
+	// Re-encoding keeps the frames, delays and loop count but drops comments and
+	// application extensions (XMP and the like)
+	outPath := filepath.Join(tmpDir, uuid.New().String()+"."+ImageVariantFull+".gif")
+	out, err := os.Create(outPath)
+	if err != nil {
+		return nil, fmt.Errorf("error creating image file: %w", err)
+	}
+	err = gif.EncodeAll(out, anim)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outPath)
+		return nil, fmt.Errorf("error encoding GIF: %w", err)
+	}
+
+	first := anim.Image[0]
+	w, h := anim.Config.Width, anim.Config.Height
+	if w == 0 || h == 0 {
+		b := first.Bounds()
+		w, h = b.Dx(), b.Dy()
+	}
+	return &ProcessedImage{
+		Width:    w,
+		Height:   h,
+		Blurhash: EncodeBlurhash(resizeToFit(first, blurhashSampleEdge), blurhashXComponent, blurhashYComponent),
+		Variants: []ImageVariant{{Name: ImageVariantFull, Path: outPath, Width: w, Height: h}},
+	}, nil
+}
+
+// checkGIFFrames walks the blocks of a GIF without decoding the image data and rejects it
+// when it has more than maxGIFFrames frames or more than maxImagePixels pixels in all frames
+func checkGIFFrames(r *bufio.Reader) error {
+	var header [13]byte // Signature, logical screen descriptor
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return fmt.Errorf("error reading GIF: %w", err)
+	}
+	if err := skipGIFColorTable(r, header[10]); err != nil {
+		return err
+	}
+
+	frames := 0
+	var pixels int64
+	for {
+		introducer, err := r.ReadByte()
+		if err == io.EOF && frames > 0 {
+			return nil // Missing trailer
+		}
+		if err != nil {
+			return fmt.Errorf("error reading GIF: %w", err)
+		}
+		switch introducer {
+		case 0x21: // Extension: label, then data sub-blocks
+			if _, err := r.ReadByte(); err != nil {
+				return fmt.Errorf("error reading GIF: %w", err)
+			}
+			if err := skipGIFSubBlocks(r); err != nil {
+				return err
+			}
+		case 0x2C: // Image descriptor: position, size and flags, then the image data
+			var desc [9]byte
+			if _, err := io.ReadFull(r, desc[:]); err != nil {
+				return fmt.Errorf("error reading GIF: %w", err)
+			}
+			frames++
+			pixels += int64(binary.LittleEndian.Uint16(desc[4:6])) * int64(binary.LittleEndian.Uint16(desc[6:8]))
+			if frames > maxGIFFrames {
+				return fmt.Errorf("GIF has more than %d frames", maxGIFFrames)
+			}
+			if pixels > maxImagePixels {
+				return fmt.Errorf("GIF frames have more than %d pixels", maxImagePixels)
+			}
+			if err := skipGIFColorTable(r, desc[8]); err != nil {
+				return err
+			}
+			if _, err := r.ReadByte(); err != nil { // LZW minimum code size
+				return fmt.Errorf("error reading GIF: %w", err)
+			}
+			if err := skipGIFSubBlocks(r); err != nil {
+				return err
+			}
+		case 0x3B: // Trailer
+			return nil
+		default:
+			return fmt.Errorf("error reading GIF: unknown block 0x%02x", introducer)
+		}
+	}
+}
+
+// skipGIFColorTable skips the color table announced by the flags of a screen or image descriptor
+func skipGIFColorTable(r *bufio.Reader, flags byte) error {
+	if flags&0x80 == 0 {
+		return nil
+	}
+	if _, err := r.Discard(3 * (1 << (flags&0x07 + 1))); err != nil {
+		return fmt.Errorf("error reading GIF: %w", err)
+	}
+	return nil
+}
+
+// skipGIFSubBlocks skips data sub-blocks up to and including the zero-length terminator
+func skipGIFSubBlocks(r *bufio.Reader) error {
+	for {
+		size, err := r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("error reading GIF: %w", err)
+		}
+		if size == 0 {
+			return nil
+		}
+		if _, err := r.Discard(int(size)); err != nil {
+			return fmt.Errorf("error reading GIF: %w", err)
+		}
+	}
+}
+
+// decodeOriented decodes any supported image and rotates/flips it according to its EXIF orientation
+func decodeOriented(srcPath string) (image.Image, error) {
+	f, err := os.Open(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	defer f.Close()
+
+	img, format, err := image.Decode(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %w", err)
+	}
+
+	if format == "jpeg" {
+		if _, err := f.Seek(0, io.SeekStart); err == nil {
+			img = applyOrientation(img, readJPEGOrientation(f))
+		}
+	}
+	return img, nil
+}
+
+// detectImageFormat reads the image header and rejects images too large to decode
+func detectImageFormat(srcPath string) (string, error) {
+	f, err := os.Open(srcPath)
+	if err != nil {
+		return "", fmt.Errorf("error opening file: %w", err)
+	}
+	defer f.Close()
+
+	cfg, format, err := image.DecodeConfig(bufio.NewReader(f))
+	if err != nil {
+		return "", fmt.Errorf("unsupported image format: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+		return "", fmt.Errorf("image dimensions %dx%d are too large", cfg.Width, cfg.Height)
+	}
+	return format, nil
+}
+
+func writeImage(path string, img image.Image, asPNG bool) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating image file: %w", err)
+	}
+	defer out.Close()
+
+	if asPNG {
+		err = png.Encode(out, img)
+	} else {
+		err = jpeg.Encode(out, flattenAlpha(img), &jpeg.Options{Quality: imageJPEGQuality})
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("error encoding image: %w", err)
+	}
+	return nil
+}
+
+// resizeToFit scales img down so its long edge is at most maxEdge (never upscales)
+func resizeToFit(img image.Image, maxEdge int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxEdge && h <= maxEdge {
+		return img
+	}
+
+	var nw, nh int
+	if w >= h {
+		nw, nh = maxEdge, h*maxEdge/w
+	} else {
+		nw, nh = w*maxEdge/h, maxEdge
+	}
+	if nw < 1 {
+		nw = 1
+	}
+	if nh < 1 {
+		nh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, nw, nh))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return true
+}
+
+// flattenAlpha composites transparent pixels onto white before JPEG encoding
+func flattenAlpha(img image.Image) image.Image {
+	if isOpaque(img) {
+		return img
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(dst, b, img, b.Min, draw.Over)
+	return dst
+}
+
+// applyOrientation transforms img according to an EXIF orientation value (1-8)
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // Mirror horizontal
+				sx, sy = w-1-x, y
+			case 3: // Rotate 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // Mirror vertical
+				sx, sy = x, h-1-y
+			case 5: // Transpose
+				sx, sy = y, x
+			case 6: // Rotate 90 CW
+				sx, sy = y, h-1-x
+			case 7: // Transverse
+				sx, sy = w-1-y, h-1-x
+			case 8: // Rotate 270 CW
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+// readJPEGOrientation returns the EXIF orientation tag of a JPEG stream, or 1 if absent
+func readJPEGOrientation(r io.Reader) int {
+	br := bufio.NewReader(r)
+	soi := make([]byte, 2)
+	if _, err := io.ReadFull(br, soi); err != nil || soi[0] != 0xFF || soi[1] != 0xD8 {
+		return 1
+	}
+
+	for {
+		marker := make([]byte, 2)
+		if _, err := io.ReadFull(br, marker); err != nil || marker[0] != 0xFF {
+			return 1
+		}
+		// Start of scan / end of image: no more metadata segments
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			return 1
+		}
+
+		lenBuf := make([]byte, 2)
+		if _, err := io.ReadFull(br, lenBuf); err != nil {
+			return 1
+		}
+		segLen := int(binary.BigEndian.Uint16(lenBuf)) - 2
+		if segLen < 0 {
+			return 1
+		}
+
+		if marker[1] != 0xE1 {
+			if _, err := br.Discard(segLen); err != nil {
+				return 1
+			}
+			continue
+		}
+
+		seg := make([]byte, segLen)
+		if _, err := io.ReadFull(br, seg); err != nil {
+			return 1
+		}
+		if o := parseEXIFOrientation(seg); o > 0 {
+			return o
+		}
+	}
+}
+
+// parseEXIFOrientation reads tag 0x0112 from IFD0 of an APP1 "Exif" segment
+func parseEXIFOrientation(seg []byte) int {
+	if len(seg) < 14 || string(seg[:6]) != "Exif\x00\x00" {
+		return 0
+	}
+	tiff := seg[6:]
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd : ifd+2]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8 : entry+10]))
+		}
+	}
+	return 0
+}
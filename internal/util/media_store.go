@@ -0,0 +1,153 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"yourapp/internal/config"
+
+	"github.com/google/uuid"
+)
+
+// Media store driver names
+const (
+	MediaStoreCloudinary = "cloudinary"
+	MediaStoreLocal      = "local"
+	MediaStoreS3         = "s3"
+)
+
+// MediaStore is a storage backend for user media (post images/videos, covers, attachments).
+// Keys are slash-separated, driver-independent paths such as "posts/images/<uuid>.jpg".
+type MediaStore interface {
+	// Put stores the file at filePath under key and returns its public URL
+	Put(key, filePath string, opts PutOptions) (*StoredMedia, error)
+	Delete(key string) error
+	// Get downloads the object stored under key to filePath
+	Get(key, filePath string) error
+	// SignedURL returns a time-limited URL for private delivery of key
+	SignedURL(key string, expiry time.Duration) (string, error)
+	// URL returns the delivery URL for key. Drivers that can transform on the fly
+	// (Cloudinary) apply the hints; others return the original object URL.
+	URL(key string, hints TransformHints) string
+	Driver() string
+}
+
+// PutOptions describes the object being stored
+type PutOptions struct {
+	ContentType string
+	Hints       TransformHints // Default delivery transformation for the returned URL
+}
+
+// StoredMedia is the result of a successful Put
+type StoredMedia struct {
+	Key string `json:"key"`
+	URL string `json:"url"`
+}
+
+// TransformHints are optional delivery hints (resize/format/quality)
+type TransformHints struct {
+	Width   int
+	Height  int
+	Crop    string // limit (default), fill
+	Format  string // e.g. webp, mp4
+	Quality string // e.g. auto, 80
+}
+
+// Default delivery hints, matching the transformations used before storage drivers existed
+var (
+	ImageDeliveryHints = TransformHints{Width: 1280, Format: "webp", Quality: "auto"}
+	VideoDeliveryHints = TransformHints{Width: 1280, Height: 720, Format: "mp4", Quality: "auto"}
+
+	// Image pipeline variants are already resized; only let the CDN pick format/quality
+	ImageVariantDeliveryHints = TransformHints{Format: "webp", Quality: "auto"}
+)
+
+// NewMediaStore creates the media store selected by MEDIA_STORE_DRIVER.
+// When no driver is configured, Cloudinary is used if credentials exist, otherwise local disk.
+func NewMediaStore(cfg *config.Config) (MediaStore, error) {
+	driver := strings.ToLower(cfg.MediaStoreDriver)
+	if driver == "" {
+		driver = MediaStoreLocal
+		if cfg.CloudinaryCloudName != "" && cfg.CloudinaryAPIKey != "" && cfg.CloudinaryAPISecret != "" {
+			driver = MediaStoreCloudinary
+		}
+	}
+
+	switch driver {
+	case MediaStoreCloudinary:
+		client, err := NewCloudinaryClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewCloudinaryMediaStore(client), nil
+	case MediaStoreLocal:
+		return NewLocalMediaStore(cfg.MediaLocalDir, cfg.MediaPublicURL, cfg.MediaSigningSecret)
+	case MediaStoreS3:
+		return NewS3MediaStore(S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			PublicURL:       cfg.S3PublicURL,
+			ForcePathStyle:  cfg.S3ForcePathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown media store driver: %s", driver)
+	}
+}
+
+// NewMediaKey builds a unique key for a file under folder, keeping its extension
+func NewMediaKey(folder, filename string) string {
+	return path.Join(folder, uuid.New().String()+GetFileExt(filename))
+}
+
+// cleanMediaKey normalizes a key and rejects path traversal
+func cleanMediaKey(key string) (string, error) {
+	cleaned := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if cleaned == "" || cleaned == "." || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid media key: %s", key)
+	}
+	return cleaned, nil
+}
+
+// mediaDownloadClient fetches objects for Get from drivers without a client of their own
+var mediaDownloadClient = &http.Client{Timeout: 5 * time.Minute}
+
+// downloadToFile runs req and writes the response body to filePath
+func downloadToFile(client *http.Client, req *http.Request, filePath string) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return writeFile(filePath, resp.Body)
+}
+
+// writeFile copies r into filePath, removing the file again when the copy fails
+func writeFile(filePath string, r io.Reader) error {
+	dst, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("error creating file: %w", err)
+	}
+	if _, err := io.Copy(dst, r); err != nil {
+		dst.Close()
+		os.Remove(filePath)
+		return fmt.Errorf("error writing file: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(filePath)
+		return fmt.Errorf("error writing file: %w", err)
+	}
+	return nil
+}
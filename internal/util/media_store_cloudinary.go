@@ -0,0 +1,214 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudinary/cloudinary-go/v2/api"
+	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+	"github.com/cloudinary/cloudinary-go/v2/asset"
+	"github.com/cloudinary/cloudinary-go/v2/transformation"
+)
+
+// cloudinaryMediaStore stores media in Cloudinary. Keys map to public IDs inside the configured folder.
+// Keys under LocalPrivatePrefix are stored as private assets, which are only delivered through
+// expiring download URLs (see SignedURL).
+type cloudinaryMediaStore struct {
+	client *CloudinaryClient
+}
+
+func NewCloudinaryMediaStore(client *CloudinaryClient) MediaStore {
+	return &cloudinaryMediaStore{client: client}
+}
+
+func (s *cloudinaryMediaStore) Driver() string {
+	return MediaStoreCloudinary
+}
+
+func (s *cloudinaryMediaStore) Put(key, filePath string, opts PutOptions) (*StoredMedia, error) {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	overwrite := true
+	_, err = s.client.cld.Upload.Upload(context.Background(), filePath, uploader.UploadParams{
+		PublicID:     s.publicID(key),
+		ResourceType: s.resourceType(key),
+		Type:         s.deliveryType(key),
+		Overwrite:    &overwrite,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error uploading to cloudinary: %w", err)
+	}
+
+	return &StoredMedia{Key: key, URL: s.URL(key, opts.Hints)}, nil
+}
+
+func (s *cloudinaryMediaStore) Delete(key string) error {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.cld.Upload.Destroy(context.Background(), uploader.DestroyParams{
+		PublicID:     s.publicID(key),
+		Type:         s.deliveryType(key).String(),
+		ResourceType: s.resourceType(key),
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting from cloudinary: %w", err)
+	}
+	return nil
+}
+
+// Get downloads the original file: private assets through a short-lived download URL, public
+// ones through their untransformed delivery URL
+func (s *cloudinaryMediaStore) Get(key, filePath string) error {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return err
+	}
+
+	url := s.URL(key, TransformHints{})
+	if s.deliveryType(key) == api.Private {
+		if url, err = s.SignedURL(key, 10*time.Minute); err != nil {
+			return err
+		}
+	}
+	if url == "" {
+		return fmt.Errorf("no cloudinary URL for key %s", key)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if err := downloadToFile(mediaDownloadClient, req, filePath); err != nil {
+		return fmt.Errorf("error downloading from cloudinary: %w", err)
+	}
+	return nil
+}
+
+// SignedURL returns a download URL of a private asset that expires after expiry. Signed
+// delivery URLs of public assets never expire, so other keys are refused.
+func (s *cloudinaryMediaStore) SignedURL(key string, expiry time.Duration) (string, error) {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return "", err
+	}
+	if s.deliveryType(key) != api.Private {
+		return "", fmt.Errorf("cloudinary cannot sign expiring URLs for public key %s", key)
+	}
+
+	resourceType := s.resourceType(key)
+	format := ""
+	if resourceType != "raw" { // Raw public IDs keep their extension
+		format = strings.TrimPrefix(GetFileExt(key), ".")
+	}
+	expiresAt := time.Now().Add(expiry)
+	return s.client.cld.Upload.PrivateDownloadURL(uploader.PrivateDownloadURLParams{
+		PublicID:     s.publicID(key),
+		Format:       format,
+		DeliveryType: api.Private,
+		ExpiresAt:    &expiresAt,
+		ResourceType: api.AssetType(resourceType),
+	})
+}
+
+func (s *cloudinaryMediaStore) URL(key string, hints TransformHints) string {
+	a, err := s.asset(key, hints)
+	if err != nil {
+		return ""
+	}
+	url, err := a.String()
+	if err != nil {
+		return ""
+	}
+	return url
+}
+
+func (s *cloudinaryMediaStore) asset(key string, hints TransformHints) (*asset.Asset, error) {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var a *asset.Asset
+	switch s.resourceType(key) {
+	case "video":
+		a, err = s.client.cld.Video(s.publicID(key))
+	case "raw":
+		a, err = s.client.cld.File(s.publicID(key))
+	default:
+		a, err = s.client.cld.Image(s.publicID(key))
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.Transformation = transformation.RawTransformation(cloudinaryTransformation(hints))
+	return a, nil
+}
+
+func (s *cloudinaryMediaStore) publicID(key string) string {
+	id := key
+	if s.resourceType(key) != "raw" { // Raw public IDs keep their extension
+		id = strings.TrimSuffix(key, GetFileExt(key))
+	}
+	if s.client.cfg.CloudinaryFolder != "" {
+		id = s.client.cfg.CloudinaryFolder + "/" + id
+	}
+	return id
+}
+
+// deliveryType stores keys under LocalPrivatePrefix as private assets
+func (s *cloudinaryMediaStore) deliveryType(key string) api.DeliveryType {
+	if strings.HasPrefix(key, LocalPrivatePrefix) {
+		return api.Private
+	}
+	return api.Upload
+}
+
+// resourceType maps a key to its Cloudinary resource type. Audio is stored as video;
+// documents and other files as raw.
+func (s *cloudinaryMediaStore) resourceType(key string) string {
+	switch {
+	case IsVideoFile(key), IsAudioFile(key):
+		return "video"
+	case IsImageFile(key), GetFileExt(key) == "":
+		return "image"
+	}
+	return "raw"
+}
+
+// cloudinaryTransformation converts hints into a Cloudinary transformation string (e.g. "c_limit,w_1280,q_auto,f_webp")
+func cloudinaryTransformation(h TransformHints) string {
+	var parts []string
+	if h.Width > 0 || h.Height > 0 {
+		crop := h.Crop
+		if crop == "" {
+			crop = "limit"
+		}
+		parts = append(parts, "c_"+crop)
+	}
+	if h.Width > 0 {
+		parts = append(parts, "w_"+strconv.Itoa(h.Width))
+	}
+	if h.Height > 0 {
+		parts = append(parts, "h_"+strconv.Itoa(h.Height))
+	}
+	if h.Quality != "" {
+		parts = append(parts, "q_"+h.Quality)
+	}
+	if h.Format != "" {
+		parts = append(parts, "f_"+h.Format)
+		if h.Format == "mp4" {
+			parts = append(parts, "vc_h264")
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,175 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LocalPrivatePrefix marks private keys: the static route only serves them with a valid
+// signature, and Cloudinary stores them as private assets
+const LocalPrivatePrefix = "private/"
+
+// LocalMediaStore stores media on the local filesystem and serves it through
+// the static route registered with ServeMedia (e.g. GET /media/*key).
+type LocalMediaStore struct {
+	rootDir   string
+	publicURL string
+	secret    []byte
+}
+
+func NewLocalMediaStore(rootDir, publicURL, signingSecret string) (*LocalMediaStore, error) {
+	absDir, err := filepath.Abs(rootDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid media directory: %w", err)
+	}
+	if err := os.MkdirAll(absDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create media directory: %w", err)
+	}
+
+	return &LocalMediaStore{
+		rootDir:   absDir,
+		publicURL: strings.TrimRight(publicURL, "/"),
+		secret:    []byte(signingSecret),
+	}, nil
+}
+
+func (s *LocalMediaStore) Driver() string {
+	return MediaStoreLocal
+}
+
+func (s *LocalMediaStore) Put(key, filePath string, opts PutOptions) (*StoredMedia, error) {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	dest := filepath.Join(s.rootDir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create media directory: %w", err)
+	}
+
+	src, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	defer src.Close()
+
+	// Write to a temp name first so a half-written file is never served
+	tmp := dest + ".tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return nil, fmt.Errorf("error creating media file: %w", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return nil, fmt.Errorf("error writing media file: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("error writing media file: %w", err)
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("error writing media file: %w", err)
+	}
+
+	return &StoredMedia{Key: key, URL: s.URL(key, opts.Hints)}, nil
+}
+
+func (s *LocalMediaStore) Delete(key string) error {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(s.rootDir, filepath.FromSlash(key))); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error deleting media file: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalMediaStore) Get(key, filePath string) error {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(filepath.Join(s.rootDir, filepath.FromSlash(key)))
+	if err != nil {
+		return fmt.Errorf("error opening media file: %w", err)
+	}
+	defer src.Close()
+	return writeFile(filePath, src)
+}
+
+// SignedURL returns a URL carrying an HMAC signature valid until now+expiry
+func (s *LocalMediaStore) SignedURL(key string, expiry time.Duration) (string, error) {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	return fmt.Sprintf("%s/%s?expires=%s&signature=%s", s.publicURL, key, expires, s.sign(key, expires)), nil
+}
+
+// URL returns the static URL of key. The local driver cannot transform, so hints are ignored.
+func (s *LocalMediaStore) URL(key string, hints TransformHints) string {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return ""
+	}
+	return s.publicURL + "/" + key
+}
+
+// ServeMedia serves stored files. Keys under LocalPrivatePrefix require a valid,
+// unexpired signature; a signature on a public key is verified as well.
+func (s *LocalMediaStore) ServeMedia(c *gin.Context) {
+	key, err := cleanMediaKey(c.Param("key"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	signature := c.Query("signature")
+	if signature != "" || strings.HasPrefix(key, LocalPrivatePrefix) {
+		if !s.verify(key, c.Query("expires"), signature) {
+			c.Status(http.StatusForbidden)
+			return
+		}
+		c.Header("Cache-Control", "private, no-store")
+	} else {
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	}
+
+	filePath := filepath.Join(s.rootDir, filepath.FromSlash(key))
+	if info, err := os.Stat(filePath); err != nil || info.IsDir() {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.File(filePath)
+}
+
+func (s *LocalMediaStore) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *LocalMediaStore) verify(key, expires, signature string) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.sign(key, expires)))
+}
@@ -0,0 +1,313 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm     = "AWS4-HMAC-SHA256"
+	s3Service       = "s3"
+	s3MaxPresignAge = 7 * 24 * time.Hour // SigV4 presigned URLs are limited to 7 days
+)
+
+// S3Config configures an S3-compatible bucket (AWS S3, MinIO, Cloudflare R2, ...)
+type S3Config struct {
+	Endpoint        string // e.g. https://s3.us-east-1.amazonaws.com or http://localhost:9000
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PublicURL       string // Optional base URL for public delivery (CDN); defaults to the bucket URL
+	ForcePathStyle  bool   // Use endpoint/bucket/key instead of bucket.endpoint/key (MinIO)
+}
+
+// s3MediaStore talks to the S3 REST API directly with AWS Signature Version 4,
+// so no SDK dependency is needed.
+type s3MediaStore struct {
+	cfg        S3Config
+	endpoint   *url.URL
+	httpClient *http.Client
+}
+
+func NewS3MediaStore(cfg S3Config) (MediaStore, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("s3 storage not configured")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint: %s", cfg.Endpoint)
+	}
+
+	return &s3MediaStore{
+		cfg:        cfg,
+		endpoint:   endpoint,
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s *s3MediaStore) Driver() string {
+	return MediaStoreS3
+}
+
+func (s *s3MediaStore) Put(key, filePath string, opts PutOptions) (*StoredMedia, error) {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	defer file.Close()
+
+	// Hash the payload first (SigV4 signs it), then rewind and stream it
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+	payloadHash := hex.EncodeToString(hasher.Sum(nil))
+
+	req, err := http.NewRequest(http.MethodPut, s.objectURL(key).String(), file)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = DetectMimeType(key)
+	}
+	req.Header.Set("Content-Type", contentType)
+	s.signRequest(req, payloadHash, time.Now().UTC())
+
+	if err := s.do(req); err != nil {
+		return nil, fmt.Errorf("error uploading to s3: %w", err)
+	}
+
+	return &StoredMedia{Key: key, URL: s.URL(key, opts.Hints)}, nil
+}
+
+func (s *s3MediaStore) Delete(key string) error {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	s.signRequest(req, emptyPayloadHash(), time.Now().UTC())
+
+	if err := s.do(req); err != nil {
+		return fmt.Errorf("error deleting from s3: %w", err)
+	}
+	return nil
+}
+
+func (s *s3MediaStore) Get(key, filePath string) error {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, s.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	s.signRequest(req, emptyPayloadHash(), time.Now().UTC())
+
+	if err := downloadToFile(s.httpClient, req, filePath); err != nil {
+		return fmt.Errorf("error downloading from s3: %w", err)
+	}
+	return nil
+}
+
+// SignedURL returns a SigV4 presigned GET URL
+func (s *s3MediaStore) SignedURL(key string, expiry time.Duration) (string, error) {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return "", err
+	}
+	if expiry <= 0 || expiry > s3MaxPresignAge {
+		expiry = s3MaxPresignAge
+	}
+	return s.presignURL(key, expiry, time.Now().UTC()), nil
+}
+
+func (s *s3MediaStore) presignURL(key string, expiry time.Duration, now time.Time) string {
+	amzDate := now.Format("20060102T150405Z")
+	scope := s.scope(now)
+
+	u := s.objectURL(key)
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", s3Algorithm)
+	query.Set("X-Amz-Credential", s.cfg.AccessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expiry.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalRequest := sigV4CanonicalRequest(http.MethodGet, u.EscapedPath(), s3CanonicalQuery(query),
+		"host:"+u.Host+"\n", "host", "UNSIGNED-PAYLOAD")
+
+	query.Set("X-Amz-Signature", s.signature(now, amzDate, scope, canonicalRequest))
+	u.RawQuery = s3CanonicalQuery(query)
+	return u.String()
+}
+
+// URL returns the public object URL. S3 cannot transform, so hints are ignored.
+func (s *s3MediaStore) URL(key string, hints TransformHints) string {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return ""
+	}
+	if s.cfg.PublicURL != "" {
+		return strings.TrimRight(s.cfg.PublicURL, "/") + "/" + s3EscapePath(key)
+	}
+	return s.objectURL(key).String()
+}
+
+func (s *s3MediaStore) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	if s.cfg.ForcePathStyle {
+		u.Path = "/" + s.cfg.Bucket + "/" + key
+		u.RawPath = "/" + s3EscapePath(s.cfg.Bucket) + "/" + s3EscapePath(key)
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path = "/" + key
+		u.RawPath = "/" + s3EscapePath(key)
+	}
+	return &u
+}
+
+func (s *s3MediaStore) do(req *http.Request) error {
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// signRequest adds SigV4 Authorization headers (host, x-amz-content-sha256 and x-amz-date are signed)
+func (s *s3MediaStore) signRequest(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	scope := s.scope(now)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := sigV4CanonicalRequest(req.Method, req.URL.EscapedPath(), s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders, signedHeaders, payloadHash)
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.cfg.AccessKeyID, scope, signedHeaders, s.signature(now, amzDate, scope, canonicalRequest)))
+}
+
+func (s *s3MediaStore) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.cfg.Region + "/" + s3Service + "/aws4_request"
+}
+
+func (s *s3MediaStore) signature(now time.Time, amzDate, scope, canonicalRequest string) string {
+	return sigV4Signature(s.cfg.SecretAccessKey, now.Format("20060102"), s.cfg.Region, s3Service,
+		sigV4StringToSign(amzDate, scope, canonicalRequest))
+}
+
+// sigV4CanonicalRequest joins the parts of a SigV4 canonical request; canonicalHeaders ends
+// with a newline
+func sigV4CanonicalRequest(method, escapedPath, canonicalQuery, canonicalHeaders, signedHeaders, payloadHash string) string {
+	return strings.Join([]string{method, escapedPath, canonicalQuery, canonicalHeaders, signedHeaders, payloadHash}, "\n")
+}
+
+func sigV4StringToSign(amzDate, scope, canonicalRequest string) string {
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	return s3Algorithm + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+}
+
+// sigV4Signature derives the signing key for date (YYYYMMDD), region and service and signs stringToSign
+func sigV4Signature(secret, date, region, service, stringToSign string) string {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func emptyPayloadHash() string {
+	sum := sha256.Sum256(nil)
+	return hex.EncodeToString(sum[:])
+}
+
+// s3CanonicalQuery encodes query parameters sorted by key, as SigV4 requires
+func s3CanonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vs := values[k]
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func s3EscapePath(p string) string {
+	return s3Escape(p, true)
+}
+
+// s3Escape percent-encodes everything except RFC 3986 unreserved characters (and '/' in paths)
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (keepSlash && c == '/') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -1,9 +1,13 @@
 package util
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	"yourapp/internal/config"
 
@@ -14,13 +18,17 @@ type RabbitMQClient struct {
 	conn    *amqp.Connection
 	channel *amqp.Channel
 	config  *config.Config
+
+	// Notifications are published on their own channel in confirm mode
+	confirmChannel *amqp.Channel
+	confirmMu      sync.Mutex
 }
 
 type EmailMessage struct {
 	To      string `json:"to"`
 	Subject string `json:"subject"`
 	Body    string `json:"body"`
-	Type    string `json:"type"` // "otp", "reset_password", "verification"
+	Type    string `json:"type"` // "otp", "reset_password", "verification", "welcome", "notification", "digest"
 }
 
 const (
@@ -236,6 +244,9 @@ func (r *RabbitMQClient) PublishEmail(message EmailMessage) error {
 
 // Close closes the RabbitMQ connection
 func (r *RabbitMQClient) Close() error {
+	if r.confirmChannel != nil {
+		r.confirmChannel.Close()
+	}
 	if r.channel != nil {
 		if err := r.channel.Close(); err != nil {
 			return err
@@ -257,79 +268,145 @@ func (r *RabbitMQClient) GetQueueName() string {
 	return EmailQueueName
 }
 
-// Publish publishes a message to RabbitMQ exchange and queue
-func (r *RabbitMQClient) Publish(exchange, queueName string, body []byte) error {
-	// Ensure connection is open
+// The notification queue and exchange of earlier releases (notification_queue on
+// notification_exchange) were declared without dead-lettering. RabbitMQ cannot change the
+// arguments of an existing queue, so this topology uses new names and leaves them unused.
+const (
+	NotificationQueueName          = "notification_delivery_queue"
+	NotificationExchange           = "notification_delivery_exchange"
+	NotificationDeadLetterExchange = "notification_dead_letter_exchange"
+	NotificationDeadLetterQueue    = "notification_dead_letter_queue"
+	notificationRoutingKey         = "notification"
+	notificationConfirmTimeout     = 5 * time.Second
+)
+
+// setupNotificationTopology declares the durable notification exchange and queue. The worker
+// republishes a failed message with its attempt count raised, up to 3 attempts; messages it
+// rejects (malformed, or failed on the last attempt) are dead-lettered to
+// NotificationDeadLetterQueue for inspection instead of being retried forever.
+func setupNotificationTopology(channel *amqp.Channel) error {
+	for _, exchange := range []string{NotificationExchange, NotificationDeadLetterExchange} {
+		if err := channel.ExchangeDeclare(exchange, "direct", true, false, false, false, nil); err != nil {
+			return fmt.Errorf("failed to declare exchange %s: %w", exchange, err)
+		}
+	}
+
+	if _, err := channel.QueueDeclare(NotificationDeadLetterQueue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead letter queue: %w", err)
+	}
+	if err := channel.QueueBind(NotificationDeadLetterQueue, notificationRoutingKey, NotificationDeadLetterExchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind dead letter queue: %w", err)
+	}
+
+	if _, err := channel.QueueDeclare(NotificationQueueName, true, false, false, false, amqp.Table{
+		"x-dead-letter-exchange":    NotificationDeadLetterExchange,
+		"x-dead-letter-routing-key": notificationRoutingKey,
+	}); err != nil {
+		return fmt.Errorf("failed to declare queue: %w", err)
+	}
+	if err := channel.QueueBind(NotificationQueueName, notificationRoutingKey, NotificationExchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind queue: %w", err)
+	}
+
+	return nil
+}
+
+// PublishNotification publishes a persistent notification message and waits for the broker to
+// confirm it. An error means the message may not have been stored and the caller should deliver
+// it another way; messageID lets consumers drop the duplicate if it was stored after all.
+func (r *RabbitMQClient) PublishNotification(messageID string, body []byte) error {
+	r.confirmMu.Lock()
+	defer r.confirmMu.Unlock()
+
 	if err := r.ensureConnection(); err != nil {
 		return fmt.Errorf("connection error: %w", err)
 	}
 
-	// Declare exchange if not email exchange
-	if exchange != EmailExchange {
-		if err := r.channel.ExchangeDeclare(
-			exchange,
-			"direct",
-			true,
-			false,
-			false,
-			false,
-			nil,
-		); err != nil {
-			return fmt.Errorf("failed to declare exchange: %w", err)
+	if r.confirmChannel == nil || r.confirmChannel.IsClosed() {
+		channel, err := r.conn.Channel()
+		if err != nil {
+			return fmt.Errorf("failed to open confirm channel: %w", err)
 		}
-	}
-
-	// Declare queue if not email queue
-	if queueName != EmailQueueName {
-		if _, err := r.channel.QueueDeclare(
-			queueName,
-			true,
-			false,
-			false,
-			false,
-			nil,
-		); err != nil {
-			return fmt.Errorf("failed to declare queue: %w", err)
+		if err := setupNotificationTopology(channel); err != nil {
+			channel.Close()
+			return err
 		}
-
-		// Bind queue to exchange
-		if err := r.channel.QueueBind(
-			queueName,
-			"notification",
-			exchange,
-			false,
-			nil,
-		); err != nil {
-			return fmt.Errorf("failed to bind queue: %w", err)
+		if err := channel.Confirm(false); err != nil {
+			channel.Close()
+			return fmt.Errorf("failed to enable publisher confirms: %w", err)
 		}
+		r.confirmChannel = channel
 	}
 
-	// Determine routing key
-	routingKey := "email"
-	if exchange == NotificationExchange {
-		routingKey = "notification"
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), notificationConfirmTimeout)
+	defer cancel()
 
-	err := r.channel.Publish(
-		exchange,
-		routingKey,
-		false,
-		false,
+	confirmation, err := r.confirmChannel.PublishWithDeferredConfirmWithContext(
+		ctx,
+		NotificationExchange,
+		notificationRoutingKey,
+		false, // mandatory
+		false, // immediate
 		amqp.Publishing{
 			ContentType:  "application/json",
+			MessageId:    messageID,
+			Timestamp:    time.Now(),
 			Body:         body,
 			DeliveryMode: amqp.Persistent,
 		},
 	)
-
 	if err != nil {
 		return fmt.Errorf("failed to publish message: %w", err)
 	}
 
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		// The channel's confirm sequence can't be trusted anymore, start over on the next publish
+		r.confirmChannel.Close()
+		return fmt.Errorf("publish not confirmed: %w", err)
+	}
+	if !acked {
+		return errors.New("publish rejected by broker")
+	}
 	return nil
 }
 
-const (
-	NotificationQueueName = "notification_queue"
-	NotificationExchange  = "notification_exchange"
-)
+// ConsumeNotifications opens a dedicated channel consuming the notification queue with at most
+// prefetch unacknowledged deliveries. The deliveries channel closes when the channel or the
+// connection does.
+func (r *RabbitMQClient) ConsumeNotifications(prefetch int) (*amqp.Channel, <-chan amqp.Delivery, error) {
+	r.confirmMu.Lock()
+	defer r.confirmMu.Unlock()
+
+	if err := r.ensureConnection(); err != nil {
+		return nil, nil, fmt.Errorf("connection error: %w", err)
+	}
+
+	channel, err := r.conn.Channel()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+	if err := setupNotificationTopology(channel); err != nil {
+		channel.Close()
+		return nil, nil, err
+	}
+	if err := channel.Qos(prefetch, 0, false); err != nil {
+		channel.Close()
+		return nil, nil, fmt.Errorf("failed to set prefetch: %w", err)
+	}
+
+	deliveries, err := channel.Consume(
+		NotificationQueueName,
+		"",    // consumer tag (generated)
+		false, // auto-ack
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,
+	)
+	if err != nil {
+		channel.Close()
+		return nil, nil, fmt.Errorf("failed to consume: %w", err)
+	}
+	return channel, deliveries, nil
+}
@@ -135,3 +135,60 @@ func (r *RedisClient) ZRem(key string, member string) error {
 func (r *RedisClient) ZIncrBy(key string, increment float64, member string) error {
 	return r.client.ZIncrBy(r.ctx, key, increment, member).Err()
 }
+
+// HGetAllMany returns the fields of several hashes in one round trip.
+// Keys that do not exist are omitted from the result.
+func (r *RedisClient) HGetAllMany(keys []string) (map[string]map[string]string, error) {
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.HGetAll(r.ctx, key)
+	}
+	if _, err := pipe.Exec(r.ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	result := make(map[string]map[string]string, len(keys))
+	for i, cmd := range cmds {
+		fields, err := cmd.Result()
+		if err != nil || len(fields) == 0 {
+			continue
+		}
+		result[keys[i]] = fields
+	}
+	return result, nil
+}
+
+// HSetWithExpiry replaces a hash with the given fields and sets its expiration
+func (r *RedisClient) HSetWithExpiry(key string, fields map[string]interface{}, expiration time.Duration) error {
+	pipe := r.client.TxPipeline()
+	pipe.Del(r.ctx, key)
+	pipe.HSet(r.ctx, key, fields)
+	pipe.Expire(r.ctx, key, expiration)
+	_, err := pipe.Exec(r.ctx)
+	return err
+}
+
+// hIncrByIfExistsScript only touches hashes that are already cached, so a partial
+// hash is never created from an increment alone
+var hIncrByIfExistsScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+for i = 1, #ARGV, 2 do
+	redis.call('HINCRBY', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+return 1
+`)
+
+// HIncrByIfExists atomically applies field increments to a hash if it exists
+func (r *RedisClient) HIncrByIfExists(key string, increments map[string]int64) error {
+	if len(increments) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, len(increments)*2)
+	for field, delta := range increments {
+		args = append(args, field, delta)
+	}
+	return hIncrByIfExistsScript.Run(r.ctx, r.client, []string{key}, args...).Err()
+}
@@ -0,0 +1,36 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// unsubscribeTokenPurpose is mixed into the signature so the secret can be shared with other tokens
+const unsubscribeTokenPurpose = "email-digest-unsubscribe:"
+
+// SignUnsubscribeToken creates the token of a one-click unsubscribe link: "<userID>.<signature>".
+// It does not expire, so links in old emails keep working.
+func SignUnsubscribeToken(secret, userID string) string {
+	return userID + "." + unsubscribeSignature(secret, userID)
+}
+
+// VerifyUnsubscribeToken checks the signature and returns the user ID of the token
+func VerifyUnsubscribeToken(secret, token string) (string, error) {
+	userID, signature, ok := strings.Cut(token, ".")
+	if !ok || userID == "" || signature == "" {
+		return "", errors.New("invalid unsubscribe token")
+	}
+	if !hmac.Equal([]byte(signature), []byte(unsubscribeSignature(secret, userID))) {
+		return "", errors.New("invalid unsubscribe token")
+	}
+	return userID, nil
+}
+
+func unsubscribeSignature(secret, userID string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsubscribeTokenPurpose + userID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
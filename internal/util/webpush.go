@@ -0,0 +1,360 @@
+package util
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	webPushRecordSize   = 4096                                // rs of the single aes128gcm record
+	webPushMaxPayload   = 4096 - webPushHeaderLength - 16 - 1 // Push services accept 4096 bytes: minus the header, GCM tag and padding delimiter
+	webPushVAPIDExpiry  = 12 * time.Hour                      // Push services reject VAPID tokens valid for more than 24h
+	webPushDefaultTTL   = 24 * time.Hour
+	webPushHeaderLength = 16 + 4 + 1 + 65 // salt, rs, idlen, keyid (sender public key)
+)
+
+// ErrWebPushSubscriptionGone is returned when the push service answers 404 or 410:
+// the subscription expired or was revoked and must be deleted
+var ErrWebPushSubscriptionGone = errors.New("web push subscription is no longer valid")
+
+// WebPushSubscription is the PushSubscription of a browser (endpoint and its keys, base64url)
+type WebPushSubscription struct {
+	Endpoint string
+	P256dh   string
+	Auth     string
+}
+
+// WebPushClient sends encrypted (RFC 8291) Web Push messages authenticated with VAPID (RFC 8292)
+type WebPushClient struct {
+	privateKey *ecdsa.PrivateKey
+	publicKey  string
+	subject    string
+	httpClient *http.Client
+}
+
+// NewWebPushClient creates a client from base64url VAPID keys.
+// subject is the mailto: or https: contact sent to push services. The client only connects to
+// public addresses; allowLoopback (development only) also lets it reach loopback endpoints.
+func NewWebPushClient(publicKey, privateKey, subject string, allowLoopback bool) (*WebPushClient, error) {
+	d, err := decodeBase64URL(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	pub := key.PublicKey().Bytes()
+	if publicKey != "" {
+		given, err := decodeBase64URL(publicKey)
+		if err != nil || !bytes.Equal(given, pub) {
+			return nil, errors.New("VAPID public key does not match the private key")
+		}
+	}
+	if subject == "" {
+		return nil, errors.New("VAPID subject is required")
+	}
+
+	return &WebPushClient{
+		privateKey: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(pub[1:33]),
+				Y:     new(big.Int).SetBytes(pub[33:65]),
+			},
+			D: new(big.Int).SetBytes(d),
+		},
+		publicKey: base64.RawURLEncoding.EncodeToString(pub),
+		subject:   subject,
+		httpClient: &http.Client{
+			Timeout: 15 * time.Second,
+			Transport: &http.Transport{
+				// Checked on every connection, so a host that resolved to a public address at
+				// subscribe time cannot be pointed at an internal one later
+				DialContext: (&net.Dialer{
+					Timeout: 10 * time.Second,
+					Control: func(network, address string, _ syscall.RawConn) error {
+						host, _, err := net.SplitHostPort(address)
+						if err != nil {
+							return err
+						}
+						if ip := net.ParseIP(host); ip == nil || !webPushAddressAllowed(ip, allowLoopback) {
+							return fmt.Errorf("push endpoint address %s is not allowed", host)
+						}
+						return nil
+					},
+				}).DialContext,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+		},
+	}, nil
+}
+
+// GenerateVAPIDKeys creates a new VAPID key pair (base64url public point and private scalar)
+func GenerateVAPIDKeys() (publicKey, privateKey string, err error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		base64.RawURLEncoding.EncodeToString(key.Bytes()), nil
+}
+
+// ValidateWebPushSubscription checks that the endpoint is an https URL on a public host and that
+// the keys are a P-256 point and a 16-byte secret. allowLoopback (development only) also accepts
+// http and https endpoints on loopback hosts, such as a local stand-in push service.
+func ValidateWebPushSubscription(sub WebPushSubscription, allowLoopback bool) error {
+	endpoint, err := url.Parse(sub.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return errors.New("invalid push endpoint")
+	}
+	host := endpoint.Hostname()
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		if ips, err = net.LookupIP(host); err != nil || len(ips) == 0 {
+			return errors.New("push endpoint host cannot be resolved")
+		}
+	}
+	loopback := true
+	for _, ip := range ips {
+		if !webPushAddressAllowed(ip, allowLoopback) {
+			return errors.New("push endpoint must be a public host")
+		}
+		loopback = loopback && ip.IsLoopback()
+	}
+	if endpoint.Scheme != "https" && (endpoint.Scheme != "http" || !loopback) {
+		return errors.New("push endpoint must use https")
+	}
+	p256dh, err := decodeBase64URL(sub.P256dh)
+	if err != nil {
+		return errors.New("invalid p256dh key")
+	}
+	if _, err := ecdh.P256().NewPublicKey(p256dh); err != nil {
+		return errors.New("invalid p256dh key")
+	}
+	if auth, err := decodeBase64URL(sub.Auth); err != nil || len(auth) != 16 {
+		return errors.New("invalid auth secret")
+	}
+	return nil
+}
+
+// webPushAddressAllowed reports whether push requests may be sent to ip: public addresses only,
+// plus loopback when allowLoopback is set
+func webPushAddressAllowed(ip net.IP, allowLoopback bool) bool {
+	if ip.IsLoopback() {
+		return allowLoopback
+	}
+	return !ip.IsPrivate() && !ip.IsUnspecified() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+// PublicKey returns the VAPID public key browsers pass as applicationServerKey
+func (c *WebPushClient) PublicKey() string {
+	return c.publicKey
+}
+
+// Send encrypts payload for the subscription and posts it to the push service.
+// It returns ErrWebPushSubscriptionGone on 404/410 so the caller can drop the subscription.
+func (c *WebPushClient) Send(sub WebPushSubscription, payload []byte, ttl time.Duration) error {
+	endpoint, err := url.Parse(sub.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "https" && endpoint.Scheme != "http") {
+		return fmt.Errorf("invalid push endpoint: %s", sub.Endpoint)
+	}
+	body, err := EncryptWebPushPayload(sub.P256dh, sub.Auth, payload)
+	if err != nil {
+		return err
+	}
+	token, err := c.vapidToken(endpoint.Scheme + "://" + endpoint.Host)
+	if err != nil {
+		return fmt.Errorf("failed to sign VAPID token: %w", err)
+	}
+	if ttl <= 0 {
+		ttl = webPushDefaultTTL
+	}
+
+	req, err := http.NewRequest(http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(ttl.Seconds())))
+	req.Header.Set("Urgency", "normal")
+	req.Header.Set("Authorization", fmt.Sprintf("vapid t=%s, k=%s", token, c.publicKey))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("push request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrWebPushSubscriptionGone
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("push service returned %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// vapidToken signs the ES256 JWT that identifies this application server to the push service
+func (c *WebPushClient) vapidToken(audience string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": audience,
+		"exp": time.Now().Add(webPushVAPIDExpiry).Unix(),
+		"sub": c.subject,
+	})
+	return token.SignedString(c.privateKey)
+}
+
+// EncryptWebPushPayload encrypts payload for a subscription as a single aes128gcm record
+// (RFC 8188) with keys derived from an ephemeral ECDH exchange and the auth secret (RFC 8291)
+func EncryptWebPushPayload(p256dh, auth string, payload []byte) ([]byte, error) {
+	if len(payload) > webPushMaxPayload {
+		return nil, fmt.Errorf("push payload too large: %d bytes (max %d)", len(payload), webPushMaxPayload)
+	}
+	uaPublicBytes, err := decodeBase64URL(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	authSecret, err := decodeBase64URL(auth)
+	if err != nil || len(authSecret) != 16 {
+		return nil, errors.New("invalid auth secret")
+	}
+
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return encryptWebPushRecord(asPrivate, salt, uaPublic, authSecret, payload)
+}
+
+// encryptWebPushRecord encrypts payload with the given ephemeral key and salt
+func encryptWebPushRecord(asPrivate *ecdh.PrivateKey, salt []byte, uaPublic *ecdh.PublicKey, authSecret, payload []byte) ([]byte, error) {
+	uaPublicBytes := uaPublic.Bytes()
+	cek, nonce, err := webPushKeys(asPrivate, uaPublic, authSecret, salt, uaPublicBytes, asPrivate.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newAESGCM(cek)
+	if err != nil {
+		return nil, err
+	}
+
+	// The last (and only) record ends with the 0x02 padding delimiter
+	plaintext := append(append([]byte{}, payload...), 0x02)
+
+	header := make([]byte, 0, webPushHeaderLength)
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(asPrivate.PublicKey().Bytes())))
+	header = append(header, asPrivate.PublicKey().Bytes()...)
+
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// DecryptWebPushPayload reverses EncryptWebPushPayload with the subscription's private key.
+// Browsers do this themselves; it exists for local stand-in push endpoints.
+func DecryptWebPushPayload(uaPrivate *ecdh.PrivateKey, authSecret, body []byte) ([]byte, error) {
+	if len(body) < webPushHeaderLength {
+		return nil, errors.New("push message too short")
+	}
+	salt := body[:16]
+	idLen := int(body[20])
+	if idLen != 65 || len(body) < 21+idLen {
+		return nil, errors.New("unexpected key id in push message")
+	}
+	asPublicBytes := body[21 : 21+idLen]
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	cek, nonce, err := webPushKeys(uaPrivate, asPublic, authSecret, salt, uaPrivate.PublicKey().Bytes(), asPublicBytes)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newAESGCM(cek)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, body[21+idLen:], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Strip padding: trailing zeros followed by the delimiter
+	end := bytes.LastIndexByte(plaintext, 0x02)
+	if end < 0 {
+		return nil, errors.New("missing padding delimiter")
+	}
+	return plaintext[:end], nil
+}
+
+// webPushKeys derives the content encryption key and nonce (RFC 8291 section 3.4)
+func webPushKeys(private *ecdh.PrivateKey, peer *ecdh.PublicKey, authSecret, salt, uaPublic, asPublic []byte) (cek, nonce []byte, err error) {
+	ecdhSecret, err := private.ECDH(peer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyInfo := append(append([]byte("WebPush: info\x00"), uaPublic...), asPublic...)
+	ikm := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ecdhSecret, authSecret, keyInfo), ikm); err != nil {
+		return nil, nil, err
+	}
+
+	cek = make([]byte, 16)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: aes128gcm\x00")), cek); err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, 12)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: nonce\x00")), nonce); err != nil {
+		return nil, nil, err
+	}
+	return cek, nonce, nil
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decodeBase64URL accepts base64url with or without padding (browsers differ)
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
@@ -0,0 +1,158 @@
+package util
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Example from RFC 8291 section 5 (keys, salt and message are base64url)
+const (
+	rfc8291Plaintext = "When I grow up, I want to be a watermelon"
+	rfc8291ASPrivate = "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"
+	rfc8291UAPrivate = "q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"
+	rfc8291UAPublic  = "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"
+	rfc8291Auth      = "BTBZMqHH6r4Tts7J_aSIgg"
+	rfc8291Salt      = "DGv6ra1nlYgDCS1FRnbzlw"
+	rfc8291Message   = "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+)
+
+func mustDecodeBase64URL(t *testing.T, value string) []byte {
+	t.Helper()
+	b, err := decodeBase64URL(value)
+	if err != nil {
+		t.Fatalf("decode %s: %v", value, err)
+	}
+	return b
+}
+
+func mustP256PrivateKey(t *testing.T, value string) *ecdh.PrivateKey {
+	t.Helper()
+	key, err := ecdh.P256().NewPrivateKey(mustDecodeBase64URL(t, value))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestEncryptWebPushRecordRFC8291(t *testing.T) {
+	asPrivate := mustP256PrivateKey(t, rfc8291ASPrivate)
+	uaPublic, err := ecdh.P256().NewPublicKey(mustDecodeBase64URL(t, rfc8291UAPublic))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := encryptWebPushRecord(asPrivate, mustDecodeBase64URL(t, rfc8291Salt), uaPublic,
+		mustDecodeBase64URL(t, rfc8291Auth), []byte(rfc8291Plaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := base64.RawURLEncoding.EncodeToString(body); got != rfc8291Message {
+		t.Fatalf("message:\n%s\nwant:\n%s", got, rfc8291Message)
+	}
+}
+
+func TestDecryptWebPushPayloadRFC8291(t *testing.T) {
+	uaPrivate := mustP256PrivateKey(t, rfc8291UAPrivate)
+	if got := base64.RawURLEncoding.EncodeToString(uaPrivate.PublicKey().Bytes()); got != rfc8291UAPublic {
+		t.Fatalf("ua public key %s, want %s", got, rfc8291UAPublic)
+	}
+
+	plaintext, err := DecryptWebPushPayload(uaPrivate, mustDecodeBase64URL(t, rfc8291Auth), mustDecodeBase64URL(t, rfc8291Message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != rfc8291Plaintext {
+		t.Fatalf("plaintext %q, want %q", plaintext, rfc8291Plaintext)
+	}
+}
+
+func TestEncryptWebPushPayloadRoundTrip(t *testing.T) {
+	uaPrivate := mustP256PrivateKey(t, rfc8291UAPrivate)
+	payload := []byte(`{"title":"Halo","body":"Pesan baru"}`)
+
+	body, err := EncryptWebPushPayload(rfc8291UAPublic, rfc8291Auth, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := DecryptWebPushPayload(uaPrivate, mustDecodeBase64URL(t, rfc8291Auth), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != string(payload) {
+		t.Fatalf("plaintext %q, want %q", plaintext, payload)
+	}
+
+	if _, err := EncryptWebPushPayload(rfc8291UAPublic, rfc8291Auth, make([]byte, webPushMaxPayload+1)); err == nil {
+		t.Fatal("expected an error for a payload over the limit")
+	}
+}
+
+func TestVAPIDTokenRoundTrip(t *testing.T) {
+	publicKey, privateKey, err := GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewWebPushClient(publicKey, privateKey, "mailto:admin@example.com", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.PublicKey() != publicKey {
+		t.Fatalf("public key %s, want %s", client.PublicKey(), publicKey)
+	}
+
+	token, err := client.vapidToken("https://push.example.net")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ES256 JWS signatures are the raw 64-byte R || S
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token has %d parts", len(parts))
+	}
+	if sig := mustDecodeBase64URL(t, parts[2]); len(sig) != 64 {
+		t.Fatalf("signature is %d bytes, want 64", len(sig))
+	}
+
+	point := mustDecodeBase64URL(t, publicKey)
+	verifyKey := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(point[1:33]),
+		Y:     new(big.Int).SetBytes(point[33:65]),
+	}
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		return verifyKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}))
+	if err != nil || !parsed.Valid {
+		t.Fatalf("token does not verify with the VAPID public key: %v", err)
+	}
+	if claims["aud"] != "https://push.example.net" || claims["sub"] != "mailto:admin@example.com" {
+		t.Fatalf("unexpected claims %v", claims)
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil || time.Until(exp.Time) > 24*time.Hour {
+		t.Fatalf("expiry %v must be within 24h", exp)
+	}
+
+	// A token signed by another key must not verify
+	otherPublic, otherPrivate, _ := GenerateVAPIDKeys()
+	other, err := NewWebPushClient(otherPublic, otherPrivate, "mailto:admin@example.com", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, _ := other.vapidToken("https://push.example.net")
+	if _, err := jwt.Parse(forged, func(token *jwt.Token) (interface{}, error) {
+		return verifyKey, nil
+	}); err == nil {
+		t.Fatal("token signed with another key verified")
+	}
+}
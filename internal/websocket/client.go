@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -20,6 +21,10 @@ const (
 
 	// Maximum message size allowed from peer
 	maxMessageSize = 512 * 1024 // 512 KB
+
+	// Typed frames (chat.send, chat.read, ...) a connection may send per second, and in a burst
+	frameRate  = 10
+	frameBurst = 20
 )
 
 // Client is a middleman between the websocket connection and the hub
@@ -34,6 +39,9 @@ type Client struct {
 
 	// User ID associated with this client
 	UserID string
+
+	// Token bucket for the typed frames of this connection, see dispatch
+	frameLimiter *rate.Limiter
 }
 
 // NewClient creates a new client
@@ -43,6 +51,8 @@ func NewClient(hub *Hub, conn *websocket.Conn, userID string) *Client {
 		conn:   conn,
 		send:   make(chan *Message, 256),
 		UserID: userID,
+
+		frameLimiter: rate.NewLimiter(frameRate, frameBurst),
 	}
 }
 
@@ -69,26 +79,27 @@ func (c *Client) readPump() {
 			break
 		}
 
-		// Handle incoming messages (e.g., ping, read receipt, etc.)
-		var message map[string]interface{}
-		if err := json.Unmarshal(messageBytes, &message); err == nil {
-			// Handle different message types
-			if msgType, ok := message["type"].(string); ok {
-				switch msgType {
-				case "ping":
-					// Respond with pong
-					c.send <- &Message{
-						Type: "pong",
-						Payload: map[string]interface{}{
-							"timestamp": time.Now().Unix(),
-						},
-					}
-				case "read_receipt":
-					// Handle read receipt for notifications
-					// This can be used to mark notifications as read
-					log.Printf("Read receipt received from user %s", c.UserID)
-				}
-			}
+		// Handle incoming frames: ping and read receipts here, typed frames by their handler
+		var frame InboundFrame
+		if err := json.Unmarshal(messageBytes, &frame); err != nil || frame.Type == "" {
+			c.replyError(&frame, NewFrameError(ErrorCodeInvalidFrame, "frame must be a JSON object with a type"))
+			continue
+		}
+		switch frame.Type {
+		case "ping":
+			// Respond with pong
+			c.reply(&Message{
+				Type: "pong",
+				Payload: map[string]interface{}{
+					"timestamp": time.Now().Unix(),
+				},
+			})
+		case "read_receipt":
+			// Handle read receipt for notifications
+			// This can be used to mark notifications as read
+			log.Printf("Read receipt received from user %s", c.UserID)
+		default:
+			c.dispatch(&frame)
 		}
 	}
 }
@@ -24,6 +24,9 @@ type Hub struct {
 
 	// Callback for user presence changes (userID, online bool)
 	onPresenceChange func(userID string, online bool)
+
+	// Handlers of inbound frames by type, see Handle
+	handlers map[string]FrameHandler
 }
 
 // Message represents a WebSocket message
@@ -40,6 +43,7 @@ func NewHub() *Hub {
 		broadcast:  make(chan *Message, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		handlers:   make(map[string]FrameHandler),
 	}
 }
 
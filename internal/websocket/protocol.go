@@ -0,0 +1,127 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+)
+
+// Error codes of the "error" frame sent back for a rejected inbound frame
+const (
+	ErrorCodeInvalidFrame   = "invalid_frame"   // Not a JSON frame with a type
+	ErrorCodeUnknownType    = "unknown_type"    // No handler for the frame type
+	ErrorCodeInvalidPayload = "invalid_payload" // Payload does not decode or fails validation
+	ErrorCodeRejected       = "rejected"        // The request was understood but refused
+	ErrorCodeRateLimited    = "rate_limited"    // The connection sent too many frames; retry later
+	ErrorCodeInternal       = "internal_error"
+)
+
+// InboundFrame is a typed frame sent by a client, e.g.
+// {"type": "chat.send", "id": "42", "payload": {...}}. The optional id is echoed back in the
+// reply and error frames so the client can match them to its request.
+type InboundFrame struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// FrameHandler handles one inbound frame of the user. The returned message, if any, is sent
+// back to the sending client only; a returned error is sent back as an "error" frame.
+type FrameHandler func(userID string, frame *InboundFrame) (*Message, error)
+
+// FrameError is an error with the code reported in the "error" frame
+type FrameError struct {
+	Code    string
+	Message string
+}
+
+func (e *FrameError) Error() string {
+	return e.Message
+}
+
+// NewFrameError creates a FrameError
+func NewFrameError(code, message string) *FrameError {
+	return &FrameError{Code: code, Message: message}
+}
+
+// Handle registers the handler of an inbound frame type
+func (h *Hub) Handle(frameType string, handler FrameHandler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handlers[frameType] = handler
+}
+
+func (h *Hub) handler(frameType string) FrameHandler {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.handlers[frameType]
+}
+
+// dispatch runs the handler of the frame and replies to the client. Frames of one client are
+// handled in order, one at a time, and refused with rate_limited once the connection's token
+// bucket is empty.
+func (c *Client) dispatch(frame *InboundFrame) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("[WEBSOCKET] PANIC while handling %s frame from user %s: %v", frame.Type, c.UserID, rec)
+			c.replyError(frame, NewFrameError(ErrorCodeInternal, "internal error"))
+		}
+	}()
+
+	if !c.frameLimiter.Allow() {
+		c.replyError(frame, NewFrameError(ErrorCodeRateLimited, "too many frames, slow down"))
+		return
+	}
+
+	handler := c.hub.handler(frame.Type)
+	if handler == nil {
+		c.replyError(frame, NewFrameError(ErrorCodeUnknownType, "unknown frame type: "+frame.Type))
+		return
+	}
+
+	reply, err := handler(c.UserID, frame)
+	if err != nil {
+		c.replyError(frame, err)
+		return
+	}
+	if reply != nil {
+		if frame.ID != "" {
+			reply.Payload["id"] = frame.ID
+		}
+		c.reply(reply)
+	}
+}
+
+// replyError sends err back as {"type": "error", "payload": {"id", "request_type", "code", "message"}}
+func (c *Client) replyError(frame *InboundFrame, err error) {
+	var frameErr *FrameError
+	if !errors.As(err, &frameErr) {
+		log.Printf("[WEBSOCKET] Failed to handle %s frame from user %s: %v", frame.Type, c.UserID, err)
+		frameErr = NewFrameError(ErrorCodeInternal, "internal error")
+	}
+	payload := map[string]interface{}{
+		"request_type": frame.Type,
+		"code":         frameErr.Code,
+		"message":      frameErr.Message,
+	}
+	if frame.ID != "" {
+		payload["id"] = frame.ID
+	}
+	c.reply(&Message{Type: "error", Payload: payload})
+}
+
+// reply queues msg for this client only. The hub closes send when it drops a slow client, so
+// a reply racing with that is discarded.
+func (c *Client) reply(msg *Message) {
+	defer func() {
+		if recover() != nil {
+			log.Printf("[WEBSOCKET] Dropped %s reply for disconnected user %s", msg.Type, c.UserID)
+		}
+	}()
+
+	select {
+	case c.send <- msg:
+	default:
+		log.Printf("[WEBSOCKET] Send buffer full, dropping %s reply for user %s", msg.Type, c.UserID)
+	}
+}